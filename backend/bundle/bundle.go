@@ -0,0 +1,181 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the format of the bundles; it must be increased when the format changes in an incompatible way
+const SchemaVersion = 1
+
+// Extension is the extension of bundle files
+const Extension = ".miam.zip"
+
+// files of a bundle
+const (
+	manifestFileName = "manifest.json"
+	recipesFileName  = "recipes.json"
+)
+
+var (
+	// ErrInvalidBundle is returned when a file is not a bundle or when its content is inconsistent
+	ErrInvalidBundle = errors.New("invalid bundle")
+	// ErrUnsupportedVersion is returned when a bundle was written with another schema version
+	ErrUnsupportedVersion = errors.New("unsupported bundle version")
+)
+
+// Bundle is a portable archive of recipes, used to move recipes between two instances of the application.
+// Recipes reference each other and their ingredients and equipment by name, only their IDs within the bundle being kept.
+// Recipes have no photos, so a bundle holds no binary files; supporting them would require a new schema version.
+type Bundle struct {
+	Manifest Manifest
+	Recipes  []Recipe
+}
+
+// Manifest describes the content of a bundle
+type Manifest struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Recipes       int       `json:"recipes"`
+}
+
+// Recipe is a recipe of a bundle; its ID is only meaningful within the bundle
+type Recipe struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	HowTo        string       `json:"howTo,omitempty"`
+	ParentID     string       `json:"parentId,omitempty"`
+	Yield        string       `json:"yield,omitempty"`
+	PrepMinutes  int          `json:"prepMinutes,omitempty"`
+	CookMinutes  int          `json:"cookMinutes,omitempty"`
+	TotalMinutes int          `json:"totalMinutes,omitempty"`
+	Ingredients  []Ingredient `json:"ingredients,omitempty"`
+	// Equipment lists the names of the equipment the recipe requires
+	Equipment []string `json:"equipment,omitempty"`
+}
+
+// Ingredient is an ingredient of a recipe of a bundle, either named or another recipe of the bundle
+type Ingredient struct {
+	Name        string `json:"name,omitempty"`
+	Quantity    string `json:"quantity,omitempty"`
+	SubRecipeID string `json:"subRecipeId,omitempty"`
+}
+
+// Write writes a bundle as a zip archive; the schema version and the number of recipes of the manifest are set
+func Write(writer io.Writer, bundle Bundle) error {
+	bundle.Manifest.SchemaVersion = SchemaVersion
+	bundle.Manifest.Recipes = len(bundle.Recipes)
+	if err := bundle.check(); err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(writer)
+	if err := writeJSON(archive, manifestFileName, bundle.Manifest); err != nil {
+		return err
+	}
+	if err := writeJSON(archive, recipesFileName, bundle.Recipes); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func writeJSON(archive *zip.Writer, name string, content any) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add [%s] to bundle: %w", name, err)
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(content); err != nil {
+		return fmt.Errorf("failed to write [%s]: %w", name, err)
+	}
+	return nil
+}
+
+// Read reads a bundle, checking that it was written with the same schema version and that its content is consistent
+func Read(data []byte) (*Bundle, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBundle, err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	bundle := &Bundle{}
+	if err := readJSON(files, manifestFileName, &bundle.Manifest); err != nil {
+		return nil, err
+	}
+	if bundle.Manifest.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("%w: got [%d], expected [%d]", ErrUnsupportedVersion, bundle.Manifest.SchemaVersion, SchemaVersion)
+	}
+	if err := readJSON(files, recipesFileName, &bundle.Recipes); err != nil {
+		return nil, err
+	}
+	if err := bundle.check(); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+func readJSON(files map[string]*zip.File, name string, target any) error {
+	file, found := files[name]
+	if !found {
+		return fmt.Errorf("%w: missing [%s]", ErrInvalidBundle, name)
+	}
+	content, err := readFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, target); err != nil {
+		return fmt.Errorf("%w: failed to read [%s]: %s", ErrInvalidBundle, name, err)
+	}
+	return nil
+}
+
+func readFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to open [%s]: %s", ErrInvalidBundle, file.Name, err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read [%s]: %s", ErrInvalidBundle, file.Name, err)
+	}
+	return content, nil
+}
+
+// check checks that the recipes have unique IDs and names, and that they only reference recipes of the bundle
+func (bundle *Bundle) check() error {
+	IDs := make(map[string]bool, len(bundle.Recipes))
+	for _, recipe := range bundle.Recipes {
+		switch {
+		case recipe.ID == "":
+			return fmt.Errorf("%w: recipe [%s] has no ID", ErrInvalidBundle, recipe.Name)
+		case strings.TrimSpace(recipe.Name) == "":
+			return fmt.Errorf("%w: recipe [%s] has no name", ErrInvalidBundle, recipe.ID)
+		case IDs[recipe.ID]:
+			return fmt.Errorf("%w: several recipes have ID [%s]", ErrInvalidBundle, recipe.ID)
+		}
+		IDs[recipe.ID] = true
+	}
+	for _, recipe := range bundle.Recipes {
+		if recipe.ParentID != "" && !IDs[recipe.ParentID] {
+			return fmt.Errorf("%w: parent [%s] of recipe [%s] is missing", ErrInvalidBundle, recipe.ParentID, recipe.ID)
+		}
+		for _, ingredient := range recipe.Ingredients {
+			if ingredient.SubRecipeID != "" && !IDs[ingredient.SubRecipeID] {
+				return fmt.Errorf("%w: sub-recipe [%s] of recipe [%s] is missing", ErrInvalidBundle, ingredient.SubRecipeID, recipe.ID)
+			}
+		}
+	}
+	return nil
+}
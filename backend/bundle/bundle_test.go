@@ -0,0 +1,134 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+type entry struct {
+	name    string
+	content string
+}
+
+func zipped(t *testing.T, entries ...entry) []byte {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, entry := range entries {
+		file, err := writer.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestWriteAndRead(t *testing.T) {
+	written := Bundle{
+		Manifest: Manifest{
+			CreatedAt: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC),
+		},
+		Recipes: []Recipe{
+			{ID: "1", Name: "Pâte brisée", HowTo: "Mélanger.", Ingredients: []Ingredient{{Name: "farine", Quantity: "250 g"}}},
+			{
+				ID:          "2",
+				Name:        "Tarte aux pommes",
+				Yield:       "6 parts",
+				CookMinutes: 35,
+				Ingredients: []Ingredient{{Name: "Pâte brisée", SubRecipeID: "1"}, {Name: "pomme", Quantity: "4"}},
+				Equipment:   []string{"Four"},
+			},
+		},
+	}
+	var buffer bytes.Buffer
+	if err := Write(&buffer, written); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := Read(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := written
+	expected.Manifest.SchemaVersion = SchemaVersion
+	expected.Manifest.Recipes = 2
+	if diff := testutils.DeepEqual(*read, expected); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWriteInconsistentBundle(t *testing.T) {
+	inconsistent := Bundle{
+		Recipes: []Recipe{{ID: "1", Name: "Variante", ParentID: "2"}},
+	}
+	if err := Write(&bytes.Buffer{}, inconsistent); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("got error [%v], wanted [%v]", err, ErrInvalidBundle)
+	}
+}
+
+func TestRead(t *testing.T) {
+	const manifest = `{"schemaVersion": 1, "recipes": 1}`
+	tests := map[string]struct {
+		data          []byte
+		expectedError error
+	}{
+		"not a zip archive": {
+			data:          []byte("not a bundle"),
+			expectedError: ErrInvalidBundle,
+		},
+		"missing manifest": {
+			data:          zipped(t, entry{"recipes.json", `[]`}),
+			expectedError: ErrInvalidBundle,
+		},
+		"other schema version": {
+			data:          zipped(t, entry{"manifest.json", `{"schemaVersion": 2}`}, entry{"recipes.json", `[]`}),
+			expectedError: ErrUnsupportedVersion,
+		},
+		"missing recipes": {
+			data:          zipped(t, entry{"manifest.json", manifest}),
+			expectedError: ErrInvalidBundle,
+		},
+		"unreadable recipes": {
+			data:          zipped(t, entry{"manifest.json", manifest}, entry{"recipes.json", `{"id": "1"}`}),
+			expectedError: ErrInvalidBundle,
+		},
+		"duplicated recipe IDs": {
+			data: zipped(t,
+				entry{"manifest.json", manifest},
+				entry{"recipes.json", `[{"id": "1", "name": "Soupe"}, {"id": "1", "name": "Pain"}]`},
+			),
+			expectedError: ErrInvalidBundle,
+		},
+		"missing sub-recipe": {
+			data: zipped(t,
+				entry{"manifest.json", manifest},
+				entry{"recipes.json", `[{"id": "1", "name": "Tarte", "ingredients": [{"name": "Pâte", "subRecipeId": "2"}]}]`},
+			),
+			expectedError: ErrInvalidBundle,
+		},
+		"valid": {
+			data: zipped(t,
+				entry{"manifest.json", manifest},
+				entry{"recipes.json", `[{"id": "1", "name": "Soupe"}]`},
+			),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Read(test.data); !errors.Is(err, test.expectedError) {
+				t.Errorf("got error [%v], wanted [%v]", err, test.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/remieven/miam/model"
+)
+
+// command is a subcommand of the executable; all the commands share the wiring of the application, but only serve starts the HTTP server
+type command struct {
+	name string
+	// arguments describes the arguments of the command, the optional ones being between brackets
+	arguments   string
+	description string
+	// minArgs and maxArgs bound the number of arguments of the command
+	minArgs, maxArgs int
+	run              func(ctx context.Context, app *application, args []string) error
+	// writes tells whether the command writes to the database with the given arguments, which it cannot do alongside a running server
+	// since the server would keep serving its own search index; nil means never
+	writes func(args []string) bool
+}
+
+// commands lists the subcommands, serve being run when none is given
+var commands = []command{
+	{"serve", "", "index the recipes and serve the HTTP API", 0, 0, serve, nil},
+	{"migrate", "", "create or upgrade the database schema", 0, 0, migrate, always},
+	{"reindex", "", "index all the recipes, checking that each of them can be indexed", 0, 0, reindex, nil},
+	{"export", "<file>", "write a JSON export of all the data", 1, 1, exportData, nil},
+	{"import", "<file> [merge|replace]", "import a JSON export, merging it into the existing data (default) or replacing them", 1, 2, importData, always},
+	{"export-bundle", "<file>", "write all the recipes as a .miam.zip bundle", 1, 1, exportBundle, nil},
+	{"import-bundle", "<file> [skip|overwrite|duplicate]", "import the recipes of a .miam.zip bundle, skipping (default), overwriting or duplicating the recipes with the same name as an existing one", 1, 2, importBundle, always},
+	{"import-cooklang", "<directory>", "import the Cooklang recipes of a directory and of its sub-directories", 1, 1, importCooklangDirectory, always},
+	{"backup", "[file]", "copy the database to a file, or make the daily and weekly copies of the backup directory, without stopping a running server", 0, 1, backup, nil},
+	{"check", "[--repair]", "check the integrity of the database and of the search index, repairing the problems if asked to", 0, 1, check, repairRequested},
+}
+
+// always is the writes function of the commands that write to the database whatever their arguments
+func always([]string) bool {
+	return true
+}
+
+// runCommand runs the command with the given name on a new application, then releases the resources of the application
+func runCommand(name string, args []string) (errors []error) {
+	for _, command := range commands {
+		if command.name != name {
+			continue
+		}
+		if len(args) < command.minArgs || len(args) > command.maxArgs {
+			printUsage()
+			return []error{fmt.Errorf("wrong number of arguments for [%s]: %s %s", name, name, command.arguments)}
+		}
+
+		if command.writes != nil && command.writes(args) && serverIsRunning() {
+			return []error{fmt.Errorf("a server is listening on port %d: stop it before running [%s], which writes to the database that the server indexed in memory", defaultPort, name)}
+		}
+
+		slog.With("command", name).Info("Starting")
+		app, err := newApplication()
+		if err != nil {
+			return []error{err}
+		}
+		defer func() { errors = append(errors, app.close()...) }()
+		if err := command.run(context.Background(), app, args); err != nil {
+			errors = append(errors, err)
+		}
+		return errors
+	}
+	printUsage()
+	if name == "help" || name == "-h" || name == "--help" {
+		return nil
+	}
+	return []error{fmt.Errorf("unknown command [%s]", name)}
+}
+
+func printUsage() {
+	writer := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "Usage: miam [command] [arguments], the commands being:")
+	for _, command := range commands {
+		fmt.Fprintf(writer, "  %s\t%s\n", strings.TrimSpace(command.name+" "+command.arguments), command.description)
+	}
+	fmt.Fprintf(writer, "The commands writing to the database (migrate, import, import-bundle, import-cooklang and check --repair) refuse to run while a server listens on port %d.\n", defaultPort)
+	_ = writer.Flush()
+}
+
+// serverIsRunning tells whether a server listens on the port of the application on this machine
+func serverIsRunning() bool {
+	connection, err := net.DialTimeout("tcp", net.JoinHostPort("localhost", strconv.Itoa(defaultPort)), time.Second)
+	if err != nil {
+		return false
+	}
+	_ = connection.Close()
+	return true
+}
+
+// migrate creates or upgrades the database schema; there is nothing else to do since the DAOs do it when the application is created
+func migrate(_ context.Context, _ *application, _ []string) error {
+	slog.Info("database schema is up to date")
+	return nil
+}
+
+// reindex indexes all the recipes; since the search index is kept in memory by the server, this checks that the recipes can be indexed without changing the index of a running server
+func reindex(ctx context.Context, app *application, _ []string) error {
+	if err := app.recipeService.RebuildIndex(ctx); err != nil {
+		return fmt.Errorf("failed to index recipes: %w", err)
+	}
+	slog.Info("all recipes indexed")
+	return nil
+}
+
+// exportData writes a JSON export of all the data to the given file
+func exportData(ctx context.Context, app *application, args []string) error {
+	file, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create [%s]: %w", args[0], err)
+	}
+	if err := app.adminService.WriteExport(ctx, file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to export: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write [%s]: %w", args[0], err)
+	}
+	slog.With("path", args[0]).Info("export done")
+	return nil
+}
+
+// importData imports a JSON export, merging it into the existing data unless the replace mode is given
+func importData(ctx context.Context, app *application, args []string) error {
+	content, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read [%s]: %w", args[0], err)
+	}
+	var export model.Export
+	if err := json.Unmarshal(content, &export); err != nil {
+		return fmt.Errorf("failed to read export [%s]: %w", args[0], err)
+	}
+	report, err := app.adminService.Import(ctx, export, optionalArgument(args, 1))
+	if err != nil {
+		return fmt.Errorf("failed to import [%s]: %w", args[0], err)
+	}
+	slog.With("categories", report.Categories, "ingredients", report.Ingredients, "equipment", report.Equipment, "recipes", report.Recipes, "revisions", report.Revisions).Info("import done")
+	return nil
+}
+
+// exportBundle writes all the recipes as a bundle to the given file
+func exportBundle(ctx context.Context, app *application, args []string) (err error) {
+	file, err := os.Create(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to create [%s]: %w", args[0], err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close [%s]: %w", args[0], closeErr)
+		}
+	}()
+	if err := app.adminService.WriteBundle(ctx, file); err != nil {
+		return fmt.Errorf("failed to export bundle: %w", err)
+	}
+	slog.With("path", args[0]).Info("bundle export done")
+	return nil
+}
+
+// importBundle imports the recipes of a bundle file, handling the recipes having the same name as an existing recipe with the given conflict strategy
+func importBundle(ctx context.Context, app *application, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read [%s]: %w", args[0], err)
+	}
+	report, err := app.adminService.ImportBundle(ctx, data, optionalArgument(args, 1))
+	if err != nil {
+		return fmt.Errorf("failed to import [%s]: %w", args[0], err)
+	}
+	for _, recipe := range report.Recipes {
+		slog.With("name", recipe.Name, "id", recipe.ID, "status", recipe.Status).Info("imported recipe")
+	}
+	slog.With("created", report.Created, "skipped", report.Skipped, "overwritten", report.Overwritten, "duplicated", report.Duplicated).Info("bundle import done")
+	return nil
+}
+
+// importCooklangDirectory adds all the Cooklang recipes of a directory and of its sub-directories, named after their file when they have no title;
+// recipes that fail to be imported are reported without stopping the import of the other ones
+func importCooklangDirectory(ctx context.Context, app *application, args []string) error {
+	directory := args[0]
+	var errs []error
+	imported := 0
+	err := filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".cook" {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read [%s]: %w", path, err)
+		}
+		_, id, err := app.importService.ImportCooklangRecipe(ctx, content, strings.TrimSuffix(entry.Name(), ".cook"), true)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to import [%s]: %w", path, err))
+			return nil
+		}
+		slog.With("path", path, "id", id).Info("imported recipe")
+		imported++
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to walk through [%s]: %w", directory, err))
+	}
+	slog.With("imported", imported, "failed", len(errs)).Info("Cooklang import done")
+	return errors.Join(errs...)
+}
+
+// backup copies the database to the given file, or as the daily and weekly copies of the configured backup directory
+func backup(ctx context.Context, app *application, args []string) error {
+	if len(args) == 0 {
+		_, err := app.backupService.Backup(ctx)
+		return err
+	}
+	if err := app.databaseHolder.Backup(ctx, args[0]); err != nil {
+		return fmt.Errorf("failed to back up database to [%s]: %w", args[0], err)
+	}
+	slog.With("path", args[0]).Info("backup done")
+	return nil
+}
+
+// parseCheckFlags reads the arguments of check, telling whether the problems are to be repaired
+func parseCheckFlags(args []string) (bool, error) {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "repair the problems in a transaction")
+	if err := flags.Parse(args); err != nil {
+		return false, err
+	}
+	return *repair, nil
+}
+
+// repairRequested tells whether check is asked to repair the problems, and thus to write to the database
+func repairRequested(args []string) bool {
+	repair, err := parseCheckFlags(args)
+	return err == nil && repair
+}
+
+// check checks the integrity of the database and of the search index, which is built first, failing if it found problems; with --repair, the problems are repaired
+func check(ctx context.Context, app *application, args []string) error {
+	repair, err := parseCheckFlags(args)
+	if err != nil {
+		return err
+	}
+	if err := app.recipeService.IndexAllExistingRecipes(ctx); err != nil {
+		return fmt.Errorf("failed to index recipes: %w", err)
+	}
+	report, err := app.adminService.CheckIntegrity(ctx, repair)
+	if err != nil {
+		return err
+	}
+	for _, problem := range report.Problems {
+		slog.With("kind", problem.Kind, "table", problem.Table, "id", problem.ID, "message", problem.Message).Warn("integrity problem")
+	}
+	remaining := report.Problems
+	if report.Repaired {
+		remaining = report.Remaining
+		slog.With("found", len(report.Problems), "remaining", len(remaining)).Info("repair done")
+	}
+	if len(remaining) != 0 {
+		return fmt.Errorf("found %d integrity problems", len(remaining))
+	}
+	slog.Info("integrity check passed")
+	return nil
+}
+
+// optionalArgument returns the argument at the given position, or an empty string if it was not given
+func optionalArgument(args []string, position int) string {
+	if position < len(args) {
+		return args[position]
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package configuration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Configuration holds the settings of the application
+type Configuration struct {
+	// TrashRetentionDays is the number of days after which deleted recipes are purged; purge is disabled if not positive
+	TrashRetentionDays int `json:"trashRetentionDays"`
+	// NutrientTablePath is the path of a CSV nutrient table used to estimate the nutrients of recipes; the bundled table is used if empty
+	NutrientTablePath string `json:"nutrientTablePath,omitempty"`
+	// MarkdownTemplatePath is the path of a text/template file defining the "recipe" and/or "index" templates used to write recipes as Markdown;
+	// the bundled templates are used for the ones it does not define, or if it is empty
+	MarkdownTemplatePath string `json:"markdownTemplatePath,omitempty"`
+	// BackupDirectory is the directory where the database is backed up
+	BackupDirectory string `json:"backupDirectory"`
+	// BackupIntervalHours is the number of hours between two scheduled backups; scheduled backups are disabled if not positive
+	BackupIntervalHours int `json:"backupIntervalHours"`
+	// DailyBackups and WeeklyBackups are the numbers of daily and weekly copies of the database to keep
+	DailyBackups  int `json:"dailyBackups"`
+	WeeklyBackups int `json:"weeklyBackups"`
+	// MetricsEnabled tells whether HTTP requests are measured and metrics are exposed to Prometheus on /metrics
+	MetricsEnabled bool `json:"metricsEnabled"`
+}
+
+// defaultConfiguration returns the settings to use for the values missing from the configuration file
+func defaultConfiguration() Configuration {
+	return Configuration{
+		TrashRetentionDays:  30,
+		BackupDirectory:     "./backups",
+		BackupIntervalHours: 24,
+		DailyBackups:        7,
+		WeeklyBackups:       4,
+		MetricsEnabled:      true,
+	}
+}
+
+// Load reads the configuration file at the given path, falling back to default values if it does not exist
+func Load(filePath string) (*Configuration, error) {
+	configuration := defaultConfiguration()
+	content, err := os.ReadFile(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &configuration, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+	if err := json.Unmarshal(content, &configuration); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file: %w", err)
+	}
+	return &configuration, nil
+}
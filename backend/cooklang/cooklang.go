@@ -0,0 +1,333 @@
+package cooklang
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/remieven/miam/quantity"
+)
+
+// ErrEmptyRecipe is returned when a Cooklang text contains neither a title nor any step
+var ErrEmptyRecipe = errors.New("empty Cooklang recipe")
+
+// Recipe is a recipe written in Cooklang, see https://cooklang.org/docs/spec/
+type Recipe struct {
+	Name      string
+	Yield     string
+	PrepTime  time.Duration
+	CookTime  time.Duration
+	TotalTime time.Duration
+	// Ingredients are listed in order of appearance; an ingredient used in several steps appears several times
+	Ingredients []Ingredient
+	// Cookware lists the names of the cookware used by the recipe, each one only once
+	Cookware []string
+	Timers   []Timer
+	// Steps are the instructions of the recipe as they read, without the Cooklang markup
+	Steps []string
+}
+
+// Ingredient is an ingredient used in a step, such as @farine{250%g}
+type Ingredient struct {
+	Name     string
+	Quantity string
+	Unit     string
+}
+
+// Timer is a timer set in a step, such as ~cuisson{20%minutes}
+type Timer struct {
+	Name     string
+	Quantity string
+	Unit     string
+}
+
+// Duration returns the duration of the timer, or false if its unit is unknown
+func (timer Timer) Duration() (time.Duration, bool) {
+	return quantity.ParseDuration(timer.Quantity + " " + timer.Unit)
+}
+
+// blockComment matches the [- block comments -] of Cooklang, which may span several lines
+var blockComment = regexp.MustCompile(`(?s)\[-.*?-\]`)
+
+// Parse parses a Cooklang text; metadata are read from >> lines or from a front matter, steps are separated by blank lines and sections are ignored
+func Parse(text string) (*Recipe, error) {
+	recipe := &Recipe{}
+	text = blockComment.ReplaceAllString(strings.ReplaceAll(text, "\r\n", "\n"), "")
+	lines := recipe.parseFrontMatter(strings.Split(text, "\n"))
+
+	var paragraph []string
+	endStep := func() {
+		if len(paragraph) != 0 {
+			recipe.parseStep(strings.Join(paragraph, " "))
+			paragraph = nil
+		}
+	}
+	for _, line := range lines {
+		if comment := strings.Index(line, "--"); comment != -1 {
+			line = line[:comment]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, ">>"):
+			key, value, _ := strings.Cut(line[2:], ":")
+			recipe.setMetadata(key, value)
+		case line == "", strings.HasPrefix(line, "="):
+			endStep()
+		case strings.HasPrefix(line, ">"):
+			// notes are kept as regular text
+			paragraph = append(paragraph, strings.TrimSpace(line[1:]))
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	endStep()
+
+	if recipe.Name == "" && len(recipe.Steps) == 0 {
+		return nil, ErrEmptyRecipe
+	}
+	return recipe, nil
+}
+
+// parseFrontMatter reads the metadata of a YAML-like front matter delimited by --- lines, and returns the lines following it
+func (recipe *Recipe) parseFrontMatter(lines []string) []string {
+	start := 0
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if start == len(lines) || strings.TrimSpace(lines[start]) != "---" {
+		return lines
+	}
+	for end := start + 1; end < len(lines); end++ {
+		if strings.TrimSpace(lines[end]) == "---" {
+			for _, line := range lines[start+1 : end] {
+				key, value, _ := strings.Cut(line, ":")
+				recipe.setMetadata(key, strings.Trim(strings.TrimSpace(value), `"'`))
+			}
+			return lines[end+1:]
+		}
+	}
+	return lines
+}
+
+// setMetadata sets the field matching a metadata key; unknown keys are ignored
+func (recipe *Recipe) setMetadata(key, value string) {
+	value = strings.TrimSpace(value)
+	switch strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "_", " ") {
+	case "title", "name":
+		recipe.Name = value
+	case "servings", "serves", "yield":
+		recipe.Yield = value
+	case "prep time", "preparation time":
+		recipe.PrepTime, _ = quantity.ParseDuration(value)
+	case "cook time", "cooking time":
+		recipe.CookTime, _ = quantity.ParseDuration(value)
+	case "time", "total time", "time required", "duration":
+		recipe.TotalTime, _ = quantity.ParseDuration(value)
+	}
+}
+
+// parseStep reads the ingredients, cookware and timers of a step, and adds the step as it reads
+func (recipe *Recipe) parseStep(text string) {
+	var step strings.Builder
+	for i := 0; i < len(text); {
+		marker := text[i]
+		if marker != '@' && marker != '#' && marker != '~' {
+			step.WriteByte(marker)
+			i++
+			continue
+		}
+		name, amount, length, ok := parseComponent(text[i+1:], marker == '~')
+		if !ok {
+			step.WriteByte(marker)
+			i++
+			continue
+		}
+		i += 1 + length
+		quantity, unit, _ := strings.Cut(amount, "%")
+		quantity, unit = strings.TrimSpace(quantity), strings.TrimSpace(unit)
+		switch marker {
+		case '@':
+			recipe.Ingredients = append(recipe.Ingredients, Ingredient{Name: name, Quantity: quantity, Unit: unit})
+			step.WriteString(name)
+		case '#':
+			if !containsFold(recipe.Cookware, name) {
+				recipe.Cookware = append(recipe.Cookware, name)
+			}
+			step.WriteString(name)
+		case '~':
+			recipe.Timers = append(recipe.Timers, Timer{Name: name, Quantity: quantity, Unit: unit})
+			step.WriteString(strings.TrimSpace(quantity + " " + unit))
+		}
+	}
+	if readable := strings.Join(strings.Fields(step.String()), " "); readable != "" {
+		recipe.Steps = append(recipe.Steps, readable)
+	}
+}
+
+// parseComponent parses the name and the amount of an ingredient, cookware or timer following its marker.
+// Names of several words must be followed by braces, which hold the amount; timers must have braces but may have no name.
+func parseComponent(text string, isTimer bool) (string, string, int, bool) {
+	if brace := strings.IndexByte(text, '{'); brace != -1 && !strings.ContainsAny(text[:brace], "@#~{}.,;:!?") {
+		if closing := strings.IndexByte(text[brace:], '}'); closing != -1 {
+			name := strings.TrimSpace(text[:brace])
+			if name != "" || isTimer {
+				return name, strings.TrimSpace(text[brace+1 : brace+closing]), brace + closing + 1, true
+			}
+		}
+	}
+	if isTimer {
+		return "", "", 0, false
+	}
+	end := strings.IndexFunc(text, func(r rune) bool {
+		return !isWordRune(r) && r != '-'
+	})
+	if end == -1 {
+		end = len(text)
+	}
+	name := strings.TrimRight(text[:end], "-")
+	if name == "" {
+		return "", "", 0, false
+	}
+	return name, "", len(name), true
+}
+
+// formatTime formats a time in minutes, the unit understood by most Cooklang tools
+func formatTime(duration time.Duration) string {
+	if duration <= 0 {
+		return ""
+	}
+	return strconv.Itoa(int(duration.Round(time.Minute)/time.Minute)) + " minutes"
+}
+
+// Format writes a recipe in Cooklang. Ingredients and cookware are marked where their name is first mentioned in the steps;
+// the ones that are not mentioned are listed in a first step. Timers are not written.
+func Format(recipe Recipe) string {
+	var text strings.Builder
+	for _, metadata := range [][2]string{
+		{"title", recipe.Name},
+		{"servings", recipe.Yield},
+		{"prep time", formatTime(recipe.PrepTime)},
+		{"cook time", formatTime(recipe.CookTime)},
+		{"time", formatTime(recipe.TotalTime)},
+	} {
+		if metadata[1] != "" {
+			text.WriteString(">> " + metadata[0] + ": " + metadata[1] + "\n")
+		}
+	}
+
+	steps := markSteps(recipe)
+	if text.Len() != 0 && len(steps) != 0 {
+		text.WriteString("\n")
+	}
+	for i, step := range steps {
+		if i != 0 {
+			text.WriteString("\n\n")
+		}
+		text.WriteString(step)
+	}
+	if len(steps) != 0 {
+		text.WriteString("\n")
+	}
+	return text.String()
+}
+
+// mark is the markup replacing a part of a step
+type mark struct {
+	start, end int
+	markup     string
+}
+
+// markSteps replaces the first mention of each ingredient and cookware in the steps with its markup
+func markSteps(recipe Recipe) []string {
+	marks := make([][]mark, len(recipe.Steps))
+	var unmentioned []string
+	markFirstMention := func(name, markup string) {
+		for i, step := range recipe.Steps {
+			if start, found := findWord(step, name, marks[i]); found {
+				marks[i] = append(marks[i], mark{start, start + len(name), markup})
+				return
+			}
+		}
+		unmentioned = append(unmentioned, markup)
+	}
+	for _, ingredient := range recipe.Ingredients {
+		amount := ingredient.Quantity
+		if ingredient.Unit != "" {
+			amount += "%" + ingredient.Unit
+		}
+		markFirstMention(ingredient.Name, componentMarkup('@', ingredient.Name, amount))
+	}
+	for _, cookware := range recipe.Cookware {
+		markFirstMention(cookware, componentMarkup('#', cookware, ""))
+	}
+
+	steps := make([]string, 0, len(recipe.Steps)+1)
+	if len(unmentioned) != 0 {
+		steps = append(steps, strings.Join(unmentioned, ", "))
+	}
+	for i, step := range recipe.Steps {
+		sort.Slice(marks[i], func(a, b int) bool {
+			return marks[i][a].start > marks[i][b].start
+		})
+		for _, stepMark := range marks[i] {
+			step = step[:stepMark.start] + stepMark.markup + step[stepMark.end:]
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// componentMarkup writes an ingredient or a cookware; braces are only used when needed
+func componentMarkup(marker byte, name, amount string) string {
+	if amount == "" && strings.IndexFunc(name, func(r rune) bool { return !isWordRune(r) }) == -1 {
+		return string(marker) + name
+	}
+	return string(marker) + name + "{" + amount + "}"
+}
+
+// findWord finds the first whole-word occurrence of a name in a text, ignoring case and the parts of the text that are already marked
+func findWord(text, name string, marks []mark) (int, bool) {
+	if name == "" {
+		return 0, false
+	}
+	for start := 0; start+len(name) <= len(text); start++ {
+		if !utf8.RuneStart(text[start]) || !strings.EqualFold(text[start:start+len(name)], name) {
+			continue
+		}
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[start+len(name):])
+		if (start != 0 && isWordRune(before)) || (start+len(name) != len(text) && isWordRune(after)) || overlaps(marks, start, start+len(name)) {
+			continue
+		}
+		return start, true
+	}
+	return 0, false
+}
+
+// overlaps tells whether a part of a text overlaps one of the marks
+func overlaps(marks []mark, start, end int) bool {
+	for _, existing := range marks {
+		if start < existing.end && existing.start < end {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func containsFold(values []string, searched string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, searched) {
+			return true
+		}
+	}
+	return false
+}
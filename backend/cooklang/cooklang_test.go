@@ -0,0 +1,179 @@
+package cooklang
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		text           string
+		expectedRecipe *Recipe
+		expectedError  error
+	}{
+		"metadata, ingredients, cookware and timers": {
+			text: `>> title: Crêpes
+>> servings: 8 crêpes
+>> prep time: 10 minutes
+
+-- à préparer la veille
+Mélanger la @farine{250%g} et les @œufs{4} dans un #saladier.
+Ajouter le @lait entier{50%cl} petit à petit.
+
+[- la pâte peut reposer
+une nuit -]
+Laisser reposer ~{1%hour} puis cuire dans une #poêle à crêpes{} ~cuisson{1,5%min}.
+`,
+			expectedRecipe: &Recipe{
+				Name:     "Crêpes",
+				Yield:    "8 crêpes",
+				PrepTime: 10 * time.Minute,
+				Ingredients: []Ingredient{
+					{Name: "farine", Quantity: "250", Unit: "g"},
+					{Name: "œufs", Quantity: "4"},
+					{Name: "lait entier", Quantity: "50", Unit: "cl"},
+				},
+				Cookware: []string{"saladier", "poêle à crêpes"},
+				Timers: []Timer{
+					{Quantity: "1", Unit: "hour"},
+					{Name: "cuisson", Quantity: "1,5", Unit: "min"},
+				},
+				Steps: []string{
+					"Mélanger la farine et les œufs dans un saladier. Ajouter le lait entier petit à petit.",
+					"Laisser reposer 1 hour puis cuire dans une poêle à crêpes 1,5 min.",
+				},
+			},
+		},
+		"front matter, sections and notes": {
+			text: `---
+title: "Salade"
+cook_time: 1h30
+---
+= Préparation
+> Bien laver la salade.
+Assaisonner avec du @sel et du @poivre noir{}.
+`,
+			expectedRecipe: &Recipe{
+				Name:     "Salade",
+				CookTime: 90 * time.Minute,
+				Ingredients: []Ingredient{
+					{Name: "sel"},
+					{Name: "poivre noir"},
+				},
+				Steps: []string{"Bien laver la salade. Assaisonner avec du sel et du poivre noir."},
+			},
+		},
+		"lone markers": {
+			text: `Compter 2 @ 3 # et ~ 5.`,
+			expectedRecipe: &Recipe{
+				Steps: []string{"Compter 2 @ 3 # et ~ 5."},
+			},
+		},
+		"empty": {
+			text:          "-- nothing but a comment\n\n",
+			expectedError: ErrEmptyRecipe,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actualRecipe, err := Parse(test.text)
+			if !errors.Is(err, test.expectedError) {
+				t.Fatalf("got error [%v], wanted [%v]", err, test.expectedError)
+			}
+			if diff := testutils.DeepEqual(actualRecipe, test.expectedRecipe); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestTimerDuration(t *testing.T) {
+	tests := map[string]struct {
+		timer            Timer
+		expectedDuration time.Duration
+		expectedOk       bool
+	}{
+		"minutes": {
+			timer:            Timer{Quantity: "20", Unit: "minutes"},
+			expectedDuration: 20 * time.Minute,
+			expectedOk:       true,
+		},
+		"decimal hours": {
+			timer:            Timer{Quantity: "1,5", Unit: "heures"},
+			expectedDuration: 90 * time.Minute,
+			expectedOk:       true,
+		},
+		"no unit": {
+			timer:            Timer{Quantity: "5"},
+			expectedDuration: 5 * time.Minute,
+			expectedOk:       true,
+		},
+		"unknown unit": {
+			timer: Timer{Quantity: "2", Unit: "chansons"},
+		},
+		"no amount": {
+			timer: Timer{Unit: "minutes"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actualDuration, actualOk := test.timer.Duration()
+			if actualOk != test.expectedOk || actualDuration != test.expectedDuration {
+				t.Errorf("got [%v] [%v], wanted [%v] [%v]", actualDuration, actualOk, test.expectedDuration, test.expectedOk)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	recipe := Recipe{
+		Name:      "Quiche",
+		Yield:     "6 parts",
+		PrepTime:  15 * time.Minute,
+		TotalTime: 55 * time.Minute,
+		Ingredients: []Ingredient{
+			{Name: "pâte brisée", Quantity: "1"},
+			{Name: "œuf", Quantity: "3"},
+			{Name: "lardons", Quantity: "200", Unit: "g"},
+			{Name: "sel"},
+			{Name: "crème fraîche", Quantity: "20", Unit: "cl"},
+		},
+		Cookware: []string{"moule à tarte", "four"},
+		Steps: []string{
+			"Étaler la pâte brisée dans le moule à tarte.",
+			"Battre l'œuf et les œufs restants avec le sel, puis ajouter les lardons.",
+			"Cuire au four.",
+		},
+	}
+
+	expectedText := `>> title: Quiche
+>> servings: 6 parts
+>> prep time: 15 minutes
+>> time: 55 minutes
+
+@crème fraîche{20%cl}
+
+Étaler la @pâte brisée{1} dans le #moule à tarte{}.
+
+Battre l'@œuf{3} et les œufs restants avec le @sel, puis ajouter les @lardons{200%g}.
+
+Cuire au #four.
+`
+	actualText := Format(recipe)
+	if actualText != expectedText {
+		t.Errorf("got:\n%s\nwanted:\n%s", actualText, expectedText)
+	}
+
+	parsed, err := Parse(actualText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := testutils.DeepEqual(parsed.Steps[1:], recipe.Steps); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,215 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+)
+
+// CategoryDao is an ingredient category dao
+type CategoryDao struct {
+	holder *DatabaseHolder
+}
+
+// NewCategoryDao returns a new ingredient category dao
+func NewCategoryDao(holder *DatabaseHolder) (*CategoryDao, error) {
+	initStatement := `
+		create table if not exists category (id integer primary key asc, name text, aisle_order int)
+	`
+	if _, err := holder.DB.Exec(initStatement); err != nil {
+		return nil, fmt.Errorf("failed to create category table: %w", err)
+	}
+	return &CategoryDao{holder}, nil
+}
+
+// GetCategories returns all categories, sorted by aisle order
+func (dao *CategoryDao) GetCategories(ctx context.Context) ([]model.Category, error) {
+	rows, err := dao.holder.DB.QueryContext(ctx, "select id, name, aisle_order from category order by aisle_order, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+	categories := make([]model.Category, 0)
+	for rows.Next() {
+		var id sqliteID
+		var name string
+		var aisleOrder int
+		if err := rows.Scan(&id, &name, &aisleOrder); err != nil {
+			return nil, fmt.Errorf("failed to scan category row: %w", err)
+		}
+		categories = append(categories, model.Category{
+			ID: fromSqliteID(id),
+			BaseCategory: model.BaseCategory{
+				Name:       name,
+				AisleOrder: aisleOrder,
+			},
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("got an error while iterating on category rows: %w", err)
+	}
+	return categories, nil
+}
+
+// GetCategory returns the category with the given ID
+func (dao *CategoryDao) GetCategory(ctx context.Context, ID string) (*model.Category, error) {
+	intID, err := toSqliteID(ID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ID),
+			Cause:   err,
+		}
+	}
+	row := dao.holder.DB.QueryRowContext(ctx, "select name, aisle_order from category where id=?", intID)
+	var name string
+	var aisleOrder int
+	if err := row.Scan(&name, &aisleOrder); errors.Is(err, sql.ErrNoRows) {
+		return nil, &failure.ResourceNotFoundError{
+			Message: "category [" + ID + "] not found",
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to retrieve category: %w", err)
+	}
+	return &model.Category{
+		ID: ID,
+		BaseCategory: model.BaseCategory{
+			Name:       name,
+			AisleOrder: aisleOrder,
+		},
+	}, nil
+}
+
+// AddCategory adds a category; without aisle order, it is put after all the existing ones
+func (dao *CategoryDao) AddCategory(ctx context.Context, category model.BaseCategory) (string, error) {
+	result, err := dao.holder.DB.ExecContext(
+		ctx,
+		"insert into category(name, aisle_order) select ?1, case when ?2 != 0 then ?2 else coalesce(max(aisle_order), 0) + 1 end from category",
+		category.Name,
+		category.AisleOrder,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute insert statement: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve ID of inserted row: %w", err)
+	}
+	return fromSqliteID(sqliteID(id)), nil
+}
+
+// UpdateCategory updates the name and aisle order of a category
+func (dao *CategoryDao) UpdateCategory(ctx context.Context, category model.Category) error {
+	intID, err := toSqliteID(category.ID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", category.ID),
+			Cause:   err,
+		}
+	}
+	result, err := dao.holder.DB.ExecContext(ctx, "update category set name=?, aisle_order=? where id=?", category.Name, category.AisleOrder, intID)
+	if err != nil {
+		return fmt.Errorf("failed to execute update statement: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to retrieve number of rows affected by update statement: %w", err)
+	case rowsAffected == 0:
+		return &failure.ResourceNotFoundError{
+			Message: "category [" + category.ID + "] not found",
+		}
+	}
+	return nil
+}
+
+// ReorderCategories sets the aisle order of the categories according to their position in the given list
+func (dao *CategoryDao) ReorderCategories(ctx context.Context, IDs []string) error {
+	transaction, err := dao.holder.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to init transaction: %w", err)
+	}
+	updateStatement, err := transaction.PrepareContext(ctx, "update category set aisle_order=? where id=?")
+	if err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to prepare update statement: %w", err)
+	}
+	defer updateStatement.Close()
+
+	for i, ID := range IDs {
+		intID, err := toSqliteID(ID)
+		if err != nil {
+			rollback(transaction)
+			return &failure.InvalidValueError{
+				Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ID),
+				Cause:   err,
+			}
+		}
+		result, err := updateStatement.ExecContext(ctx, i+1, intID)
+		if err != nil {
+			rollback(transaction)
+			return fmt.Errorf("failed to execute update statement: %w", err)
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			rollback(transaction)
+			return fmt.Errorf("failed to retrieve number of rows affected by update statement: %w", err)
+		} else if rowsAffected == 0 {
+			rollback(transaction)
+			return &failure.ResourceNotFoundError{
+				Message: "category [" + ID + "] not found",
+			}
+		}
+	}
+	return transaction.Commit()
+}
+
+// DeleteCategory deletes a category; the ingredients it contains are left without category
+func (dao *CategoryDao) DeleteCategory(ctx context.Context, ID string) error {
+	intID, err := toSqliteID(ID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ID),
+			Cause:   err,
+		}
+	}
+	transaction, err := dao.holder.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to init transaction: %w", err)
+	}
+	result, err := transaction.ExecContext(ctx, "delete from category where id=?", intID)
+	if err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to execute delete statement: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to retrieve number of rows affected by delete statement: %w", err)
+	} else if rowsAffected == 0 {
+		rollback(transaction)
+		return &failure.ResourceNotFoundError{
+			Message: "category [" + ID + "] not found",
+		}
+	}
+	if _, err := transaction.ExecContext(ctx, "update ingredient set category_id=null where category_id=?", intID); err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to remove category from ingredients: %w", err)
+	}
+	return transaction.Commit()
+}
+
+// toCategory builds the category of an ingredient from the columns of a left join on the category table
+func toCategory(ID sql.NullInt64, name sql.NullString, aisleOrder sql.NullInt64) *model.Category {
+	if !ID.Valid {
+		return nil
+	}
+	return &model.Category{
+		ID: fromNullableSqliteID(ID),
+		BaseCategory: model.BaseCategory{
+			Name:       name.String,
+			AisleOrder: int(aisleOrder.Int64),
+		},
+	}
+}
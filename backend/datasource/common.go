@@ -1,6 +1,8 @@
 package datasource
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"strconv"
 )
@@ -20,3 +22,60 @@ func toSqliteID(ID string) (sqliteID, error) {
 func fromSqliteID(ID sqliteID) string {
 	return strconv.Itoa(ID)
 }
+
+// addColumnIfMissing adds a column to a table that was created by an older version of the application
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	row := db.QueryRow("select exists(select 1 from pragma_table_info(?) where name=?)", table, column)
+	var exists bool
+	if err := row.Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check if column [%s] of table [%s] exists: %w", column, table, err)
+	}
+	if exists {
+		return nil
+	}
+	if _, err := db.Exec("alter table " + table + " add column " + column + " " + definition); err != nil {
+		return fmt.Errorf("failed to add column [%s] to table [%s]: %w", column, table, err)
+	}
+	return nil
+}
+
+// dropColumnIfExists removes a column that is no longer used from a table that was created by an older version of the application
+func dropColumnIfExists(db *sql.DB, table, column string) error {
+	row := db.QueryRow("select exists(select 1 from pragma_table_info(?) where name=?)", table, column)
+	var exists bool
+	if err := row.Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check if column [%s] of table [%s] exists: %w", column, table, err)
+	}
+	if !exists {
+		return nil
+	}
+	if _, err := db.Exec("alter table " + table + " drop column " + column); err != nil {
+		return fmt.Errorf("failed to drop column [%s] of table [%s]: %w", column, table, err)
+	}
+	return nil
+}
+
+// toNullableSqliteID parses an optional id, an empty id being stored as null
+func toNullableSqliteID(ID string) (sql.NullInt64, error) {
+	if ID == "" {
+		return sql.NullInt64{}, nil
+	}
+	intID, err := toSqliteID(ID)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+	return sql.NullInt64{Int64: int64(intID), Valid: true}, nil
+}
+
+// fromNullableSqliteID serializes an optional id, a null id being serialized as an empty string
+func fromNullableSqliteID(ID sql.NullInt64) string {
+	if !ID.Valid {
+		return ""
+	}
+	return fromSqliteID(sqliteID(ID.Int64))
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx, for reads that may happen inside or outside a transaction
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
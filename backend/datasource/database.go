@@ -1,12 +1,30 @@
 package datasource
 
 import (
+	"context"
 	"database/sql"
 	"log/slog"
+	"strconv"
 
-	_ "github.com/mattn/go-sqlite3" // Necessary to load sqlite3 driver
+	"github.com/mattn/go-sqlite3"
+	"github.com/remieven/miam/similarity"
 )
 
+// busyTimeoutMilliseconds is how long a statement waits for the lock of another connection or process, such as a running backup, before failing with "database is locked"
+const busyTimeoutMilliseconds = 5000
+
+// driverName is the name of the sqlite3 driver extended with the functions of the application
+const driverName = "sqlite3_miam"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			// normalize_name makes names comparable the same way in queries as in the rest of the application
+			return conn.RegisterFunc("normalize_name", similarity.NormalizeName, true)
+		},
+	})
+}
+
 // DatabaseHolder holds a database connection
 type DatabaseHolder struct {
 	DB *sql.DB
@@ -14,7 +32,7 @@ type DatabaseHolder struct {
 
 // NewDatabaseHolder returns a new database holder
 func NewDatabaseHolder(dbFilePath string) (*DatabaseHolder, error) {
-	db, err := sql.Open("sqlite3", dbFilePath)
+	db, err := sql.Open(driverName, dbFilePath+"?_busy_timeout="+strconv.Itoa(busyTimeoutMilliseconds))
 	if err != nil {
 		return nil, err
 	}
@@ -26,3 +44,8 @@ func (holder *DatabaseHolder) Close() error {
 	slog.Info("closing sqlite database connection")
 	return holder.DB.Close()
 }
+
+// Ping checks that the database can still be reached
+func (holder *DatabaseHolder) Ping(ctx context.Context) error {
+	return holder.DB.PingContext(ctx)
+}
@@ -0,0 +1,304 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+)
+
+// EquipmentDao is a kitchen equipment dao
+type EquipmentDao struct {
+	holder *DatabaseHolder
+}
+
+// NewEquipmentDao returns a new kitchen equipment dao
+func NewEquipmentDao(holder *DatabaseHolder) (*EquipmentDao, error) {
+	initStatement := `
+		create table if not exists equipment (id integer primary key asc, name text, owned int default 0);
+		create table if not exists recipe_equipment (recipe_id int, equipment_id int);
+		create index if not exists recipe_equipment_recipe_id_index on recipe_equipment(recipe_id);
+		create index if not exists recipe_equipment_equipment_id_index on recipe_equipment(equipment_id);
+	`
+	if _, err := holder.DB.Exec(initStatement); err != nil {
+		return nil, fmt.Errorf("failed to create equipment tables and/or their indices: %w", err)
+	}
+	return &EquipmentDao{holder}, nil
+}
+
+// GetAllEquipment returns all the equipment, sorted by name
+func (dao *EquipmentDao) GetAllEquipment(ctx context.Context) ([]model.Equipment, error) {
+	return dao.queryEquipment(ctx, "select id, name, owned from equipment order by name, id")
+}
+
+// GetRecipeEquipment returns the equipment required by a recipe, sorted by name
+func (dao *EquipmentDao) GetRecipeEquipment(ctx context.Context, recipeID string) ([]model.Equipment, error) {
+	intID, err := toSqliteID(recipeID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", recipeID),
+			Cause:   err,
+		}
+	}
+	return dao.queryEquipment(ctx, `select distinct equipment.id, equipment.name, equipment.owned
+		from recipe_equipment
+		join equipment
+		on recipe_equipment.equipment_id=equipment.id
+		where recipe_equipment.recipe_id=?
+		order by equipment.name, equipment.id`, intID)
+}
+
+// queryEquipment runs a query selecting the id, name and owned flag of equipment
+func (dao *EquipmentDao) queryEquipment(ctx context.Context, query string, queryParams ...any) ([]model.Equipment, error) {
+	rows, err := dao.holder.DB.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query equipment: %w", err)
+	}
+	defer rows.Close()
+	equipment := make([]model.Equipment, 0)
+	for rows.Next() {
+		var id sqliteID
+		var name string
+		var owned bool
+		if err := rows.Scan(&id, &name, &owned); err != nil {
+			return nil, fmt.Errorf("failed to scan equipment row: %w", err)
+		}
+		equipment = append(equipment, model.Equipment{
+			ID: fromSqliteID(id),
+			BaseEquipment: model.BaseEquipment{
+				Name:  name,
+				Owned: owned,
+			},
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("got an error while iterating on equipment rows: %w", err)
+	}
+	return equipment, nil
+}
+
+// GetEquipment returns the piece of equipment with the given ID
+func (dao *EquipmentDao) GetEquipment(ctx context.Context, ID string) (*model.Equipment, error) {
+	intID, err := toSqliteID(ID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ID),
+			Cause:   err,
+		}
+	}
+	row := dao.holder.DB.QueryRowContext(ctx, "select name, owned from equipment where id=?", intID)
+	var name string
+	var owned bool
+	if err := row.Scan(&name, &owned); errors.Is(err, sql.ErrNoRows) {
+		return nil, &failure.ResourceNotFoundError{
+			Message: "equipment [" + ID + "] not found",
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to retrieve equipment: %w", err)
+	}
+	return &model.Equipment{
+		ID: ID,
+		BaseEquipment: model.BaseEquipment{
+			Name:  name,
+			Owned: owned,
+		},
+	}, nil
+}
+
+// AddEquipment adds a piece of equipment
+func (dao *EquipmentDao) AddEquipment(ctx context.Context, equipment model.BaseEquipment) (string, error) {
+	result, err := dao.holder.DB.ExecContext(ctx, "insert into equipment(name, owned) values (?, ?)", equipment.Name, equipment.Owned)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute insert statement: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve ID of inserted row: %w", err)
+	}
+	return fromSqliteID(sqliteID(id)), nil
+}
+
+// UpdateEquipment updates the name and owned flag of a piece of equipment
+func (dao *EquipmentDao) UpdateEquipment(ctx context.Context, equipment model.Equipment) error {
+	intID, err := toSqliteID(equipment.ID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", equipment.ID),
+			Cause:   err,
+		}
+	}
+	result, err := dao.holder.DB.ExecContext(ctx, "update equipment set name=?, owned=? where id=?", equipment.Name, equipment.Owned, intID)
+	if err != nil {
+		return fmt.Errorf("failed to execute update statement: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to retrieve number of rows affected by update statement: %w", err)
+	case rowsAffected == 0:
+		return &failure.ResourceNotFoundError{
+			Message: "equipment [" + equipment.ID + "] not found",
+		}
+	}
+	return nil
+}
+
+// SetOwnedEquipment marks the equipment with the given IDs as owned, and all the other equipment as not owned
+func (dao *EquipmentDao) SetOwnedEquipment(ctx context.Context, IDs []string) error {
+	intIDs := make([]any, len(IDs))
+	for i, ID := range IDs {
+		intID, err := toSqliteID(ID)
+		if err != nil {
+			return &failure.InvalidValueError{
+				Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ID),
+				Cause:   err,
+			}
+		}
+		intIDs[i] = intID
+	}
+	transaction, err := dao.holder.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to init transaction: %w", err)
+	}
+	if _, err := transaction.ExecContext(ctx, "update equipment set owned=0"); err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to reset owned equipment: %w", err)
+	}
+	if len(intIDs) != 0 {
+		placeholders := "?" + strings.Repeat(",?", len(intIDs)-1)
+		result, err := transaction.ExecContext(ctx, "update equipment set owned=1 where id in ("+placeholders+")", intIDs...)
+		if err != nil {
+			rollback(transaction)
+			return fmt.Errorf("failed to set owned equipment: %w", err)
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			rollback(transaction)
+			return fmt.Errorf("failed to retrieve number of rows affected by update statement: %w", err)
+		} else if rowsAffected != int64(len(distinct(IDs))) {
+			rollback(transaction)
+			return &failure.InvalidValueError{
+				Message: "some of the owned equipment [" + strings.Join(IDs, ", ") + "] does not exist",
+			}
+		}
+	}
+	return transaction.Commit()
+}
+
+// SetRecipeEquipment replaces the equipment required by a recipe
+func (dao *EquipmentDao) SetRecipeEquipment(ctx context.Context, transaction *sql.Tx, recipeID string, equipment []model.Equipment) error {
+	intRecipeID, err := toSqliteID(recipeID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", recipeID),
+			Cause:   err,
+		}
+	}
+	if _, err := transaction.ExecContext(ctx, "delete from recipe_equipment where recipe_id=?", intRecipeID); err != nil {
+		return fmt.Errorf("failed to delete recipe equipment: %w", err)
+	}
+	if len(equipment) == 0 {
+		return nil
+	}
+	insertStatement, err := transaction.PrepareContext(ctx, "insert into recipe_equipment(recipe_id, equipment_id) values (?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert statement: %w", err)
+	}
+	defer insertStatement.Close()
+	for _, piece := range equipment {
+		intEquipmentID, err := toSqliteID(piece.ID)
+		if err != nil {
+			return &failure.InvalidValueError{
+				Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", piece.ID),
+				Cause:   err,
+			}
+		}
+		if _, err := insertStatement.ExecContext(ctx, intRecipeID, intEquipmentID); err != nil {
+			return fmt.Errorf("failed to execute insert statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteRecipeEquipment deletes the links between a recipe and the equipment it requires
+func (dao *EquipmentDao) DeleteRecipeEquipment(ctx context.Context, transaction *sql.Tx, recipeID string) error {
+	return dao.SetRecipeEquipment(ctx, transaction, recipeID, nil)
+}
+
+// ListRecipeIdsUsingEquipment lists the IDs of the recipes that directly require the given equipment
+func (dao *EquipmentDao) ListRecipeIdsUsingEquipment(ctx context.Context, equipmentID string) ([]string, error) {
+	intID, err := toSqliteID(equipmentID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", equipmentID),
+			Cause:   err,
+		}
+	}
+	rows, err := dao.holder.DB.QueryContext(ctx, "select distinct recipe_id from recipe_equipment where equipment_id=?", intID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recipes using equipment: %w", err)
+	}
+	defer rows.Close()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id sqliteID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan recipe id row: %w", err)
+		}
+		ids = append(ids, fromSqliteID(id))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("got an error while iterating on recipe id rows: %w", err)
+	}
+	return ids, nil
+}
+
+// DeleteEquipment deletes a piece of equipment; the recipes requiring it no longer do
+func (dao *EquipmentDao) DeleteEquipment(ctx context.Context, ID string) error {
+	intID, err := toSqliteID(ID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ID),
+			Cause:   err,
+		}
+	}
+	transaction, err := dao.holder.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to init transaction: %w", err)
+	}
+	result, err := transaction.ExecContext(ctx, "delete from equipment where id=?", intID)
+	if err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to execute delete statement: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to retrieve number of rows affected by delete statement: %w", err)
+	} else if rowsAffected == 0 {
+		rollback(transaction)
+		return &failure.ResourceNotFoundError{
+			Message: "equipment [" + ID + "] not found",
+		}
+	}
+	if _, err := transaction.ExecContext(ctx, "delete from recipe_equipment where equipment_id=?", intID); err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to remove equipment from recipes: %w", err)
+	}
+	return transaction.Commit()
+}
+
+// distinct returns the given strings without duplicates
+func distinct(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,489 @@
+package datasource
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+)
+
+// ExportDao reads and writes all the data at once, to export it and import it back
+type ExportDao struct {
+	holder *DatabaseHolder
+}
+
+// NewExportDao returns a new export dao; it must be created after the other daos, which create the tables
+func NewExportDao(holder *DatabaseHolder) *ExportDao {
+	return &ExportDao{holder}
+}
+
+// exportedTables are the tables whose rows are exported, children first so that they can be cleared in this order
+var exportedTables = []string{"recipe_revision", "recipe_equipment", "recipe_ingredient", "recipe", "equipment", "ingredient_alias", "ingredient", "category"}
+
+// WriteExport writes all the data as JSON, recipes in the trash and revisions included, reading it in a single transaction.
+// The export is written section by section and recipe by recipe as it is read, so that it is never held in memory as a whole;
+// the output is left incomplete if an error occurs midway.
+func (dao *ExportDao) WriteExport(ctx context.Context, w io.Writer) error {
+	transaction, err := dao.holder.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to init transaction: %w", err)
+	}
+	defer rollback(transaction)
+
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+	fmt.Fprintf(writer, `{"version":%d,"exportedAt":`, model.ExportVersion)
+	if err := encoder.Encode(time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to write export date: %w", err)
+	}
+
+	if err := writeExportSection(writer, encoder, "categories", func(write func(any) error) error {
+		return forEachRow(ctx, transaction, "select id, name, aisle_order from category order by id", func(rows *sql.Rows) error {
+			var category model.Category
+			var id sqliteID
+			var aisleOrder sql.NullInt64
+			if err := rows.Scan(&id, &category.Name, &aisleOrder); err != nil {
+				return err
+			}
+			category.ID, category.AisleOrder = fromSqliteID(id), int(aisleOrder.Int64)
+			return write(category)
+		})
+	}); err != nil {
+		return fmt.Errorf("failed to export categories: %w", err)
+	}
+
+	// Aliases are few compared to the recipes, so they are read beforehand to be written along with their ingredient
+	aliases := make(map[sqliteID][]string)
+	if err := forEachRow(ctx, transaction, "select ingredient_id, name from ingredient_alias order by rowid", func(rows *sql.Rows) error {
+		var ingredientID sqliteID
+		var name string
+		if err := rows.Scan(&ingredientID, &name); err != nil {
+			return err
+		}
+		aliases[ingredientID] = append(aliases[ingredientID], name)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to export ingredient aliases: %w", err)
+	}
+	if err := writeExportSection(writer, encoder, "ingredients", func(write func(any) error) error {
+		return forEachRow(ctx, transaction, "select id, name, category_id, allergens, allergens_known, season_from, season_to from ingredient order by id", func(rows *sql.Rows) error {
+			var ingredient model.Ingredient
+			var id sqliteID
+			var categoryID, seasonFrom, seasonTo sql.NullInt64
+			var allergens sql.NullString
+			var allergensKnown sql.NullBool
+			if err := rows.Scan(&id, &ingredient.Name, &categoryID, &allergens, &allergensKnown, &seasonFrom, &seasonTo); err != nil {
+				return err
+			}
+			ingredient.ID = fromSqliteID(id)
+			ingredient.CategoryID = fromNullableSqliteID(categoryID)
+			ingredient.Allergens = splitAllergens(allergens)
+			ingredient.AllergensKnown = allergensKnown.Bool
+			ingredient.Season = toSeason(seasonFrom, seasonTo)
+			ingredient.Aliases = aliases[id]
+			return write(ingredient)
+		})
+	}); err != nil {
+		return fmt.Errorf("failed to export ingredients: %w", err)
+	}
+
+	if err := writeExportSection(writer, encoder, "equipment", func(write func(any) error) error {
+		return forEachRow(ctx, transaction, "select id, name, owned from equipment order by id", func(rows *sql.Rows) error {
+			var equipment model.Equipment
+			var id sqliteID
+			if err := rows.Scan(&id, &equipment.Name, &equipment.Owned); err != nil {
+				return err
+			}
+			equipment.ID = fromSqliteID(id)
+			return write(equipment)
+		})
+	}); err != nil {
+		return fmt.Errorf("failed to export equipment: %w", err)
+	}
+
+	// Only the IDs of the recipes are read beforehand, each recipe being then read and written on its own
+	recipeIDs := make([]sqliteID, 0)
+	if err := forEachRow(ctx, transaction, "select id from recipe order by id", func(rows *sql.Rows) error {
+		var id sqliteID
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		recipeIDs = append(recipeIDs, id)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to export recipes: %w", err)
+	}
+	if err := writeExportSection(writer, encoder, "recipes", func(write func(any) error) error {
+		for _, id := range recipeIDs {
+			recipe, err := exportRecipe(ctx, transaction, id)
+			if err != nil {
+				return fmt.Errorf("failed to export recipe [%d]: %w", id, err)
+			}
+			if err := write(recipe); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	writer.WriteString("}\n")
+	return writer.Flush()
+}
+
+// writeExportSection writes a named array of the export; the given function writes its elements one by one
+func writeExportSection(writer *bufio.Writer, encoder *json.Encoder, name string, writeElements func(write func(any) error) error) error {
+	fmt.Fprintf(writer, `,%q:[`, name)
+	first := true
+	if err := writeElements(func(element any) error {
+		if !first {
+			writer.WriteByte(',')
+		}
+		first = false
+		return encoder.Encode(element)
+	}); err != nil {
+		return err
+	}
+	_, err := writer.WriteString("]")
+	return err
+}
+
+// exportRecipe reads a recipe, in the trash or not, along with its ingredients, equipment and revisions
+func exportRecipe(ctx context.Context, transaction *sql.Tx, id sqliteID) (*model.ExportedRecipe, error) {
+	recipe := &model.ExportedRecipe{ID: fromSqliteID(id)}
+	var parentID, deletedAt sql.NullInt64
+	var details recipeDetails
+	row := transaction.QueryRowContext(ctx, "select name, how_to, parent_id, deleted_at, "+recipeDetailColumns+" from recipe where id=?", id)
+	if err := row.Scan(append([]any{&recipe.Name, &recipe.HowTo, &parentID, &deletedAt}, details.targets()...)...); err != nil {
+		return nil, fmt.Errorf("failed to read recipe: %w", err)
+	}
+	var base model.BaseRecipe
+	details.apply(&base)
+	recipe.Yield, recipe.PrepMinutes, recipe.CookMinutes, recipe.TotalMinutes = base.Yield, base.PrepMinutes, base.CookMinutes, base.TotalMinutes
+	recipe.ParentID = fromNullableSqliteID(parentID)
+	if deletedAt.Valid {
+		deletedTime := time.UnixMilli(deletedAt.Int64).UTC()
+		recipe.DeletedAt = &deletedTime
+	}
+
+	if err := forEachRow(ctx, transaction, "select ingredient_id, sub_recipe_id, quantity from recipe_ingredient where recipe_id=? order by rowid", func(rows *sql.Rows) error {
+		var ingredientID, subRecipeID sql.NullInt64
+		var quantity sql.NullString
+		if err := rows.Scan(&ingredientID, &subRecipeID, &quantity); err != nil {
+			return err
+		}
+		recipe.Ingredients = append(recipe.Ingredients, model.ExportedRecipeIngredient{
+			IngredientID: fromNullableSqliteID(ingredientID),
+			SubRecipeID:  fromNullableSqliteID(subRecipeID),
+			Quantity:     quantity.String,
+		})
+		return nil
+	}, id); err != nil {
+		return nil, fmt.Errorf("failed to export recipe ingredients: %w", err)
+	}
+	if err := forEachRow(ctx, transaction, "select equipment_id from recipe_equipment where recipe_id=? order by rowid", func(rows *sql.Rows) error {
+		var equipmentID sqliteID
+		if err := rows.Scan(&equipmentID); err != nil {
+			return err
+		}
+		recipe.EquipmentIDs = append(recipe.EquipmentIDs, fromSqliteID(equipmentID))
+		return nil
+	}, id); err != nil {
+		return nil, fmt.Errorf("failed to export recipe equipment: %w", err)
+	}
+	if err := forEachRow(ctx, transaction, "select id, created_at, content from recipe_revision where recipe_id=? order by id", func(rows *sql.Rows) error {
+		var revisionID sqliteID
+		var createdAt int64
+		var content string
+		if err := rows.Scan(&revisionID, &createdAt, &content); err != nil {
+			return err
+		}
+		var snapshot model.Recipe
+		if err := json.Unmarshal([]byte(content), &snapshot); err != nil {
+			return fmt.Errorf("failed to parse content of revision [%d]: %w", revisionID, err)
+		}
+		recipe.Revisions = append(recipe.Revisions, model.RecipeRevision{
+			ID:        fromSqliteID(revisionID),
+			RecipeID:  recipe.ID,
+			CreatedAt: time.UnixMilli(createdAt).UTC(),
+			Recipe:    &snapshot,
+		})
+		return nil
+	}, id); err != nil {
+		return nil, fmt.Errorf("failed to export recipe revisions: %w", err)
+	}
+	return recipe, nil
+}
+
+// forEachRow runs a query with the given arguments and calls the given function on each of the rows it returns
+func forEachRow(ctx context.Context, querier querier, query string, scan func(*sql.Rows) error, args ...any) error {
+	rows, err := querier.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("got an error while iterating on rows: %w", err)
+	}
+	return nil
+}
+
+// Import writes all the data of an export in a single transaction, keeping the IDs it contains.
+// When replacing, all the existing data is deleted first; otherwise the imported rows replace the existing ones with the same IDs.
+// The import is rolled back if it leaves references to missing rows.
+func (dao *ExportDao) Import(ctx context.Context, export model.Export, replace bool) (*model.ImportReport, error) {
+	transaction, err := dao.holder.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to init transaction: %w", err)
+	}
+	report, err := importInTransaction(ctx, transaction, export, replace)
+	if err != nil {
+		rollback(transaction)
+		return nil, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return report, nil
+}
+
+// importInTransaction writes all the data of an export using the given transaction
+func importInTransaction(ctx context.Context, transaction *sql.Tx, export model.Export, replace bool) (*model.ImportReport, error) {
+	if replace {
+		for _, table := range exportedTables {
+			if _, err := transaction.ExecContext(ctx, "delete from "+table); err != nil {
+				return nil, fmt.Errorf("failed to clear table [%s]: %w", table, err)
+			}
+		}
+	}
+
+	report := &model.ImportReport{}
+	for _, category := range export.Categories {
+		id, err := importedID("category", category.ID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := transaction.ExecContext(ctx, "insert or replace into category(id, name, aisle_order) values (?, ?, ?)", id, category.Name, category.AisleOrder); err != nil {
+			return nil, fmt.Errorf("failed to import category [%s]: %w", category.ID, err)
+		}
+		report.Categories++
+	}
+
+	for _, ingredient := range export.Ingredients {
+		id, err := importedID("ingredient", ingredient.ID)
+		if err != nil {
+			return nil, err
+		}
+		categoryID, err := importedNullableID("category", ingredient.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		var seasonFrom, seasonTo sql.NullInt64
+		if ingredient.Season != nil {
+			seasonFrom = sql.NullInt64{Int64: int64(ingredient.Season.From), Valid: true}
+			seasonTo = sql.NullInt64{Int64: int64(ingredient.Season.To), Valid: true}
+		}
+		if _, err := transaction.ExecContext(
+			ctx,
+			"insert or replace into ingredient(id, name, category_id, allergens, allergens_known, season_from, season_to) values (?, ?, ?, ?, ?, ?, ?)",
+			id, ingredient.Name, categoryID, joinAllergens(ingredient.Allergens), ingredient.AllergensKnown, seasonFrom, seasonTo,
+		); err != nil {
+			return nil, fmt.Errorf("failed to import ingredient [%s]: %w", ingredient.ID, err)
+		}
+		if _, err := transaction.ExecContext(ctx, "delete from ingredient_alias where ingredient_id=?", id); err != nil {
+			return nil, fmt.Errorf("failed to delete aliases of ingredient [%s]: %w", ingredient.ID, err)
+		}
+		for _, alias := range ingredient.Aliases {
+			if _, err := transaction.ExecContext(ctx, "insert into ingredient_alias(ingredient_id, name) values (?, ?)", id, alias); err != nil {
+				return nil, fmt.Errorf("failed to import alias [%s] of ingredient [%s]: %w", alias, ingredient.ID, err)
+			}
+		}
+		report.Ingredients++
+	}
+
+	for _, equipment := range export.Equipment {
+		id, err := importedID("equipment", equipment.ID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := transaction.ExecContext(ctx, "insert or replace into equipment(id, name, owned) values (?, ?, ?)", id, equipment.Name, equipment.Owned); err != nil {
+			return nil, fmt.Errorf("failed to import equipment [%s]: %w", equipment.ID, err)
+		}
+		report.Equipment++
+	}
+
+	for _, recipe := range export.Recipes {
+		if err := importRecipe(ctx, transaction, recipe); err != nil {
+			return nil, err
+		}
+		report.Recipes++
+		report.Revisions += len(recipe.Revisions)
+	}
+
+	if err := checkImportedReferences(ctx, transaction); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// importRecipe writes an exported recipe, replacing its ingredients, its equipment and the revisions with the same IDs
+func importRecipe(ctx context.Context, transaction *sql.Tx, recipe model.ExportedRecipe) error {
+	id, err := importedID("recipe", recipe.ID)
+	if err != nil {
+		return err
+	}
+	parentID, err := importedNullableID("recipe", recipe.ParentID)
+	if err != nil {
+		return err
+	}
+	var deletedAt sql.NullInt64
+	if recipe.DeletedAt != nil {
+		deletedAt = sql.NullInt64{Int64: recipe.DeletedAt.UnixMilli(), Valid: true}
+	}
+	details := toRecipeDetails(model.BaseRecipe{Yield: recipe.Yield, PrepMinutes: recipe.PrepMinutes, CookMinutes: recipe.CookMinutes, TotalMinutes: recipe.TotalMinutes})
+	if _, err := transaction.ExecContext(
+		ctx,
+		"insert or replace into recipe(id, name, how_to, parent_id, deleted_at, "+recipeDetailColumns+") values (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		append([]any{id, recipe.Name, recipe.HowTo, parentID, deletedAt}, details.values()...)...,
+	); err != nil {
+		return fmt.Errorf("failed to import recipe [%s]: %w", recipe.ID, err)
+	}
+
+	if _, err := transaction.ExecContext(ctx, "delete from recipe_ingredient where recipe_id=?", id); err != nil {
+		return fmt.Errorf("failed to delete ingredients of recipe [%s]: %w", recipe.ID, err)
+	}
+	for _, ingredient := range recipe.Ingredients {
+		ingredientID, err := importedNullableID("ingredient", ingredient.IngredientID)
+		if err != nil {
+			return err
+		}
+		subRecipeID, err := importedNullableID("recipe", ingredient.SubRecipeID)
+		if err != nil {
+			return err
+		}
+		if ingredientID.Valid == subRecipeID.Valid {
+			return &failure.InvalidValueError{
+				Message: "ingredients of recipe [" + recipe.ID + "] must have either an ingredient ID or a sub-recipe ID",
+			}
+		}
+		if _, err := transaction.ExecContext(ctx, "insert into recipe_ingredient(recipe_id, ingredient_id, sub_recipe_id, quantity) values (?, ?, ?, ?)", id, ingredientID, subRecipeID, ingredient.Quantity); err != nil {
+			return fmt.Errorf("failed to import ingredient of recipe [%s]: %w", recipe.ID, err)
+		}
+	}
+
+	if _, err := transaction.ExecContext(ctx, "delete from recipe_equipment where recipe_id=?", id); err != nil {
+		return fmt.Errorf("failed to delete equipment of recipe [%s]: %w", recipe.ID, err)
+	}
+	for _, equipmentID := range recipe.EquipmentIDs {
+		intEquipmentID, err := importedID("equipment", equipmentID)
+		if err != nil {
+			return err
+		}
+		if _, err := transaction.ExecContext(ctx, "insert into recipe_equipment(recipe_id, equipment_id) values (?, ?)", id, intEquipmentID); err != nil {
+			return fmt.Errorf("failed to import equipment of recipe [%s]: %w", recipe.ID, err)
+		}
+	}
+
+	for _, revision := range recipe.Revisions {
+		revisionID, err := importedID("revision", revision.ID)
+		if err != nil {
+			return err
+		}
+		snapshot := model.Recipe{ID: recipe.ID}
+		if revision.Recipe != nil {
+			snapshot = *revision.Recipe
+		}
+		content, err := json.Marshal(snapshot)
+		if err != nil {
+			return fmt.Errorf("failed to serialize revision [%s] of recipe [%s]: %w", revision.ID, recipe.ID, err)
+		}
+		if _, err := transaction.ExecContext(
+			ctx,
+			"insert or replace into recipe_revision(id, recipe_id, created_at, content) values (?, ?, ?, ?)",
+			revisionID, id, revision.CreatedAt.UnixMilli(), string(content),
+		); err != nil {
+			return fmt.Errorf("failed to import revision [%s] of recipe [%s]: %w", revision.ID, recipe.ID, err)
+		}
+	}
+	return nil
+}
+
+// subRecipeCycleQuery counts the recipes using themselves as an ingredient, directly or through their sub-recipes;
+// union removes the links already found, so that the recursion ends even with cycles
+const subRecipeCycleQuery = `with recursive uses(recipe_id, sub_recipe_id) as (
+		select recipe_id, sub_recipe_id from recipe_ingredient where sub_recipe_id is not null
+		union
+		select uses.recipe_id, recipe_ingredient.sub_recipe_id from uses join recipe_ingredient on recipe_ingredient.recipe_id = uses.sub_recipe_id
+		where recipe_ingredient.sub_recipe_id is not null
+	)
+	select count(distinct recipe_id) from uses where recipe_id = sub_recipe_id`
+
+// danglingReferenceChecks are queries counting the rows referencing missing rows, or recipes in a cycle of sub-recipes, by description of the problem
+var danglingReferenceChecks = []struct {
+	description string
+	query       string
+}{
+	{"ingredients in missing categories", "select count(*) from ingredient where category_id is not null and category_id not in (select id from category)"},
+	{"recipe ingredients referencing missing ingredients", "select count(*) from recipe_ingredient where ingredient_id is not null and ingredient_id not in (select id from ingredient)"},
+	{"recipe ingredients referencing missing sub-recipes", "select count(*) from recipe_ingredient where sub_recipe_id is not null and sub_recipe_id not in (select id from recipe)"},
+	{"recipes referencing missing parent recipes", "select count(*) from recipe where parent_id is not null and parent_id not in (select id from recipe)"},
+	{"recipes requiring missing equipment", "select count(*) from recipe_equipment where equipment_id not in (select id from equipment)"},
+	{"recipes using themselves as sub-recipes, even indirectly", subRecipeCycleQuery},
+}
+
+// checkImportedReferences checks that the imported data does not reference missing rows, and that no recipe uses itself as a sub-recipe
+func checkImportedReferences(ctx context.Context, transaction *sql.Tx) error {
+	problems := make([]string, 0)
+	for _, check := range danglingReferenceChecks {
+		var count int
+		if err := transaction.QueryRowContext(ctx, check.query).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count %s: %w", check.description, err)
+		}
+		if count != 0 {
+			problems = append(problems, fmt.Sprintf("%d %s", count, check.description))
+		}
+	}
+	if len(problems) != 0 {
+		return &failure.InvalidValueError{
+			Message: "import would leave " + strings.Join(problems, ", "),
+		}
+	}
+	return nil
+}
+
+// importedID parses the ID of an imported row
+func importedID(kind, ID string) (sqliteID, error) {
+	intID, err := toSqliteID(ID)
+	if err != nil {
+		return 0, &failure.InvalidValueError{
+			Message: fmt.Sprintf("invalid %s ID [%s]", kind, ID),
+			Cause:   err,
+		}
+	}
+	return intID, nil
+}
+
+// importedNullableID parses the optional ID of a row referenced by an imported row
+func importedNullableID(kind, ID string) (sql.NullInt64, error) {
+	intID, err := toNullableSqliteID(ID)
+	if err != nil {
+		return sql.NullInt64{}, &failure.InvalidValueError{
+			Message: fmt.Sprintf("invalid %s ID [%s]", kind, ID),
+			Cause:   err,
+		}
+	}
+	return intID, nil
+}
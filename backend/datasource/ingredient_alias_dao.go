@@ -0,0 +1,193 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/similarity"
+)
+
+// IngredientAliasDao is a dao for the alternative names of ingredients
+type IngredientAliasDao struct {
+	holder *DatabaseHolder
+}
+
+// NewIngredientAliasDao returns a new ingredient alias dao
+func NewIngredientAliasDao(holder *DatabaseHolder) (*IngredientAliasDao, error) {
+	initStatement := `
+		create table if not exists ingredient_alias (ingredient_id int, name text);
+		create index if not exists ingredient_alias_ingredient_id_index on ingredient_alias(ingredient_id);
+	`
+	if _, err := holder.DB.Exec(initStatement); err != nil {
+		return nil, fmt.Errorf("failed to create ingredient_alias table and/or its indices: %w", err)
+	}
+	if err := addColumnIfMissing(holder.DB, "ingredient_alias", "normalized_name", "text"); err != nil {
+		return nil, err
+	}
+	// The normalized names are kept up to date by triggers, and refreshed at startup in case the normalization changed
+	normalizedNameStatement := `
+		update ingredient_alias set normalized_name=normalize_name(name) where normalized_name is not normalize_name(name);
+		create index if not exists ingredient_alias_normalized_name_index on ingredient_alias(normalized_name);
+		create trigger if not exists ingredient_alias_normalized_name_insert after insert on ingredient_alias begin
+			update ingredient_alias set normalized_name=normalize_name(new.name) where rowid=new.rowid;
+		end;
+		create trigger if not exists ingredient_alias_normalized_name_update after update of name on ingredient_alias begin
+			update ingredient_alias set normalized_name=normalize_name(new.name) where rowid=new.rowid;
+		end;
+	`
+	if _, err := holder.DB.Exec(normalizedNameStatement); err != nil {
+		return nil, fmt.Errorf("failed to initialize ingredient_alias normalized_name column: %w", err)
+	}
+	return &IngredientAliasDao{holder}, nil
+}
+
+// GetAllAliases returns the aliases of all ingredients, by ingredient ID
+func (dao *IngredientAliasDao) GetAllAliases(ctx context.Context) (map[string][]string, error) {
+	rows, err := dao.holder.DB.QueryContext(ctx, "select ingredient_id, name from ingredient_alias order by rowid")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ingredient aliases: %w", err)
+	}
+	defer rows.Close()
+	aliases := make(map[string][]string)
+	for rows.Next() {
+		var ingredientID sqliteID
+		var name string
+		if err := rows.Scan(&ingredientID, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan ingredient alias row: %w", err)
+		}
+		aliases[fromSqliteID(ingredientID)] = append(aliases[fromSqliteID(ingredientID)], name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("got an error while iterating on ingredient alias rows: %w", err)
+	}
+	return aliases, nil
+}
+
+// FindIngredientIDByName returns the ID of the ingredient whose name or one of its aliases matches the given name once normalized
+func (dao *IngredientAliasDao) FindIngredientIDByName(ctx context.Context, querier querier, name string) (string, bool, error) {
+	rows, err := querier.QueryContext(ctx, `select id, 0 from ingredient where normalized_name=?1
+		union all select ingredient_id, 1 from ingredient_alias where normalized_name=?1
+		order by 2 limit 1`, similarity.NormalizeName(name))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to query ingredient names: %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", false, fmt.Errorf("got an error while iterating on ingredient name rows: %w", err)
+		}
+		return "", false, nil
+	}
+	var ingredientID, priority sqliteID
+	if err := rows.Scan(&ingredientID, &priority); err != nil {
+		return "", false, fmt.Errorf("failed to scan ingredient name row: %w", err)
+	}
+	return fromSqliteID(ingredientID), true, nil
+}
+
+// AddAlias adds an alias to an ingredient, provided no ingredient is already named that way
+func (dao *IngredientAliasDao) AddAlias(ctx context.Context, transaction *sql.Tx, ingredientID, name string) error {
+	intIngredientID, err := toSqliteID(ingredientID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ingredientID),
+			Cause:   err,
+		}
+	}
+	if strings.TrimSpace(name) == "" {
+		return &failure.InvalidValueError{
+			Message: "alias cannot be empty",
+		}
+	}
+	existingID, found, err := dao.FindIngredientIDByName(ctx, transaction, name)
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to look for ingredients named [%s]: %w", name, err)
+	case found && existingID == ingredientID:
+		return nil
+	case found:
+		return &failure.InvalidValueError{
+			Message: "[" + name + "] is already the name or an alias of ingredient [" + existingID + "]",
+		}
+	}
+
+	insertStatement, err := transaction.PrepareContext(ctx, "insert into ingredient_alias(ingredient_id, name) values(?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert statement: %w", err)
+	}
+	defer insertStatement.Close()
+
+	if _, err := insertStatement.ExecContext(ctx, intIngredientID, strings.TrimSpace(name)); err != nil {
+		return fmt.Errorf("failed to execute insert statement: %w", err)
+	}
+	return nil
+}
+
+// AddAliasToIngredient adds an alias to an ingredient in its own transaction
+func (dao *IngredientAliasDao) AddAliasToIngredient(ctx context.Context, ingredientID, name string) error {
+	transaction, err := dao.holder.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to init transaction: %w", err)
+	}
+	if err := dao.AddAlias(ctx, transaction, ingredientID, name); err != nil {
+		rollback(transaction)
+		return err
+	}
+	return transaction.Commit()
+}
+
+// DeleteAlias removes an alias from an ingredient
+func (dao *IngredientAliasDao) DeleteAlias(ctx context.Context, ingredientID, name string) error {
+	intIngredientID, err := toSqliteID(ingredientID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ingredientID),
+			Cause:   err,
+		}
+	}
+	deleteStatement, err := dao.holder.DB.PrepareContext(ctx, "delete from ingredient_alias where ingredient_id=? and name=?")
+	if err != nil {
+		return fmt.Errorf("failed to prepare delete statement: %w", err)
+	}
+	defer deleteStatement.Close()
+
+	result, err := deleteStatement.ExecContext(ctx, intIngredientID, name)
+	if err != nil {
+		return fmt.Errorf("failed to execute delete statement: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to retrieve number of rows affected by delete statement: %w", err)
+	case rowsAffected == 0:
+		return &failure.ResourceNotFoundError{
+			Message: "alias [" + name + "] of ingredient [" + ingredientID + "] not found",
+		}
+	}
+	return nil
+}
+
+// MoveAliases gives the aliases of an ingredient to another one
+func (dao *IngredientAliasDao) MoveAliases(ctx context.Context, transaction *sql.Tx, fromIngredientID, toIngredientID string) error {
+	intFromID, err := toSqliteID(fromIngredientID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", fromIngredientID),
+			Cause:   err,
+		}
+	}
+	intToID, err := toSqliteID(toIngredientID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", toIngredientID),
+			Cause:   err,
+		}
+	}
+	if _, err := transaction.ExecContext(ctx, "update ingredient_alias set ingredient_id=? where ingredient_id=?", intToID, intFromID); err != nil {
+		return fmt.Errorf("failed to execute update statement: %w", err)
+	}
+	return nil
+}
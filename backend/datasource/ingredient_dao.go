@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/remieven/miam/model"
 	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/similarity"
 )
 
 // IngredientDao struct
@@ -22,6 +24,45 @@ func NewIngredientDao(holder *DatabaseHolder) (*IngredientDao, error) {
 	if _, err := holder.DB.Exec(initStatement); err != nil {
 		return nil, fmt.Errorf("failed to create ingredient table: %w", err)
 	}
+	if err := addColumnIfMissing(holder.DB, "ingredient", "category_id", "int"); err != nil {
+		return nil, err
+	}
+	if err := addColumnIfMissing(holder.DB, "ingredient", "allergens", "text"); err != nil {
+		return nil, err
+	}
+	if err := addColumnIfMissing(holder.DB, "ingredient", "allergens_known", "int"); err != nil {
+		return nil, err
+	}
+	// Ingredients whose allergens were never set, including all the ones added before the column, are the ones with unknown allergens
+	if _, err := holder.DB.Exec("update ingredient set allergens_known=coalesce(allergens, '')!='' where allergens_known is null"); err != nil {
+		return nil, fmt.Errorf("failed to initialize ingredient allergens_known column: %w", err)
+	}
+	if err := addColumnIfMissing(holder.DB, "ingredient", "season_from", "int"); err != nil {
+		return nil, err
+	}
+	if err := addColumnIfMissing(holder.DB, "ingredient", "season_to", "int"); err != nil {
+		return nil, err
+	}
+	if _, err := holder.DB.Exec("create index if not exists ingredient_category_id_index on ingredient(category_id)"); err != nil {
+		return nil, fmt.Errorf("failed to create ingredient category_id index: %w", err)
+	}
+	if err := addColumnIfMissing(holder.DB, "ingredient", "normalized_name", "text"); err != nil {
+		return nil, err
+	}
+	// The normalized names are kept up to date by triggers, and refreshed at startup in case the normalization changed
+	normalizedNameStatement := `
+		update ingredient set normalized_name=normalize_name(name) where normalized_name is not normalize_name(name);
+		create index if not exists ingredient_normalized_name_index on ingredient(normalized_name);
+		create trigger if not exists ingredient_normalized_name_insert after insert on ingredient begin
+			update ingredient set normalized_name=normalize_name(new.name) where id=new.id;
+		end;
+		create trigger if not exists ingredient_normalized_name_update after update of name on ingredient begin
+			update ingredient set normalized_name=normalize_name(new.name) where id=new.id;
+		end;
+	`
+	if _, err := holder.DB.Exec(normalizedNameStatement); err != nil {
+		return nil, fmt.Errorf("failed to initialize ingredient normalized_name column: %w", err)
+	}
 	return &IngredientDao{holder}, nil
 }
 
@@ -34,10 +75,17 @@ func (dao *IngredientDao) GetIngredient(ctx context.Context, ID string) (*model.
 			Cause:   err,
 		}
 	}
-	row := dao.holder.DB.QueryRowContext(ctx, "select name from ingredient where id=?", oid)
+	row := dao.holder.DB.QueryRowContext(ctx, `select ingredient.name, ingredient.allergens, ingredient.allergens_known, ingredient.season_from, ingredient.season_to, category.id, category.name, category.aisle_order
+		from ingredient
+		left join category
+		on ingredient.category_id=category.id
+		where ingredient.id=?`, oid)
 	var name string
+	var allergens, categoryName sql.NullString
+	var allergensKnown sql.NullBool
+	var seasonFrom, seasonTo, categoryID, categoryAisleOrder sql.NullInt64
 
-	if err := row.Scan(&name); errors.Is(err, sql.ErrNoRows) {
+	if err := row.Scan(&name, &allergens, &allergensKnown, &seasonFrom, &seasonTo, &categoryID, &categoryName, &categoryAisleOrder); errors.Is(err, sql.ErrNoRows) {
 		return nil, &failure.ResourceNotFoundError{
 			Message: "ingredient [" + ID + "] not found",
 		}
@@ -48,8 +96,13 @@ func (dao *IngredientDao) GetIngredient(ctx context.Context, ID string) (*model.
 	return &model.Ingredient{
 		ID: ID,
 		BaseIngredient: model.BaseIngredient{
-			Name: name,
+			Name:           name,
+			CategoryID:     fromNullableSqliteID(categoryID),
+			Allergens:      splitAllergens(allergens),
+			AllergensKnown: allergensKnown.Bool,
+			Season:         toSeason(seasonFrom, seasonTo),
 		},
+		Category: toCategory(categoryID, categoryName, categoryAisleOrder),
 	}, nil
 }
 
@@ -73,7 +126,7 @@ func (dao *IngredientDao) AddIngredient(ctx context.Context, transaction *sql.Tx
 	return fromSqliteID(sqliteID(id)), nil
 }
 
-// DeleteIngredient deletes the ingredient with the given id if present.
+// DeleteIngredient deletes the ingredient with the given id if present, along with its aliases.
 // It is up to the caller to ensure no recipe uses the ingredient.
 func (dao *IngredientDao) DeleteIngredient(ctx context.Context, ID string) error {
 	oid, err := toSqliteID(ID)
@@ -83,27 +136,53 @@ func (dao *IngredientDao) DeleteIngredient(ctx context.Context, ID string) error
 			Cause:   err,
 		}
 	}
-	deleteStatement, err := dao.holder.DB.PrepareContext(ctx, "delete from ingredient where id=?")
+	transaction, err := dao.holder.DB.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return fmt.Errorf("failed to init transaction: %w", err)
+	}
+	if err := dao.deleteIngredientInTransaction(ctx, transaction, oid); err != nil {
+		rollback(transaction)
+		return err
+	}
+	if _, err := transaction.ExecContext(ctx, "delete from ingredient_alias where ingredient_id=?", oid); err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to execute delete statement: %w", err)
 	}
-	defer deleteStatement.Close()
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
 
-	if _, err := deleteStatement.ExecContext(ctx, oid); err != nil {
+// deleteIngredientInTransaction deletes the ingredient with the given id as part of a transaction
+func (dao *IngredientDao) deleteIngredientInTransaction(ctx context.Context, transaction *sql.Tx, oid sqliteID) error {
+	if _, err := transaction.ExecContext(ctx, "delete from ingredient where id=?", oid); err != nil {
 		return fmt.Errorf("failed to execute delete statement: %w", err)
 	}
-	return err
+	return nil
 }
 
-// UpdateIngredient updates the name of an ingredient
+// UpdateIngredient updates the name, category, allergens and season of an ingredient
 func (dao *IngredientDao) UpdateIngredient(ctx context.Context, ingredient model.Ingredient) error {
-	updateStatement, err := dao.holder.DB.PrepareContext(ctx, "update ingredient set name=?2 where id=?1")
+	categoryID, err := toNullableSqliteID(ingredient.CategoryID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ingredient.CategoryID),
+			Cause:   err,
+		}
+	}
+	updateStatement, err := dao.holder.DB.PrepareContext(ctx, "update ingredient set name=?2, category_id=?3, allergens=?4, allergens_known=?5, season_from=?6, season_to=?7 where id=?1")
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer updateStatement.Close()
 
-	result, err := updateStatement.ExecContext(ctx, ingredient.ID, ingredient.Name)
+	var seasonFrom, seasonTo sql.NullInt64
+	if ingredient.Season != nil {
+		seasonFrom = sql.NullInt64{Int64: int64(ingredient.Season.From), Valid: true}
+		seasonTo = sql.NullInt64{Int64: int64(ingredient.Season.To), Valid: true}
+	}
+	result, err := updateStatement.ExecContext(ctx, ingredient.ID, ingredient.Name, categoryID, joinAllergens(ingredient.Allergens), ingredient.AllergensKnown, seasonFrom, seasonTo)
 	if err != nil {
 		return fmt.Errorf("failed to execute update statement: %w", err)
 	}
@@ -121,7 +200,10 @@ func (dao *IngredientDao) UpdateIngredient(ctx context.Context, ingredient model
 
 // GetAllIngredients returns all ingredients
 func (dao *IngredientDao) GetAllIngredients(ctx context.Context) ([]model.Ingredient, error) {
-	rows, err := dao.holder.DB.QueryContext(ctx, "select id, name from ingredient")
+	rows, err := dao.holder.DB.QueryContext(ctx, `select ingredient.id, ingredient.name, ingredient.allergens, ingredient.allergens_known, ingredient.season_from, ingredient.season_to, category.id, category.name, category.aisle_order
+		from ingredient
+		left join category
+		on ingredient.category_id=category.id`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve ingredients: %w", err)
 	}
@@ -131,14 +213,22 @@ func (dao *IngredientDao) GetAllIngredients(ctx context.Context) ([]model.Ingred
 	for rows.Next() {
 		var id int
 		var name string
-		if err := rows.Scan(&id, &name); err != nil {
+		var allergens, categoryName sql.NullString
+		var allergensKnown sql.NullBool
+		var seasonFrom, seasonTo, categoryID, categoryAisleOrder sql.NullInt64
+		if err := rows.Scan(&id, &name, &allergens, &allergensKnown, &seasonFrom, &seasonTo, &categoryID, &categoryName, &categoryAisleOrder); err != nil {
 			return nil, fmt.Errorf("failed to scan ingredient row: %w", err)
 		}
 		ingredients = append(ingredients, model.Ingredient{
 			ID: fromSqliteID(id),
 			BaseIngredient: model.BaseIngredient{
-				Name: name,
+				Name:           name,
+				CategoryID:     fromNullableSqliteID(categoryID),
+				Allergens:      splitAllergens(allergens),
+				AllergensKnown: allergensKnown.Bool,
+				Season:         toSeason(seasonFrom, seasonTo),
 			},
+			Category: toCategory(categoryID, categoryName, categoryAisleOrder),
 		})
 	}
 	if err := rows.Err(); err != nil {
@@ -146,3 +236,231 @@ func (dao *IngredientDao) GetAllIngredients(ctx context.Context) ([]model.Ingred
 	}
 	return ingredients, nil
 }
+
+// joinAllergens turns allergens into the comma separated list stored in the allergens column
+func joinAllergens(allergens []string) string {
+	return strings.Join(allergens, ",")
+}
+
+// splitAllergens turns the content of the allergens column back into a list
+func splitAllergens(allergens sql.NullString) []string {
+	if allergens.String == "" {
+		return nil
+	}
+	return strings.Split(allergens.String, ",")
+}
+
+// toSeason builds the season of an ingredient from the season_from and season_to columns, which are null for ingredients found all year long
+func toSeason(from, to sql.NullInt64) *model.Season {
+	if !from.Valid || !to.Valid {
+		return nil
+	}
+	return &model.Season{
+		From: int(from.Int64),
+		To:   int(to.Int64),
+	}
+}
+
+// upsertedIngredient is the current state of an ingredient during an upsert
+type upsertedIngredient struct {
+	name       string
+	categoryID string
+	aliases    []string
+}
+
+// UpsertIngredients creates or updates ingredients in a single transaction, along with their categories and aliases.
+// A row whose ingredient does not exist, or whose name or aliases belong to another ingredient, is reported as an error and skipped;
+// the transaction is rolled back only on unexpected errors. Allergens and seasons are left untouched.
+func (dao *IngredientDao) UpsertIngredients(ctx context.Context, upserts []model.IngredientUpsert) ([]model.IngredientImportRow, error) {
+	transaction, err := dao.holder.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to init transaction: %w", err)
+	}
+	rows, err := upsertIngredientsInTransaction(ctx, transaction, upserts)
+	if err != nil {
+		rollback(transaction)
+		return nil, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return rows, nil
+}
+
+func upsertIngredientsInTransaction(ctx context.Context, transaction *sql.Tx, upserts []model.IngredientUpsert) ([]model.IngredientImportRow, error) {
+	ingredients := make(map[string]*upsertedIngredient)
+	// owners maps the normalized names and aliases to the IDs of their ingredients
+	owners := make(map[string]string)
+	err := forEachRow(ctx, transaction, "select id, name, category_id from ingredient", func(rows *sql.Rows) error {
+		var ID sqliteID
+		var name string
+		var categoryID sql.NullInt64
+		if err := rows.Scan(&ID, &name, &categoryID); err != nil {
+			return err
+		}
+		ingredients[fromSqliteID(ID)] = &upsertedIngredient{name: name, categoryID: fromNullableSqliteID(categoryID)}
+		owners[similarity.NormalizeName(name)] = fromSqliteID(ID)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingredients: %w", err)
+	}
+	err = forEachRow(ctx, transaction, "select ingredient_id, name from ingredient_alias order by rowid", func(rows *sql.Rows) error {
+		var ingredientID sqliteID
+		var name string
+		if err := rows.Scan(&ingredientID, &name); err != nil {
+			return err
+		}
+		if ingredient, found := ingredients[fromSqliteID(ingredientID)]; found {
+			ingredient.aliases = append(ingredient.aliases, name)
+		}
+		owners[similarity.NormalizeName(name)] = fromSqliteID(ingredientID)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingredient aliases: %w", err)
+	}
+
+	rows := make([]model.IngredientImportRow, len(upserts))
+	for i, upsert := range upserts {
+		row := &rows[i]
+		row.Line, row.ID, row.Name = upsert.Line, upsert.ID, upsert.Name
+
+		ID := upsert.ID
+		if ID == "" {
+			// the ingredient is found by its name or one of its aliases, whose name is then kept
+			ID = owners[similarity.NormalizeName(upsert.Name)]
+			if existing, found := ingredients[ID]; found && similarity.NormalizeName(existing.name) != similarity.NormalizeName(upsert.Name) {
+				upsert.Name = existing.name
+			}
+		} else if _, found := ingredients[ID]; !found {
+			row.Status, row.Error = model.IngredientImportError, "ingredient ["+ID+"] not found"
+			continue
+		}
+		if conflict := findConflict(owners, ID, append([]string{upsert.Name}, upsert.Aliases...)); conflict != "" {
+			row.Status, row.Error = model.IngredientImportError, conflict
+			continue
+		}
+		updated := &upsertedIngredient{
+			name:       upsert.Name,
+			categoryID: upsert.CategoryID,
+			aliases:    distinctAliases(upsert.Name, upsert.Aliases),
+		}
+
+		existing, found := ingredients[ID]
+		switch {
+		case !found:
+			if ID, err = insertUpsertedIngredient(ctx, transaction, *updated); err != nil {
+				return nil, err
+			}
+			row.Status = model.IngredientImportCreated
+		case existing.name == updated.name && existing.categoryID == updated.categoryID && equalAliases(existing.aliases, updated.aliases):
+			row.Status = model.IngredientImportUnchanged
+		default:
+			if err := updateUpsertedIngredient(ctx, transaction, ID, *updated); err != nil {
+				return nil, err
+			}
+			row.Status = model.IngredientImportUpdated
+			for _, name := range append([]string{existing.name}, existing.aliases...) {
+				delete(owners, similarity.NormalizeName(name))
+			}
+		}
+		row.ID = ID
+		ingredients[ID] = updated
+		for _, name := range append([]string{updated.name}, updated.aliases...) {
+			owners[similarity.NormalizeName(name)] = ID
+		}
+	}
+	return rows, nil
+}
+
+// findConflict returns why the given names cannot be given to an ingredient, if one of them belongs to another ingredient
+func findConflict(owners map[string]string, ID string, names []string) string {
+	for _, name := range names {
+		if owner, found := owners[similarity.NormalizeName(name)]; found && owner != ID {
+			return "[" + name + "] is already the name or an alias of ingredient [" + owner + "]"
+		}
+	}
+	return ""
+}
+
+// distinctAliases trims aliases and removes the ones that are blank, duplicated or the same as the name
+func distinctAliases(name string, aliases []string) []string {
+	var distinct []string
+	seen := map[string]bool{similarity.NormalizeName(name): true}
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if normalized := similarity.NormalizeName(alias); normalized != "" && !seen[normalized] {
+			seen[normalized] = true
+			distinct = append(distinct, alias)
+		}
+	}
+	return distinct
+}
+
+func equalAliases(first, second []string) bool {
+	if len(first) != len(second) {
+		return false
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func insertUpsertedIngredient(ctx context.Context, transaction *sql.Tx, ingredient upsertedIngredient) (string, error) {
+	categoryID, err := toNullableSqliteID(ingredient.categoryID)
+	if err != nil {
+		return "", &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ingredient.categoryID),
+			Cause:   err,
+		}
+	}
+	result, err := transaction.ExecContext(ctx, "insert into ingredient(name, category_id) values(?, ?)", ingredient.name, categoryID)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute insert statement: %w", err)
+	}
+	ID, err := result.LastInsertId()
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve ID of inserted row: %w", err)
+	}
+	if err := insertAliases(ctx, transaction, sqliteID(ID), ingredient.aliases); err != nil {
+		return "", err
+	}
+	return fromSqliteID(sqliteID(ID)), nil
+}
+
+func updateUpsertedIngredient(ctx context.Context, transaction *sql.Tx, ID string, ingredient upsertedIngredient) error {
+	oid, err := toSqliteID(ID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ID),
+			Cause:   err,
+		}
+	}
+	categoryID, err := toNullableSqliteID(ingredient.categoryID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ingredient.categoryID),
+			Cause:   err,
+		}
+	}
+	if _, err := transaction.ExecContext(ctx, "update ingredient set name=?, category_id=? where id=?", ingredient.name, categoryID, oid); err != nil {
+		return fmt.Errorf("failed to execute update statement: %w", err)
+	}
+	if _, err := transaction.ExecContext(ctx, "delete from ingredient_alias where ingredient_id=?", oid); err != nil {
+		return fmt.Errorf("failed to execute delete statement: %w", err)
+	}
+	return insertAliases(ctx, transaction, oid, ingredient.aliases)
+}
+
+func insertAliases(ctx context.Context, transaction *sql.Tx, ingredientID sqliteID, aliases []string) error {
+	for _, alias := range aliases {
+		if _, err := transaction.ExecContext(ctx, "insert into ingredient_alias(ingredient_id, name) values(?, ?)", ingredientID, alias); err != nil {
+			return fmt.Errorf("failed to execute insert statement: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/remieven/miam/model"
+)
+
+// IntegrityDao finds and repairs the inconsistencies that the schema does not prevent, since it has no foreign keys
+type IntegrityDao struct {
+	holder *DatabaseHolder
+}
+
+// NewIntegrityDao creates a new integrity dao
+func NewIntegrityDao(holder *DatabaseHolder) *IntegrityDao {
+	return &IntegrityDao{holder}
+}
+
+// integrityCheck finds a kind of problem in a table and repairs it
+type integrityCheck struct {
+	kind  string
+	table string
+	// query lists the problems, selecting an ID and a message for each of them
+	query string
+	// repair is the statement repairing all the problems listed by the query
+	repair string
+}
+
+// emptyName is the condition on the rows without name
+const emptyName = "trim(coalesce(name, '')) = ''"
+
+// integrityChecks are run in that order; repairs deleting rows come first, so that the links they delete are not deduplicated for nothing
+var integrityChecks = []integrityCheck{
+	{
+		kind:   model.IntegrityOrphan,
+		table:  "recipe_ingredient",
+		query:  "select recipe_id, 'ingredient of missing recipe' from recipe_ingredient where recipe_id not in (select id from recipe)",
+		repair: "delete from recipe_ingredient where recipe_id not in (select id from recipe)",
+	},
+	{
+		kind:   model.IntegrityOrphan,
+		table:  "recipe_ingredient",
+		query:  "select recipe_id, 'link to missing ingredient ' || ingredient_id from recipe_ingredient where ingredient_id is not null and ingredient_id not in (select id from ingredient)",
+		repair: "delete from recipe_ingredient where ingredient_id is not null and ingredient_id not in (select id from ingredient)",
+	},
+	{
+		kind:   model.IntegrityOrphan,
+		table:  "recipe_ingredient",
+		query:  "select recipe_id, 'link to missing sub-recipe ' || sub_recipe_id from recipe_ingredient where sub_recipe_id is not null and sub_recipe_id not in (select id from recipe)",
+		repair: "delete from recipe_ingredient where sub_recipe_id is not null and sub_recipe_id not in (select id from recipe)",
+	},
+	{
+		kind:   model.IntegrityOrphan,
+		table:  "recipe_ingredient",
+		query:  "select recipe_id, 'link to neither an ingredient nor a sub-recipe' from recipe_ingredient where ingredient_id is null and sub_recipe_id is null",
+		repair: "delete from recipe_ingredient where ingredient_id is null and sub_recipe_id is null",
+	},
+	{
+		kind:   model.IntegrityOrphan,
+		table:  "recipe_equipment",
+		query:  "select recipe_id, 'equipment of missing recipe' from recipe_equipment where recipe_id not in (select id from recipe)",
+		repair: "delete from recipe_equipment where recipe_id not in (select id from recipe)",
+	},
+	{
+		kind:   model.IntegrityOrphan,
+		table:  "recipe_equipment",
+		query:  "select recipe_id, 'link to missing equipment ' || equipment_id from recipe_equipment where equipment_id not in (select id from equipment)",
+		repair: "delete from recipe_equipment where equipment_id not in (select id from equipment)",
+	},
+	{
+		kind:   model.IntegrityOrphan,
+		table:  "ingredient_alias",
+		query:  "select ingredient_id, 'alias [' || name || '] of missing ingredient' from ingredient_alias where ingredient_id not in (select id from ingredient)",
+		repair: "delete from ingredient_alias where ingredient_id not in (select id from ingredient)",
+	},
+	{
+		kind:   model.IntegrityOrphan,
+		table:  "recipe_revision",
+		query:  "select id, 'revision of missing recipe ' || recipe_id from recipe_revision where recipe_id not in (select id from recipe)",
+		repair: "delete from recipe_revision where recipe_id not in (select id from recipe)",
+	},
+	{
+		kind:   model.IntegrityOrphan,
+		table:  "recipe",
+		query:  "select id, 'variant of missing recipe ' || parent_id from recipe where parent_id is not null and parent_id not in (select id from recipe)",
+		repair: "update recipe set parent_id = null where parent_id is not null and parent_id not in (select id from recipe)",
+	},
+	{
+		kind:   model.IntegrityOrphan,
+		table:  "ingredient",
+		query:  "select id, 'in missing category ' || category_id from ingredient where category_id is not null and category_id not in (select id from category)",
+		repair: "update ingredient set category_id = null where category_id is not null and category_id not in (select id from category)",
+	},
+	{
+		kind:   model.IntegrityDuplicateLink,
+		table:  "recipe_ingredient",
+		query:  "select recipe_id, 'ingredient ' || ingredient_id || ' linked ' || count(*) || ' times' from recipe_ingredient where ingredient_id is not null group by recipe_id, ingredient_id having count(*) > 1",
+		repair: "delete from recipe_ingredient where ingredient_id is not null and rowid not in (select min(rowid) from recipe_ingredient where ingredient_id is not null group by recipe_id, ingredient_id)",
+	},
+	{
+		kind:   model.IntegrityDuplicateLink,
+		table:  "recipe_ingredient",
+		query:  "select recipe_id, 'sub-recipe ' || sub_recipe_id || ' linked ' || count(*) || ' times' from recipe_ingredient where sub_recipe_id is not null group by recipe_id, sub_recipe_id having count(*) > 1",
+		repair: "delete from recipe_ingredient where sub_recipe_id is not null and rowid not in (select min(rowid) from recipe_ingredient where sub_recipe_id is not null group by recipe_id, sub_recipe_id)",
+	},
+	{
+		kind:   model.IntegrityDuplicateLink,
+		table:  "recipe_equipment",
+		query:  "select recipe_id, 'equipment ' || equipment_id || ' linked ' || count(*) || ' times' from recipe_equipment group by recipe_id, equipment_id having count(*) > 1",
+		repair: "delete from recipe_equipment where rowid not in (select min(rowid) from recipe_equipment group by recipe_id, equipment_id)",
+	},
+	{
+		kind:   model.IntegrityDuplicateLink,
+		table:  "ingredient_alias",
+		query:  "select ingredient_id, 'alias [' || name || '] stored ' || count(*) || ' times' from ingredient_alias group by ingredient_id, name having count(*) > 1",
+		repair: "delete from ingredient_alias where rowid not in (select min(rowid) from ingredient_alias group by ingredient_id, name)",
+	},
+	{
+		kind:   model.IntegrityEmptyName,
+		table:  "recipe",
+		query:  "select id, 'recipe without name' from recipe where " + emptyName,
+		repair: "update recipe set name = 'sans nom ' || id where " + emptyName,
+	},
+	{
+		kind:   model.IntegrityEmptyName,
+		table:  "ingredient",
+		query:  "select id, 'ingredient without name' from ingredient where " + emptyName,
+		repair: "update ingredient set name = 'sans nom ' || id where " + emptyName,
+	},
+	{
+		kind:   model.IntegrityEmptyName,
+		table:  "equipment",
+		query:  "select id, 'equipment without name' from equipment where " + emptyName,
+		repair: "update equipment set name = 'sans nom ' || id where " + emptyName,
+	},
+	{
+		kind:   model.IntegrityEmptyName,
+		table:  "category",
+		query:  "select id, 'category without name' from category where " + emptyName,
+		repair: "update category set name = 'sans nom ' || id where " + emptyName,
+	},
+}
+
+// FindProblems lists the problems of the database file reported by SQLite, then the inconsistencies of the data
+func (dao *IntegrityDao) FindProblems(ctx context.Context) ([]model.IntegrityProblem, error) {
+	corruptions, err := dao.holder.CheckIntegrity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	problems := make([]model.IntegrityProblem, 0, len(corruptions))
+	for _, corruption := range corruptions {
+		problems = append(problems, model.IntegrityProblem{
+			Kind:    model.IntegrityCorruption,
+			Message: corruption,
+		})
+	}
+	for _, check := range integrityChecks {
+		if err := forEachRow(ctx, dao.holder.DB, check.query, func(rows *sql.Rows) error {
+			var (
+				ID      sql.NullInt64
+				message sql.NullString
+			)
+			if err := rows.Scan(&ID, &message); err != nil {
+				return err
+			}
+			problems = append(problems, model.IntegrityProblem{
+				Kind:    check.kind,
+				Table:   check.table,
+				ID:      fromNullableSqliteID(ID),
+				Message: message.String,
+			})
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("failed to check %s of table [%s]: %w", check.kind, check.table, err)
+		}
+	}
+	return problems, nil
+}
+
+// Repair repairs all the inconsistencies of the data in a single transaction:
+// orphan rows are deleted, references to missing rows are cleared, a single copy of duplicated links is kept, and rows without name are named after their ID
+func (dao *IntegrityDao) Repair(ctx context.Context) error {
+	transaction, err := dao.holder.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	for _, check := range integrityChecks {
+		if _, err := transaction.ExecContext(ctx, check.repair); err != nil {
+			rollback(transaction)
+			return fmt.Errorf("failed to repair %s of table [%s]: %w", check.kind, check.table, err)
+		}
+	}
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// backupPagesPerStep is the number of pages copied at once by a backup; the database is only locked while copying them,
+// and the backup restarts from the beginning when the database is written in between
+const backupPagesPerStep = 256
+
+// Backup copies the database to the given file with the online backup API of SQLite, so that the copy is consistent without stopping writes.
+// The copy is written to a temporary file first, so that an existing file at that path is only replaced by a complete backup.
+func (holder *DatabaseHolder) Backup(ctx context.Context, destinationPath string) (err error) {
+	temporaryPath := destinationPath + ".tmp"
+	defer func() {
+		if err != nil {
+			_ = os.Remove(temporaryPath)
+		}
+	}()
+	if err := holder.backupTo(ctx, temporaryPath); err != nil {
+		return err
+	}
+	if err := os.Rename(temporaryPath, destinationPath); err != nil {
+		return fmt.Errorf("failed to move backup to [%s]: %w", destinationPath, err)
+	}
+	return nil
+}
+
+func (holder *DatabaseHolder) backupTo(ctx context.Context, destinationPath string) error {
+	if err := os.Remove(destinationPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove previous temporary backup: %w", err)
+	}
+	destination, err := sql.Open(driverName, destinationPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup database: %w", err)
+	}
+	defer destination.Close()
+	destinationConnection, err := destination.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to backup database: %w", err)
+	}
+	defer destinationConnection.Close()
+	sourceConnection, err := holder.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer sourceConnection.Close()
+
+	return destinationConnection.Raw(func(destinationDriverConnection any) error {
+		return sourceConnection.Raw(func(sourceDriverConnection any) error {
+			destinationSQLite, ok := destinationDriverConnection.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("backup database is not a sqlite database")
+			}
+			sourceSQLite, ok := sourceDriverConnection.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("database is not a sqlite database")
+			}
+			backup, err := destinationSQLite.Backup("main", sourceSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+			for done := false; !done; {
+				if done, err = backup.Step(backupPagesPerStep); err != nil {
+					_ = backup.Close()
+					return fmt.Errorf("failed to copy database: %w", err)
+				}
+				if !done {
+					select {
+					case <-ctx.Done():
+						_ = backup.Close()
+						return ctx.Err()
+					case <-time.After(time.Millisecond):
+					}
+				}
+			}
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+// CheckIntegrity runs the integrity check of SQLite, returning the problems it found in the database file
+func (holder *DatabaseHolder) CheckIntegrity(ctx context.Context) ([]string, error) {
+	var problems []string
+	if err := forEachRow(ctx, holder.DB, "pragma integrity_check", func(rows *sql.Rows) error {
+		var problem string
+		if err := rows.Scan(&problem); err != nil {
+			return err
+		}
+		if problem != "ok" {
+			problems = append(problems, problem)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	return problems, nil
+}
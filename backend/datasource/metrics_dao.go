@@ -0,0 +1,36 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/remieven/miam/model"
+)
+
+// MetricsDao reads the figures of the database exposed as metrics
+type MetricsDao struct {
+	holder *DatabaseHolder
+}
+
+// NewMetricsDao returns a new metrics dao; it must be created after the other daos, which create the tables
+func NewMetricsDao(holder *DatabaseHolder) *MetricsDao {
+	return &MetricsDao{holder}
+}
+
+// CountRows counts the recipes, in the trash or not, and the ingredients in a single query
+func (dao *MetricsDao) CountRows(ctx context.Context) (*model.RowCounts, error) {
+	var counts model.RowCounts
+	if err := dao.holder.DB.QueryRowContext(ctx, `select
+		(select count(*) from recipe where deleted_at is null),
+		(select count(*) from recipe where deleted_at is not null),
+		(select count(*) from ingredient)`).Scan(&counts.Recipes, &counts.DeletedRecipes, &counts.Ingredients); err != nil {
+		return nil, fmt.Errorf("failed to count rows: %w", err)
+	}
+	return &counts, nil
+}
+
+// Stats returns the statistics of the connection pool of the database
+func (dao *MetricsDao) Stats() sql.DBStats {
+	return dao.holder.DB.Stats()
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/remieven/miam/model"
 	"github.com/remieven/miam/pb-lite/failure"
@@ -16,17 +18,33 @@ import (
 type RecipeDao struct {
 	holder              *DatabaseHolder
 	recipeIngredientDao *RecipeIngredientDao
+	recipeRevisionDao   *RecipeRevisionDao
+	equipmentDao        *EquipmentDao
 }
 
 // NewRecipeDao returns a new recipe dao
-func NewRecipeDao(holder *DatabaseHolder, recipeIngredientDao *RecipeIngredientDao) (*RecipeDao, error) {
+func NewRecipeDao(holder *DatabaseHolder, recipeIngredientDao *RecipeIngredientDao, recipeRevisionDao *RecipeRevisionDao, equipmentDao *EquipmentDao) (*RecipeDao, error) {
 	initStatement := `
-		create table if not exists recipe (id integer primary key asc, name text, how_to text);
+		create table if not exists recipe (id integer primary key asc, name text, how_to text, deleted_at int, parent_id int);
 	`
 	if _, err := holder.DB.Exec(initStatement); err != nil {
 		return nil, fmt.Errorf("failed to create recipe table: %w", err)
 	}
-	return &RecipeDao{holder, recipeIngredientDao}, nil
+	if err := addColumnIfMissing(holder.DB, "recipe", "deleted_at", "int"); err != nil {
+		return nil, err
+	}
+	if err := addColumnIfMissing(holder.DB, "recipe", "parent_id", "int"); err != nil {
+		return nil, err
+	}
+	for column, definition := range map[string]string{"yield": "text", "prep_minutes": "int", "cook_minutes": "int", "total_minutes": "int"} {
+		if err := addColumnIfMissing(holder.DB, "recipe", column, definition); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := holder.DB.Exec("create index if not exists recipe_parent_id_index on recipe(parent_id)"); err != nil {
+		return nil, fmt.Errorf("failed to create recipe parent_id index: %w", err)
+	}
+	return &RecipeDao{holder, recipeIngredientDao, recipeRevisionDao, equipmentDao}, nil
 }
 
 // GetRecipe returns the recipe with the given ID or nil
@@ -38,10 +56,12 @@ func (dao *RecipeDao) GetRecipe(ctx context.Context, ID string) (*model.Recipe,
 			Cause:   err,
 		}
 	}
-	row := dao.holder.DB.QueryRowContext(ctx, "select name, how_to from recipe where id=?", oid)
+	row := dao.holder.DB.QueryRowContext(ctx, "select name, how_to, parent_id, "+recipeDetailColumns+" from recipe where id=? and deleted_at is null", oid)
 	var name, howTo string
+	var parentID sql.NullInt64
+	var details recipeDetails
 
-	if err := row.Scan(&name, &howTo); errors.Is(err, sql.ErrNoRows) {
+	if err := row.Scan(append([]any{&name, &howTo, &parentID}, details.targets()...)...); errors.Is(err, sql.ErrNoRows) {
 		return nil, &failure.ResourceNotFoundError{
 			Message: "recipe [" + ID + "] not found",
 		}
@@ -52,18 +72,48 @@ func (dao *RecipeDao) GetRecipe(ctx context.Context, ID string) (*model.Recipe,
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve recipe ingredients: %w", err)
 	}
+	equipment, err := dao.equipmentDao.GetRecipeEquipment(ctx, ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve recipe equipment: %w", err)
+	}
 
-	return &model.Recipe{
+	recipe := &model.Recipe{
 		ID: ID,
 		BaseRecipe: model.BaseRecipe{
 			Name:        name,
 			HowTo:       howTo,
 			Ingredients: ingredients,
+			ParentID:    fromNullableSqliteID(parentID),
+			Equipment:   equipment,
 		},
-	}, nil
+	}
+	details.apply(&recipe.BaseRecipe)
+	return recipe, nil
 }
 
-// GetRecipes returns the recipes with the given IDs or an empty slice
+// GetRecipeParent returns the parent ID of the recipe with the given ID and whether the recipe is in the trash
+func (dao *RecipeDao) GetRecipeParent(ctx context.Context, ID string) (string, bool, error) {
+	oid, err := toSqliteID(ID)
+	if err != nil {
+		return "", false, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ID),
+			Cause:   err,
+		}
+	}
+	var parentID sql.NullInt64
+	var deleted bool
+	row := dao.holder.DB.QueryRowContext(ctx, "select parent_id, deleted_at is not null from recipe where id=?", oid)
+	if err := row.Scan(&parentID, &deleted); errors.Is(err, sql.ErrNoRows) {
+		return "", false, &failure.ResourceNotFoundError{
+			Message: "recipe [" + ID + "] not found",
+		}
+	} else if err != nil {
+		return "", false, fmt.Errorf("failed to retrieve recipe parent: %w", err)
+	}
+	return fromNullableSqliteID(parentID), deleted, nil
+}
+
+// GetRecipes returns the recipes with the given IDs, in the same order, or an empty slice
 func (dao *RecipeDao) GetRecipes(ctx context.Context, IDs []string) ([]model.Recipe, error) {
 	if len(IDs) == 0 {
 		return []model.Recipe{}, nil
@@ -79,16 +129,46 @@ func (dao *RecipeDao) GetRecipes(ctx context.Context, IDs []string) ([]model.Rec
 			}
 		}
 	}
-	rows, err := dao.holder.DB.QueryContext(ctx, "select id, name, how_to from recipe where id in ("+queryParamPlaceholders+")", queryParams...)
+	recipes, err := dao.queryRecipes(ctx, "select id, name, how_to, parent_id, "+recipeDetailColumns+" from recipe where deleted_at is null and id in ("+queryParamPlaceholders+")", queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	positions := make(map[string]int, len(IDs))
+	for i, ID := range IDs {
+		positions[ID] = i
+	}
+	sort.SliceStable(recipes, func(i, j int) bool {
+		return positions[recipes[i].ID] < positions[recipes[j].ID]
+	})
+	return recipes, nil
+}
+
+// GetRecipeVariants returns the recipes whose parent is the recipe with the given ID
+func (dao *RecipeDao) GetRecipeVariants(ctx context.Context, parentID string) ([]model.Recipe, error) {
+	oid, err := toSqliteID(parentID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", parentID),
+			Cause:   err,
+		}
+	}
+	return dao.queryRecipes(ctx, "select id, name, how_to, parent_id, "+recipeDetailColumns+" from recipe where deleted_at is null and parent_id=? order by id", oid)
+}
+
+// queryRecipes runs a query selecting the id, name, how_to, parent_id and detail columns of recipes and hydrates them with their ingredients
+func (dao *RecipeDao) queryRecipes(ctx context.Context, query string, queryParams ...any) ([]model.Recipe, error) {
+	rows, err := dao.holder.DB.QueryContext(ctx, query, queryParams...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve recipes: %w", err)
 	}
 	defer rows.Close()
-	results := make([]model.Recipe, 0, len(IDs))
+	results := make([]model.Recipe, 0)
 	for rows.Next() {
 		var id sqliteID
 		var name, howTo string
-		if err = rows.Scan(&id, &name, &howTo); err != nil {
+		var parentID sql.NullInt64
+		var details recipeDetails
+		if err = rows.Scan(append([]any{&id, &name, &howTo, &parentID}, details.targets()...)...); err != nil {
 			return nil, fmt.Errorf("failed to scan recipe row: %w", err)
 		}
 		recipeID := fromSqliteID(id)
@@ -96,15 +176,23 @@ func (dao *RecipeDao) GetRecipes(ctx context.Context, IDs []string) ([]model.Rec
 		if err != nil {
 			return nil, fmt.Errorf("failed to retrieve recipe ingredients: %w", err)
 		}
+		equipment, err := dao.equipmentDao.GetRecipeEquipment(ctx, recipeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve recipe equipment: %w", err)
+		}
 
-		results = append(results, model.Recipe{
+		recipe := model.Recipe{
 			ID: recipeID,
 			BaseRecipe: model.BaseRecipe{
 				Name:        name,
 				HowTo:       howTo,
 				Ingredients: ingredients,
+				ParentID:    fromNullableSqliteID(parentID),
+				Equipment:   equipment,
 			},
-		})
+		}
+		details.apply(&recipe.BaseRecipe)
+		results = append(results, recipe)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -114,46 +202,224 @@ func (dao *RecipeDao) GetRecipes(ctx context.Context, IDs []string) ([]model.Rec
 	return results, nil
 }
 
-// AddRecipe adds the given recipe
+// AddRecipe adds the given recipe and saves a snapshot of it as its first revision
 func (dao *RecipeDao) AddRecipe(ctx context.Context, recipe *model.BaseRecipe) (string, error) {
 	transaction, err := dao.holder.DB.Begin()
 	if err != nil {
 		return "", fmt.Errorf("failed to init transaction: %w", err)
 	}
-	insertStatement, err := transaction.PrepareContext(ctx, "insert into recipe(name, how_to) values (?, ?)")
+	recipeID, err := dao.addRecipeInTransaction(ctx, transaction, recipe)
+	if err != nil {
+		rollback(transaction)
+		return "", err
+	}
+	if err := transaction.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return recipeID, nil
+}
+
+// AddRecipes adds the given recipes in a single transaction, so that either all of them or none are added, and returns their IDs in the same order
+func (dao *RecipeDao) AddRecipes(ctx context.Context, recipes []model.BaseRecipe) ([]string, error) {
+	transaction, err := dao.holder.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to init transaction: %w", err)
+	}
+	recipeIDs := make([]string, 0, len(recipes))
+	for i := range recipes {
+		recipeID, err := dao.addRecipeInTransaction(ctx, transaction, &recipes[i])
+		if err != nil {
+			rollback(transaction)
+			return nil, fmt.Errorf("failed to add recipe [%s]: %w", recipes[i].Name, err)
+		}
+		recipeIDs = append(recipeIDs, recipeID)
+	}
+	if err := transaction.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return recipeIDs, nil
+}
+
+// addRecipeInTransaction adds a recipe along with its first revision as part of a transaction, which the caller rolls back on error
+func (dao *RecipeDao) addRecipeInTransaction(ctx context.Context, transaction *sql.Tx, recipe *model.BaseRecipe) (string, error) {
+	parentID, err := toNullableSqliteID(recipe.ParentID)
+	if err != nil {
+		return "", &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", recipe.ParentID),
+			Cause:   err,
+		}
+	}
+	insertStatement, err := transaction.PrepareContext(ctx, "insert into recipe(name, how_to, parent_id, "+recipeDetailColumns+") values (?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return "", fmt.Errorf("failed to prepare recipe statement: %w", err)
 	}
 	defer insertStatement.Close()
 
-	result, err := insertStatement.ExecContext(ctx, recipe.Name, recipe.HowTo)
+	result, err := insertStatement.ExecContext(ctx, append([]any{recipe.Name, recipe.HowTo, parentID}, toRecipeDetails(*recipe).values()...)...)
 	if err != nil {
-		rollback(transaction)
 		return "", fmt.Errorf("failed to execute insert recipe statement: %w", err)
 	}
 	id, err := result.LastInsertId()
 	if err != nil {
-		rollback(transaction)
 		return "", fmt.Errorf("failed to retrieve ID of inserted recipe: %w", err)
 	}
 	recipeID := fromSqliteID(sqliteID(id))
 
-	for _, recipeIngredient := range recipe.Ingredients {
-		if _, err := dao.recipeIngredientDao.AddRecipeIngredient(ctx, transaction, recipeID, recipeIngredient); err != nil {
-			rollback(transaction)
+	for i, recipeIngredient := range recipe.Ingredients {
+		ingredientID, err := dao.recipeIngredientDao.AddRecipeIngredient(ctx, transaction, recipeID, recipeIngredient)
+		if err != nil {
 			return "", fmt.Errorf("failed to add ingredient: %w", err)
 		}
+		recipe.Ingredients[i].ID = ingredientID
 	}
 
-	if err := transaction.Commit(); err != nil {
-		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	if err := dao.equipmentDao.SetRecipeEquipment(ctx, transaction, recipeID, recipe.Equipment); err != nil {
+		return "", fmt.Errorf("failed to set recipe equipment: %w", err)
+	}
+
+	if _, err := dao.recipeRevisionDao.AddRecipeRevision(ctx, transaction, model.Recipe{ID: recipeID, BaseRecipe: *recipe}); err != nil {
+		return "", fmt.Errorf("failed to save recipe revision: %w", err)
 	}
 
 	return recipeID, nil
 }
 
-// DeleteRecipe deletes a recipe and its ingredients
+// DeleteRecipe moves a recipe to the trash
 func (dao *RecipeDao) DeleteRecipe(ctx context.Context, ID string) error {
+	oid, err := toSqliteID(ID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ID),
+			Cause:   err,
+		}
+	}
+	updateStatement, err := dao.holder.DB.PrepareContext(ctx, "update recipe set deleted_at=?2 where id=?1 and deleted_at is null")
+	if err != nil {
+		return fmt.Errorf("failed to prepare update statement: %w", err)
+	}
+	defer updateStatement.Close()
+
+	result, err := updateStatement.ExecContext(ctx, oid, time.Now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to execute update statement: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to retrieve number of rows affected by update statement: %w", err)
+	case rowsAffected == 0:
+		return &failure.ResourceNotFoundError{
+			Message: "recipe [" + ID + "] not found",
+		}
+	}
+	return nil
+}
+
+// RestoreRecipe takes a recipe out of the trash
+func (dao *RecipeDao) RestoreRecipe(ctx context.Context, ID string) error {
+	oid, err := toSqliteID(ID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ID),
+			Cause:   err,
+		}
+	}
+	updateStatement, err := dao.holder.DB.PrepareContext(ctx, "update recipe set deleted_at=null where id=? and deleted_at is not null")
+	if err != nil {
+		return fmt.Errorf("failed to prepare update statement: %w", err)
+	}
+	defer updateStatement.Close()
+
+	result, err := updateStatement.ExecContext(ctx, oid)
+	if err != nil {
+		return fmt.Errorf("failed to execute update statement: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to retrieve number of rows affected by update statement: %w", err)
+	case rowsAffected == 0:
+		return &failure.ResourceNotFoundError{
+			Message: "recipe [" + ID + "] not found in trash",
+		}
+	}
+	return nil
+}
+
+// GetDeletedRecipes returns the recipes that are in the trash, most recently deleted first
+func (dao *RecipeDao) GetDeletedRecipes(ctx context.Context) ([]model.DeletedRecipe, error) {
+	rows, err := dao.holder.DB.QueryContext(ctx, "select id, name, how_to, parent_id, deleted_at, "+recipeDetailColumns+" from recipe where deleted_at is not null order by deleted_at desc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted recipes: %w", err)
+	}
+	defer rows.Close()
+	results := make([]model.DeletedRecipe, 0)
+	for rows.Next() {
+		var id sqliteID
+		var name, howTo string
+		var parentID sql.NullInt64
+		var deletedAt int64
+		var details recipeDetails
+		if err = rows.Scan(append([]any{&id, &name, &howTo, &parentID, &deletedAt}, details.targets()...)...); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted recipe row: %w", err)
+		}
+		recipeID := fromSqliteID(id)
+		ingredients, err := dao.recipeIngredientDao.GetRecipeIngredients(ctx, recipeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve recipe ingredients: %w", err)
+		}
+		equipment, err := dao.equipmentDao.GetRecipeEquipment(ctx, recipeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve recipe equipment: %w", err)
+		}
+
+		deletedRecipe := model.DeletedRecipe{
+			Recipe: model.Recipe{
+				ID: recipeID,
+				BaseRecipe: model.BaseRecipe{
+					Name:        name,
+					HowTo:       howTo,
+					Ingredients: ingredients,
+					ParentID:    fromNullableSqliteID(parentID),
+					Equipment:   equipment,
+				},
+			},
+			DeletedAt: time.UnixMilli(deletedAt).UTC(),
+		}
+		details.apply(&deletedRecipe.BaseRecipe)
+		results = append(results, deletedRecipe)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("got an error while iterating on deleted recipe rows: %w", err)
+	}
+	return results, nil
+}
+
+// ListRecipeIdsDeletedBefore returns the ids of the recipes that were moved to the trash before the given time
+func (dao *RecipeDao) ListRecipeIdsDeletedBefore(ctx context.Context, before time.Time) ([]string, error) {
+	rows, err := dao.holder.DB.QueryContext(ctx, "select id from recipe where deleted_at < ?", before.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted recipe ids: %w", err)
+	}
+
+	defer rows.Close()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan recipe id row: %w", err)
+		}
+		ids = append(ids, fromSqliteID(id))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("got an error while iterating on recipe id rows: %w", err)
+	}
+
+	return ids, nil
+}
+
+// PurgeRecipe permanently deletes a recipe that is in the trash, along with its ingredients, its equipment and its revisions
+func (dao *RecipeDao) PurgeRecipe(ctx context.Context, ID string) error {
 	oid, err := toSqliteID(ID)
 	if err != nil {
 		return &failure.InvalidValueError{
@@ -165,68 +431,135 @@ func (dao *RecipeDao) DeleteRecipe(ctx context.Context, ID string) error {
 	if err != nil {
 		return fmt.Errorf("failed to init transaction: %w", err)
 	}
-	deleteStatement, err := transaction.PrepareContext(ctx, "delete from recipe where id=?")
+	deleteStatement, err := transaction.PrepareContext(ctx, "delete from recipe where id=? and deleted_at is not null")
 	if err != nil {
 		return fmt.Errorf("failed to prepare delete statement: %w", err)
 	}
 	defer deleteStatement.Close()
 
-	if _, err := deleteStatement.ExecContext(ctx, oid); err != nil {
+	result, err := deleteStatement.ExecContext(ctx, oid)
+	if err != nil {
 		rollback(transaction)
 		return fmt.Errorf("failed to execute delete statement: %w", err)
 	}
+	rowsAffected, err := result.RowsAffected()
+	switch {
+	case err != nil:
+		rollback(transaction)
+		return fmt.Errorf("failed to retrieve number of rows affected by delete statement: %w", err)
+	case rowsAffected == 0:
+		rollback(transaction)
+		return &failure.ResourceNotFoundError{
+			Message: "recipe [" + ID + "] not found in trash",
+		}
+	}
 	if err = dao.recipeIngredientDao.DeleteRecipeIngredients(ctx, transaction, ID); err != nil {
 		rollback(transaction)
 		return fmt.Errorf("failed to delete recipe ingredients: %w", err)
 	}
+	if err = dao.equipmentDao.DeleteRecipeEquipment(ctx, transaction, ID); err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to delete recipe equipment: %w", err)
+	}
+	if err = dao.recipeRevisionDao.DeleteRecipeRevisions(ctx, transaction, ID); err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to delete recipe revisions: %w", err)
+	}
+	if _, err = transaction.ExecContext(ctx, "update recipe set parent_id=null where parent_id=?", oid); err != nil {
+		rollback(transaction)
+		return fmt.Errorf("failed to detach variants of recipe: %w", err)
+	}
 
 	return transaction.Commit()
 }
 
-// UpdateRecipe updates a recipe
+// UpdateRecipe updates a recipe and saves a snapshot of its new version as a revision
 func (dao *RecipeDao) UpdateRecipe(ctx context.Context, recipe model.Recipe) (*model.Recipe, error) {
 	transaction, err := dao.holder.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("failed to init transaction: %w", err)
 	}
-	updateStatement, err := transaction.PrepareContext(ctx, "update recipe set (name, how_to) = (?2, ?3) where id=?1")
+	if err := dao.updateRecipeInTransaction(ctx, transaction, &recipe); err != nil {
+		rollback(transaction)
+		return nil, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &recipe, nil
+}
+
+// SaveRecipes adds or overwrites several recipes in a single transaction, so that either all of them or none are saved, and returns their IDs in the same order.
+// The recipes are built one at a time from the IDs of the recipes saved before them, so that they can reference these recipes;
+// a recipe with an ID overwrites the existing recipe with this ID, the others are added.
+func (dao *RecipeDao) SaveRecipes(ctx context.Context, count int, build func(savedIDs []string) model.Recipe) ([]string, error) {
+	transaction, err := dao.holder.DB.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare update statement: %w", err)
+		return nil, fmt.Errorf("failed to init transaction: %w", err)
 	}
+	recipeIDs := make([]string, 0, count)
+	for len(recipeIDs) < count {
+		recipe := build(recipeIDs)
+		if recipe.ID != "" {
+			if err := dao.updateRecipeInTransaction(ctx, transaction, &recipe); err != nil {
+				rollback(transaction)
+				return nil, fmt.Errorf("failed to overwrite recipe [%s]: %w", recipe.Name, err)
+			}
+		} else if recipe.ID, err = dao.addRecipeInTransaction(ctx, transaction, &recipe.BaseRecipe); err != nil {
+			rollback(transaction)
+			return nil, fmt.Errorf("failed to add recipe [%s]: %w", recipe.Name, err)
+		}
+		recipeIDs = append(recipeIDs, recipe.ID)
+	}
+	if err := transaction.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return recipeIDs, nil
+}
 
-	result, err := updateStatement.ExecContext(ctx, recipe.ID, recipe.Name, recipe.HowTo)
+// updateRecipeInTransaction updates a recipe along with its ingredients, equipment and revisions as part of a transaction, which the caller rolls back on error
+func (dao *RecipeDao) updateRecipeInTransaction(ctx context.Context, transaction *sql.Tx, recipe *model.Recipe) error {
+	parentID, err := toNullableSqliteID(recipe.ParentID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", recipe.ParentID),
+			Cause:   err,
+		}
+	}
+	updateStatement, err := transaction.PrepareContext(ctx, "update recipe set (name, how_to, parent_id, "+recipeDetailColumns+") = (?2, ?3, ?4, ?5, ?6, ?7, ?8) where id=?1 and deleted_at is null")
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute update statement: %w", err)
+		return fmt.Errorf("failed to prepare update statement: %w", err)
+	}
+	defer updateStatement.Close()
+
+	result, err := updateStatement.ExecContext(ctx, append([]any{recipe.ID, recipe.Name, recipe.HowTo, parentID}, toRecipeDetails(recipe.BaseRecipe).values()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to execute update statement: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	switch {
 	case err != nil:
-		rollback(transaction)
-		return nil, fmt.Errorf("failed to retrieve number of rows affected by update statement: %w", err)
+		return fmt.Errorf("failed to retrieve number of rows affected by update statement: %w", err)
 	case rowsAffected == 0:
-		rollback(transaction)
-		return nil, &failure.ResourceNotFoundError{
+		return &failure.ResourceNotFoundError{
 			Message: "recipe [" + recipe.ID + "] not found",
 		}
 	}
 
 	currentIngredients, err := dao.recipeIngredientDao.GetRecipeIngredients(ctx, recipe.ID)
 	if err != nil {
-		rollback(transaction)
-		return nil, fmt.Errorf("failed to retrieve recipe ingredients: %w", err)
+		return fmt.Errorf("failed to retrieve recipe ingredients: %w", err)
 	}
 	for _, currentIngredient := range currentIngredients {
 		stillThere, newIngredient := containsIngredient(currentIngredient, recipe.Ingredients)
 		if !stillThere {
-			if err = dao.recipeIngredientDao.DeleteRecipeIngredient(ctx, transaction, recipe.ID, currentIngredient.ID); err != nil {
-				rollback(transaction)
-				return nil, fmt.Errorf("failed to remove ingredient from recipe: %w", err)
+			if err = dao.recipeIngredientDao.DeleteRecipeIngredient(ctx, transaction, recipe.ID, currentIngredient); err != nil {
+				return fmt.Errorf("failed to remove ingredient from recipe: %w", err)
 			}
 		} else if newIngredient.Quantity != currentIngredient.Quantity {
 			if err = dao.recipeIngredientDao.UpdateRecipeIngredient(ctx, transaction, recipe.ID, newIngredient); err != nil {
-				rollback(transaction)
-				return nil, fmt.Errorf("failed to update quantity of recipe ingredient: %w", err)
+				return fmt.Errorf("failed to update quantity of recipe ingredient: %w", err)
 			}
 
 		}
@@ -235,85 +568,75 @@ func (dao *RecipeDao) UpdateRecipe(ctx context.Context, recipe model.Recipe) (*m
 		if alreadyThere, _ := containsIngredient(newIngredient, currentIngredients); !alreadyThere {
 			ingredientID, err := dao.recipeIngredientDao.AddRecipeIngredient(ctx, transaction, recipe.ID, newIngredient)
 			if err != nil {
-				rollback(transaction)
-				return nil, fmt.Errorf("failed to add recipe ingredient: %w", err)
+				return fmt.Errorf("failed to add recipe ingredient: %w", err)
 			}
 			recipe.Ingredients[i].ID = ingredientID
 		}
 	}
 
-	if err := transaction.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	if err := dao.equipmentDao.SetRecipeEquipment(ctx, transaction, recipe.ID, recipe.Equipment); err != nil {
+		return fmt.Errorf("failed to set recipe equipment: %w", err)
 	}
-	return &recipe, nil
+
+	if _, err := dao.recipeRevisionDao.AddRecipeRevision(ctx, transaction, *recipe); err != nil {
+		return fmt.Errorf("failed to save recipe revision: %w", err)
+	}
+
+	return nil
 }
 
 // containsIngredient returns whether a given recipe ingredient is present in a slice of recipe ingredients
 func containsIngredient(searched model.RecipeIngredient, ingredients []model.RecipeIngredient) (bool, model.RecipeIngredient) {
 	for _, ingredient := range ingredients {
-		if ingredient.ID == searched.ID {
+		if ingredient.ID == searched.ID && ingredient.SubRecipeID == searched.SubRecipeID {
 			return true, ingredient
 		}
 	}
 	return false, model.RecipeIngredient{}
 }
 
-// GetRandomRecipes search for recipes according to given search criteria
-func (dao *RecipeDao) GetRandomRecipes(ctx context.Context, search model.RecipeSearch) (*model.RecipeSearchResult, error) {
-	results, err := dao.getRandomRecipes(ctx, 10) // 10 is arbitrary
+// GetRandomRecipes returns a few randomly selected recipes along with the total number of recipes;
+// recipes whose seasonal ingredients are all in season during the given month are selected first
+func (dao *RecipeDao) GetRandomRecipes(ctx context.Context, month time.Month) ([]model.Recipe, int, error) {
+	results, err := dao.getRandomRecipes(ctx, 10, month) // 10 is arbitrary
 	if err != nil {
-		return nil, fmt.Errorf("failed to get random recipes: %w", err)
+		return nil, 0, fmt.Errorf("failed to get random recipes: %w", err)
 	}
 	total, err := dao.getRecipeCount(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count recipes: %w", err)
+		return nil, 0, fmt.Errorf("failed to count recipes: %w", err)
 	}
 
-	return &model.RecipeSearchResult{
-		FirstResults: results,
-		Total:        total,
-	}, nil
+	return results, total, nil
 }
 
-// getRandomRecipes returns a given number of randomly selected recipes
-func (dao *RecipeDao) getRandomRecipes(ctx context.Context, numberWanted int) ([]model.Recipe, error) {
-	rows, err := dao.holder.DB.QueryContext(ctx, "select id, name, how_to from recipe where id in (select id from recipe order by random() limit ?)", numberWanted)
+// getRandomRecipes returns a given number of randomly selected recipes, preferring the ones having no ingredient out of season during the given month.
+// Only the direct ingredients of recipes are considered, not the ones of their sub-recipes.
+func (dao *RecipeDao) getRandomRecipes(ctx context.Context, numberWanted int, month time.Month) ([]model.Recipe, error) {
+	results, err := dao.queryRecipes(ctx, `select id, name, how_to, parent_id, `+recipeDetailColumns+` from recipe where id in (
+		select id from recipe
+		where deleted_at is null
+		order by exists (
+			select 1 from recipe_ingredient
+			join ingredient on recipe_ingredient.ingredient_id=ingredient.id
+			where recipe_ingredient.recipe_id=recipe.id
+			and ingredient.season_from is not null
+			and not case
+				when ingredient.season_from <= ingredient.season_to then ?2 between ingredient.season_from and ingredient.season_to
+				else ?2 >= ingredient.season_from or ?2 <= ingredient.season_to
+			end
+		), random()
+		limit ?1
+	)`, numberWanted, int(month))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query random recipes: %w", err)
 	}
-	defer rows.Close()
-	results := make([]model.Recipe, 0, numberWanted)
-	for rows.Next() {
-		var id int
-		var name, howTo string
-		if err = rows.Scan(&id, &name, &howTo); err != nil {
-			return nil, fmt.Errorf("failed to scan recipe row: %w", err)
-		}
-		recipeID := fromSqliteID(id)
-		ingredients, err := dao.recipeIngredientDao.GetRecipeIngredients(ctx, recipeID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to retrieve recipe ingredients: %w", err)
-		}
-
-		results = append(results, model.Recipe{
-			ID: recipeID,
-			BaseRecipe: model.BaseRecipe{
-				Name:        name,
-				HowTo:       howTo,
-				Ingredients: ingredients,
-			},
-		})
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("got an error while iterating on recipe rows: %w", err)
-	}
 	return results, nil
 }
 
 // getRecipeCount returns the number of saved recipes
 func (dao *RecipeDao) getRecipeCount(ctx context.Context) (int, error) {
-	rows, err := dao.holder.DB.QueryContext(ctx, "select count(*) from recipe")
+	rows, err := dao.holder.DB.QueryContext(ctx, "select count(*) from recipe where deleted_at is null")
 	if err != nil {
 		return 0, fmt.Errorf("query to count recipes failed: %w", err)
 	}
@@ -338,7 +661,7 @@ func rollback(transaction *sql.Tx) {
 
 // ListRecipeIds returns all recipe ids
 func (dao *RecipeDao) ListRecipeIds(ctx context.Context) ([]string, error) {
-	rows, err := dao.holder.DB.QueryContext(ctx, "select id from recipe")
+	rows, err := dao.holder.DB.QueryContext(ctx, "select id from recipe where deleted_at is null")
 	if err != nil {
 		return nil, fmt.Errorf("failed to query recipe ids: %w", err)
 	}
@@ -358,3 +681,40 @@ func (dao *RecipeDao) ListRecipeIds(ctx context.Context) ([]string, error) {
 
 	return ids, nil
 }
+
+// recipeDetailColumns are the optional columns of the recipe table describing its yield and times
+const recipeDetailColumns = "yield, prep_minutes, cook_minutes, total_minutes"
+
+// recipeDetails holds the values of the recipeDetailColumns, which are null when not set
+type recipeDetails struct {
+	yield                                  sql.NullString
+	prepMinutes, cookMinutes, totalMinutes sql.NullInt64
+}
+
+// toRecipeDetails extracts the values of the recipeDetailColumns from a recipe
+func toRecipeDetails(recipe model.BaseRecipe) recipeDetails {
+	return recipeDetails{
+		yield:        sql.NullString{String: recipe.Yield, Valid: recipe.Yield != ""},
+		prepMinutes:  sql.NullInt64{Int64: int64(recipe.PrepMinutes), Valid: recipe.PrepMinutes != 0},
+		cookMinutes:  sql.NullInt64{Int64: int64(recipe.CookMinutes), Valid: recipe.CookMinutes != 0},
+		totalMinutes: sql.NullInt64{Int64: int64(recipe.TotalMinutes), Valid: recipe.TotalMinutes != 0},
+	}
+}
+
+// targets returns the destinations to scan the recipeDetailColumns into
+func (details *recipeDetails) targets() []any {
+	return []any{&details.yield, &details.prepMinutes, &details.cookMinutes, &details.totalMinutes}
+}
+
+// values returns the arguments to write the recipeDetailColumns with
+func (details recipeDetails) values() []any {
+	return []any{details.yield, details.prepMinutes, details.cookMinutes, details.totalMinutes}
+}
+
+// apply sets the yield and times of a recipe
+func (details recipeDetails) apply(recipe *model.BaseRecipe) {
+	recipe.Yield = details.yield.String
+	recipe.PrepMinutes = int(details.prepMinutes.Int64)
+	recipe.CookMinutes = int(details.cookMinutes.Int64)
+	recipe.TotalMinutes = int(details.totalMinutes.Int64)
+}
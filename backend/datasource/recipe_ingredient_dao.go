@@ -11,24 +11,31 @@ import (
 
 // RecipeIngredientDao struct
 type RecipeIngredientDao struct {
-	holder        *DatabaseHolder
-	ingredientDao *IngredientDao
+	holder             *DatabaseHolder
+	ingredientDao      *IngredientDao
+	ingredientAliasDao *IngredientAliasDao
 }
 
 // NewRecipeIngredientDao returns a new recipe ingredient dao
-func NewRecipeIngredientDao(holder *DatabaseHolder, ingredientDao *IngredientDao) (*RecipeIngredientDao, error) {
+func NewRecipeIngredientDao(holder *DatabaseHolder, ingredientDao *IngredientDao, ingredientAliasDao *IngredientAliasDao) (*RecipeIngredientDao, error) {
 	initStatement := `
-		create table if not exists recipe_ingredient (recipe_id int, ingredient_id int, quantity text);
+		create table if not exists recipe_ingredient (recipe_id int, ingredient_id int, quantity text, sub_recipe_id int);
 		create index if not exists recipe_id_index on recipe_ingredient(recipe_id);
 		create index if not exists ingredient_id_index on recipe_ingredient(ingredient_id);
 	`
 	if _, err := holder.DB.Exec(initStatement); err != nil {
 		return nil, fmt.Errorf("failed to create recipe_ingredient table and/or its indices: %w", err)
 	}
-	return &RecipeIngredientDao{holder, ingredientDao}, nil
+	if err := addColumnIfMissing(holder.DB, "recipe_ingredient", "sub_recipe_id", "int"); err != nil {
+		return nil, err
+	}
+	if _, err := holder.DB.Exec("create index if not exists sub_recipe_id_index on recipe_ingredient(sub_recipe_id)"); err != nil {
+		return nil, fmt.Errorf("failed to create recipe_ingredient sub_recipe_id index: %w", err)
+	}
+	return &RecipeIngredientDao{holder, ingredientDao, ingredientAliasDao}, nil
 }
 
-// GetRecipeIngredients returns the ingredient of a recipe
+// GetRecipeIngredients returns the ingredients of a recipe, including the recipes it uses as ingredients
 func (dao *RecipeIngredientDao) GetRecipeIngredients(ctx context.Context, recipeID string) ([]model.RecipeIngredient, error) {
 	intRecipeID, err := toSqliteID(recipeID)
 	if err != nil {
@@ -38,31 +45,45 @@ func (dao *RecipeIngredientDao) GetRecipeIngredients(ctx context.Context, recipe
 		}
 	}
 	rows, err := dao.holder.DB.QueryContext(ctx, `select
-		recipe_ingredient.ingredient_id, recipe_ingredient.quantity, ingredient.name
+		recipe_ingredient.ingredient_id, recipe_ingredient.sub_recipe_id, recipe_ingredient.quantity, coalesce(ingredient.name, recipe.name), ingredient.allergens, ingredient.allergens_known, ingredient.season_from, ingredient.season_to,
+		category.id, category.name, category.aisle_order
 		from recipe_ingredient
-		inner join ingredient
+		left join ingredient
 		on recipe_ingredient.ingredient_id=ingredient.id
-		where recipe_ingredient.recipe_id=?`, intRecipeID)
+		left join category
+		on ingredient.category_id=category.id
+		left join recipe
+		on recipe_ingredient.sub_recipe_id=recipe.id
+		where recipe_ingredient.recipe_id=? and (ingredient.id is not null or recipe.id is not null)`, intRecipeID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query recipe ingredients: %w", err)
 	}
 	defer rows.Close()
 	recipeIngredients := make([]model.RecipeIngredient, 0, 8) // most recipes have 8 or less ingredients
 	for rows.Next() {
-		var ingredientID int
+		var ingredientID, subRecipeID sql.NullInt64
 		var quantity string
 		var name string
-		if err := rows.Scan(&ingredientID, &quantity, &name); err != nil {
+		var allergens, categoryName sql.NullString
+		var allergensKnown sql.NullBool
+		var seasonFrom, seasonTo, categoryID, categoryAisleOrder sql.NullInt64
+		if err := rows.Scan(&ingredientID, &subRecipeID, &quantity, &name, &allergens, &allergensKnown, &seasonFrom, &seasonTo, &categoryID, &categoryName, &categoryAisleOrder); err != nil {
 			return nil, fmt.Errorf("failed to scan recipe ingredient row: %w", err)
 		}
 		recipeIngredients = append(recipeIngredients, model.RecipeIngredient{
 			Ingredient: model.Ingredient{
-				ID: fromSqliteID(ingredientID),
+				ID: fromNullableSqliteID(ingredientID),
 				BaseIngredient: model.BaseIngredient{
-					Name: name,
+					Name:           name,
+					CategoryID:     fromNullableSqliteID(categoryID),
+					Allergens:      splitAllergens(allergens),
+					AllergensKnown: allergensKnown.Bool,
+					Season:         toSeason(seasonFrom, seasonTo),
 				},
+				Category: toCategory(categoryID, categoryName, categoryAisleOrder),
 			},
-			Quantity: quantity,
+			Quantity:    quantity,
+			SubRecipeID: fromNullableSqliteID(subRecipeID),
 		})
 	}
 	if err = rows.Err(); err != nil {
@@ -72,15 +93,27 @@ func (dao *RecipeIngredientDao) GetRecipeIngredients(ctx context.Context, recipe
 }
 
 // AddRecipeIngredient adds a recipe ingredient.
-// If the ingredient is new (ie. it has not yet got an ID) it is also added.
+// If the ingredient has not yet got an ID, an ingredient with the same name or alias is used, or a new one is added.
+// If the ingredient is another recipe, no ingredient ID is returned.
 func (dao *RecipeIngredientDao) AddRecipeIngredient(ctx context.Context, transaction *sql.Tx, recipeID string, recipeIngredient model.RecipeIngredient) (string, error) {
+	if recipeIngredient.IsSubRecipe() {
+		return "", dao.addRecipeSubRecipe(ctx, transaction, recipeID, recipeIngredient)
+	}
 	if len(recipeIngredient.ID) == 0 {
-		// New ingredient
-		ingredientID, err := dao.ingredientDao.AddIngredient(ctx, transaction, recipeIngredient.Name)
+		existingID, found, err := dao.ingredientAliasDao.FindIngredientIDByName(ctx, transaction, recipeIngredient.Name)
 		if err != nil {
-			return "", fmt.Errorf("failed to add new ingredient: %w", err)
+			return "", fmt.Errorf("failed to look for an existing ingredient with the same name: %w", err)
+		}
+		if found {
+			recipeIngredient.ID = existingID
+		} else {
+			// New ingredient
+			ingredientID, err := dao.ingredientDao.AddIngredient(ctx, transaction, recipeIngredient.Name)
+			if err != nil {
+				return "", fmt.Errorf("failed to add new ingredient: %w", err)
+			}
+			recipeIngredient.ID = ingredientID
 		}
-		recipeIngredient.ID = ingredientID
 	}
 	intRecipeID, err := toSqliteID(recipeID)
 	if err != nil {
@@ -110,6 +143,108 @@ func (dao *RecipeIngredientDao) AddRecipeIngredient(ctx context.Context, transac
 	return recipeIngredient.ID, nil
 }
 
+// addRecipeSubRecipe adds a recipe ingredient that is another recipe
+func (dao *RecipeIngredientDao) addRecipeSubRecipe(ctx context.Context, transaction *sql.Tx, recipeID string, recipeIngredient model.RecipeIngredient) error {
+	intRecipeID, err := toSqliteID(recipeID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", recipeID),
+			Cause:   err,
+		}
+	}
+	intSubRecipeID, err := toSqliteID(recipeIngredient.SubRecipeID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", recipeIngredient.SubRecipeID),
+			Cause:   err,
+		}
+	}
+
+	insertStatement, err := transaction.PrepareContext(ctx, "insert into recipe_ingredient(recipe_id, sub_recipe_id, quantity) values(?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert statement: %w", err)
+	}
+	defer insertStatement.Close()
+
+	if _, err = insertStatement.ExecContext(ctx, intRecipeID, intSubRecipeID, recipeIngredient.Quantity); err != nil {
+		return fmt.Errorf("failed to execute insert statement: %w", err)
+	}
+	return nil
+}
+
+// IsUsedAsSubRecipe returns whether a recipe is used as an ingredient by at least one other recipe
+func (dao *RecipeIngredientDao) IsUsedAsSubRecipe(ctx context.Context, recipeID string) (bool, error) {
+	intID, err := toSqliteID(recipeID)
+	if err != nil {
+		return false, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", recipeID),
+			Cause:   err,
+		}
+	}
+	row := dao.holder.DB.QueryRowContext(ctx, "select exists(select 1 from recipe_ingredient where sub_recipe_id=?)", intID)
+	var exists bool
+	if err := row.Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to scan exist row: %w", err)
+	}
+	return exists, nil
+}
+
+// ListRecipeIdsUsingSubRecipe returns the ids of the recipes that directly use the given recipe as an ingredient
+func (dao *RecipeIngredientDao) ListRecipeIdsUsingSubRecipe(ctx context.Context, subRecipeID string) ([]string, error) {
+	intID, err := toSqliteID(subRecipeID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", subRecipeID),
+			Cause:   err,
+		}
+	}
+	rows, err := dao.holder.DB.QueryContext(ctx, "select distinct recipe_id from recipe_ingredient where sub_recipe_id=?", intID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recipes using sub-recipe: %w", err)
+	}
+	defer rows.Close()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan recipe id row: %w", err)
+		}
+		ids = append(ids, fromSqliteID(id))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("got an error while iterating on recipe id rows: %w", err)
+	}
+	return ids, nil
+}
+
+// ListRecipeIdsUsingIngredient lists the IDs of the recipes that directly use the given ingredient
+func (dao *RecipeIngredientDao) ListRecipeIdsUsingIngredient(ctx context.Context, ingredientID string) ([]string, error) {
+	intID, err := toSqliteID(ingredientID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", ingredientID),
+			Cause:   err,
+		}
+	}
+	rows, err := dao.holder.DB.QueryContext(ctx, "select distinct recipe_id from recipe_ingredient where ingredient_id=?", intID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recipes using ingredient: %w", err)
+	}
+	defer rows.Close()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id sqliteID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan recipe id row: %w", err)
+		}
+		ids = append(ids, fromSqliteID(id))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("got an error while iterating on recipe id rows: %w", err)
+	}
+	return ids, nil
+}
+
 // IsUsedInRecipe returns whether an ingredient is used by at least one recipe
 func (dao *RecipeIngredientDao) IsUsedInRecipe(ctx context.Context, ingredientID string) (bool, error) {
 	intID, err := toSqliteID(ingredientID)
@@ -127,6 +262,28 @@ func (dao *RecipeIngredientDao) IsUsedInRecipe(ctx context.Context, ingredientID
 	return exists, nil
 }
 
+// GetIngredientUsageCounts returns, for each ingredient used by at least one recipe, the number of recipes using it
+func (dao *RecipeIngredientDao) GetIngredientUsageCounts(ctx context.Context) (map[string]int, error) {
+	rows, err := dao.holder.DB.QueryContext(ctx, "select ingredient_id, count(distinct recipe_id) from recipe_ingredient where ingredient_id is not null group by ingredient_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ingredient usage counts: %w", err)
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var id sqliteID
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan ingredient usage count row: %w", err)
+		}
+		counts[fromSqliteID(id)] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("got an error while iterating on ingredient usage count rows: %w", err)
+	}
+	return counts, nil
+}
+
 // DeleteRecipeIngredients deletes the ingredients of a recipe
 func (dao *RecipeIngredientDao) DeleteRecipeIngredients(ctx context.Context, transaction *sql.Tx, recipeID string) error {
 	intID, err := toSqliteID(recipeID)
@@ -149,7 +306,11 @@ func (dao *RecipeIngredientDao) DeleteRecipeIngredients(ctx context.Context, tra
 }
 
 // DeleteRecipeIngredient removes one ingredient from a recipe
-func (dao *RecipeIngredientDao) DeleteRecipeIngredient(ctx context.Context, transaction *sql.Tx, recipeID, ingredientID string) error {
+func (dao *RecipeIngredientDao) DeleteRecipeIngredient(ctx context.Context, transaction *sql.Tx, recipeID string, recipeIngredient model.RecipeIngredient) error {
+	column, ingredientID := "ingredient_id", recipeIngredient.ID
+	if recipeIngredient.IsSubRecipe() {
+		column, ingredientID = "sub_recipe_id", recipeIngredient.SubRecipeID
+	}
 	intRecipeID, err := toSqliteID(recipeID)
 	if err != nil {
 		return &failure.InvalidValueError{
@@ -164,13 +325,13 @@ func (dao *RecipeIngredientDao) DeleteRecipeIngredient(ctx context.Context, tran
 			Cause:   err,
 		}
 	}
-	deleteStatement, err := transaction.PrepareContext(ctx, "delete from recipe_ingredient where recipe_id=? and ingredient_id=?")
+	deleteStatement, err := transaction.PrepareContext(ctx, "delete from recipe_ingredient where recipe_id=? and "+column+"=?")
 	if err != nil {
 		return fmt.Errorf("failed to prepare delete statement: %w", err)
 	}
 	defer deleteStatement.Close()
 
-	if _, err := deleteStatement.Exec(ctx, intRecipeID, intIngredientID); err != nil {
+	if _, err := deleteStatement.ExecContext(ctx, intRecipeID, intIngredientID); err != nil {
 		return fmt.Errorf("failed to execute delete statement: %w", err)
 	}
 	return nil
@@ -185,13 +346,17 @@ func (dao *RecipeIngredientDao) UpdateRecipeIngredient(ctx context.Context, tran
 			Cause:   err,
 		}
 	}
-	updateStatement, err := transaction.PrepareContext(ctx, "update recipe_ingredient set quantity=?3 where recipe_id=?1 and ingredient_id=?2")
+	column, ingredientID := "ingredient_id", recipeIngredient.ID
+	if recipeIngredient.IsSubRecipe() {
+		column, ingredientID = "sub_recipe_id", recipeIngredient.SubRecipeID
+	}
+	updateStatement, err := transaction.PrepareContext(ctx, "update recipe_ingredient set quantity=?3 where recipe_id=?1 and "+column+"=?2")
 	if err != nil {
 		return fmt.Errorf("failed to prepare update statement: %w", err)
 	}
 	defer updateStatement.Close()
 
-	result, err := updateStatement.ExecContext(ctx, intID, recipeIngredient.ID, recipeIngredient.Quantity)
+	result, err := updateStatement.ExecContext(ctx, intID, ingredientID, recipeIngredient.Quantity)
 	if err != nil {
 		return fmt.Errorf("failed to execute update statement: %w", err)
 	}
@@ -201,8 +366,130 @@ func (dao *RecipeIngredientDao) UpdateRecipeIngredient(ctx context.Context, tran
 		return fmt.Errorf("failed to retrieve number of rows affected by update statement: %w", err)
 	case rowsAffected == 0:
 		return &failure.ResourceNotFoundError{
-			Message: "ingredient [" + recipeIngredient.ID + "] for recipe [" + recipeID + "] not found",
+			Message: "ingredient [" + ingredientID + "] for recipe [" + recipeID + "] not found",
 		}
 	}
 	return nil
 }
+
+// MergeIngredients makes the recipes using some ingredients use another one instead, then deletes the former and records their names as aliases of the latter.
+// Quantities are concatenated for recipes that were using several of these ingredients. All the ingredients are merged in a single transaction, and
+// the ids of the recipes that used the merged ingredients are returned.
+func (dao *RecipeIngredientDao) MergeIngredients(ctx context.Context, sourceIDs []string, targetID string) ([]string, error) {
+	sources := make([]*model.Ingredient, 0, len(sourceIDs))
+	for _, sourceID := range sourceIDs {
+		source, err := dao.ingredientDao.GetIngredient(ctx, sourceID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ingredient [%s] to merge: %w", sourceID, err)
+		}
+		sources = append(sources, source)
+	}
+	if _, err := dao.ingredientDao.GetIngredient(ctx, targetID); err != nil {
+		return nil, fmt.Errorf("failed to get ingredient to merge into: %w", err)
+	}
+
+	transaction, err := dao.holder.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to init transaction: %w", err)
+	}
+	recipeIDs := make([]string, 0)
+	for _, source := range sources {
+		mergedRecipeIDs, err := dao.mergeIngredientInTransaction(ctx, transaction, *source, targetID)
+		if err != nil {
+			rollback(transaction)
+			return nil, fmt.Errorf("failed to merge ingredient [%s]: %w", source.ID, err)
+		}
+		recipeIDs = append(recipeIDs, mergedRecipeIDs...)
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return recipeIDs, nil
+}
+
+// mergeIngredientInTransaction merges an ingredient into another one as part of a transaction, which the caller rolls back on error
+func (dao *RecipeIngredientDao) mergeIngredientInTransaction(ctx context.Context, transaction *sql.Tx, source model.Ingredient, targetID string) ([]string, error) {
+	intSourceID, err := toSqliteID(source.ID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", source.ID),
+			Cause:   err,
+		}
+	}
+	intTargetID, err := toSqliteID(targetID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", targetID),
+			Cause:   err,
+		}
+	}
+
+	rows, err := transaction.QueryContext(ctx, `select source.recipe_id, source.quantity, target.quantity
+		from recipe_ingredient source
+		left join recipe_ingredient target
+		on target.recipe_id=source.recipe_id and target.ingredient_id=?2
+		where source.ingredient_id=?1`, intSourceID, intTargetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recipes using ingredient to merge: %w", err)
+	}
+	recipeIDs := make([]string, 0)
+	mergedQuantities := make(map[sqliteID]string)
+	for rows.Next() {
+		var recipeID sqliteID
+		var sourceQuantity string
+		var targetQuantity sql.NullString
+		if err := rows.Scan(&recipeID, &sourceQuantity, &targetQuantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan recipe ingredient row: %w", err)
+		}
+		recipeIDs = append(recipeIDs, fromSqliteID(recipeID))
+		if targetQuantity.Valid {
+			mergedQuantities[recipeID] = joinQuantities(targetQuantity.String, sourceQuantity)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("got an error while iterating on recipe ingredient rows: %w", err)
+	}
+
+	for recipeID, quantity := range mergedQuantities {
+		if _, err := transaction.ExecContext(ctx, "update recipe_ingredient set quantity=?3 where recipe_id=?1 and ingredient_id=?2", recipeID, intTargetID, quantity); err != nil {
+			return nil, fmt.Errorf("failed to merge quantities: %w", err)
+		}
+		if _, err := transaction.ExecContext(ctx, "delete from recipe_ingredient where recipe_id=? and ingredient_id=?", recipeID, intSourceID); err != nil {
+			return nil, fmt.Errorf("failed to remove merged ingredient: %w", err)
+		}
+	}
+	if _, err := transaction.ExecContext(ctx, "update recipe_ingredient set ingredient_id=? where ingredient_id=?", intTargetID, intSourceID); err != nil {
+		return nil, fmt.Errorf("failed to replace merged ingredient: %w", err)
+	}
+	if err := dao.ingredientDao.deleteIngredientInTransaction(ctx, transaction, intSourceID); err != nil {
+		return nil, fmt.Errorf("failed to delete merged ingredient: %w", err)
+	}
+	if err := dao.ingredientAliasDao.MoveAliases(ctx, transaction, source.ID, targetID); err != nil {
+		return nil, fmt.Errorf("failed to move aliases of merged ingredient: %w", err)
+	}
+	// The name is not recorded if another ingredient is already named that way
+	if _, found, err := dao.ingredientAliasDao.FindIngredientIDByName(ctx, transaction, source.Name); err != nil {
+		return nil, fmt.Errorf("failed to look for ingredients named like merged ingredient: %w", err)
+	} else if !found {
+		if err := dao.ingredientAliasDao.AddAlias(ctx, transaction, targetID, source.Name); err != nil {
+			return nil, fmt.Errorf("failed to record name of merged ingredient as an alias: %w", err)
+		}
+	}
+
+	return recipeIDs, nil
+}
+
+// joinQuantities concatenates two quantities of the same ingredient, ignoring empty ones
+func joinQuantities(first, second string) string {
+	switch {
+	case first == "":
+		return second
+	case second == "":
+		return first
+	default:
+		return first + " + " + second
+	}
+}
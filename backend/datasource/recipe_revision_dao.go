@@ -0,0 +1,160 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+)
+
+// RecipeRevisionDao is a recipe revision dao
+type RecipeRevisionDao struct {
+	holder *DatabaseHolder
+}
+
+// NewRecipeRevisionDao returns a new recipe revision dao
+func NewRecipeRevisionDao(holder *DatabaseHolder) (*RecipeRevisionDao, error) {
+	initStatement := `
+		create table if not exists recipe_revision (id integer primary key asc, recipe_id int, created_at int, content text);
+		create index if not exists recipe_revision_recipe_id_index on recipe_revision(recipe_id);
+	`
+	if _, err := holder.DB.Exec(initStatement); err != nil {
+		return nil, fmt.Errorf("failed to create recipe_revision table and/or its indices: %w", err)
+	}
+	// revisions used to have an author, which was never known since there are no user accounts
+	if err := dropColumnIfExists(holder.DB, "recipe_revision", "author"); err != nil {
+		return nil, err
+	}
+	return &RecipeRevisionDao{holder}, nil
+}
+
+// AddRecipeRevision saves a snapshot of the given recipe
+func (dao *RecipeRevisionDao) AddRecipeRevision(ctx context.Context, transaction *sql.Tx, recipe model.Recipe) (string, error) {
+	intRecipeID, err := toSqliteID(recipe.ID)
+	if err != nil {
+		return "", &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", recipe.ID),
+			Cause:   err,
+		}
+	}
+	content, err := json.Marshal(recipe)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize recipe: %w", err)
+	}
+
+	insertStatement, err := transaction.PrepareContext(ctx, "insert into recipe_revision(recipe_id, created_at, content) values(?, ?, ?)")
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare insert statement: %w", err)
+	}
+	defer insertStatement.Close()
+
+	result, err := insertStatement.ExecContext(ctx, intRecipeID, time.Now().UnixMilli(), string(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to execute insert statement: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve ID of inserted row: %w", err)
+	}
+	return fromSqliteID(sqliteID(id)), nil
+}
+
+// GetRecipeRevisions returns the revisions of a recipe, oldest first, without their content
+func (dao *RecipeRevisionDao) GetRecipeRevisions(ctx context.Context, recipeID string) ([]model.RecipeRevision, error) {
+	intRecipeID, err := toSqliteID(recipeID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", recipeID),
+			Cause:   err,
+		}
+	}
+	rows, err := dao.holder.DB.QueryContext(ctx, "select id, created_at from recipe_revision where recipe_id=? order by id", intRecipeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recipe revisions: %w", err)
+	}
+	defer rows.Close()
+	revisions := make([]model.RecipeRevision, 0)
+	for rows.Next() {
+		var id sqliteID
+		var createdAt int64
+		if err := rows.Scan(&id, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan recipe revision row: %w", err)
+		}
+		revisions = append(revisions, model.RecipeRevision{
+			ID:        fromSqliteID(id),
+			RecipeID:  recipeID,
+			CreatedAt: time.UnixMilli(createdAt).UTC(),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("got an error while iterating on recipe revision rows: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetRecipeRevision returns a revision of a recipe along with its content
+func (dao *RecipeRevisionDao) GetRecipeRevision(ctx context.Context, recipeID, revisionID string) (*model.RecipeRevision, error) {
+	intRecipeID, err := toSqliteID(recipeID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", recipeID),
+			Cause:   err,
+		}
+	}
+	intRevisionID, err := toSqliteID(revisionID)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", revisionID),
+			Cause:   err,
+		}
+	}
+	row := dao.holder.DB.QueryRowContext(ctx, "select created_at, content from recipe_revision where id=? and recipe_id=?", intRevisionID, intRecipeID)
+	var createdAt int64
+	var content string
+
+	if err := row.Scan(&createdAt, &content); errors.Is(err, sql.ErrNoRows) {
+		return nil, &failure.ResourceNotFoundError{
+			Message: "revision [" + revisionID + "] of recipe [" + recipeID + "] not found",
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to retrieve recipe revision: %w", err)
+	}
+
+	var recipe model.Recipe
+	if err := json.Unmarshal([]byte(content), &recipe); err != nil {
+		return nil, fmt.Errorf("failed to parse content of recipe revision: %w", err)
+	}
+
+	return &model.RecipeRevision{
+		ID:        revisionID,
+		RecipeID:  recipeID,
+		CreatedAt: time.UnixMilli(createdAt).UTC(),
+		Recipe:    &recipe,
+	}, nil
+}
+
+// DeleteRecipeRevisions deletes all revisions of a recipe
+func (dao *RecipeRevisionDao) DeleteRecipeRevisions(ctx context.Context, transaction *sql.Tx, recipeID string) error {
+	intRecipeID, err := toSqliteID(recipeID)
+	if err != nil {
+		return &failure.InvalidValueError{
+			Message: fmt.Sprintf("failed to convert [%s] to sqlite ID", recipeID),
+			Cause:   err,
+		}
+	}
+	deleteStatement, err := transaction.PrepareContext(ctx, "delete from recipe_revision where recipe_id=?")
+	if err != nil {
+		return fmt.Errorf("failed to prepare delete statement: %w", err)
+	}
+	defer deleteStatement.Close()
+
+	if _, err := deleteStatement.ExecContext(ctx, intRecipeID); err != nil {
+		return fmt.Errorf("failed to execute delete statement: %w", err)
+	}
+	return nil
+}
@@ -1,13 +1,20 @@
 package datasource
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
 	"github.com/blevesearch/bleve/v2/analysis/lang/fr"
 	"github.com/blevesearch/bleve/v2/mapping"
+	blevequery "github.com/blevesearch/bleve/v2/search/query"
 
 	"github.com/remieven/miam/model"
 )
@@ -15,6 +22,11 @@ import (
 // RecipeSearchDao struct
 type RecipeSearchDao struct {
 	index bleve.Index
+	// fingerprints are the hashes of the indexed documents by recipe ID, since the index does not store them; they tell whether a recipe is indexed as it is stored
+	fingerprints      map[string][sha256.Size]byte
+	fingerprintsMutex sync.Mutex
+	// searchObserver is given the duration of each search, if set
+	searchObserver atomic.Pointer[func(time.Duration)]
 }
 
 // NewRecipeSearchDao creates a new recipe search dao
@@ -26,7 +38,8 @@ func NewRecipeSearchDao() (*RecipeSearchDao, error) {
 	}
 
 	return &RecipeSearchDao{
-		index: recipeIndex,
+		index:        recipeIndex,
+		fingerprints: make(map[string][sha256.Size]byte),
 	}, nil
 }
 
@@ -44,10 +57,18 @@ func buildIndexMapping() mapping.IndexMapping {
 	ingredientMapping.AddFieldMappingsAt("id", idTextFieldMapping)
 	ingredientMapping.AddFieldMappingsAt("name", frenchTextFieldMapping)
 
+	equipmentMapping := bleve.NewDocumentStaticMapping()
+	equipmentMapping.AddFieldMappingsAt("id", idTextFieldMapping)
+
 	recipeMapping := bleve.NewDocumentStaticMapping()
 	recipeMapping.AddFieldMappingsAt("name", frenchTextFieldMapping)
 	recipeMapping.AddFieldMappingsAt("howTo", frenchTextFieldMapping)
+	recipeMapping.AddFieldMappingsAt("diets", idTextFieldMapping)
+	recipeMapping.AddFieldMappingsAt("allergens", idTextFieldMapping)
+	recipeMapping.AddFieldMappingsAt("allergensKnown", bleve.NewBooleanFieldMapping())
+	recipeMapping.AddFieldMappingsAt("inSeasonMonths", idTextFieldMapping)
 	recipeMapping.AddSubDocumentMapping("ingredients", ingredientMapping)
+	recipeMapping.AddSubDocumentMapping("equipment", equipmentMapping)
 
 	indexMapping := bleve.NewIndexMapping()
 	indexMapping.AddDocumentMapping("recipe", recipeMapping)
@@ -56,17 +77,111 @@ func buildIndexMapping() mapping.IndexMapping {
 	return indexMapping
 }
 
-// IndexRecipe indexes a new or already existing recipe in the search engine
-func (dao *RecipeSearchDao) IndexRecipe(recipe model.Recipe) error {
-	return dao.index.Index(recipe.ID, recipe)
+// recipeDocument is a recipe as indexed in the search engine
+type recipeDocument struct {
+	model.Recipe
+	InSeasonMonths []string `json:"inSeasonMonths"`
+}
+
+// inSeasonBoost is how much more relevant in-season recipes are when they are preferred
+const inSeasonBoost = 2
+
+// IndexRecipe indexes a new or already existing recipe in the search engine, along with the months during which it is in season
+func (dao *RecipeSearchDao) IndexRecipe(recipe model.Recipe, inSeasonMonths []time.Month) error {
+	document := newRecipeDocument(recipe, inSeasonMonths)
+	fingerprint, err := document.fingerprint()
+	if err != nil {
+		return err
+	}
+	if err := dao.index.Index(recipe.ID, document); err != nil {
+		return err
+	}
+	dao.fingerprintsMutex.Lock()
+	defer dao.fingerprintsMutex.Unlock()
+	dao.fingerprints[recipe.ID] = fingerprint
+	return nil
+}
+
+func newRecipeDocument(recipe model.Recipe, inSeasonMonths []time.Month) recipeDocument {
+	document := recipeDocument{
+		Recipe:         recipe,
+		InSeasonMonths: make([]string, len(inSeasonMonths)),
+	}
+	for i, month := range inSeasonMonths {
+		document.InSeasonMonths[i] = strconv.Itoa(int(month))
+	}
+	return document
+}
+
+func (document recipeDocument) fingerprint() ([sha256.Size]byte, error) {
+	content, err := json.Marshal(document)
+	if err != nil {
+		return [sha256.Size]byte{}, fmt.Errorf("failed to serialize indexed recipe: %w", err)
+	}
+	return sha256.Sum256(content), nil
+}
+
+// IsIndexedAs tells whether a recipe is indexed exactly as given
+func (dao *RecipeSearchDao) IsIndexedAs(recipe model.Recipe, inSeasonMonths []time.Month) (bool, error) {
+	fingerprint, err := newRecipeDocument(recipe, inSeasonMonths).fingerprint()
+	if err != nil {
+		return false, err
+	}
+	dao.fingerprintsMutex.Lock()
+	defer dao.fingerprintsMutex.Unlock()
+	indexed, found := dao.fingerprints[recipe.ID]
+	return found && indexed == fingerprint, nil
 }
 
 // DeleteRecipe deletes a recipe from the search engine
 func (dao *RecipeSearchDao) DeleteRecipe(recipeID string) error {
-	return dao.index.Delete(recipeID)
+	if err := dao.index.Delete(recipeID); err != nil {
+		return err
+	}
+	dao.fingerprintsMutex.Lock()
+	defer dao.fingerprintsMutex.Unlock()
+	delete(dao.fingerprints, recipeID)
+	return nil
 }
 
-// SearchRecipes searches for recipes according to the given criteria
+// DeleteAllRecipes deletes all recipes from the search engine
+func (dao *RecipeSearchDao) DeleteAllRecipes() error {
+	IDs, err := dao.ListIndexedRecipeIDs()
+	if err != nil {
+		return err
+	}
+	batch := dao.index.NewBatch()
+	for _, ID := range IDs {
+		batch.Delete(ID)
+	}
+	if err := dao.index.Batch(batch); err != nil {
+		return err
+	}
+	dao.fingerprintsMutex.Lock()
+	defer dao.fingerprintsMutex.Unlock()
+	clear(dao.fingerprints)
+	return nil
+}
+
+// ListIndexedRecipeIDs returns the IDs of all the indexed recipes
+func (dao *RecipeSearchDao) ListIndexedRecipeIDs() ([]string, error) {
+	count, err := dao.index.DocCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count indexed recipes: %w", err)
+	}
+	request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(count), 0, false)
+	searchResults, err := dao.index.Search(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexed recipes: %w", err)
+	}
+	IDs := make([]string, 0, len(searchResults.Hits))
+	for _, hit := range searchResults.Hits {
+		IDs = append(IDs, hit.ID)
+	}
+	return IDs, nil
+}
+
+// SearchRecipes searches for recipes according to the given criteria; the month of the search must be set when it involves seasonality
 func (dao *RecipeSearchDao) SearchRecipes(search model.RecipeSearch) ([]string, int, error) {
 	query := bleve.NewConjunctionQuery()
 	if search.SearchTerm != "" {
@@ -88,8 +203,60 @@ func (dao *RecipeSearchDao) SearchRecipes(search model.RecipeSearch) ([]string,
 		}
 		query.AddQuery(exclusionQuery)
 	}
+	for _, diet := range search.Diets {
+		dietQuery := bleve.NewTermQuery(diet)
+		dietQuery.SetField("diets")
+		query.AddQuery(dietQuery)
+	}
+	if len(search.ExcludedAllergens) != 0 {
+		// Recipes whose allergens are unknown may contain the excluded ones
+		knownQuery := bleve.NewBoolFieldQuery(true)
+		knownQuery.SetField("allergensKnown")
+		exclusionQuery := bleve.NewBooleanQuery()
+		exclusionQuery.AddMust(knownQuery)
+		for _, excluded := range search.ExcludedAllergens {
+			excludeAllergenQuery := bleve.NewTermQuery(excluded)
+			excludeAllergenQuery.SetField("allergens")
+			exclusionQuery.AddMustNot(excludeAllergenQuery)
+		}
+		query.AddQuery(exclusionQuery)
+	}
+	if search.UnknownAllergensOnly {
+		unknownQuery := bleve.NewBoolFieldQuery(false)
+		unknownQuery.SetField("allergensKnown")
+		query.AddQuery(unknownQuery)
+	}
+	if len(search.ExcludedEquipment) != 0 {
+		exclusionQuery := bleve.NewBooleanQuery()
+		for _, excluded := range search.ExcludedEquipment {
+			excludeEquipmentQuery := bleve.NewTermQuery(excluded)
+			excludeEquipmentQuery.SetField("equipment.id")
+			exclusionQuery.AddMustNot(excludeEquipmentQuery)
+		}
+		query.AddQuery(exclusionQuery)
+	}
+	if search.InSeasonOnly {
+		inSeasonQuery := bleve.NewTermQuery(strconv.Itoa(search.Month))
+		inSeasonQuery.SetField("inSeasonMonths")
+		query.AddQuery(inSeasonQuery)
+	}
 
-	searchResults, err := dao.index.Search(bleve.NewSearchRequest(query))
+	var searchQuery blevequery.Query = query
+	if search.PreferInSeason {
+		inSeasonQuery := bleve.NewTermQuery(strconv.Itoa(search.Month))
+		inSeasonQuery.SetField("inSeasonMonths")
+		inSeasonQuery.SetBoost(inSeasonBoost)
+		preferenceQuery := bleve.NewBooleanQuery()
+		preferenceQuery.AddMust(query)
+		preferenceQuery.AddShould(inSeasonQuery)
+		searchQuery = preferenceQuery
+	}
+
+	start := time.Now()
+	searchResults, err := dao.index.Search(bleve.NewSearchRequest(searchQuery))
+	if observe := dao.searchObserver.Load(); observe != nil {
+		(*observe)(time.Since(start))
+	}
 	if err != nil {
 		return nil, 0, fmt.Errorf("search failed: %w", err)
 	}
@@ -102,6 +269,20 @@ func (dao *RecipeSearchDao) SearchRecipes(search model.RecipeSearch) ([]string,
 	return ids, int(searchResults.Total), nil
 }
 
+// ObserveSearches makes the dao give the duration of each search to the given function, eg. to measure the latency of the search engine
+func (dao *RecipeSearchDao) ObserveSearches(observe func(time.Duration)) {
+	dao.searchObserver.Store(&observe)
+}
+
+// CountIndexedRecipes returns the number of documents of the index
+func (dao *RecipeSearchDao) CountIndexedRecipes() (uint64, error) {
+	count, err := dao.index.DocCount()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count indexed recipes: %w", err)
+	}
+	return count, nil
+}
+
 // Close closes the index used to search recipes
 func (dao *RecipeSearchDao) Close() error {
 	slog.Info("closing bleve search engine index")
@@ -10,7 +10,10 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/remieven/miam/configuration"
 	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/markdown"
+	"github.com/remieven/miam/nutrition"
 	"github.com/remieven/miam/rest"
 	"github.com/remieven/miam/service"
 )
@@ -18,65 +21,146 @@ import (
 const defaultPort = 7040
 
 func main() {
-	for _, err := range startApplication() {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))
+	slog.SetDefault(logger)
+
+	name, args := "serve", os.Args[1:]
+	if len(args) != 0 {
+		name, args = args[0], args[1:]
+	}
+	errors := runCommand(name, args)
+	for _, err := range errors {
 		slog.With("error", err).Error("execution failed")
 	}
+	if len(errors) != 0 {
+		os.Exit(1)
+	}
 }
 
-func startApplication() (errors []error) {
-	appendError := func(err error) {
+// application holds the configuration and the services of the application, along with the resources to release when it stops
+type application struct {
+	config            *configuration.Configuration
+	databaseHolder    *datasource.DatabaseHolder
+	recipeService     *service.RecipeService
+	ingredientService *service.IngredientService
+	categoryService   *service.CategoryService
+	nutritionService  *service.NutritionService
+	equipmentService  *service.EquipmentService
+	adminService      *service.AdminService
+	importService     *service.ImportService
+	markdownService   *service.MarkdownService
+	backupService     *service.BackupService
+	healthService     *service.HealthService
+	// metricsService is nil when metrics are disabled
+	metricsService *service.MetricsService
+	closers        []func() error
+}
+
+// newApplication loads the configuration, opens the database and creates the services
+func newApplication() (app *application, err error) {
+	app = &application{}
+	defer func() {
 		if err != nil {
-			errors = append(errors, err)
+			app.close()
 		}
-	}
+	}()
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	}))
-	slog.SetDefault(logger)
-	slog.Info("Starting")
+	app.config, err = configuration.Load("./configuration.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
 
 	databaseHolder, err := datasource.NewDatabaseHolder("./miam.db")
 	if err != nil {
-		appendError(fmt.Errorf("failed to create database holder: %w", err))
-		return
+		return nil, fmt.Errorf("failed to create database holder: %w", err)
 	}
-	defer func() { appendError(databaseHolder.Close()) }()
+	app.databaseHolder = databaseHolder
+	app.closers = append(app.closers, databaseHolder.Close)
 
+	categoryDao, err := datasource.NewCategoryDao(databaseHolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize categoryDao: %w", err)
+	}
 	ingredientDao, err := datasource.NewIngredientDao(databaseHolder)
 	if err != nil {
-		appendError(fmt.Errorf("failed to initialize ingredientDao: %w", err))
-		return
+		return nil, fmt.Errorf("failed to initialize ingredientDao: %w", err)
+	}
+	ingredientAliasDao, err := datasource.NewIngredientAliasDao(databaseHolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize ingredientAliasDao: %w", err)
+	}
+	recipeIngredientDao, err := datasource.NewRecipeIngredientDao(databaseHolder, ingredientDao, ingredientAliasDao)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize recipeIngredientDao: %w", err)
+	}
+	equipmentDao, err := datasource.NewEquipmentDao(databaseHolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize equipmentDao: %w", err)
 	}
-	recipeIngredientDao, err := datasource.NewRecipeIngredientDao(databaseHolder, ingredientDao)
+	recipeRevisionDao, err := datasource.NewRecipeRevisionDao(databaseHolder)
 	if err != nil {
-		appendError(fmt.Errorf("failed to initialize recipeIngredientDao: %w", err))
-		return
+		return nil, fmt.Errorf("failed to initialize recipeRevisionDao: %w", err)
 	}
-	recipeDao, err := datasource.NewRecipeDao(databaseHolder, recipeIngredientDao)
+	recipeDao, err := datasource.NewRecipeDao(databaseHolder, recipeIngredientDao, recipeRevisionDao, equipmentDao)
 	if err != nil {
-		appendError(fmt.Errorf("failed to initialize recipeDao: %w", err))
-		return
+		return nil, fmt.Errorf("failed to initialize recipeDao: %w", err)
 	}
 	recipeSearchDao, err := datasource.NewRecipeSearchDao()
 	if err != nil {
-		appendError(fmt.Errorf("failed to initialize recipeSearchDao: %w", err))
-		return
+		return nil, fmt.Errorf("failed to initialize recipeSearchDao: %w", err)
 	}
-	defer func() { appendError(recipeSearchDao.Close()) }()
+	app.closers = append(app.closers, recipeSearchDao.Close)
 
-	var (
-		ingredientService = service.NewIngredientService(ingredientDao, recipeIngredientDao)
-		recipeService     = service.NewRecipeService(recipeDao, recipeSearchDao)
-	)
+	nutrientTable, err := loadNutrientTable(app.config.NutrientTablePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load nutrient table: %w", err)
+	}
+	markdownTemplates, err := loadMarkdownTemplates(app.config.MarkdownTemplatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load markdown templates: %w", err)
+	}
 
-	ctx := context.Background()
-	if err := recipeService.IndexAllExistingRecipes(ctx); err != nil {
-		appendError(fmt.Errorf("failed to index recipes: %w", err))
-		return
+	app.recipeService = service.NewRecipeService(recipeDao, recipeSearchDao, recipeRevisionDao, ingredientDao, recipeIngredientDao, equipmentDao)
+	app.ingredientService = service.NewIngredientService(ingredientDao, recipeIngredientDao, ingredientAliasDao, categoryDao, app.recipeService)
+	app.categoryService = service.NewCategoryService(categoryDao)
+	app.nutritionService = service.NewNutritionService(app.recipeService, ingredientAliasDao, nutrientTable)
+	app.equipmentService = service.NewEquipmentService(equipmentDao, app.recipeService)
+	app.importService = service.NewImportService(app.recipeService, ingredientDao, ingredientAliasDao, equipmentDao)
+	app.adminService = service.NewAdminService(datasource.NewExportDao(databaseHolder), datasource.NewIntegrityDao(databaseHolder), app.recipeService, app.importService)
+	app.markdownService = service.NewMarkdownService(app.recipeService, markdownTemplates)
+	app.backupService, err = service.NewBackupService(databaseHolder, app.config.BackupDirectory, app.config.DailyBackups, app.config.WeeklyBackups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup service: %w", err)
+	}
+	app.healthService = service.NewHealthService(databaseHolder, app.recipeService, app.backupService)
+	if app.config.MetricsEnabled {
+		app.metricsService = service.NewMetricsService(datasource.NewMetricsDao(databaseHolder), recipeSearchDao)
 	}
+	return app, nil
+}
 
-	router := rest.CreateRouter(recipeService, ingredientService)
+// close releases the resources of the application, in the reverse order of their creation
+func (app *application) close() (errors []error) {
+	for i := len(app.closers) - 1; i >= 0; i-- {
+		if err := app.closers[i](); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+// serve serves the HTTP API until the process is interrupted; the recipes are indexed once the server is started, and it is not ready until then
+func serve(ctx context.Context, app *application, _ []string) error {
+	ctx, cancelJobs := context.WithCancel(ctx)
+	defer cancelJobs()
+
+	if app.config.BackupIntervalHours > 0 {
+		go app.backupService.BackupPeriodically(ctx, time.Duration(app.config.BackupIntervalHours)*time.Hour)
+	}
+
+	router := rest.CreateRouter(app.recipeService, app.ingredientService, app.categoryService, app.nutritionService, app.equipmentService, app.adminService, app.importService, app.markdownService, app.backupService, app.healthService, app.metricsService)
 
 	port := defaultPort
 	srv := &http.Server{
@@ -85,7 +169,7 @@ func startApplication() (errors []error) {
 		ReadTimeout:  time.Second * 15,
 		IdleTimeout:  time.Second * 60,
 		Handler:      router,
-		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		ErrorLog:     slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
 	}
 
 	go func() {
@@ -100,6 +184,18 @@ func startApplication() (errors []error) {
 		}
 	}()
 
+	if err := app.recipeService.IndexAllExistingRecipes(ctx); err != nil {
+		srv.Close()
+		return fmt.Errorf("failed to index recipes: %w", err)
+	}
+	slog.Info("indexed recipes, ready to serve requests")
+
+	// the purge starts once the recipes are indexed, so that it does not remove recipes while they are being indexed
+	if app.config.TrashRetentionDays > 0 {
+		retention := time.Duration(app.config.TrashRetentionDays) * 24 * time.Hour
+		go app.recipeService.PurgeExpiredDeletedRecipesPeriodically(ctx, retention, time.Hour)
+	}
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 
@@ -113,5 +209,21 @@ func startApplication() (errors []error) {
 
 	slog.Info("shutting down")
 
-	return
+	return nil
+}
+
+// loadNutrientTable loads the nutrient table at the given path, or the bundled one if the path is empty
+func loadNutrientTable(filePath string) (*nutrition.Table, error) {
+	if filePath == "" {
+		return nutrition.LoadDefault()
+	}
+	return nutrition.LoadFile(filePath)
+}
+
+// loadMarkdownTemplates loads the markdown templates at the given path, or the bundled ones if the path is empty
+func loadMarkdownTemplates(filePath string) (*markdown.Templates, error) {
+	if filePath == "" {
+		return markdown.LoadDefault()
+	}
+	return markdown.LoadFile(filePath)
 }
@@ -0,0 +1,132 @@
+package markdown
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+//go:embed default.md.tmpl
+var defaultTemplates string
+
+// names of the templates used to write recipes and the index of a cookbook
+const (
+	recipeTemplate = "recipe"
+	indexTemplate  = "index"
+)
+
+// Recipe is a recipe as given to the recipe template
+type Recipe struct {
+	Name         string
+	Yield        string
+	PrepMinutes  int
+	CookMinutes  int
+	TotalMinutes int
+	// Ingredients are written as lines, such as "250 g farine"
+	Ingredients []string
+	Equipment   []string
+	Steps       []string
+}
+
+// IndexEntry is a recipe of a cookbook as given to the index template
+type IndexEntry struct {
+	Name     string
+	FileName string
+}
+
+// Templates writes recipes and cookbook indexes as Markdown
+type Templates struct {
+	templates *template.Template
+}
+
+// functions available in templates
+var templateFunctions = template.FuncMap{
+	"inc": func(i int) int {
+		return i + 1
+	},
+}
+
+// LoadDefault loads the templates bundled with the application
+func LoadDefault() (*Templates, error) {
+	templates, err := template.New("default").Funcs(templateFunctions).Parse(defaultTemplates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse default markdown templates: %w", err)
+	}
+	return &Templates{templates}, nil
+}
+
+// LoadFile loads the templates of the file at the given path; it must define a "recipe" template, an "index" template, or both,
+// the bundled ones being used for the missing ones
+func LoadFile(filePath string) (*Templates, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read markdown templates: %w", err)
+	}
+	defaults, err := LoadDefault()
+	if err != nil {
+		return nil, err
+	}
+	templates, err := defaults.templates.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy default markdown templates: %w", err)
+	}
+	if _, err := templates.Parse(string(content)); err != nil {
+		return nil, fmt.Errorf("failed to parse markdown templates: %w", err)
+	}
+	return &Templates{templates}, nil
+}
+
+// WriteRecipe writes a recipe using the recipe template
+func (templates *Templates) WriteRecipe(writer io.Writer, recipe Recipe) error {
+	return templates.templates.ExecuteTemplate(writer, recipeTemplate, recipe)
+}
+
+// WriteIndex writes the index of a cookbook using the index template
+func (templates *Templates) WriteIndex(writer io.Writer, entries []IndexEntry) error {
+	return templates.templates.ExecuteTemplate(writer, indexTemplate, entries)
+}
+
+// FileNames gives file names to recipes, such as tarte-aux-pommes.md, making sure that two recipes do not get the same name
+type FileNames struct {
+	used map[string]bool
+}
+
+// NewFileNames creates a new file names generator that will not give any of the reserved file names
+func NewFileNames(reserved ...string) *FileNames {
+	fileNames := &FileNames{
+		used: make(map[string]bool, len(reserved)),
+	}
+	for _, fileName := range reserved {
+		fileNames.used[fileName] = true
+	}
+	return fileNames
+}
+
+// For returns a file name for a recipe; accents are removed and anything else than letters and digits is replaced by dashes
+func (fileNames *FileNames) For(recipeName string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), strings.ToLower(recipeName))
+	if err != nil {
+		folded = strings.ToLower(recipeName)
+	}
+	base := strings.Join(strings.FieldsFunc(folded, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+	if base == "" {
+		base = "recette"
+	}
+	fileName := base + ".md"
+	for i := 2; fileNames.used[fileName]; i++ {
+		fileName = base + "-" + strconv.Itoa(i) + ".md"
+	}
+	fileNames.used[fileName] = true
+	return fileName
+}
@@ -0,0 +1,149 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteRecipe(t *testing.T) {
+	tests := map[string]struct {
+		recipe       Recipe
+		expectedText string
+	}{
+		"full recipe": {
+			recipe: Recipe{
+				Name:        "Crêpes",
+				Yield:       "8 crêpes",
+				PrepMinutes: 10,
+				CookMinutes: 20,
+				Ingredients: []string{"250 g farine", "4 œufs"},
+				Equipment:   []string{"poêle"},
+				Steps:       []string{"Mélanger.", "Cuire."},
+			},
+			expectedText: `# Crêpes
+
+Portions : 8 crêpes
+Préparation : 10 min
+Cuisson : 20 min
+
+## Ingrédients
+
+- 250 g farine
+- 4 œufs
+
+## Ustensiles
+
+- poêle
+
+## Instructions
+
+1. Mélanger.
+2. Cuire.
+`,
+		},
+		"name only": {
+			recipe:       Recipe{Name: "Eau"},
+			expectedText: "# Eau\n",
+		},
+		"times without yield": {
+			recipe: Recipe{
+				Name:         "Riz",
+				TotalMinutes: 15,
+				Steps:        []string{"Cuire."},
+			},
+			expectedText: `# Riz
+
+Total : 15 min
+
+## Instructions
+
+1. Cuire.
+`,
+		},
+	}
+
+	templates, err := LoadDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var actualText strings.Builder
+			if err := templates.WriteRecipe(&actualText, test.recipe); err != nil {
+				t.Fatal(err)
+			}
+			if actualText.String() != test.expectedText {
+				t.Errorf("got:\n%s\nwanted:\n%s", actualText.String(), test.expectedText)
+			}
+		})
+	}
+}
+
+func TestWriteIndex(t *testing.T) {
+	templates, err := LoadDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actualText strings.Builder
+	if err := templates.WriteIndex(&actualText, []IndexEntry{{Name: "Crêpes", FileName: "crepes.md"}, {Name: "Riz", FileName: "riz.md"}}); err != nil {
+		t.Fatal(err)
+	}
+	expectedText := `# Recettes
+
+- [Crêpes](crepes.md)
+- [Riz](riz.md)
+`
+	if actualText.String() != expectedText {
+		t.Errorf("got:\n%s\nwanted:\n%s", actualText.String(), expectedText)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "templates.md.tmpl")
+	if err := os.WriteFile(filePath, []byte(`{{define "recipe"}}{{.Name | printf "%q"}}{{end}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recipeText, indexText strings.Builder
+	if err := templates.WriteRecipe(&recipeText, Recipe{Name: "Riz"}); err != nil {
+		t.Fatal(err)
+	}
+	if recipeText.String() != `"Riz"` {
+		t.Errorf("unexpected recipe: got [%s]", recipeText.String())
+	}
+	// the index template is not overridden, so the default one is used
+	if err := templates.WriteIndex(&indexText, nil); err != nil {
+		t.Fatal(err)
+	}
+	if indexText.String() != "# Recettes\n\n" {
+		t.Errorf("unexpected index: got [%s]", indexText.String())
+	}
+
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestFileNames(t *testing.T) {
+	fileNames := NewFileNames("index.md")
+	for _, test := range []struct {
+		recipeName       string
+		expectedFileName string
+	}{
+		{"Tarte aux pommes", "tarte-aux-pommes.md"},
+		{"Crème brûlée !", "creme-brulee.md"},
+		{"tarte aux pommes", "tarte-aux-pommes-2.md"},
+		{"Index", "index-2.md"},
+		{"???", "recette.md"},
+	} {
+		if actualFileName := fileNames.For(test.recipeName); actualFileName != test.expectedFileName {
+			t.Errorf("got [%s] for [%s], wanted [%s]", actualFileName, test.recipeName, test.expectedFileName)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package mealmaster
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Recipe is a recipe read from a MealMaster text file, see https://www.wedesoft.de/software/anymeal/mealmaster.html
+type Recipe struct {
+	Name       string
+	Categories []string
+	Yield      string
+	// Ingredients are listed in order, continuation lines being joined to the ingredient they continue
+	Ingredients []Ingredient
+	// Steps are the paragraphs of the directions, their wrapped lines being joined
+	Steps []string
+}
+
+// Ingredient is an ingredient line, such as "  1 1/2 c  Flour"
+type Ingredient struct {
+	Quantity string
+	// Unit is the unit written out in full, such as "cup" for c; it is empty for items that are counted
+	Unit string
+	Name string
+}
+
+// ParseError is an error met while reading a line of a MealMaster file
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (err ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", err.Line, err.Message)
+}
+
+var (
+	// recipeStart matches the line starting a recipe, such as "MMMMM----- Recipe via Meal-Master (tm) v8.05"
+	recipeStart = regexp.MustCompile(`(?i)^(MMMMM|-----)-*.*meal-master`)
+	// recipeEnd matches the line ending a recipe
+	recipeEnd = regexp.MustCompile(`^(MMMMM|-----)\s*$`)
+	// sectionHeader matches the lines separating groups of ingredients, such as "MMMMM----------SAUCE----------"
+	sectionHeader = regexp.MustCompile(`^(MMMMM|-----)-*[^-]*-+\s*$`)
+	// header matches the title, categories and yield lines
+	header = regexp.MustCompile(`(?i)^\s*(title|categories|yield|servings)\s*:\s*(.*)$`)
+)
+
+// units maps the MealMaster unit abbreviations to their names
+var units = map[string]string{
+	"x":  "",
+	"ea": "",
+	"sm": "small",
+	"md": "medium",
+	"lg": "large",
+	"cn": "can",
+	"pk": "package",
+	"pn": "pinch",
+	"dr": "drop",
+	"ds": "dash",
+	"ct": "carton",
+	"bn": "bunch",
+	"sl": "slice",
+	"t":  "tsp",
+	"ts": "tsp",
+	"T":  "tbsp",
+	"tb": "tbsp",
+	"fl": "fl oz",
+	"c":  "cup",
+	"pt": "pint",
+	"qt": "quart",
+	"ga": "gallon",
+	"oz": "oz",
+	"lb": "lb",
+	"ml": "ml",
+	"cb": "cubic cm",
+	"cl": "cl",
+	"dl": "dl",
+	"l":  "l",
+	"mg": "mg",
+	"cg": "cg",
+	"dg": "dg",
+	"g":  "g",
+	"kg": "kg",
+}
+
+// column layout of the ingredient lines: the quantity takes 7 columns, then the unit takes 2 columns after a space, then the name starts after another space.
+// Ingredients may be written on two columns, the second one starting at secondColumn.
+const (
+	quantityWidth = 7
+	unitStart     = 8
+	nameStart     = 11
+	secondColumn  = 41
+)
+
+// section of a recipe being read
+type section int
+
+const (
+	headerSection section = iota
+	ingredientsSection
+	directionsSection
+)
+
+// Parse reads all the recipes of a MealMaster file; text outside of recipes is ignored.
+// Recipes without title are skipped; they are reported along with the unreadable lines.
+func Parse(text string) ([]Recipe, []ParseError) {
+	var (
+		recipes []Recipe
+		errs    []ParseError
+		parser  *recipeParser
+	)
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		lineNumber := i + 1
+		line = strings.TrimRight(line, " \t\x1a")
+		switch {
+		case recipeStart.MatchString(line):
+			if parser != nil {
+				errs = append(errs, ParseError{parser.startLine, "recipe is not terminated"})
+				recipes, errs = parser.finish(recipes, errs)
+			}
+			parser = &recipeParser{startLine: lineNumber}
+		case parser == nil:
+			continue
+		case recipeEnd.MatchString(line):
+			recipes, errs = parser.finish(recipes, errs)
+			parser = nil
+		default:
+			if err := parser.parseLine(line); err != "" {
+				errs = append(errs, ParseError{lineNumber, err})
+			}
+		}
+	}
+	if parser != nil {
+		errs = append(errs, ParseError{parser.startLine, "recipe is not terminated"})
+		recipes, errs = parser.finish(recipes, errs)
+	}
+	return recipes, errs
+}
+
+// recipeParser reads the lines of a recipe
+type recipeParser struct {
+	recipe    Recipe
+	startLine int
+	section   section
+	paragraph []string
+}
+
+// parseLine reads a line of a recipe, returning why it cannot be read if so
+func (parser *recipeParser) parseLine(line string) string {
+	if parser.section == headerSection {
+		if match := header.FindStringSubmatch(line); match != nil {
+			parser.setHeader(strings.ToLower(match[1]), strings.TrimSpace(match[2]))
+			return ""
+		}
+		if strings.TrimSpace(line) == "" {
+			return ""
+		}
+		parser.section = ingredientsSection
+	}
+
+	if parser.section == ingredientsSection {
+		if strings.TrimSpace(line) == "" || sectionHeader.MatchString(line) {
+			return ""
+		}
+		if first, second, ok := splitColumns(line); ok {
+			if err := parser.addIngredient(first); err != "" {
+				return err
+			}
+			return parser.addIngredient(second)
+		}
+		if looksLikeIngredient(line) {
+			return parser.addIngredient(line)
+		}
+		parser.section = directionsSection
+	}
+
+	if line = strings.TrimSpace(line); line == "" {
+		parser.endStep()
+	} else {
+		parser.paragraph = append(parser.paragraph, line)
+	}
+	return ""
+}
+
+func (parser *recipeParser) setHeader(key, value string) {
+	switch key {
+	case "title":
+		parser.recipe.Name = value
+	case "categories":
+		for _, category := range strings.Split(value, ",") {
+			if category = strings.TrimSpace(category); category != "" {
+				parser.recipe.Categories = append(parser.recipe.Categories, category)
+			}
+		}
+	default:
+		parser.recipe.Yield = value
+	}
+}
+
+// addIngredient adds an ingredient line, or joins it to the previous ingredient if it is a continuation line starting with a dash
+func (parser *recipeParser) addIngredient(line string) string {
+	columns := []rune(line)
+	for len(columns) < nameStart {
+		columns = append(columns, ' ')
+	}
+	quantity := strings.TrimSpace(string(columns[:quantityWidth]))
+	abbreviation := strings.TrimSpace(string(columns[unitStart : nameStart-1]))
+	name := strings.TrimSpace(string(columns[nameStart:]))
+	if !isQuantity(quantity) {
+		return fmt.Sprintf("unreadable quantity [%s]", quantity)
+	}
+
+	ingredients := parser.recipe.Ingredients
+	if quantity == "" && abbreviation == "" && strings.HasPrefix(name, "-") && len(ingredients) != 0 {
+		last := &ingredients[len(ingredients)-1]
+		last.Name = strings.TrimSpace(last.Name + " " + strings.TrimLeft(name, "- "))
+		return ""
+	}
+	unit, known := unitName(abbreviation)
+	if !known {
+		// the unit is kept in the name so that nothing is lost
+		name = strings.TrimSpace(abbreviation + " " + name)
+	}
+	if name != "" {
+		parser.recipe.Ingredients = append(parser.recipe.Ingredients, Ingredient{
+			Quantity: quantity,
+			Unit:     unit,
+			Name:     name,
+		})
+	}
+	if !known {
+		return fmt.Sprintf("unknown unit [%s]", abbreviation)
+	}
+	return ""
+}
+
+func (parser *recipeParser) endStep() {
+	if len(parser.paragraph) != 0 {
+		parser.recipe.Steps = append(parser.recipe.Steps, strings.Join(parser.paragraph, " "))
+		parser.paragraph = nil
+	}
+}
+
+// finish adds the recipe being read to the recipes, unless it has no title
+func (parser *recipeParser) finish(recipes []Recipe, errs []ParseError) ([]Recipe, []ParseError) {
+	parser.endStep()
+	if parser.recipe.Name == "" {
+		return recipes, append(errs, ParseError{parser.startLine, "recipe has no title"})
+	}
+	return append(recipes, parser.recipe), errs
+}
+
+// looksLikeIngredient returns whether a line follows the layout of ingredient lines: a quantity column, then a unit column, then a name
+func looksLikeIngredient(line string) bool {
+	columns := []rune(line)
+	if len(columns) <= nameStart {
+		return false
+	}
+	if columns[quantityWidth] != ' ' || columns[nameStart-1] != ' ' {
+		return false
+	}
+	quantity := strings.TrimSpace(string(columns[:quantityWidth]))
+	abbreviation := strings.TrimSpace(string(columns[unitStart : nameStart-1]))
+	if !isQuantity(quantity) || strings.ContainsRune(abbreviation, ' ') {
+		return false
+	}
+	if quantity == "" {
+		// without quantity, only a known unit or the indentation of the name tells it is an ingredient
+		_, known := unitName(abbreviation)
+		return known && strings.TrimSpace(string(columns[:nameStart])) == abbreviation && columns[nameStart] != ' '
+	}
+	return true
+}
+
+// splitColumns splits a line holding two ingredients side by side
+func splitColumns(line string) (string, string, bool) {
+	columns := []rune(line)
+	if len(columns) <= secondColumn+nameStart || strings.TrimSpace(string(columns[secondColumn-2:secondColumn])) != "" {
+		return "", "", false
+	}
+	first, second := string(columns[:secondColumn]), string(columns[secondColumn:])
+	if !looksLikeIngredient(first) || !looksLikeIngredient(second) {
+		return "", "", false
+	}
+	return first, second, true
+}
+
+// isQuantity returns whether a text is a MealMaster quantity, such as 1 1/2 or 2-3, or is empty
+func isQuantity(text string) bool {
+	return strings.Trim(text, "0123456789/.- ") == ""
+}
+
+// unitName returns the name of a unit abbreviation; the empty abbreviation is known and has no name
+func unitName(abbreviation string) (string, bool) {
+	if abbreviation == "" {
+		return "", true
+	}
+	if name, known := units[abbreviation]; known {
+		return name, true
+	}
+	name, known := units[strings.ToLower(abbreviation)]
+	return name, known
+}
@@ -0,0 +1,146 @@
+package mealmaster
+
+import (
+	"testing"
+
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		text            string
+		expectedRecipes []Recipe
+		expectedErrors  []ParseError
+	}{
+		"single column with sections and continuation lines": {
+			text: `From: someone@example.com
+Subject: cakes
+
+MMMMM----- Recipe via Meal-Master (tm) v8.05
+
+      Title: Chocolate Cake
+ Categories: Desserts, Cakes
+      Yield: 12 servings
+
+      2 c  Flour
+  1 1/2 c  Sugar
+    1/2 ts Salt
+      3 lg Eggs
+           -beaten
+MMMMM--------------------------FROSTING-------------------------------
+      4 oz Chocolate
+           Butter
+
+  Preheat the oven. Mix the flour, the sugar
+  and the salt.
+
+  Add the eggs and bake.
+
+MMMMM
+`,
+			expectedRecipes: []Recipe{
+				{
+					Name:       "Chocolate Cake",
+					Categories: []string{"Desserts", "Cakes"},
+					Yield:      "12 servings",
+					Ingredients: []Ingredient{
+						{Quantity: "2", Unit: "cup", Name: "Flour"},
+						{Quantity: "1 1/2", Unit: "cup", Name: "Sugar"},
+						{Quantity: "1/2", Unit: "tsp", Name: "Salt"},
+						{Quantity: "3", Unit: "large", Name: "Eggs beaten"},
+						{Quantity: "4", Unit: "oz", Name: "Chocolate"},
+						{Name: "Butter"},
+					},
+					Steps: []string{
+						"Preheat the oven. Mix the flour, the sugar and the salt.",
+						"Add the eggs and bake.",
+					},
+				},
+			},
+		},
+		"two columns": {
+			text: `---------- Recipe via Meal-Master (tm) v8.02
+
+      Title: Pancakes
+   Servings: 4
+
+      1 c  Flour                               1 ea Egg
+      1 c  Milk                                1 pn Salt
+
+  Whisk and cook.
+
+-----
+`,
+			expectedRecipes: []Recipe{
+				{
+					Name:  "Pancakes",
+					Yield: "4",
+					Ingredients: []Ingredient{
+						{Quantity: "1", Unit: "cup", Name: "Flour"},
+						{Quantity: "1", Name: "Egg"},
+						{Quantity: "1", Unit: "cup", Name: "Milk"},
+						{Quantity: "1", Unit: "pinch", Name: "Salt"},
+					},
+					Steps: []string{"Whisk and cook."},
+				},
+			},
+		},
+		"several recipes with errors": {
+			text: `MMMMM----- Recipe via Meal-Master (tm) v8.05
+
+ Categories: Soups
+
+      1 l  Water
+
+MMMMM
+
+MMMMM----- Recipe via Meal-Master (tm) v8.05
+
+      Title: Soup
+      1 bx Stock
+      2 sm Carrots
+
+  Boil.
+MMMMM----- Recipe via Meal-Master (tm) v8.05
+
+      Title: Bread
+
+  Bake.`,
+			expectedRecipes: []Recipe{
+				{
+					Name: "Soup",
+					Ingredients: []Ingredient{
+						{Quantity: "1", Name: "bx Stock"},
+						{Quantity: "2", Unit: "small", Name: "Carrots"},
+					},
+					Steps: []string{"Boil."},
+				},
+				{
+					Name:  "Bread",
+					Steps: []string{"Bake."},
+				},
+			},
+			expectedErrors: []ParseError{
+				{Line: 1, Message: "recipe has no title"},
+				{Line: 12, Message: "unknown unit [bx]"},
+				{Line: 9, Message: "recipe is not terminated"},
+				{Line: 16, Message: "recipe is not terminated"},
+			},
+		},
+		"no recipe": {
+			text: "Nothing to see here.",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actualRecipes, actualErrors := Parse(test.text)
+			if diff := testutils.DeepEqual(actualRecipes, test.expectedRecipes); diff != "" {
+				t.Error(diff)
+			}
+			if diff := testutils.DeepEqual(actualErrors, test.expectedErrors); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+// Package metrics keeps counters, gauges and histograms, and writes them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format written by Registry.Write
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are upper bounds, in seconds, suited to histograms of HTTP request durations
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// types of metrics, as written in the TYPE lines
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// labelSeparator separates the label values in the keys of the series, since it cannot appear in UTF-8 strings
+const labelSeparator = "\xff"
+
+// Registry holds metrics and writes them in the order they were created
+type Registry struct {
+	mutex    sync.Mutex
+	families []*family
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// family is a metric, with a series of values for each combination of label values
+type family struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	// buckets are the sorted upper bounds of histograms
+	buckets []float64
+	series  map[string]*series
+}
+
+// series holds the value of a metric for a combination of label values
+type series struct {
+	labelValues []string
+	// value is the value of counters and gauges, and the sum of the observations of histograms
+	value float64
+	// bucketCounts are the numbers of observations of histograms falling in each bucket, the last one being for +Inf
+	bucketCounts []uint64
+	count        uint64
+}
+
+// Counter is a metric that only goes up, such as a number of requests
+type Counter struct {
+	registry *Registry
+	family   *family
+}
+
+// Gauge is a metric that goes up and down, such as a number of open connections
+type Gauge struct {
+	registry *Registry
+	family   *family
+}
+
+// Histogram counts observations, such as durations, in buckets
+type Histogram struct {
+	registry *Registry
+	family   *family
+}
+
+// NewCounter adds a counter with the given label names to the registry
+func (registry *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{registry, registry.addFamily(name, help, typeCounter, labelNames, nil)}
+}
+
+// NewGauge adds a gauge with the given label names to the registry
+func (registry *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{registry, registry.addFamily(name, help, typeGauge, labelNames, nil)}
+}
+
+// NewHistogram adds a histogram with the given bucket upper bounds and label names to the registry; a +Inf bucket is always added
+func (registry *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	sorted := make([]float64, 0, len(buckets))
+	for _, bucket := range buckets {
+		if !math.IsInf(bucket, 1) {
+			sorted = append(sorted, bucket)
+		}
+	}
+	sort.Float64s(sorted)
+	return &Histogram{registry, registry.addFamily(name, help, typeHistogram, labelNames, sorted)}
+}
+
+func (registry *Registry) addFamily(name, help, metricType string, labelNames []string, buckets []float64) *family {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	family := &family{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     map[string]*series{},
+	}
+	// metrics without labels have a single series, written even before being updated
+	if len(labelNames) == 0 {
+		family.getSeries()
+	}
+	registry.families = append(registry.families, family)
+	return family
+}
+
+// getSeries returns the series of the given label values, creating it if needed; the registry must be locked.
+// It panics if the number of label values differs from the number of label names, which is a programming error.
+func (family *family) getSeries(labelValues ...string) *series {
+	if len(labelValues) != len(family.labelNames) {
+		panic(fmt.Sprintf("metric [%s] has %d labels, got %d values", family.name, len(family.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, labelSeparator)
+	s, ok := family.series[key]
+	if !ok {
+		s = &series{labelValues: labelValues}
+		if family.metricType == typeHistogram {
+			s.bucketCounts = make([]uint64, len(family.buckets)+1)
+		}
+		family.series[key] = s
+	}
+	return s
+}
+
+// Inc adds one to the counter of the given label values
+func (counter *Counter) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+// Add adds a non-negative value to the counter of the given label values
+func (counter *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	counter.registry.mutex.Lock()
+	defer counter.registry.mutex.Unlock()
+	counter.family.getSeries(labelValues...).value += value
+}
+
+// Set sets the counter of the given label values, for counters maintained elsewhere such as the statistics of a connection pool
+func (counter *Counter) Set(value float64, labelValues ...string) {
+	counter.registry.mutex.Lock()
+	defer counter.registry.mutex.Unlock()
+	counter.family.getSeries(labelValues...).value = value
+}
+
+// Set sets the gauge of the given label values
+func (gauge *Gauge) Set(value float64, labelValues ...string) {
+	gauge.registry.mutex.Lock()
+	defer gauge.registry.mutex.Unlock()
+	gauge.family.getSeries(labelValues...).value = value
+}
+
+// Observe adds an observation to the histogram of the given label values
+func (histogram *Histogram) Observe(value float64, labelValues ...string) {
+	histogram.registry.mutex.Lock()
+	defer histogram.registry.mutex.Unlock()
+	s := histogram.family.getSeries(labelValues...)
+	s.bucketCounts[sort.SearchFloat64s(histogram.family.buckets, value)]++
+	s.value += value
+	s.count++
+}
+
+// Write writes all the metrics in the Prometheus text exposition format, the series of each metric being sorted by label values.
+// Metrics with labels are only written once they have a series.
+func (registry *Registry) Write(w io.Writer) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	writer := bufio.NewWriter(w)
+	for _, family := range registry.families {
+		if len(family.series) == 0 {
+			continue
+		}
+		fmt.Fprintf(writer, "# HELP %s %s\n", family.name, escapeHelp(family.help))
+		fmt.Fprintf(writer, "# TYPE %s %s\n", family.name, family.metricType)
+		for _, s := range family.sortedSeries() {
+			if family.metricType != typeHistogram {
+				writeSample(writer, family.name, family.labelNames, s.labelValues, s.value)
+				continue
+			}
+			labelNames := append(family.labelNames[:len(family.labelNames):len(family.labelNames)], "le")
+			var cumulativeCount uint64
+			for i, bucketCount := range s.bucketCounts {
+				cumulativeCount += bucketCount
+				upperBound := math.Inf(1)
+				if i < len(family.buckets) {
+					upperBound = family.buckets[i]
+				}
+				labelValues := append(s.labelValues[:len(s.labelValues):len(s.labelValues)], formatFloat(upperBound))
+				writeSample(writer, family.name+"_bucket", labelNames, labelValues, float64(cumulativeCount))
+			}
+			writeSample(writer, family.name+"_sum", family.labelNames, s.labelValues, s.value)
+			writeSample(writer, family.name+"_count", family.labelNames, s.labelValues, float64(s.count))
+		}
+	}
+	return writer.Flush()
+}
+
+func (family *family) sortedSeries() []*series {
+	keys := make([]string, 0, len(family.series))
+	for key := range family.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sorted := make([]*series, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, family.series[key])
+	}
+	return sorted
+}
+
+func writeSample(writer *bufio.Writer, name string, labelNames, labelValues []string, value float64) {
+	writer.WriteString(name)
+	if len(labelNames) != 0 {
+		writer.WriteByte('{')
+		for i, labelName := range labelNames {
+			if i != 0 {
+				writer.WriteByte(',')
+			}
+			fmt.Fprintf(writer, "%s=\"%s\"", labelName, escapeLabelValue(labelValues[i]))
+		}
+		writer.WriteByte('}')
+	}
+	writer.WriteByte(' ')
+	writer.WriteString(formatFloat(value))
+	writer.WriteByte('\n')
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+// formatFloat formats a value as expected by Prometheus, which spells infinities +Inf and -Inf
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	tests := map[string]struct {
+		record   func(registry *Registry)
+		expected string
+	}{
+		"empty registry": {
+			record:   func(*Registry) {},
+			expected: "",
+		},
+		"metric without labels is written before being updated": {
+			record: func(registry *Registry) {
+				registry.NewGauge("recipes", "Number of recipes")
+			},
+			expected: `# HELP recipes Number of recipes
+# TYPE recipes gauge
+recipes 0
+`,
+		},
+		"metric with labels is not written before being updated": {
+			record: func(registry *Registry) {
+				registry.NewCounter("requests_total", "Number of requests", "method")
+			},
+			expected: "",
+		},
+		"counter series sorted by label values": {
+			record: func(registry *Registry) {
+				counter := registry.NewCounter("requests_total", "Number of requests", "method", "code")
+				counter.Inc("POST", "201")
+				counter.Inc("GET", "200")
+				counter.Add(2, "GET", "200")
+				counter.Add(-1, "GET", "200")
+			},
+			expected: `# HELP requests_total Number of requests
+# TYPE requests_total counter
+requests_total{method="GET",code="200"} 3
+requests_total{method="POST",code="201"} 1
+`,
+		},
+		"counter set from elsewhere": {
+			record: func(registry *Registry) {
+				counter := registry.NewCounter("waits_total", "Number of waits")
+				counter.Set(12)
+				counter.Set(15)
+			},
+			expected: `# HELP waits_total Number of waits
+# TYPE waits_total counter
+waits_total 15
+`,
+		},
+		"histogram with cumulative buckets": {
+			record: func(registry *Registry) {
+				histogram := registry.NewHistogram("duration_seconds", "Duration", []float64{1, 0.1}, "route")
+				histogram.Observe(0.05, "/recipe")
+				histogram.Observe(0.1, "/recipe")
+				histogram.Observe(0.5, "/recipe")
+				histogram.Observe(3, "/recipe")
+			},
+			expected: `# HELP duration_seconds Duration
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/recipe",le="0.1"} 2
+duration_seconds_bucket{route="/recipe",le="1"} 3
+duration_seconds_bucket{route="/recipe",le="+Inf"} 4
+duration_seconds_sum{route="/recipe"} 3.65
+duration_seconds_count{route="/recipe"} 4
+`,
+		},
+		"escaped help and label values": {
+			record: func(registry *Registry) {
+				registry.NewGauge("gauge", "Help with \\ and\nnewline", "label").Set(1.5, "a \"quoted\"\nvalue \\")
+			},
+			expected: `# HELP gauge Help with \\ and\nnewline
+# TYPE gauge gauge
+gauge{label="a \"quoted\"\nvalue \\"} 1.5
+`,
+		},
+		"metrics in order of creation": {
+			record: func(registry *Registry) {
+				registry.NewGauge("b", "B").Set(2)
+				registry.NewGauge("a", "A").Set(1)
+			},
+			expected: `# HELP b B
+# TYPE b gauge
+b 2
+# HELP a A
+# TYPE a gauge
+a 1
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			registry := NewRegistry()
+			test.record(registry)
+			var builder strings.Builder
+			if err := registry.Write(&builder); err != nil {
+				t.Error(err)
+				return
+			}
+			if builder.String() != test.expected {
+				t.Errorf("unexpected output: wanted\n%s\ngot\n%s", test.expected, builder.String())
+			}
+		})
+	}
+}
+
+func TestLabelValuesMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	NewRegistry().NewCounter("requests_total", "Number of requests", "method").Inc()
+}
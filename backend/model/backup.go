@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Kinds of backups
+const (
+	BackupDaily  = "daily"
+	BackupWeekly = "weekly"
+)
+
+// BackupStatus describes the backups of the database
+type BackupStatus struct {
+	// LastBackupAt is the time of the last successful backup, if any
+	LastBackupAt *time.Time `json:"lastBackupAt,omitempty"`
+	// Backups are the kept copies of the database, the most recent ones first
+	Backups []Backup `json:"backups"`
+}
+
+// Backup is a copy of the database
+type Backup struct {
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
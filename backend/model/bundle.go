@@ -0,0 +1,35 @@
+package model
+
+// Ways of handling the recipes of a bundle having the same name as an existing recipe
+const (
+	// BundleConflictSkip keeps the existing recipe and does not import the one of the bundle
+	BundleConflictSkip = "skip"
+	// BundleConflictOverwrite replaces the existing recipe by the one of the bundle, keeping its ID
+	BundleConflictOverwrite = "overwrite"
+	// BundleConflictDuplicate imports the recipe of the bundle as a new recipe, along with the existing one
+	BundleConflictDuplicate = "duplicate"
+)
+
+// Statuses of the recipes of an imported bundle
+const (
+	BundleRecipeCreated     = "created"
+	BundleRecipeSkipped     = "skipped"
+	BundleRecipeOverwritten = "overwritten"
+	BundleRecipeDuplicated  = "duplicated"
+)
+
+// BundleImportReport tells what was done with each recipe of an imported bundle
+type BundleImportReport struct {
+	Created     int                    `json:"created"`
+	Skipped     int                    `json:"skipped"`
+	Overwritten int                    `json:"overwritten"`
+	Duplicated  int                    `json:"duplicated"`
+	Recipes     []BundleImportedRecipe `json:"recipes"`
+}
+
+// BundleImportedRecipe is a recipe of an imported bundle; ID is the ID of the recipe it was imported as, or of the existing recipe if it was skipped
+type BundleImportedRecipe struct {
+	Name   string `json:"name"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
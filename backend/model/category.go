@@ -0,0 +1,14 @@
+package model
+
+// Category is a category of ingredients, such as a supermarket aisle
+type Category struct {
+	BaseCategory `json:""`
+	ID           string `json:"id"`
+}
+
+// BaseCategory is an editable category.
+// Categories are sorted by ascending aisle order, as they are met in the supermarket.
+type BaseCategory struct {
+	Name       string `json:"name"`
+	AisleOrder int    `json:"aisleOrder"`
+}
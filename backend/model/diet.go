@@ -0,0 +1,95 @@
+package model
+
+// Allergens and other dietary attributes of ingredients
+const (
+	AllergenGluten        = "gluten"
+	AllergenNuts          = "nuts"
+	AllergenDairy         = "dairy"
+	AllergenMeat          = "meat"
+	AllergenFish          = "fish"
+	AllergenAnimalProduct = "animalProduct"
+)
+
+// Diets that recipes can be compatible with
+const (
+	DietVegetarian = "vegetarian"
+	DietVegan      = "vegan"
+	DietGlutenFree = "glutenFree"
+)
+
+// Allergens lists all known allergens, in the order they are displayed
+var Allergens = []string{AllergenGluten, AllergenNuts, AllergenDairy, AllergenMeat, AllergenFish, AllergenAnimalProduct}
+
+// dietExcludedAllergens lists, for each diet in the order they are displayed, the allergens a compatible recipe must not contain
+var dietExcludedAllergens = []struct {
+	diet      string
+	allergens []string
+}{
+	{DietVegetarian, []string{AllergenMeat, AllergenFish}},
+	{DietVegan, []string{AllergenMeat, AllergenFish, AllergenDairy, AllergenAnimalProduct}},
+	{DietGlutenFree, []string{AllergenGluten}},
+}
+
+// IsAllergen returns true if the given value is a known allergen
+func IsAllergen(value string) bool {
+	return contains(Allergens, value)
+}
+
+// IsDiet returns true if the given value is a known diet
+func IsDiet(value string) bool {
+	for _, excluded := range dietExcludedAllergens {
+		if excluded.diet == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SortAllergens returns the known allergens among the given ones, without duplicates and in display order
+func SortAllergens(allergens []string) []string {
+	sorted := make([]string, 0, len(allergens))
+	for _, allergen := range Allergens {
+		if contains(allergens, allergen) {
+			sorted = append(sorted, allergen)
+		}
+	}
+	return sorted
+}
+
+// RecipeAllergens returns the allergens of all the given ingredients, in display order, and whether the allergens of every ingredient are known
+func RecipeAllergens(ingredients []RecipeIngredient) ([]string, bool) {
+	allergens := make([]string, 0)
+	known := true
+	for _, ingredient := range ingredients {
+		allergens = append(allergens, ingredient.Allergens...)
+		known = known && ingredient.AllergensKnown
+	}
+	return SortAllergens(allergens), known
+}
+
+// CompatibleDiets returns the diets a recipe containing the given allergens is compatible with
+func CompatibleDiets(allergens []string) []string {
+	diets := make([]string, 0, len(dietExcludedAllergens))
+	for _, excluded := range dietExcludedAllergens {
+		compatible := true
+		for _, allergen := range excluded.allergens {
+			if contains(allergens, allergen) {
+				compatible = false
+				break
+			}
+		}
+		if compatible {
+			diets = append(diets, excluded.diet)
+		}
+	}
+	return diets
+}
+
+func contains(values []string, searched string) bool {
+	for _, value := range values {
+		if value == searched {
+			return true
+		}
+	}
+	return false
+}
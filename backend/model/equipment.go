@@ -0,0 +1,14 @@
+package model
+
+// Equipment is a piece of kitchen equipment that recipes may require, such as a stand mixer or a pressure cooker
+type Equipment struct {
+	BaseEquipment `json:""`
+	ID            string `json:"id"`
+}
+
+// BaseEquipment is an editable piece of kitchen equipment.
+// Owned equipment makes up the profile used to filter out recipes requiring equipment we do not have.
+type BaseEquipment struct {
+	Name  string `json:"name"`
+	Owned bool   `json:"owned"`
+}
@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// ExportVersion is the version of the export format; it must be increased when the format changes in an incompatible way
+const ExportVersion = 1
+
+// Ways of importing an export
+const (
+	// ImportModeMerge keeps the existing data, the imported rows replacing the existing ones with the same IDs
+	ImportModeMerge = "merge"
+	// ImportModeReplace deletes all the existing data before importing
+	ImportModeReplace = "replace"
+)
+
+// Export is a full export of the data, which can be imported back with the same IDs
+type Export struct {
+	Version     int              `json:"version"`
+	ExportedAt  time.Time        `json:"exportedAt"`
+	Categories  []Category       `json:"categories"`
+	Ingredients []Ingredient     `json:"ingredients"`
+	Equipment   []Equipment      `json:"equipment"`
+	Recipes     []ExportedRecipe `json:"recipes"`
+}
+
+// ExportedRecipe is a recipe as exported, including the ones in the trash, along with its revisions.
+// Ingredients and equipment are only referenced by their IDs.
+type ExportedRecipe struct {
+	ID           string                     `json:"id"`
+	Name         string                     `json:"name"`
+	HowTo        string                     `json:"howTo,omitempty"`
+	ParentID     string                     `json:"parentId,omitempty"`
+	Yield        string                     `json:"yield,omitempty"`
+	PrepMinutes  int                        `json:"prepMinutes,omitempty"`
+	CookMinutes  int                        `json:"cookMinutes,omitempty"`
+	TotalMinutes int                        `json:"totalMinutes,omitempty"`
+	DeletedAt    *time.Time                 `json:"deletedAt,omitempty"`
+	Ingredients  []ExportedRecipeIngredient `json:"ingredients,omitempty"`
+	EquipmentIDs []string                   `json:"equipmentIds,omitempty"`
+	Revisions    []RecipeRevision           `json:"revisions,omitempty"`
+}
+
+// ExportedRecipeIngredient is an ingredient of an exported recipe; either IngredientID or SubRecipeID is set
+type ExportedRecipeIngredient struct {
+	IngredientID string `json:"ingredientId,omitempty"`
+	SubRecipeID  string `json:"subRecipeId,omitempty"`
+	Quantity     string `json:"quantity,omitempty"`
+}
+
+// ImportReport counts the rows imported from an export
+type ImportReport struct {
+	Categories  int `json:"categories"`
+	Ingredients int `json:"ingredients"`
+	Equipment   int `json:"equipment"`
+	Recipes     int `json:"recipes"`
+	Revisions   int `json:"revisions"`
+}
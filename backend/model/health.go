@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// Health tells whether the application is working
+type Health struct {
+	Status string `json:"status"`
+	// LastBackupAt is the time of the last successful backup of the database, if any
+	LastBackupAt *time.Time `json:"lastBackupAt,omitempty"`
+}
+
+// Readiness statuses
+const (
+	ReadinessReady    = "ready"
+	ReadinessNotReady = "notReady"
+)
+
+// Readiness tells whether the application can serve requests, with the result of each of the checks it depends on
+type Readiness struct {
+	Status string           `json:"status"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+// ReadinessCheck is the result of a check of something the application depends on, such as the database
+type ReadinessCheck struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	// Message explains why the check failed
+	Message string `json:"message,omitempty"`
+}
+
+// Version describes the build of the application
+type Version struct {
+	Version string `json:"version"`
+	// Commit is the VCS revision the application was built from, if known
+	Commit     string     `json:"commit,omitempty"`
+	CommitTime *time.Time `json:"commitTime,omitempty"`
+	// Modified tells whether the working tree had uncommitted changes when the application was built
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"goVersion"`
+}
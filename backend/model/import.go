@@ -0,0 +1,19 @@
+package model
+
+// ImportedRecipes are the recipes read from a file exported by another application, along with the errors met while reading it
+type ImportedRecipes struct {
+	// Recipes are drafts to review, unless they were saved
+	Recipes []BaseRecipe `json:"recipes"`
+	// IDs are the IDs of the saved recipes, in the same order
+	IDs    []string      `json:"ids,omitempty"`
+	Errors []ImportError `json:"errors,omitempty"`
+}
+
+// ImportError is an error met while reading part of an imported file, the rest of the file being imported anyway
+type ImportError struct {
+	// Line is the line of a text file where the error was met, starting from 1
+	Line int `json:"line,omitempty"`
+	// Entry is the file of an archive where the error was met
+	Entry   string `json:"entry,omitempty"`
+	Message string `json:"message"`
+}
@@ -3,10 +3,86 @@ package model
 // Ingredient is an ingredient with id/name
 type Ingredient struct {
 	BaseIngredient `json:""`
-	ID             string `json:"id"`
+	ID             string    `json:"id"`
+	Aliases        []string  `json:"aliases,omitempty"`
+	Category       *Category `json:"category,omitempty"`
 }
 
 // BaseIngredient is an editable ingredient
 type BaseIngredient struct {
+	Name       string `json:"name"`
+	CategoryID string `json:"categoryId,omitempty"`
+	// Allergens lists the allergens and other dietary attributes of the ingredient, see the Allergen constants
+	Allergens []string `json:"allergens,omitempty"`
+	// AllergensKnown tells whether the allergens of the ingredient were reviewed, an empty list then meaning it has none
+	AllergensKnown bool `json:"allergensKnown,omitempty"`
+	// Season is the range of months during which the ingredient is in season, or nil if it can be found all year long
+	Season *Season `json:"season,omitempty"`
+}
+
+// IngredientMerge is a request to merge an ingredient into another one
+type IngredientMerge struct {
+	TargetID string `json:"targetId"`
+}
+
+// IngredientAlias is an alternative name of an ingredient
+type IngredientAlias struct {
 	Name string `json:"name"`
 }
+
+// IngredientUsage is an ingredient along with the number of recipes using it
+type IngredientUsage struct {
+	Ingredient `json:""`
+	UsageCount int `json:"usageCount"`
+}
+
+// IngredientDuplicates is a group of ingredients whose names are so close they likely are the same ingredient.
+// TargetID is the ingredient the others should be merged into, ie. the most used one.
+type IngredientDuplicates struct {
+	TargetID    string            `json:"targetId"`
+	Ingredients []IngredientUsage `json:"ingredients"`
+}
+
+// IngredientDuplicatesMerge is a request to merge several ingredients into another one
+type IngredientDuplicatesMerge struct {
+	TargetID      string   `json:"targetId"`
+	IngredientIDs []string `json:"ingredientIds"`
+}
+
+// Statuses of the rows of an ingredient import
+const (
+	IngredientImportCreated   = "created"
+	IngredientImportUpdated   = "updated"
+	IngredientImportUnchanged = "unchanged"
+	IngredientImportError     = "error"
+)
+
+// IngredientUpsert is an ingredient to create or update, as read from a row of an imported file.
+// Without ID, the ingredient with the same name or alias is updated, and a new ingredient is created if there is none.
+type IngredientUpsert struct {
+	// Line is the line of the row in the imported file, starting from 1 with the header
+	Line       int
+	ID         string
+	Name       string
+	CategoryID string
+	// Aliases replace the aliases of the ingredient
+	Aliases []string
+}
+
+// IngredientImportRow tells what happened to a row of an ingredient import
+type IngredientImportRow struct {
+	Line   int    `json:"line"`
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// IngredientImportReport counts the rows of an ingredient import by status, and details each one of them
+type IngredientImportReport struct {
+	Created   int                   `json:"created"`
+	Updated   int                   `json:"updated"`
+	Unchanged int                   `json:"unchanged"`
+	Errors    int                   `json:"errors"`
+	Rows      []IngredientImportRow `json:"rows"`
+}
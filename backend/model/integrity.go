@@ -0,0 +1,34 @@
+package model
+
+// Kinds of integrity problems
+const (
+	// IntegrityCorruption is a problem of the database file reported by SQLite, which cannot be repaired by the application
+	IntegrityCorruption = "corruption"
+	// IntegrityOrphan is a row referencing a missing row, such as the ingredient of a missing recipe or a link to a deleted ingredient
+	IntegrityOrphan = "orphan"
+	// IntegrityDuplicateLink is a link, such as an ingredient of a recipe, stored several times
+	IntegrityDuplicateLink = "duplicateLink"
+	// IntegrityEmptyName is a recipe, an ingredient, a piece of equipment or a category without name
+	IntegrityEmptyName = "emptyName"
+	// IntegrityMissingIndexEntry is a recipe that cannot be found by the search engine
+	IntegrityMissingIndexEntry = "missingIndexEntry"
+	// IntegrityStaleIndexEntry is a recipe indexed differently from the database, or indexed while it is in the trash or purged
+	IntegrityStaleIndexEntry = "staleIndexEntry"
+)
+
+// IntegrityReport lists the problems found by an integrity check
+type IntegrityReport struct {
+	Problems []IntegrityProblem `json:"problems"`
+	// Repaired tells whether the problems were repaired; Remaining then lists the problems found after the repair
+	Repaired  bool               `json:"repaired"`
+	Remaining []IntegrityProblem `json:"remaining,omitempty"`
+}
+
+// IntegrityProblem is a problem found by an integrity check
+type IntegrityProblem struct {
+	Kind  string `json:"kind"`
+	Table string `json:"table,omitempty"`
+	// ID is the ID of the row with the problem; for links, which have no ID, it is the ID of the recipe or of the ingredient they belong to
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+}
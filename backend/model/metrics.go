@@ -0,0 +1,8 @@
+package model
+
+// RowCounts are the numbers of rows of the main tables of the database
+type RowCounts struct {
+	Recipes        int
+	DeletedRecipes int
+	Ingredients    int
+}
@@ -0,0 +1,30 @@
+package model
+
+// Nutrients are the main nutrients of some food; energy is in kilocalories, other nutrients in grams
+type Nutrients struct {
+	Energy        float64 `json:"energyKcal"`
+	Protein       float64 `json:"proteinG"`
+	Fat           float64 `json:"fatG"`
+	Carbohydrates float64 `json:"carbohydratesG"`
+}
+
+// Reasons why an ingredient could not be taken into account in nutrition estimates
+const (
+	UnmatchedReasonNoNutrientEntry = "noNutrientEntry"
+	UnmatchedReasonUnknownQuantity = "unknownQuantity"
+)
+
+// UnmatchedIngredient is an ingredient that could not be taken into account in nutrition estimates
+type UnmatchedIngredient struct {
+	RecipeIngredient `json:""`
+	Reason           string `json:"reason"`
+}
+
+// RecipeNutrition is an estimate of the nutrients of a recipe, from the ingredients that could be matched with the nutrient table
+type RecipeNutrition struct {
+	RecipeID   string                `json:"recipeId"`
+	Servings   int                   `json:"servings"`
+	Total      Nutrients             `json:"total"`
+	PerServing Nutrients             `json:"perServing"`
+	Unmatched  []UnmatchedIngredient `json:"unmatched"`
+}
@@ -1,9 +1,18 @@
 package model
 
+import "time"
+
 // Recipe is a recipe with id, name, howto and ingredients
 type Recipe struct {
 	BaseRecipe `json:""`
 	ID         string `json:"id"`
+	// Diets and Allergens are derived from the allergens of the ingredients, including the ones of sub-recipes.
+	// AllergensKnown tells whether the allergens of all these ingredients are known; diets are only derived when they are.
+	Diets          []string `json:"diets,omitempty"`
+	Allergens      []string `json:"allergens,omitempty"`
+	AllergensKnown bool     `json:"allergensKnown,omitempty"`
+	// SeasonScore is the share of the seasonal ingredients that are in season during the current month, if the recipe has some
+	SeasonScore *float64 `json:"seasonScore,omitempty"`
 }
 
 // BaseRecipe is an editable recipe
@@ -11,10 +20,32 @@ type BaseRecipe struct {
 	Name        string             `json:"name"`
 	HowTo       string             `json:"howTo,omitempty"`
 	Ingredients []RecipeIngredient `json:"ingredients,omitempty"`
+	ParentID    string             `json:"parentId,omitempty"`
+	// Yield is what the recipe makes, such as 4 servings or 12 cookies
+	Yield string `json:"yield,omitempty"`
+	// PrepMinutes, CookMinutes and TotalMinutes are the times needed by the recipe, in minutes
+	PrepMinutes  int `json:"prepMinutes,omitempty"`
+	CookMinutes  int `json:"cookMinutes,omitempty"`
+	TotalMinutes int `json:"totalMinutes,omitempty"`
+	// Equipment is the kitchen equipment the recipe requires; only the IDs are needed when editing a recipe
+	Equipment []Equipment `json:"equipment,omitempty"`
 }
 
-// RecipeIngredient is a recipe ingredient with an optional quantity
+// RecipeIngredient is a recipe ingredient with an optional quantity.
+// When SubRecipeID is set, the ingredient is another recipe whose name is given by Name; its quantity is then a multiplier of that recipe.
 type RecipeIngredient struct {
-	Quantity   string `json:"quantity,omitempty"`
-	Ingredient `json:""`
+	Quantity    string `json:"quantity,omitempty"`
+	Ingredient  `json:""`
+	SubRecipeID string `json:"subRecipeId,omitempty"`
+}
+
+// IsSubRecipe returns whether the recipe ingredient is another recipe
+func (recipeIngredient RecipeIngredient) IsSubRecipe() bool {
+	return recipeIngredient.SubRecipeID != ""
+}
+
+// DeletedRecipe is a recipe that has been moved to the trash
+type DeletedRecipe struct {
+	Recipe    `json:""`
+	DeletedAt time.Time `json:"deletedAt"`
 }
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// RecipeRevision is a snapshot of a recipe taken when it was saved
+type RecipeRevision struct {
+	ID        string    `json:"id"`
+	RecipeID  string    `json:"recipeId"`
+	CreatedAt time.Time `json:"createdAt"`
+	Recipe    *Recipe   `json:"recipe,omitempty"`
+}
+
+// Kinds of changes that can be found between two recipe revisions
+const (
+	ChangeKindAdded    = "added"
+	ChangeKindRemoved  = "removed"
+	ChangeKindModified = "modified"
+)
+
+// RecipeChange is a field-level change between two revisions of a recipe
+type RecipeChange struct {
+	Field      string      `json:"field"`
+	Kind       string      `json:"kind"`
+	Ingredient *Ingredient `json:"ingredient,omitempty"`
+	Before     string      `json:"before,omitempty"`
+	After      string      `json:"after,omitempty"`
+}
+
+// RecipeRevisionDiff lists the changes made to a recipe between two of its revisions
+type RecipeRevisionDiff struct {
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Changes []RecipeChange `json:"changes"`
+}
@@ -5,17 +5,47 @@ type RecipeSearch struct {
 	SearchTerm          string   `json:"searchTerm,omitempty"`
 	ExcludedRecipes     []string `json:"excludedRecipes,omitempty"`
 	ExcludedIngredients []string `json:"excludedIngredients,omitempty"`
+	// Diets are the diets matching recipes must all be compatible with
+	Diets []string `json:"diets,omitempty"`
+	// ExcludedAllergens are the allergens matching recipes must not contain
+	ExcludedAllergens []string `json:"excludedAllergens,omitempty"`
+	// UnknownAllergensOnly restricts matching recipes to the ones having ingredients whose allergens are unknown, which are left out by the diet and allergen criteria
+	UnknownAllergensOnly bool `json:"unknownAllergensOnly,omitempty"`
+	// ExcludedEquipment are the pieces of equipment matching recipes must not require
+	ExcludedEquipment []string `json:"excludedEquipment,omitempty"`
+	// OwnedEquipmentOnly restricts matching recipes to the ones requiring only owned equipment
+	OwnedEquipmentOnly bool `json:"ownedEquipmentOnly,omitempty"`
+	// InSeasonOnly restricts matching recipes to the ones whose seasonal ingredients are all in season
+	InSeasonOnly bool `json:"inSeasonOnly,omitempty"`
+	// PreferInSeason ranks first the matching recipes whose seasonal ingredients are all in season
+	PreferInSeason bool `json:"preferInSeason,omitempty"`
+	// Month is the month, from 1 to 12, used to tell whether ingredients are in season; it is the current month if not set
+	Month int `json:"month,omitempty"`
+	// CollapseVariants makes matching variants be replaced by their parent recipe instead of being grouped under it
+	CollapseVariants bool `json:"collapseVariants,omitempty"`
 }
 
 // IsEmpty returns true if the search contains no criteria
 func (search RecipeSearch) IsEmpty() bool {
 	return len(search.SearchTerm) == 0 &&
 		(search.ExcludedRecipes == nil || len(search.ExcludedRecipes) == 0) &&
-		(search.ExcludedIngredients == nil || len(search.ExcludedIngredients) == 0)
+		(search.ExcludedIngredients == nil || len(search.ExcludedIngredients) == 0) &&
+		len(search.Diets) == 0 &&
+		len(search.ExcludedAllergens) == 0 &&
+		!search.UnknownAllergensOnly &&
+		len(search.ExcludedEquipment) == 0 &&
+		!search.OwnedEquipmentOnly &&
+		!search.InSeasonOnly
 }
 
 // RecipeSearchResult is the result of a recipe search
 type RecipeSearchResult struct {
-	Total        int      `json:"total"`
-	FirstResults []Recipe `json:"firstResults"`
+	Total        int               `json:"total"`
+	FirstResults []RecipeSearchHit `json:"firstResults"`
+}
+
+// RecipeSearchHit is a recipe matching a search, along with its variants that also match it
+type RecipeSearchHit struct {
+	Recipe   `json:""`
+	Variants []Recipe `json:"variants,omitempty"`
 }
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// Season is the range of months during which an ingredient is in season, From and To being included and going from 1 (January) to 12.
+// The range wraps around the new year when From is after To, eg. from 10 to 3 for leeks.
+type Season struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// IsValid returns true if both months of the season are between 1 and 12
+func (season Season) IsValid() bool {
+	return season.From >= 1 && season.From <= 12 && season.To >= 1 && season.To <= 12
+}
+
+// Contains returns true if the given month is part of the season
+func (season Season) Contains(month time.Month) bool {
+	if season.From <= season.To {
+		return int(month) >= season.From && int(month) <= season.To
+	}
+	return int(month) >= season.From || int(month) <= season.To
+}
+
+// SeasonScore returns the share of the seasonal ingredients that are in season during the given month;
+// it returns false if none of the ingredients is seasonal.
+func SeasonScore(ingredients []RecipeIngredient, month time.Month) (float64, bool) {
+	seasonal, inSeason := 0, 0
+	for _, ingredient := range ingredients {
+		if ingredient.Season == nil {
+			continue
+		}
+		seasonal++
+		if ingredient.Season.Contains(month) {
+			inSeason++
+		}
+	}
+	if seasonal == 0 {
+		return 0, false
+	}
+	return float64(inSeason) / float64(seasonal), true
+}
+
+// InSeasonMonths returns the months during which all the seasonal ingredients are in season, ie. all months if none of them is seasonal
+func InSeasonMonths(ingredients []RecipeIngredient) []time.Month {
+	months := make([]time.Month, 0, 12)
+	for month := time.January; month <= time.December; month++ {
+		if score, seasonal := SeasonScore(ingredients, month); !seasonal || score == 1 {
+			months = append(months, month)
+		}
+	}
+	return months
+}
@@ -0,0 +1,235 @@
+package nutrition
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/quantity"
+	"github.com/remieven/miam/similarity"
+)
+
+//go:embed nutrients.csv
+var defaultTable string
+
+// Entry is an entry of a nutrient table.
+// Nutrients are given for 100 grams; UnitWeight is the weight in grams of one piece (eg. one egg), or 0 if unknown.
+type Entry struct {
+	Name       string
+	Per100g    model.Nutrients
+	UnitWeight float64
+}
+
+// Table is a nutrient table whose entries can be looked up by ingredient name
+type Table struct {
+	entries map[string]Entry
+}
+
+// columns of a nutrient table; extra columns are ignored, and unit_weight_g is optional
+const (
+	nameColumn          = "name"
+	energyColumn        = "energy_kcal"
+	proteinColumn       = "protein_g"
+	fatColumn           = "fat_g"
+	carbohydratesColumn = "carbohydrates_g"
+	unitWeightColumn    = "unit_weight_g"
+)
+
+// LoadDefault loads the nutrient table bundled with the application
+func LoadDefault() (*Table, error) {
+	return Load(strings.NewReader(defaultTable))
+}
+
+// LoadFile loads the nutrient table of the CSV file at the given path
+func LoadFile(filePath string) (*Table, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open nutrient table: %w", err)
+	}
+	defer file.Close()
+	return Load(file)
+}
+
+// Load reads a nutrient table from CSV content having a header line.
+// Values can be separated by commas or semicolons, the latter allowing decimal commas as in CIQUAL extracts.
+func Load(reader io.Reader) (*Table, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nutrient table: %w", err)
+	}
+	header, _, _ := strings.Cut(string(content), "\n")
+	csvReader := csv.NewReader(strings.NewReader(string(content)))
+	if strings.Contains(header, ";") {
+		csvReader.Comma = ';'
+	}
+	csvReader.TrimLeadingSpace = true
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse nutrient table: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("nutrient table has no header")
+	}
+
+	columnIndexes := make(map[string]int, len(records[0]))
+	for i, column := range records[0] {
+		columnIndexes[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, column := range []string{nameColumn, energyColumn, proteinColumn, fatColumn, carbohydratesColumn} {
+		if _, ok := columnIndexes[column]; !ok {
+			return nil, fmt.Errorf("nutrient table misses column [%s]", column)
+		}
+	}
+
+	table := &Table{
+		entries: make(map[string]Entry, len(records)-1),
+	}
+	for line, record := range records[1:] {
+		values := make(map[string]float64, len(columnIndexes))
+		for _, column := range []string{energyColumn, proteinColumn, fatColumn, carbohydratesColumn, unitWeightColumn} {
+			index, ok := columnIndexes[column]
+			if !ok || strings.TrimSpace(record[index]) == "" {
+				continue
+			}
+			value, err := parseValue(record[index])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value [%s] for column [%s] on line %d: %w", record[index], column, line+2, err)
+			}
+			values[column] = value
+		}
+		name := strings.TrimSpace(record[columnIndexes[nameColumn]])
+		table.entries[similarity.NormalizeName(name)] = Entry{
+			Name: name,
+			Per100g: model.Nutrients{
+				Energy:        values[energyColumn],
+				Protein:       values[proteinColumn],
+				Fat:           values[fatColumn],
+				Carbohydrates: values[carbohydratesColumn],
+			},
+			UnitWeight: values[unitWeightColumn],
+		}
+	}
+	return table, nil
+}
+
+// parseValue parses a nutrient value, which can use a decimal comma; values such as "traces" or "< 0,5" found in CIQUAL are considered as 0
+func parseValue(text string) (float64, error) {
+	text = strings.TrimSpace(text)
+	if text == "-" || strings.EqualFold(text, "traces") || strings.HasPrefix(text, "<") {
+		return 0, nil
+	}
+	return strconv.ParseFloat(strings.Replace(text, ",", ".", 1), 64)
+}
+
+// Match returns the entry matching the first of the given names that can be matched, names being typically an ingredient name and its aliases.
+// Names are compared ignoring accents, case and plural forms; failing that, the entry with the longest name starting the ingredient name is used,
+// eg. "oignon rouge" matches "oignon".
+func (table *Table) Match(names ...string) (Entry, bool) {
+	for _, name := range names {
+		if entry, ok := table.entries[similarity.NormalizeName(name)]; ok {
+			return entry, true
+		}
+	}
+	for _, name := range names {
+		words := strings.Fields(similarity.NormalizeName(name))
+		for length := len(words) - 1; length > 0; length-- {
+			if entry, ok := table.entries[strings.Join(words[:length], " ")]; ok {
+				return entry, true
+			}
+		}
+	}
+	return Entry{}, false
+}
+
+// unitWeights gives the weight in grams of the units usually found in recipes, by unit normalized with normalizeUnit; liquids are assumed to weigh as much as water
+var unitWeights = map[string]float64{
+	"g":              1,
+	"gr":             1,
+	"gramme":         1,
+	"kg":             1000,
+	"kilo":           1000,
+	"mg":             0.001,
+	"ml":             1,
+	"cl":             10,
+	"dl":             100,
+	"l":              1000,
+	"litre":          1000,
+	"cuillereasoupe": 15,
+	"cuilasoupe":     15,
+	"cas":            15,
+	"cs":             15,
+	"tbsp":           15,
+	"cuillereacafe":  5,
+	"cuilacafe":      5,
+	"cac":            5,
+	"cc":             5,
+	"tsp":            5,
+	"pincee":         0.5,
+	"verre":          200,
+	"tasse":          250,
+	"cup":            240,
+	"sachet":         11,
+	"noix":           15,
+	"noisette":       5,
+	"filet":          5,
+	"trait":          5,
+}
+
+// countUnits are units designating a number of pieces, whose weight is the unit weight of the nutrient table entry
+var countUnits = map[string]bool{
+	"":        true,
+	"piece":   true,
+	"unite":   true,
+	"gousse":  true,
+	"tranche": true,
+}
+
+// normalizeUnit turns a unit such as "cuillères à soupe" or "c. à s." into a key of unitWeights or countUnits
+func normalizeUnit(unit string) string {
+	normalized := similarity.NormalizeName(unit)
+	for _, suffix := range []string{" de", " d'", " du", " des"} {
+		normalized = strings.TrimSuffix(normalized, suffix)
+	}
+	return strings.NewReplacer(" ", "", ".", "").Replace(normalized)
+}
+
+// Grams converts a quantity of the ingredient matching the given entry to grams
+func Grams(ingredientQuantity quantity.Quantity, entry Entry) (float64, bool) {
+	unit := normalizeUnit(ingredientQuantity.Unit)
+	if weight, ok := unitWeights[unit]; ok {
+		return ingredientQuantity.Amount * weight, true
+	}
+	if countUnits[unit] && entry.UnitWeight > 0 {
+		return ingredientQuantity.Amount * entry.UnitWeight, true
+	}
+	return 0, false
+}
+
+// Of returns the nutrients of the given weight in grams of the ingredient matching the entry
+func (entry Entry) Of(grams float64) model.Nutrients {
+	return model.Nutrients{
+		Energy:        entry.Per100g.Energy * grams / 100,
+		Protein:       entry.Per100g.Protein * grams / 100,
+		Fat:           entry.Per100g.Fat * grams / 100,
+		Carbohydrates: entry.Per100g.Carbohydrates * grams / 100,
+	}
+}
+
+// Round rounds nutrients to one decimal
+func Round(nutrients model.Nutrients) model.Nutrients {
+	round := func(value float64) float64 {
+		return math.Round(value*10) / 10
+	}
+	return model.Nutrients{
+		Energy:        round(nutrients.Energy),
+		Protein:       round(nutrients.Protein),
+		Fat:           round(nutrients.Fat),
+		Carbohydrates: round(nutrients.Carbohydrates),
+	}
+}
@@ -0,0 +1,134 @@
+package nutrition
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/quantity"
+)
+
+func TestLoad(t *testing.T) {
+	tests := map[string]struct {
+		content       string
+		expectedError bool
+		searchedName  string
+		expectedEntry Entry
+	}{
+		"comma separated values": {
+			content:      "name,energy_kcal,protein_g,fat_g,carbohydrates_g,unit_weight_g\nœuf,140,12.7,9.8,0.3,55\n",
+			searchedName: "oeufs",
+			expectedEntry: Entry{
+				Name:       "œuf",
+				Per100g:    model.Nutrients{Energy: 140, Protein: 12.7, Fat: 9.8, Carbohydrates: 0.3},
+				UnitWeight: 55,
+			},
+		},
+		"semicolon separated values with decimal commas and extra columns": {
+			content:      "alim_code;Name;energy_kcal;protein_g;fat_g;carbohydrates_g\n13000;Beurre doux;745;0,7;82;traces\n",
+			searchedName: "beurre doux",
+			expectedEntry: Entry{
+				Name:    "Beurre doux",
+				Per100g: model.Nutrients{Energy: 745, Protein: 0.7, Fat: 82},
+			},
+		},
+		"missing column": {
+			content:       "name,energy_kcal,protein_g,fat_g\nbeurre,745,0.7,82\n",
+			expectedError: true,
+		},
+		"invalid value": {
+			content:       "name,energy_kcal,protein_g,fat_g,carbohydrates_g\nbeurre,a lot,0.7,82,0.7\n",
+			expectedError: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			table, err := Load(strings.NewReader(test.content))
+			if test.expectedError {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			entry, ok := table.Match(test.searchedName)
+			if !ok {
+				t.Errorf("no entry found for [%s]", test.searchedName)
+				return
+			}
+			if diff := deep.Equal(entry, test.expectedEntry); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	table, err := LoadDefault()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	tests := []struct {
+		names         []string
+		expectedMatch string
+	}{
+		{[]string{"farine"}, "farine"},
+		{[]string{"Tomates"}, "tomate"},
+		{[]string{"echalotes"}, "échalote"},
+		{[]string{"oignon rouge"}, "oignon"},
+		{[]string{"pomme de terre nouvelle"}, "pomme de terre"},
+		{[]string{"pommes de terre"}, "pomme de terre"},
+		{[]string{"patate", "pomme de terre"}, "pomme de terre"},
+		{[]string{"fenouil"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.names, "/"), func(t *testing.T) {
+			entry, ok := table.Match(test.names...)
+			if ok != (test.expectedMatch != "") || entry.Name != test.expectedMatch {
+				t.Errorf("unexpected match: wanted [%s], got [%s]", test.expectedMatch, entry.Name)
+			}
+		})
+	}
+}
+
+func TestGrams(t *testing.T) {
+	egg := Entry{Name: "œuf", UnitWeight: 55}
+	flour := Entry{Name: "farine"}
+	tests := []struct {
+		quantity      string
+		entry         Entry
+		expectedGrams float64
+		expectedOk    bool
+	}{
+		{"250g", flour, 250, true},
+		{"1,5 kg", flour, 1500, true},
+		{"50cl", flour, 500, true},
+		{"2 cuillères à soupe", flour, 30, true},
+		{"1 c. à c.", flour, 5, true},
+		{"3 càs", flour, 45, true},
+		{"1 pincée", flour, 0.5, true},
+		{"3", egg, 165, true},
+		{"2 pièces", egg, 110, true},
+		{"3", flour, 0, false},
+		{"1 poignée", flour, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.quantity, func(t *testing.T) {
+			parsed, ok := quantity.Parse(test.quantity)
+			if !ok {
+				t.Errorf("failed to parse [%s]", test.quantity)
+				return
+			}
+			grams, ok := Grams(parsed, test.entry)
+			if ok != test.expectedOk || grams != test.expectedGrams {
+				t.Errorf("unexpected grams: wanted [%v, %t], got [%v, %t]", test.expectedGrams, test.expectedOk, grams, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package paprika
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/remieven/miam/quantity"
+)
+
+// ErrUnknownFormat is returned when a file is neither a Paprika export nor a single Paprika recipe
+var ErrUnknownFormat = errors.New("not a Paprika file")
+
+// recipeExtension is the extension of the files of single recipes, which are gzipped JSON documents;
+// an export (.paprikarecipes) is a zip archive of such files
+const recipeExtension = ".paprikarecipe"
+
+// maxRecipeSize is the maximum size of an uncompressed recipe, which leaves room for the photos Paprika embeds in its recipes
+// while preventing a small compressed entry from expanding without bound
+const maxRecipeSize = 20 << 20
+
+// Recipe is a recipe exported by Paprika
+type Recipe struct {
+	Name       string
+	Categories []string
+	Yield      string
+	PrepTime   time.Duration
+	CookTime   time.Duration
+	TotalTime  time.Duration
+	// Ingredients are the ingredient lines, such as "250 g flour", without the blank lines and the headings of groups of ingredients
+	Ingredients []string
+	// Steps are the non-blank lines of the directions
+	Steps []string
+	Notes string
+}
+
+// ParseError is an error met while reading a recipe of a Paprika export
+type ParseError struct {
+	// Entry is the name of the file of the recipe in the export; it is empty for a single recipe
+	Entry   string
+	Message string
+}
+
+func (err ParseError) Error() string {
+	if err.Entry == "" {
+		return err.Message
+	}
+	return err.Entry + ": " + err.Message
+}
+
+// exportedRecipe is a recipe as written by Paprika
+type exportedRecipe struct {
+	Name        string   `json:"name"`
+	Ingredients string   `json:"ingredients"`
+	Directions  string   `json:"directions"`
+	Servings    string   `json:"servings"`
+	PrepTime    string   `json:"prep_time"`
+	CookTime    string   `json:"cook_time"`
+	TotalTime   string   `json:"total_time"`
+	Categories  []string `json:"categories"`
+	Notes       string   `json:"notes"`
+}
+
+// Parse reads the recipes of a Paprika export, or a single Paprika recipe.
+// The recipes that cannot be read are reported and skipped, as well as the times that cannot be read;
+// an error is returned if the file is not a Paprika file at all.
+func Parse(data []byte) ([]Recipe, []ParseError, error) {
+	if isGzip(data) {
+		recipe, problems, err := parseRecipe(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUnknownFormat, err)
+		}
+		return []Recipe{recipe}, toParseErrors("", problems), nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnknownFormat, err)
+	}
+	var (
+		recipes []Recipe
+		errs    []ParseError
+	)
+	for _, file := range archive.File {
+		if path.Ext(file.Name) != recipeExtension {
+			continue
+		}
+		recipe, problems, err := parseEntry(file)
+		if err != nil {
+			errs = append(errs, ParseError{file.Name, err.Error()})
+			continue
+		}
+		recipes = append(recipes, recipe)
+		errs = append(errs, toParseErrors(file.Name, problems)...)
+	}
+	if len(recipes) == 0 && len(errs) == 0 {
+		return nil, nil, fmt.Errorf("%w: the archive holds no recipe", ErrUnknownFormat)
+	}
+	return recipes, errs, nil
+}
+
+func parseEntry(file *zip.File) (Recipe, []string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return Recipe{}, nil, err
+	}
+	defer reader.Close()
+	return parseRecipe(reader)
+}
+
+// parseRecipe reads a gzipped JSON recipe; the problems are the parts of the recipe that could not be read
+func parseRecipe(reader io.Reader) (Recipe, []string, error) {
+	uncompressed, err := gzip.NewReader(reader)
+	if err != nil {
+		return Recipe{}, nil, fmt.Errorf("failed to uncompress recipe: %w", err)
+	}
+	defer uncompressed.Close()
+	data, err := io.ReadAll(io.LimitReader(uncompressed, maxRecipeSize+1))
+	if err != nil {
+		return Recipe{}, nil, fmt.Errorf("failed to uncompress recipe: %w", err)
+	}
+	if len(data) > maxRecipeSize {
+		return Recipe{}, nil, fmt.Errorf("recipe is larger than %d bytes once uncompressed", maxRecipeSize)
+	}
+	var exported exportedRecipe
+	if err := json.Unmarshal(data, &exported); err != nil {
+		return Recipe{}, nil, fmt.Errorf("failed to read recipe: %w", err)
+	}
+	if exported.Name = strings.TrimSpace(exported.Name); exported.Name == "" {
+		return Recipe{}, nil, errors.New("recipe has no name")
+	}
+
+	recipe := Recipe{
+		Name:       exported.Name,
+		Categories: exported.Categories,
+		Yield:      strings.TrimSpace(exported.Servings),
+		Steps:      nonBlankLines(exported.Directions),
+		Notes:      strings.TrimSpace(exported.Notes),
+	}
+	for _, line := range nonBlankLines(exported.Ingredients) {
+		// headings of groups of ingredients, such as "For the sauce:", are not ingredients
+		if !strings.HasSuffix(line, ":") {
+			recipe.Ingredients = append(recipe.Ingredients, line)
+		}
+	}
+	var problems []string
+	for _, duration := range []struct {
+		text   string
+		target *time.Duration
+		label  string
+	}{
+		{exported.PrepTime, &recipe.PrepTime, "prep"},
+		{exported.CookTime, &recipe.CookTime, "cook"},
+		{exported.TotalTime, &recipe.TotalTime, "total"},
+	} {
+		if strings.TrimSpace(duration.text) == "" {
+			continue
+		}
+		var ok bool
+		if *duration.target, ok = quantity.ParseDuration(duration.text); !ok {
+			problems = append(problems, fmt.Sprintf("failed to read %s time of [%s]: unknown duration [%s]", duration.label, recipe.Name, duration.text))
+		}
+	}
+	return recipe, problems, nil
+}
+
+func toParseErrors(entry string, problems []string) []ParseError {
+	var errs []ParseError
+	for _, problem := range problems {
+		errs = append(errs, ParseError{entry, problem})
+	}
+	return errs
+}
+
+func isGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+func nonBlankLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,127 @@
+package paprika
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+func gzipped(t *testing.T, content string) []byte {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+type entry struct {
+	name    string
+	content []byte
+}
+
+func zipped(t *testing.T, entries ...entry) []byte {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, entry := range entries {
+		file, err := writer.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write(entry.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+const crepes = `{
+	"uid": "0B8A5B0C",
+	"name": "Crêpes ",
+	"ingredients": "250 g farine\n\nPâte :\n4 œufs\n50 cl lait",
+	"directions": "Mélanger.\n\nCuire.",
+	"servings": "8 crêpes",
+	"prep_time": "10 mins",
+	"cook_time": "1 hr 5 min",
+	"total_time": "",
+	"categories": ["Desserts"],
+	"notes": "Laisser reposer une nuit.",
+	"photo_data": null
+}`
+
+var expectedCrepes = Recipe{
+	Name:        "Crêpes",
+	Categories:  []string{"Desserts"},
+	Yield:       "8 crêpes",
+	PrepTime:    10 * time.Minute,
+	CookTime:    65 * time.Minute,
+	Ingredients: []string{"250 g farine", "4 œufs", "50 cl lait"},
+	Steps:       []string{"Mélanger.", "Cuire."},
+	Notes:       "Laisser reposer une nuit.",
+}
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		data            []byte
+		expectedRecipes []Recipe
+		expectedErrors  []ParseError
+		expectedError   error
+	}{
+		"single recipe": {
+			data:            gzipped(t, crepes),
+			expectedRecipes: []Recipe{expectedCrepes},
+		},
+		"export with unreadable recipes": {
+			data: zipped(t,
+				entry{"Crêpes.paprikarecipe", gzipped(t, crepes)},
+				entry{"Soupe.paprikarecipe", gzipped(t, `{"name": "Soupe", "cook_time": "a while"}`)},
+				entry{"Broken.paprikarecipe", []byte("not gzipped")},
+				entry{"Nameless.paprikarecipe", gzipped(t, `{"name": " "}`)},
+				entry{"Huge.paprikarecipe", gzipped(t, `{"name": "Huge", "notes": "`+strings.Repeat("a", maxRecipeSize)+`"}`)},
+				entry{"readme.txt", []byte("ignored")},
+			),
+			expectedRecipes: []Recipe{expectedCrepes, {Name: "Soupe"}},
+			expectedErrors: []ParseError{
+				{Entry: "Soupe.paprikarecipe", Message: "failed to read cook time of [Soupe]: unknown duration [a while]"},
+				{Entry: "Broken.paprikarecipe", Message: "failed to uncompress recipe: gzip: invalid header"},
+				{Entry: "Nameless.paprikarecipe", Message: "recipe has no name"},
+				{Entry: "Huge.paprikarecipe", Message: "recipe is larger than 20971520 bytes once uncompressed"},
+			},
+		},
+		"not a Paprika file": {
+			data:          []byte("MMMMM----- Recipe via Meal-Master"),
+			expectedError: ErrUnknownFormat,
+		},
+		"empty archive": {
+			data:          zipped(t, entry{"readme.txt", []byte("nothing")}),
+			expectedError: ErrUnknownFormat,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actualRecipes, actualErrors, err := Parse(test.data)
+			if !errors.Is(err, test.expectedError) {
+				t.Fatalf("got error [%v], wanted [%v]", err, test.expectedError)
+			}
+			if diff := testutils.DeepEqual(actualRecipes, test.expectedRecipes); diff != "" {
+				t.Error(diff)
+			}
+			if diff := testutils.DeepEqual(actualErrors, test.expectedErrors); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package quantity
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Quantity is an amount along with an optional unit, eg. 250g or 2 cuillères à soupe
+type Quantity struct {
+	Amount float64
+	Unit   string
+}
+
+// unicodeFractions maps the unicode vulgar fractions commonly found in recipes to their value
+var unicodeFractions = map[rune]float64{
+	'¼': 0.25,
+	'½': 0.5,
+	'¾': 0.75,
+	'⅓': 1.0 / 3,
+	'⅔': 2.0 / 3,
+}
+
+// abbreviatedUnits are units that are written right after the amount, without space
+var abbreviatedUnits = map[string]bool{
+	"mg": true,
+	"g":  true,
+	"kg": true,
+	"ml": true,
+	"cl": true,
+	"dl": true,
+	"l":  true,
+}
+
+// rangeSeparators separate the bounds of a range of amounts, as in 2-3 œufs or 2 à 3 œufs; the words must be followed by a space
+var rangeSeparators = []string{"-", "–", "à", "to"}
+
+// Parse parses a quantity starting with an amount (eg. 250g, 1/2 citron, 1,5 l, ½ cuillère); it returns false if there is no amount
+func Parse(text string) (Quantity, bool) {
+	amount, rest, ok := parseLeadingAmount(strings.TrimSpace(text))
+	if !ok {
+		return Quantity{}, false
+	}
+	return Quantity{
+		Amount: amount,
+		Unit:   strings.TrimSpace(rest),
+	}, true
+}
+
+// parseLeadingAmount parses the amount at the start of a text, including mixed numbers such as 1 1/2
+func parseLeadingAmount(text string) (float64, string, bool) {
+	amount, rest, ok := parseAmount(text)
+	if !ok {
+		return 0, text, false
+	}
+	if trimmed := strings.TrimLeft(rest, " "); len(trimmed) < len(rest) {
+		if fraction, fractionRest, ok := parseAmount(trimmed); ok && fraction < 1 {
+			amount, rest = amount+fraction, fractionRest
+		}
+	}
+	return amount, rest, true
+}
+
+// parseAmount parses the number, decimal number, fraction or unicode fraction at the start of a text
+func parseAmount(text string) (float64, string, bool) {
+	for fractionRune, value := range unicodeFractions {
+		if rest, found := strings.CutPrefix(text, string(fractionRune)); found {
+			return value, rest, true
+		}
+	}
+	end := strings.IndexFunc(text, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != ',' && r != '/'
+	})
+	if end == -1 {
+		end = len(text)
+	}
+	number := strings.TrimRight(text[:end], ".,/")
+	rest := text[len(number):]
+	if number == "" {
+		return 0, text, false
+	}
+	if numerator, denominator, isFraction := strings.Cut(number, "/"); isFraction {
+		n, err := strconv.ParseFloat(numerator, 64)
+		if err != nil {
+			return 0, text, false
+		}
+		d, err := strconv.ParseFloat(denominator, 64)
+		if err != nil || d == 0 {
+			return 0, text, false
+		}
+		return n / d, rest, true
+	}
+	amount, err := strconv.ParseFloat(strings.Replace(number, ",", ".", 1), 64)
+	if err != nil {
+		return 0, text, false
+	}
+	return amount, rest, true
+}
+
+// parseRange parses a range of amounts such as 2-3 œufs or 2 à 3 œufs, returning its bounds, its unit and its separator as it is to be written
+func parseRange(text string) (float64, float64, string, string, bool) {
+	lower, rest, ok := parseLeadingAmount(text)
+	if !ok {
+		return 0, 0, "", "", false
+	}
+	rest = strings.TrimLeft(rest, " ")
+	for _, separator := range rangeSeparators {
+		afterSeparator, found := strings.CutPrefix(rest, separator)
+		isWord := unicode.IsLetter([]rune(separator)[0])
+		if !found || (isWord && !strings.HasPrefix(afterSeparator, " ")) {
+			continue
+		}
+		upper, unit, ok := parseLeadingAmount(strings.TrimLeft(afterSeparator, " "))
+		if !ok {
+			return 0, 0, "", "", false
+		}
+		if isWord {
+			separator = " " + separator + " "
+		}
+		return lower, upper, strings.TrimSpace(unit), separator, true
+	}
+	return 0, 0, "", "", false
+}
+
+// formatAmount formats an amount, rounded to two decimals
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(math.Round(amount*100)/100, 'f', -1, 64)
+}
+
+// String formats the quantity, rounding its amount to two decimals
+func (quantity Quantity) String() string {
+	amount := formatAmount(quantity.Amount)
+	switch {
+	case quantity.Unit == "":
+		return amount
+	case abbreviatedUnits[strings.ToLower(quantity.Unit)]:
+		return amount + quantity.Unit
+	default:
+		return amount + " " + quantity.Unit
+	}
+}
+
+// Scale multiplies the amount of a textual quantity, or both bounds of a range such as 2-3 œufs, by the given factor; quantities without amount are left as-is
+func Scale(text string, factor float64) string {
+	if factor == 1 {
+		return text
+	}
+	if lower, upper, unit, separator, ok := parseRange(strings.TrimSpace(text)); ok {
+		return formatAmount(lower*factor) + separator + Quantity{Amount: upper * factor, Unit: unit}.String()
+	}
+	quantity, ok := Parse(text)
+	if !ok {
+		return text
+	}
+	quantity.Amount *= factor
+	return quantity.String()
+}
+
+// SplitAmount splits a textual quantity such as 250g or 1 1/2 cuillère into its amount and its unit, both as written;
+// a quantity without amount is returned as-is, without unit
+func SplitAmount(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	_, rest, ok := parseLeadingAmount(text)
+	if !ok {
+		return text, ""
+	}
+	return text[:len(text)-len(rest)], strings.TrimSpace(rest)
+}
+
+// ParseFactor parses a multiplier such as 2, x2, ½ or 1,5 fois; an empty text means 1
+func ParseFactor(text string) (float64, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 1, true
+	}
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "x"), "×")
+	quantity, ok := Parse(text)
+	if !ok || quantity.Amount <= 0 {
+		return 0, false
+	}
+	switch quantity.Unit {
+	case "", "x", "×", "fois":
+		return quantity.Amount, true
+	}
+	return 0, false
+}
+
+// ingredientUnits are the units that may follow the amount of an ingredient line, in French and in English
+var ingredientUnits = []string{
+	"mg", "g", "kg", "ml", "cl", "dl", "l",
+	"cuillère à soupe", "cuillères à soupe", "cuillère à café", "cuillères à café",
+	"c. à soupe", "c. à café", "c. à s.", "c. à c.", "càs", "càc", "cas", "cac", "cs", "cc",
+	"pincée", "pincées", "gousse", "gousses", "tranche", "tranches", "verre", "verres", "tasse", "tasses",
+	"boîte", "boîtes", "sachet", "sachets", "botte", "bottes", "bouquet", "bouquets", "brin", "brins",
+	"feuille", "feuilles", "morceau", "morceaux", "poignée", "poignées", "pot", "pots", "filet", "noix", "noisette", "zeste",
+	"tablespoon", "tablespoons", "tbsp", "teaspoon", "teaspoons", "tsp", "cup", "cups",
+	"oz", "ounce", "ounces", "lb", "lbs", "pound", "pounds", "can", "cans",
+	"clove", "cloves", "pinch", "pinches", "slice", "slices", "sprig", "sprigs", "handful",
+}
+
+// ingredientConnectors are the words linking a unit to the name of an ingredient, as in 200 g de farine
+var ingredientConnectors = []string{"de ", "d'", "d’", "of "}
+
+// SplitIngredient splits an ingredient line such as "200 g de farine" or "2 cups of flour, sifted" into its quantity and the name of the ingredient.
+// Comments after a comma or between parentheses are left out of the name; lines without amount have no quantity.
+func SplitIngredient(line string) (string, string) {
+	line = strings.Join(strings.Fields(line), " ")
+	quantity, name := "", line
+	if _, rest, ok := parseLeadingAmount(line); ok {
+		afterUnit := rest
+		if unitRest, ok := cutUnit(strings.TrimLeft(rest, " ")); ok {
+			afterUnit = unitRest
+			name = strings.TrimLeft(afterUnit, " ")
+			for _, connector := range ingredientConnectors {
+				if connectorRest, found := cutPrefixFold(name, connector); found {
+					name = connectorRest
+					break
+				}
+			}
+		} else {
+			name = strings.TrimLeft(rest, " ")
+		}
+		quantity = strings.TrimSpace(line[:len(line)-len(afterUnit)])
+	}
+	if end := strings.IndexAny(name, ",("); end != -1 {
+		name = name[:end]
+	}
+	return quantity, strings.TrimSpace(name)
+}
+
+// cutUnit removes the longest unit found at the start of a text, provided it is a whole word
+func cutUnit(text string) (string, bool) {
+	bestRest, found := "", false
+	for _, unit := range ingredientUnits {
+		rest, ok := cutPrefixFold(text, unit)
+		if !ok || (rest != "" && rest[0] != ' ') {
+			continue
+		}
+		if !found || len(rest) < len(bestRest) {
+			bestRest, found = rest, true
+		}
+	}
+	return bestRest, found
+}
+
+// cutPrefixFold removes a prefix from a text, ignoring case
+func cutPrefixFold(text, prefix string) (string, bool) {
+	if len(text) < len(prefix) || !strings.EqualFold(text[:len(prefix)], prefix) {
+		return text, false
+	}
+	return text[len(prefix):], true
+}
+
+// durationUnits maps the units of durations to their value, in English and in French
+var durationUnits = map[string]time.Duration{
+	"":        time.Minute,
+	"s":       time.Second,
+	"sec":     time.Second,
+	"secs":    time.Second,
+	"second":  time.Second,
+	"seconds": time.Second,
+	"seconde": time.Second,
+	"m":       time.Minute,
+	"mn":      time.Minute,
+	"min":     time.Minute,
+	"mins":    time.Minute,
+	"minute":  time.Minute,
+	"minutes": time.Minute,
+	"h":       time.Hour,
+	"hr":      time.Hour,
+	"hrs":     time.Hour,
+	"hour":    time.Hour,
+	"hours":   time.Hour,
+	"heure":   time.Hour,
+	"heures":  time.Hour,
+	"d":       24 * time.Hour,
+	"day":     24 * time.Hour,
+	"days":    24 * time.Hour,
+	"jour":    24 * time.Hour,
+	"jours":   24 * time.Hour,
+}
+
+// durationPart matches an amount of time along with its optional unit, such as 1h or 30 minutes
+var durationPart = regexp.MustCompile(`(\d+(?:[.,]\d+)?)\s*(\pL*)`)
+
+// ParseDuration parses a free text duration such as 20 minutes, 1h30 or 1 hr 15 mins; amounts without unit are minutes
+func ParseDuration(text string) (time.Duration, bool) {
+	parts := durationPart.FindAllStringSubmatch(text, -1)
+	if len(parts) == 0 {
+		return 0, false
+	}
+	var result time.Duration
+	for _, part := range parts {
+		unit, known := durationUnits[strings.ToLower(part[2])]
+		if !known {
+			return 0, false
+		}
+		amount, err := strconv.ParseFloat(strings.Replace(part[1], ",", ".", 1), 64)
+		if err != nil {
+			return 0, false
+		}
+		result += time.Duration(amount * float64(unit))
+	}
+	return result, true
+}
@@ -0,0 +1,309 @@
+package quantity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		text             string
+		expectedQuantity Quantity
+		expectedOk       bool
+	}{
+		"empty text": {
+			text: "",
+		},
+		"no amount": {
+			text: "not too much",
+		},
+		"amount only": {
+			text:             "3",
+			expectedQuantity: Quantity{Amount: 3},
+			expectedOk:       true,
+		},
+		"amount with abbreviated unit": {
+			text:             "250g",
+			expectedQuantity: Quantity{Amount: 250, Unit: "g"},
+			expectedOk:       true,
+		},
+		"decimal amount with comma": {
+			text:             " 1,5 l ",
+			expectedQuantity: Quantity{Amount: 1.5, Unit: "l"},
+			expectedOk:       true,
+		},
+		"fraction": {
+			text:             "1/2 citron",
+			expectedQuantity: Quantity{Amount: 0.5, Unit: "citron"},
+			expectedOk:       true,
+		},
+		"mixed number": {
+			text:             "1 1/2 cuillère à soupe",
+			expectedQuantity: Quantity{Amount: 1.5, Unit: "cuillère à soupe"},
+			expectedOk:       true,
+		},
+		"unicode fraction": {
+			text:             "½ cuillère à café",
+			expectedQuantity: Quantity{Amount: 0.5, Unit: "cuillère à café"},
+			expectedOk:       true,
+		},
+		"division by zero": {
+			text: "1/0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actualQuantity, actualOk := Parse(test.text)
+			if actualOk != test.expectedOk {
+				t.Errorf("got ok [%v], wanted [%v]", actualOk, test.expectedOk)
+			}
+			if diff := testutils.DeepEqual(actualQuantity, test.expectedQuantity); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := map[string]struct {
+		text           string
+		factor         float64
+		expectedResult string
+	}{
+		"factor of one keeps text as-is": {
+			text:           "250 g",
+			factor:         1,
+			expectedResult: "250 g",
+		},
+		"no amount": {
+			text:           "une pincée",
+			factor:         2,
+			expectedResult: "une pincée",
+		},
+		"abbreviated unit": {
+			text:           "250 g",
+			factor:         2,
+			expectedResult: "500g",
+		},
+		"other unit": {
+			text:           "3 oeufs",
+			factor:         0.5,
+			expectedResult: "1.5 oeufs",
+		},
+		"rounding": {
+			text:           "1",
+			factor:         1.0 / 3,
+			expectedResult: "0.33",
+		},
+		"range with hyphen": {
+			text:           "2-3 œufs",
+			factor:         2,
+			expectedResult: "4-6 œufs",
+		},
+		"range with words": {
+			text:           "2 à 3 cuillères à soupe",
+			factor:         0.5,
+			expectedResult: "1 à 1.5 cuillères à soupe",
+		},
+		"range with abbreviated unit": {
+			text:           "100 - 150 g",
+			factor:         2,
+			expectedResult: "200-300g",
+		},
+		"unit starting like a separator": {
+			text:           "2 tomates",
+			factor:         2,
+			expectedResult: "4 tomates",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actualResult := Scale(test.text, test.factor); actualResult != test.expectedResult {
+				t.Errorf("got [%q], wanted [%q]", actualResult, test.expectedResult)
+			}
+		})
+	}
+}
+
+func TestParseFactor(t *testing.T) {
+	tests := map[string]struct {
+		text           string
+		expectedFactor float64
+		expectedOk     bool
+	}{
+		"empty text": {
+			text:           "",
+			expectedFactor: 1,
+			expectedOk:     true,
+		},
+		"number": {
+			text:           "2",
+			expectedFactor: 2,
+			expectedOk:     true,
+		},
+		"multiplication sign": {
+			text:           "x1,5",
+			expectedFactor: 1.5,
+			expectedOk:     true,
+		},
+		"fois": {
+			text:           "½ fois",
+			expectedFactor: 0.5,
+			expectedOk:     true,
+		},
+		"unit": {
+			text: "200g",
+		},
+		"zero": {
+			text: "0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actualFactor, actualOk := ParseFactor(test.text)
+			if actualOk != test.expectedOk {
+				t.Errorf("got ok [%v], wanted [%v]", actualOk, test.expectedOk)
+			}
+			if actualFactor != test.expectedFactor {
+				t.Errorf("got factor [%v], wanted [%v]", actualFactor, test.expectedFactor)
+			}
+		})
+	}
+}
+
+func TestSplitAmount(t *testing.T) {
+	tests := map[string]struct {
+		text           string
+		expectedAmount string
+		expectedUnit   string
+	}{
+		"empty text": {
+			text: "",
+		},
+		"no amount": {
+			text:           "un peu",
+			expectedAmount: "un peu",
+		},
+		"amount only": {
+			text:           "3",
+			expectedAmount: "3",
+		},
+		"amount with abbreviated unit": {
+			text:           "250g",
+			expectedAmount: "250",
+			expectedUnit:   "g",
+		},
+		"mixed number with unit": {
+			text:           " 1 1/2 cuillère à soupe ",
+			expectedAmount: "1 1/2",
+			expectedUnit:   "cuillère à soupe",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actualAmount, actualUnit := SplitAmount(test.text)
+			if actualAmount != test.expectedAmount || actualUnit != test.expectedUnit {
+				t.Errorf("got [%s] [%s], wanted [%s] [%s]", actualAmount, actualUnit, test.expectedAmount, test.expectedUnit)
+			}
+		})
+	}
+}
+
+func TestSplitIngredient(t *testing.T) {
+	tests := map[string]struct {
+		line             string
+		expectedQuantity string
+		expectedName     string
+	}{
+		"no amount": {
+			line:         "sel",
+			expectedName: "sel",
+		},
+		"amount only": {
+			line:             "3 œufs",
+			expectedQuantity: "3",
+			expectedName:     "œufs",
+		},
+		"abbreviated unit": {
+			line:             "250g farine",
+			expectedQuantity: "250g",
+			expectedName:     "farine",
+		},
+		"unit and connector": {
+			line:             "200 g de farine",
+			expectedQuantity: "200 g",
+			expectedName:     "farine",
+		},
+		"multi-word unit and elided connector": {
+			line:             "2 cuillères à soupe d'huile d'olive",
+			expectedQuantity: "2 cuillères à soupe",
+			expectedName:     "huile d'olive",
+		},
+		"name looking like a unit followed by a connector": {
+			line:             "3 pommes de terre",
+			expectedQuantity: "3",
+			expectedName:     "pommes de terre",
+		},
+		"unit that is the start of a word": {
+			line:             "2 canards",
+			expectedQuantity: "2",
+			expectedName:     "canards",
+		},
+		"english with comment": {
+			line:             "1 1/2 Cups of flour, sifted",
+			expectedQuantity: "1 1/2 Cups",
+			expectedName:     "flour",
+		},
+		"parenthesis and extra spaces": {
+			line:             "  1  gousse   d’ail (hachée)",
+			expectedQuantity: "1 gousse",
+			expectedName:     "ail",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			quantity, ingredientName := SplitIngredient(test.line)
+			if quantity != test.expectedQuantity || ingredientName != test.expectedName {
+				t.Errorf("got [%q] [%q], wanted [%q] [%q]", quantity, ingredientName, test.expectedQuantity, test.expectedName)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]struct {
+		text             string
+		expectedDuration time.Duration
+		expectedOk       bool
+	}{
+		"minutes":               {text: "45 minutes", expectedDuration: 45 * time.Minute, expectedOk: true},
+		"hours and minutes":     {text: "1 hr 30 mins", expectedDuration: 90 * time.Minute, expectedOk: true},
+		"compact":               {text: "1h30", expectedDuration: 90 * time.Minute, expectedOk: true},
+		"decimal hours":         {text: "1,5 heures", expectedDuration: 90 * time.Minute, expectedOk: true},
+		"capitalized unit":      {text: "2 Jours", expectedDuration: 48 * time.Hour, expectedOk: true},
+		"number without unit":   {text: "20", expectedDuration: 20 * time.Minute, expectedOk: true},
+		"unknown accented unit": {text: "3 journées"},
+		"unknown unit":          {text: "2 chansons"},
+		"no number":             {text: "overnight"},
+		"empty":                 {text: ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actualDuration, actualOk := ParseDuration(test.text)
+			if actualOk != test.expectedOk {
+				t.Errorf("got ok [%v], wanted [%v]", actualOk, test.expectedOk)
+			}
+			if actualDuration != test.expectedDuration {
+				t.Errorf("got [%v], wanted [%v]", actualDuration, test.expectedDuration)
+			}
+		})
+	}
+}
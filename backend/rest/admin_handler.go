@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/remieven/miam/bundle"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/rest"
+	"github.com/remieven/miam/service"
+)
+
+// maxBundleSize is the maximum size of an imported bundle, which holds a whole cookbook
+const maxBundleSize = 100 << 20
+
+// bundleTransferTimeout is the time given to send or receive a bundle, the timeouts of the server being meant for regular requests
+const bundleTransferTimeout = 5 * time.Minute
+
+// AdminHandler is a handler for administration tasks
+type AdminHandler struct {
+	adminService  *service.AdminService
+	backupService *service.BackupService
+}
+
+func newAdminHandler(adminService *service.AdminService, backupService *service.BackupService) *AdminHandler {
+	return &AdminHandler{
+		adminService,
+		backupService,
+	}
+}
+
+// Export writes a JSON export of all the data, streamed as it is read.
+// Errors can only be reported with a proper status until the first bytes are sent; after that, the export is left incomplete.
+func (handler *AdminHandler) Export(responseWriter http.ResponseWriter, request *http.Request) {
+	writer := &countingWriter{Writer: responseWriter}
+	responseWriter.Header().Set(rest.HeaderContentType, rest.ContentTypeJSONUTF8)
+	responseWriter.Header().Set("Content-Disposition", `attachment; filename="miam-export-`+time.Now().UTC().Format("2006-01-02")+`.json"`)
+	err := handler.adminService.WriteExport(request.Context(), writer)
+	if err != nil && writer.count == 0 {
+		responseWriter.Header().Del("Content-Disposition")
+		rest.HandleErrorCase(responseWriter, err)
+	} else if err != nil {
+		slog.With("error", err).Error("failed to write export")
+	}
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	io.Writer
+	count int
+}
+
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	n, err := writer.Writer.Write(p)
+	writer.count += n
+	return n, err
+}
+
+// Import imports a JSON export; the mode query parameter tells whether to merge it into the existing data (default) or to replace it
+func (handler *AdminHandler) Import(responseWriter http.ResponseWriter, request *http.Request) {
+	var export model.Export
+	if err := json.NewDecoder(request.Body).Decode(&export); rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	report, err := handler.adminService.Import(request.Context(), export, request.URL.Query().Get("mode"))
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, report)
+}
+
+// ExportBundle writes all the recipes as a .miam.zip bundle, streamed as it is written.
+// Errors can only be reported with a proper status until the first bytes are sent; after that, the bundle is left incomplete.
+func (handler *AdminHandler) ExportBundle(responseWriter http.ResponseWriter, request *http.Request) {
+	_ = http.NewResponseController(responseWriter).SetWriteDeadline(time.Now().Add(bundleTransferTimeout))
+	writer := &countingWriter{Writer: responseWriter}
+	responseWriter.Header().Set(rest.HeaderContentType, contentTypeZip)
+	responseWriter.Header().Set("Content-Disposition", `attachment; filename="miam-`+time.Now().Format("2006-01-02")+bundle.Extension+`"`)
+	err := handler.adminService.WriteBundle(request.Context(), writer)
+	if err != nil && writer.count == 0 {
+		responseWriter.Header().Del("Content-Disposition")
+		rest.HandleErrorCase(responseWriter, err)
+	} else if err != nil {
+		slog.With("error", err).Error("failed to write bundle")
+	}
+}
+
+// ImportBundle imports a .miam.zip bundle, uploaded as the file of a form or as the request body;
+// the conflict query parameter tells what to do with the recipes having the same name as an existing recipe
+func (handler *AdminHandler) ImportBundle(responseWriter http.ResponseWriter, request *http.Request) {
+	controller := http.NewResponseController(responseWriter)
+	_ = controller.SetReadDeadline(time.Now().Add(bundleTransferTimeout))
+	_ = controller.SetWriteDeadline(time.Now().Add(bundleTransferTimeout))
+	data, _, err := readImportedFile(responseWriter, request, maxBundleSize)
+	if rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	report, err := handler.adminService.ImportBundle(request.Context(), data, request.URL.Query().Get("conflict"))
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, report)
+}
+
+// CheckIntegrity reports the inconsistencies of the database and of the search index
+func (handler *AdminHandler) CheckIntegrity(responseWriter http.ResponseWriter, request *http.Request) {
+	report, err := handler.adminService.CheckIntegrity(request.Context(), false)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, report)
+}
+
+// RepairIntegrity repairs the inconsistencies of the database and of the search index, reporting the ones found before and after the repair
+func (handler *AdminHandler) RepairIntegrity(responseWriter http.ResponseWriter, request *http.Request) {
+	report, err := handler.adminService.CheckIntegrity(request.Context(), true)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, report)
+}
+
+// Backup backs up the database, returning the kept backups
+func (handler *AdminHandler) Backup(responseWriter http.ResponseWriter, request *http.Request) {
+	status, err := handler.backupService.Backup(request.Context())
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, status)
+}
@@ -0,0 +1,563 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/remieven/miam/bundle"
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/pb-lite/fixture"
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+var prepareExportedDatabase = fixture.PrepareDatabase(
+	`insert into category(id, name, aisle_order) values (1, "fruits et légumes", 1)`,
+	`insert into ingredient(id, name, category_id, allergens, allergens_known, season_from, season_to) values
+		(1, "farine", null, "gluten", 1, null, null),
+		(2, "pomme", 1, null, 1, 1, 12)
+	`,
+	`insert into ingredient_alias(ingredient_id, name) values (2, "pommes")`,
+	`insert into equipment(id, name, owned) values (1, "robot pâtissier", 1)`,
+	`insert into recipe(id, name, how_to, parent_id, deleted_at) values
+		(1, "pâte brisée", "knead", null, null),
+		(2, "tarte aux pommes", "bake", null, null),
+		(3, "tarte fine", "bake", 2, 1700000000000)
+	`,
+	`insert into recipe_ingredient (recipe_id, ingredient_id, quantity, sub_recipe_id) values
+		(1, 1, "250g", null),
+		(2, null, "1", 1),
+		(2, 2, "4", null),
+		(3, 2, "2", null)
+	`,
+	`insert into recipe_equipment(recipe_id, equipment_id) values (1, 1)`,
+	`insert into recipe_revision(id, recipe_id, created_at, content) values
+		(1, 2, 1700000000000, '{"id": "2", "name": "tarte", "howTo": "bake"}')
+	`,
+)
+
+func TestExport(t *testing.T) {
+	router, err := newTestRouter(t, prepareExportedDatabase)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	status, body := serve(router, http.MethodGet, "/admin/export", "")
+	if status != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+	}
+	var export model.Export
+	if err := json.Unmarshal([]byte(body), &export); err != nil {
+		t.Error(err)
+		return
+	}
+	if export.Version != model.ExportVersion {
+		t.Errorf("unexpected version: wanted [%d], got [%d]", model.ExportVersion, export.Version)
+	}
+	// exportedAt cannot be predicted, so it is left out of the comparison
+	exportWithoutDate, err := json.Marshal(struct {
+		Categories  []model.Category       `json:"categories"`
+		Ingredients []model.Ingredient     `json:"ingredients"`
+		Equipment   []model.Equipment      `json:"equipment"`
+		Recipes     []model.ExportedRecipe `json:"recipes"`
+	}{export.Categories, export.Ingredients, export.Equipment, export.Recipes})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if msg, ok := testutils.JsonResponseBodyTest(`{
+		"categories": [{"id": "1", "name": "fruits et légumes", "aisleOrder": 1}],
+		"ingredients": [
+			{"id": "1", "name": "farine", "allergens": ["gluten"], "allergensKnown": true},
+			{"id": "2", "name": "pomme", "categoryId": "1", "allergensKnown": true, "season": {"from": 1, "to": 12}, "aliases": ["pommes"]}
+		],
+		"equipment": [{"id": "1", "name": "robot pâtissier", "owned": true}],
+		"recipes": [
+			{"id": "1", "name": "pâte brisée", "howTo": "knead", "ingredients": [{"ingredientId": "1", "quantity": "250g"}], "equipmentIds": ["1"]},
+			{
+				"id": "2",
+				"name": "tarte aux pommes",
+				"howTo": "bake",
+				"ingredients": [{"subRecipeId": "1", "quantity": "1"}, {"ingredientId": "2", "quantity": "4"}],
+				"revisions": [{"id": "1", "recipeId": "2", "createdAt": "2023-11-14T22:13:20Z", "recipe": {"id": "2", "name": "tarte", "howTo": "bake"}}]
+			},
+			{"id": "3", "name": "tarte fine", "howTo": "bake", "parentId": "2", "deletedAt": "2023-11-14T22:13:20Z", "ingredients": [{"ingredientId": "2", "quantity": "2"}]}
+		]
+	}`)(string(exportWithoutDate)); !ok {
+		t.Error(msg)
+	}
+}
+
+func TestImport(t *testing.T) {
+	source, err := newTestRouter(t, prepareExportedDatabase)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, export := serve(source, http.MethodGet, "/admin/export", "")
+
+	tests := map[string]struct {
+		path             string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+		// checks are requests made after the import, with their expected status and response
+		checks []importCheck
+	}{
+		"replace existing data": {
+			path:             "/admin/import?mode=replace",
+			body:             export,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"categories": 1, "ingredients": 2, "equipment": 1, "recipes": 3, "revisions": 1}`),
+			checks: []importCheck{
+				{http.MethodGet, "/recipe/4", "", http.StatusNotFound, testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode)},
+				{http.MethodPost, "/recipe/search", `{"searchTerm": "tarte"}`, http.StatusOK, searchResultIDsTest(1, "2")},
+				{http.MethodPost, "/recipe/search", `{"searchTerm": "gâteau"}`, http.StatusOK, searchResultIDsTest(0)},
+				{http.MethodGet, "/recipe/2", "", http.StatusOK, testutils.JsonResponseBodyTest(`{
+					"id": "2",
+					"name": "tarte aux pommes",
+					"howTo": "bake",
+					"diets": ["vegetarian", "vegan"],
+					"allergens": ["gluten"],
+					"allergensKnown": true,
+					"seasonScore": 1,
+					"ingredients": [
+						{"id": "", "subRecipeId": "1", "name": "pâte brisée", "quantity": "1"},
+						{"id": "2", "name": "pomme", "categoryId": "1", "allergensKnown": true, "category": {"id": "1", "name": "fruits et légumes", "aisleOrder": 1}, "season": {"from": 1, "to": 12}, "quantity": "4"}
+					]
+				}`)},
+			},
+		},
+		"merge into existing data": {
+			path:             "/admin/import",
+			body:             export,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"categories": 1, "ingredients": 2, "equipment": 1, "recipes": 3, "revisions": 1}`),
+			checks: []importCheck{
+				{http.MethodPost, "/recipe/search", `{"searchTerm": "gâteau"}`, http.StatusOK, searchResultIDsTest(1, "4")},
+				{http.MethodPost, "/recipe/search", `{"searchTerm": "tarte"}`, http.StatusOK, searchResultIDsTest(1, "2")},
+			},
+		},
+		"unsupported version": {
+			path:             "/admin/import",
+			body:             `{"version": 2}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"unknown mode": {
+			path:             "/admin/import?mode=append",
+			body:             export,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"sub-recipe cycles are rolled back": {
+			path:             "/admin/import?mode=replace",
+			body:             `{"version": 1, "recipes": [{"id": "1", "name": "béchamel", "ingredients": [{"subRecipeId": "2", "quantity": "1"}]}, {"id": "2", "name": "lasagnes", "ingredients": [{"subRecipeId": "1", "quantity": "1"}]}]}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+			checks: []importCheck{
+				{http.MethodPost, "/recipe/search", `{"searchTerm": "gâteau"}`, http.StatusOK, searchResultIDsTest(1, "4")},
+			},
+		},
+		"missing references are rolled back": {
+			path:             "/admin/import?mode=replace",
+			body:             `{"version": 1, "recipes": [{"id": "1", "name": "gaufres", "ingredients": [{"ingredientId": "9"}]}]}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+			checks: []importCheck{
+				{http.MethodPost, "/recipe/search", `{"searchTerm": "gâteau"}`, http.StatusOK, searchResultIDsTest(1, "4")},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, fixture.PrepareDatabase(
+				`insert into ingredient(id, name) values (5, "sucre")`,
+				`insert into recipe(id, name, how_to) values (4, "gâteau", "bake")`,
+				`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values (4, 5, "100g")`,
+			))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			status, body := serve(router, http.MethodPost, test.path, test.body)
+			if status != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, status)
+			}
+			if msg, ok := test.responseBodyTest(body); !ok {
+				t.Error(msg)
+			}
+
+			for _, check := range test.checks {
+				status, body := serve(router, check.method, check.path, check.body)
+				if status != check.expectedStatus {
+					t.Errorf("%s %s: unexpected statusCode: wanted [%d], got [%d]", check.method, check.path, check.expectedStatus, status)
+				}
+				if msg, ok := check.responseBodyTest(body); !ok {
+					t.Errorf("%s %s: %s", check.method, check.path, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestExportBundle(t *testing.T) {
+	router, err := newTestRouter(t, prepareExportedDatabase)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/admin/export/bundle", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+	if rr.Code != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, rr.Code)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != contentTypeZip {
+		t.Errorf("unexpected content type: wanted [%s], got [%s]", contentTypeZip, contentType)
+	}
+	exported, err := bundle.Read(rr.Body.Bytes())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// the recipe in the trash is not exported
+	if diff := testutils.DeepEqual(exported.Recipes, []bundle.Recipe{
+		{ID: "1", Name: "pâte brisée", HowTo: "knead", Ingredients: []bundle.Ingredient{{Name: "farine", Quantity: "250g"}}, Equipment: []string{"robot pâtissier"}},
+		{ID: "2", Name: "tarte aux pommes", HowTo: "bake", Ingredients: []bundle.Ingredient{{Name: "pâte brisée", Quantity: "1", SubRecipeID: "1"}, {Name: "pomme", Quantity: "4"}}},
+	}); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestImportBundle(t *testing.T) {
+	source, err := newTestRouter(t, prepareExportedDatabase)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, exported := serve(source, http.MethodGet, "/admin/export/bundle", "")
+	// the second recipe uses the first one with a quantity that is not a multiplier, which is only detected after the first one is converted
+	var invalid bytes.Buffer
+	if err := bundle.Write(&invalid, bundle.Bundle{Recipes: []bundle.Recipe{
+		{ID: "1", Name: "pâte sablée", HowTo: "knead"},
+		{ID: "2", Name: "tarte au citron", HowTo: "bake", Ingredients: []bundle.Ingredient{{Name: "pâte sablée", Quantity: "200g", SubRecipeID: "1"}}},
+	}}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := map[string]struct {
+		path             string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+		checks           []importCheck
+	}{
+		"skip existing recipes": {
+			path:           "/admin/import/bundle",
+			body:           exported,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"created": 1,
+				"skipped": 1,
+				"overwritten": 0,
+				"duplicated": 0,
+				"recipes": [
+					{"name": "pâte brisée", "id": "5", "status": "created"},
+					{"name": "tarte aux pommes", "id": "4", "status": "skipped"}
+				]
+			}`),
+			checks: []importCheck{
+				{http.MethodGet, "/recipe/5", "", http.StatusOK, testutils.JsonResponseBodyTest(`{
+					"id": "5",
+					"name": "pâte brisée",
+					"howTo": "knead",
+					"ingredients": [{"id": "6", "name": "Farine", "quantity": "250g"}],
+					"equipment": [{"id": "1", "name": "robot pâtissier", "owned": false}]
+				}`)},
+				{http.MethodPost, "/recipe/search", `{"searchTerm": "tarte"}`, http.StatusOK, searchResultIDsTest(1, "4")},
+			},
+		},
+		"overwrite existing recipes": {
+			path:           "/admin/import/bundle?conflict=overwrite",
+			body:           exported,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"created": 1,
+				"skipped": 0,
+				"overwritten": 1,
+				"duplicated": 0,
+				"recipes": [
+					{"name": "pâte brisée", "id": "5", "status": "created"},
+					{"name": "tarte aux pommes", "id": "4", "status": "overwritten"}
+				]
+			}`),
+			checks: []importCheck{
+				{http.MethodGet, "/recipe/4", "", http.StatusOK, testutils.JsonResponseBodyTest(`{
+					"id": "4",
+					"name": "tarte aux pommes",
+					"howTo": "bake",
+					"ingredients": [
+						{"id": "", "subRecipeId": "5", "name": "pâte brisée", "quantity": "1"},
+						{"id": "7", "name": "pomme", "quantity": "4"}
+					]
+				}`)},
+			},
+		},
+		"duplicate existing recipes": {
+			path:           "/admin/import/bundle?conflict=duplicate",
+			body:           exported,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"created": 1,
+				"skipped": 0,
+				"overwritten": 0,
+				"duplicated": 1,
+				"recipes": [
+					{"name": "pâte brisée", "id": "5", "status": "created"},
+					{"name": "tarte aux pommes", "id": "6", "status": "duplicated"}
+				]
+			}`),
+			checks: []importCheck{
+				{http.MethodPost, "/recipe/search", `{"searchTerm": "tarte"}`, http.StatusOK, searchResultIDsTest(2, "4", "6")},
+			},
+		},
+		"invalid recipe imports nothing": {
+			path:             "/admin/import/bundle",
+			body:             invalid.String(),
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+			checks: []importCheck{
+				{http.MethodPost, "/recipe/search", `{"searchTerm": "sablée"}`, http.StatusOK, searchResultIDsTest(0)},
+			},
+		},
+		"unknown conflict strategy": {
+			path:             "/admin/import/bundle?conflict=rename",
+			body:             exported,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"not a bundle": {
+			path:             "/admin/import/bundle",
+			body:             `{"version": 1}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, fixture.PrepareDatabase(
+				`insert into ingredient(id, name) values (5, "sucre"), (6, "Farine")`,
+				`insert into recipe(id, name, how_to) values (4, "Tarte aux pommes", "bake")`,
+				`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values (4, 5, "100g")`,
+			))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			status, body := serve(router, http.MethodPost, test.path, test.body)
+			if status != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, status)
+			}
+			if msg, ok := test.responseBodyTest(body); !ok {
+				t.Error(msg)
+			}
+
+			for _, check := range test.checks {
+				status, body := serve(router, check.method, check.path, check.body)
+				if status != check.expectedStatus {
+					t.Errorf("%s %s: unexpected statusCode: wanted [%d], got [%d]", check.method, check.path, check.expectedStatus, status)
+				}
+				if msg, ok := check.responseBodyTest(body); !ok {
+					t.Errorf("%s %s: %s", check.method, check.path, msg)
+				}
+			}
+		})
+	}
+}
+
+var prepareInconsistentDatabase = fixture.PrepareDatabase(
+	`insert into category(id, name, aisle_order) values (1, " ", 1)`,
+	`insert into ingredient(id, name, category_id) values (1, "farine", 1), (2, "sucre", 4)`,
+	`insert into ingredient_alias(ingredient_id, name) values (1, "farine de blé"), (1, "farine de blé"), (3, "beurre")`,
+	`insert into equipment(id, name) values (1, "four")`,
+	`insert into recipe(id, name, how_to, parent_id) values (1, "gâteau", "bake", null), (2, "", "mix", 9)`,
+	`insert into recipe_ingredient (recipe_id, ingredient_id, quantity, sub_recipe_id) values
+		(1, 1, "100g", null),
+		(1, 1, "200g", null),
+		(1, 3, "50g", null),
+		(2, null, "1", 8),
+		(2, null, "1", null),
+		(7, 2, "1", null)
+	`,
+	`insert into recipe_equipment(recipe_id, equipment_id) values (1, 1), (1, 5), (6, 1)`,
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	tests := map[string]struct {
+		prepareDatabase  func(*datasource.DatabaseHolder) error
+		method           string
+		path             string
+		responseBodyTest func(string) (string, bool)
+		checks           []importCheck
+	}{
+		"consistent database": {
+			prepareDatabase:  prepareExportedDatabase,
+			method:           http.MethodGet,
+			path:             "/admin/check",
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"problems": [], "repaired": false}`),
+		},
+		"inconsistent database": {
+			prepareDatabase: prepareInconsistentDatabase,
+			method:          http.MethodGet,
+			path:            "/admin/check",
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"problems": [
+					{"kind": "orphan", "table": "recipe_ingredient", "id": "7", "message": "ingredient of missing recipe"},
+					{"kind": "orphan", "table": "recipe_ingredient", "id": "1", "message": "link to missing ingredient 3"},
+					{"kind": "orphan", "table": "recipe_ingredient", "id": "2", "message": "link to missing sub-recipe 8"},
+					{"kind": "orphan", "table": "recipe_ingredient", "id": "2", "message": "link to neither an ingredient nor a sub-recipe"},
+					{"kind": "orphan", "table": "recipe_equipment", "id": "6", "message": "equipment of missing recipe"},
+					{"kind": "orphan", "table": "recipe_equipment", "id": "1", "message": "link to missing equipment 5"},
+					{"kind": "orphan", "table": "ingredient_alias", "id": "3", "message": "alias [beurre] of missing ingredient"},
+					{"kind": "orphan", "table": "recipe", "id": "2", "message": "variant of missing recipe 9"},
+					{"kind": "orphan", "table": "ingredient", "id": "2", "message": "in missing category 4"},
+					{"kind": "duplicateLink", "table": "recipe_ingredient", "id": "1", "message": "ingredient 1 linked 2 times"},
+					{"kind": "duplicateLink", "table": "ingredient_alias", "id": "1", "message": "alias [farine de blé] stored 2 times"},
+					{"kind": "emptyName", "table": "recipe", "id": "2", "message": "recipe without name"},
+					{"kind": "emptyName", "table": "category", "id": "1", "message": "category without name"}
+				],
+				"repaired": false
+			}`),
+		},
+		"repair": {
+			prepareDatabase: prepareInconsistentDatabase,
+			method:          http.MethodPost,
+			path:            "/admin/check/repair",
+			responseBodyTest: func(body string) (string, bool) {
+				var report model.IntegrityReport
+				if err := json.Unmarshal([]byte(body), &report); err != nil {
+					return err.Error(), false
+				}
+				if !report.Repaired || len(report.Problems) != 13 || len(report.Remaining) != 0 {
+					return "unexpected report: " + body, false
+				}
+				return "", true
+			},
+			checks: []importCheck{
+				{http.MethodGet, "/admin/check", "", http.StatusOK, testutils.JsonResponseBodyTest(`{"problems": [], "repaired": false}`)},
+				{http.MethodGet, "/recipe/1", "", http.StatusOK, testutils.JsonResponseBodyTest(`{
+					"id": "1",
+					"name": "gâteau",
+					"howTo": "bake",
+					"ingredients": [{"id": "1", "name": "farine", "categoryId": "1", "category": {"id": "1", "name": "sans nom 1", "aisleOrder": 1}, "quantity": "100g"}],
+					"equipment": [{"id": "1", "name": "four", "owned": false}]
+				}`)},
+				{http.MethodGet, "/recipe/2", "", http.StatusOK, testutils.JsonResponseBodyTest(`{"id": "2", "name": "sans nom 2", "howTo": "mix", "diets": ["vegetarian", "vegan", "glutenFree"], "allergensKnown": true}`)},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, test.prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			status, body := serve(router, test.method, test.path, "")
+			if status != http.StatusOK {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+			}
+			if msg, ok := test.responseBodyTest(body); !ok {
+				t.Error(msg)
+			}
+
+			for _, check := range test.checks {
+				status, body := serve(router, check.method, check.path, check.body)
+				if status != check.expectedStatus {
+					t.Errorf("%s %s: unexpected statusCode: wanted [%d], got [%d]", check.method, check.path, check.expectedStatus, status)
+				}
+				if msg, ok := check.responseBodyTest(body); !ok {
+					t.Errorf("%s %s: %s", check.method, check.path, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestBackup(t *testing.T) {
+	router, err := newTestRouter(t, prepareExportedDatabase)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// backing up twice the same day replaces the daily copy, and keeps the weekly copy made by the first backup
+	for i := 0; i < 2; i++ {
+		status, body := serve(router, http.MethodPost, "/admin/backup", "")
+		if status != http.StatusOK {
+			t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+		}
+		var backupStatus model.BackupStatus
+		if err := json.Unmarshal([]byte(body), &backupStatus); err != nil {
+			t.Error(err)
+			return
+		}
+		if backupStatus.LastBackupAt == nil {
+			t.Error("missing last backup time")
+		}
+		kinds := map[string]int{}
+		for _, backup := range backupStatus.Backups {
+			kinds[backup.Kind]++
+			if backup.Size == 0 || !strings.HasPrefix(backup.Name, "miam-"+backup.Kind+"-") {
+				t.Errorf("unexpected backup %+v", backup)
+			}
+		}
+		if diff := testutils.DeepEqual(kinds, map[string]int{model.BackupDaily: 1, model.BackupWeekly: 1}); diff != "" {
+			t.Error(diff)
+		}
+	}
+
+	_, body := serve(router, http.MethodGet, "/healthz", "")
+	var health model.Health
+	if err := json.Unmarshal([]byte(body), &health); err != nil {
+		t.Error(err)
+		return
+	}
+	if health.LastBackupAt == nil {
+		t.Errorf("missing last backup time in health: %s", body)
+	}
+}
+
+// importCheck is a request made after an import to check its outcome
+type importCheck struct {
+	method           string
+	path             string
+	body             string
+	expectedStatus   int
+	responseBodyTest func(string) (string, bool)
+}
+
+// serve sends a request to the router and returns the status and body of the response
+func serve(router http.Handler, method, path, body string) (int, string) {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+	responseBody, _ := io.ReadAll(rr.Result().Body)
+	return rr.Result().StatusCode, string(responseBody)
+}
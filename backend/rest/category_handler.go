@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/rest"
+	"github.com/remieven/miam/service"
+)
+
+// CategoryHandler is an ingredient category handler
+type CategoryHandler struct {
+	categoryService *service.CategoryService
+}
+
+func newCategoryHandler(categoryService *service.CategoryService) *CategoryHandler {
+	return &CategoryHandler{
+		categoryService,
+	}
+}
+
+// GetCategories returns all categories, sorted by aisle order
+func (handler *CategoryHandler) GetCategories(responseWriter http.ResponseWriter, request *http.Request) {
+	categories, err := handler.categoryService.GetCategories(request.Context())
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, categories)
+}
+
+// AddCategory adds a category
+func (handler *CategoryHandler) AddCategory(responseWriter http.ResponseWriter, request *http.Request) {
+	var category model.BaseCategory
+	if err := json.NewDecoder(request.Body).Decode(&category); rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	id, err := handler.categoryService.AddCategory(request.Context(), category)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteCreatedResponse(responseWriter, request, id)
+}
+
+// UpdateCategory updates a category
+func (handler *CategoryHandler) UpdateCategory(responseWriter http.ResponseWriter, request *http.Request) {
+	var baseCategory model.BaseCategory
+	if err := json.NewDecoder(request.Body).Decode(&baseCategory); rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	vars := mux.Vars(request)
+	category, err := handler.categoryService.UpdateCategory(request.Context(), vars["id"], baseCategory)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, category)
+}
+
+// ReorderCategories sets the aisle order of the categories from an ordered list of their IDs
+func (handler *CategoryHandler) ReorderCategories(responseWriter http.ResponseWriter, request *http.Request) {
+	var IDs []string
+	if err := json.NewDecoder(request.Body).Decode(&IDs); rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	categories, err := handler.categoryService.ReorderCategories(request.Context(), IDs)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, categories)
+}
+
+// DeleteCategory deletes the category with the given id
+func (handler *CategoryHandler) DeleteCategory(responseWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	if err := handler.categoryService.DeleteCategory(request.Context(), vars["id"]); rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteNoContentResponse(responseWriter)
+}
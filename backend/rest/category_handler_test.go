@@ -0,0 +1,212 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/pb-lite/fixture"
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+func TestCategories(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into category(id, name, aisle_order) values
+			(1, "épices", 3),
+			(2, "légumes", 1),
+			(3, "crèmerie", 2)
+		`,
+		`insert into ingredient(id, name, category_id) values
+			(1, "sel", 1),
+			(2, "crème", 3),
+			(3, "pâtes", null),
+			(4, "carotte", 2)
+		`,
+		`insert into recipe(id, name, how_to) values (1, "pâtes carbonara", "cook")`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values
+			(1, 1, "1 pincée"),
+			(1, 3, "500g"),
+			(1, 2, "20cl")
+		`,
+	)
+
+	tests := map[string]struct {
+		prepareDatabase  func(*datasource.DatabaseHolder) error
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		expectedLocation string
+		responseBodyTest func(string) (string, bool)
+	}{
+		"no categories": {
+			method:           http.MethodGet,
+			path:             "/category",
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[]`),
+		},
+		"categories are sorted by aisle order": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodGet,
+			path:            "/category",
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[
+				{"id": "2", "name": "légumes", "aisleOrder": 1},
+				{"id": "3", "name": "crèmerie", "aisleOrder": 2},
+				{"id": "1", "name": "épices", "aisleOrder": 3}
+			]`),
+		},
+		"add category": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/category",
+			body:             `{"name": "surgelés"}`,
+			expectedStatus:   http.StatusCreated,
+			expectedLocation: "4",
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"add category without name": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/category",
+			body:             `{"name": " "}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"update category": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPut,
+			path:             "/category/1",
+			body:             `{"name": "épices et condiments", "aisleOrder": 4}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"id": "1", "name": "épices et condiments", "aisleOrder": 4}`),
+		},
+		"update unknown category": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPut,
+			path:             "/category/42",
+			body:             `{"name": "boissons"}`,
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"reorder categories": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodPut,
+			path:            "/category/order",
+			body:            `["1", "2", "3"]`,
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[
+				{"id": "1", "name": "épices", "aisleOrder": 1},
+				{"id": "2", "name": "légumes", "aisleOrder": 2},
+				{"id": "3", "name": "crèmerie", "aisleOrder": 3}
+			]`),
+		},
+		"reorder unknown category": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPut,
+			path:             "/category/order",
+			body:             `["1", "42"]`,
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"delete category": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodDelete,
+			path:             "/category/1",
+			expectedStatus:   http.StatusNoContent,
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"delete unknown category": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodDelete,
+			path:             "/category/42",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"ingredients sorted by aisle": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodGet,
+			path:            "/ingredient?sort=aisle",
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[
+				{"id": "4", "name": "carotte", "categoryId": "2", "category": {"id": "2", "name": "légumes", "aisleOrder": 1}},
+				{"id": "2", "name": "crème", "categoryId": "3", "category": {"id": "3", "name": "crèmerie", "aisleOrder": 2}},
+				{"id": "1", "name": "sel", "categoryId": "1", "category": {"id": "1", "name": "épices", "aisleOrder": 3}},
+				{"id": "3", "name": "pâtes"}
+			]`),
+		},
+		"recipe ingredients sorted by aisle": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodGet,
+			path:            "/recipe/1?sort=aisle",
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "1",
+				"name": "pâtes carbonara",
+				"howTo": "cook",
+				"ingredients": [
+					{"id": "2", "name": "crème", "categoryId": "3", "category": {"id": "3", "name": "crèmerie", "aisleOrder": 2}, "quantity": "20cl"},
+					{"id": "1", "name": "sel", "categoryId": "1", "category": {"id": "1", "name": "épices", "aisleOrder": 3}, "quantity": "1 pincée"},
+					{"id": "3", "name": "pâtes", "quantity": "500g"}
+				]
+			}`),
+		},
+		"set ingredient category": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPut,
+			path:             "/ingredient/3",
+			body:             `{"name": "pâtes", "categoryId": "2"}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"id": "3", "name": "pâtes", "categoryId": "2", "category": {"id": "2", "name": "légumes", "aisleOrder": 1}}`),
+		},
+		"set unknown ingredient category": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPut,
+			path:             "/ingredient/3",
+			body:             `{"name": "pâtes", "categoryId": "42"}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, test.prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			request, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+
+			if location := rr.Result().Header.Get("Location"); location != test.expectedLocation {
+				t.Errorf("unexpected location: wanted [%s], got [%s]", test.expectedLocation, location)
+			}
+
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/rest"
+	"github.com/remieven/miam/service"
+)
+
+// EquipmentHandler is a kitchen equipment handler
+type EquipmentHandler struct {
+	equipmentService *service.EquipmentService
+}
+
+func newEquipmentHandler(equipmentService *service.EquipmentService) *EquipmentHandler {
+	return &EquipmentHandler{
+		equipmentService,
+	}
+}
+
+// GetAllEquipment returns all the equipment, sorted by name
+func (handler *EquipmentHandler) GetAllEquipment(responseWriter http.ResponseWriter, request *http.Request) {
+	equipment, err := handler.equipmentService.GetAllEquipment(request.Context())
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, equipment)
+}
+
+// AddEquipment adds a piece of equipment
+func (handler *EquipmentHandler) AddEquipment(responseWriter http.ResponseWriter, request *http.Request) {
+	var equipment model.BaseEquipment
+	if err := json.NewDecoder(request.Body).Decode(&equipment); rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	id, err := handler.equipmentService.AddEquipment(request.Context(), equipment)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteCreatedResponse(responseWriter, request, id)
+}
+
+// UpdateEquipment updates a piece of equipment
+func (handler *EquipmentHandler) UpdateEquipment(responseWriter http.ResponseWriter, request *http.Request) {
+	var baseEquipment model.BaseEquipment
+	if err := json.NewDecoder(request.Body).Decode(&baseEquipment); rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	vars := mux.Vars(request)
+	equipment, err := handler.equipmentService.UpdateEquipment(request.Context(), vars["id"], baseEquipment)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, equipment)
+}
+
+// SetOwnedEquipment sets the owned equipment from the list of their IDs
+func (handler *EquipmentHandler) SetOwnedEquipment(responseWriter http.ResponseWriter, request *http.Request) {
+	var IDs []string
+	if err := json.NewDecoder(request.Body).Decode(&IDs); rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	equipment, err := handler.equipmentService.SetOwnedEquipment(request.Context(), IDs)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, equipment)
+}
+
+// DeleteEquipment deletes the piece of equipment with the given id
+func (handler *EquipmentHandler) DeleteEquipment(responseWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	if err := handler.equipmentService.DeleteEquipment(request.Context(), vars["id"]); rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteNoContentResponse(responseWriter)
+}
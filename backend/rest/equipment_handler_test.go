@@ -0,0 +1,266 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/pb-lite/fixture"
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+func TestEquipment(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into equipment(id, name, owned) values (1, "robot pâtissier", 1), (2, "machine à pâtes", 0), (3, "autocuiseur", 0)`,
+	)
+
+	tests := map[string]struct {
+		prepareDatabase  func(*datasource.DatabaseHolder) error
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		"no equipment": {
+			method:           http.MethodGet,
+			path:             "/equipment",
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[]`),
+		},
+		"list equipment sorted by name": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodGet,
+			path:            "/equipment",
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[
+				{"id": "3", "name": "autocuiseur", "owned": false},
+				{"id": "2", "name": "machine à pâtes", "owned": false},
+				{"id": "1", "name": "robot pâtissier", "owned": true}
+			]`),
+		},
+		"add equipment": {
+			method:           http.MethodPost,
+			path:             "/equipment",
+			body:             `{"name": "mandoline", "owned": true}`,
+			expectedStatus:   http.StatusCreated,
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"add equipment without name": {
+			method:           http.MethodPost,
+			path:             "/equipment",
+			body:             `{"name": " "}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"update equipment": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPut,
+			path:             "/equipment/3",
+			body:             `{"name": "cocotte-minute", "owned": true}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"id": "3", "name": "cocotte-minute", "owned": true}`),
+		},
+		"update unknown equipment": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPut,
+			path:             "/equipment/4",
+			body:             `{"name": "mandoline"}`,
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"set owned equipment": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodPut,
+			path:            "/equipment/owned",
+			body:            `["2", "3"]`,
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[
+				{"id": "3", "name": "autocuiseur", "owned": true},
+				{"id": "2", "name": "machine à pâtes", "owned": true},
+				{"id": "1", "name": "robot pâtissier", "owned": false}
+			]`),
+		},
+		"set unknown owned equipment": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPut,
+			path:             "/equipment/owned",
+			body:             `["2", "4"]`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"delete equipment": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodDelete,
+			path:             "/equipment/2",
+			expectedStatus:   http.StatusNoContent,
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"delete unknown equipment": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodDelete,
+			path:             "/equipment/4",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, test.prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			request, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestSearchRecipesWithOwnedEquipment(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into equipment(id, name, owned) values (1, "robot pâtissier", 1), (2, "machine à pâtes", 0)`,
+		`insert into ingredient(id, name) values (1, "farine")`,
+		`insert into recipe(id, name, how_to) values
+			(1, "brioche", "knead"),
+			(2, "pâtes fraîches", "roll"),
+			(3, "lasagnes", "bake"),
+			(4, "crêpes", "mix")
+		`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, quantity, sub_recipe_id) values
+			(1, 1, "500g", null),
+			(2, 1, "300g", null),
+			(3, null, "1", 2),
+			(4, 1, "250g", null)
+		`,
+		`insert into recipe_equipment (recipe_id, equipment_id) values (1, 1), (2, 2)`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	steps := []struct {
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		{
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"ownedEquipmentOnly": true}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: searchResultIDsTest(2, "1", "4"),
+		},
+		{
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"excludedEquipment": ["1"]}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: searchResultIDsTest(3, "2", "3", "4"),
+		},
+		{
+			method:         http.MethodPut,
+			path:           "/recipe/4",
+			body:           `{"name": "crêpes", "howTo": "mix", "ingredients": [{"id": "1", "name": "farine", "quantity": "250g"}], "equipment": [{"id": "1"}, {"id": "1"}]}`,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "4",
+				"name": "crêpes",
+				"howTo": "mix",
+				"ingredients": [{"id": "1", "name": "farine", "quantity": "250g"}],
+				"equipment": [{"id": "1", "name": "robot pâtissier", "owned": true}]
+			}`),
+		},
+		{
+			method:           http.MethodPut,
+			path:             "/recipe/4",
+			body:             `{"name": "crêpes", "howTo": "mix", "equipment": [{"id": "3"}]}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		{
+			method:         http.MethodPut,
+			path:           "/equipment/owned",
+			body:           `["2"]`,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[
+				{"id": "2", "name": "machine à pâtes", "owned": true},
+				{"id": "1", "name": "robot pâtissier", "owned": false}
+			]`),
+		},
+		{
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"ownedEquipmentOnly": true}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: searchResultIDsTest(2, "2", "3"),
+		},
+		{
+			method:           http.MethodDelete,
+			path:             "/equipment/1",
+			expectedStatus:   http.StatusNoContent,
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		{
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"ownedEquipmentOnly": true, "excludedRecipes": ["4"]}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: searchResultIDsTest(3, "1", "2", "3"),
+		},
+	}
+
+	for _, step := range steps {
+		request, err := http.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, request)
+
+		if rr.Result().StatusCode != step.expectedStatus {
+			t.Errorf("%s %s: unexpected statusCode: wanted [%d], got [%d]", step.method, step.path, step.expectedStatus, rr.Result().StatusCode)
+		}
+
+		responseBody, err := io.ReadAll(rr.Result().Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if msg, ok := step.responseBodyTest(string(responseBody)); !ok {
+			t.Errorf("%s %s: %s", step.method, step.path, msg)
+		}
+	}
+}
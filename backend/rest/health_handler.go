@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/rest"
+	"github.com/remieven/miam/service"
+)
+
+// HealthHandler is a handler telling whether the application is working
+type HealthHandler struct {
+	healthService *service.HealthService
+}
+
+func newHealthHandler(healthService *service.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService,
+	}
+}
+
+// GetHealth tells that the application is alive, along with the time of the last backup of the database
+func (handler *HealthHandler) GetHealth(responseWriter http.ResponseWriter, _ *http.Request) {
+	rest.WriteOKResponse(responseWriter, handler.healthService.GetHealth())
+}
+
+// GetReadiness tells whether the application is ready to serve requests, answering with a 503 status until it is
+func (handler *HealthHandler) GetReadiness(responseWriter http.ResponseWriter, request *http.Request) {
+	readiness := handler.healthService.GetReadiness(request.Context())
+	if readiness.Status == model.ReadinessReady {
+		rest.WriteOKResponse(responseWriter, readiness)
+		return
+	}
+	responseWriter.Header().Set(rest.HeaderContentType, rest.ContentTypeJSONUTF8)
+	responseWriter.WriteHeader(http.StatusServiceUnavailable)
+	if err := json.NewEncoder(responseWriter).Encode(readiness); err != nil {
+		slog.With("error", err).Error("failed to write readiness response")
+	}
+}
+
+// GetVersion returns the version of the application and how it was built
+func (handler *HealthHandler) GetVersion(responseWriter http.ResponseWriter, _ *http.Request) {
+	rest.WriteOKResponse(responseWriter, handler.healthService.GetVersion())
+}
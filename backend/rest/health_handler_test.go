@@ -0,0 +1,104 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+func TestGetHealth(t *testing.T) {
+	router, err := newTestRouter(t, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	status, body := serve(router, http.MethodGet, "/healthz", "")
+	if status != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+	}
+	if msg, ok := testutils.JsonResponseBodyTest(`{"status": "ok"}`)(body); !ok {
+		t.Error(msg)
+	}
+}
+
+func TestGetReadiness(t *testing.T) {
+	router, err := newTestRouter(t, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	status, body := serve(router, http.MethodGet, "/readyz", "")
+	if status != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+	}
+	if msg, ok := testutils.JsonResponseBodyTest(`{
+		"status": "ready",
+		"checks": [
+			{"name": "database", "ready": true},
+			{"name": "index", "ready": true}
+		]
+	}`)(body); !ok {
+		t.Error(msg)
+	}
+}
+
+func TestGetReadinessWithoutDatabase(t *testing.T) {
+	var databaseHolder *datasource.DatabaseHolder
+	router, err := newTestRouter(t, func(holder *datasource.DatabaseHolder) error {
+		databaseHolder = holder
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := databaseHolder.Close(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	status, body := serve(router, http.MethodGet, "/readyz", "")
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusServiceUnavailable, status)
+	}
+	if msg, ok := testutils.JsonResponseBodyTest(`{
+		"status": "notReady",
+		"checks": [
+			{"name": "database", "ready": false, "message": "sql: database is closed"},
+			{"name": "index", "ready": true}
+		]
+	}`)(body); !ok {
+		t.Error(msg)
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	router, err := newTestRouter(t, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	status, body := serve(router, http.MethodGet, "/version", "")
+	if status != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+	}
+	var version model.Version
+	if err := json.Unmarshal([]byte(body), &version); err != nil {
+		t.Errorf("failed to parse version [%s]: %v", body, err)
+		return
+	}
+	if version.Version == "" {
+		t.Error("missing version")
+	}
+	if version.GoVersion != runtime.Version() {
+		t.Errorf("unexpected Go version: wanted [%s], got [%s]", runtime.Version(), version.GoVersion)
+	}
+}
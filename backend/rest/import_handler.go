@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/pb-lite/rest"
+	"github.com/remieven/miam/service"
+)
+
+// maxImportedFileSize is the maximum size of an imported file, which is enough for most recipe web pages
+const maxImportedFileSize = 10 << 20
+
+// ImportHandler is a handler importing recipes written in other formats
+type ImportHandler struct {
+	importService *service.ImportService
+}
+
+func newImportHandler(importService *service.ImportService) *ImportHandler {
+	return &ImportHandler{
+		importService,
+	}
+}
+
+// ImportRecipe imports a recipe, either uploaded as a file or sent as the request body; the format query param tells how it is written:
+// as a schema.org recipe in an HTML page or in a JSON-LD document (default), or in Cooklang, in which case the recipe is named after the uploaded file
+// or the name query param when it has no title. The recipe is returned as a draft unless the save query param is true, in which case it is added.
+func (handler *ImportHandler) ImportRecipe(responseWriter http.ResponseWriter, request *http.Request) {
+	data, fileName, err := readImportedFile(responseWriter, request, maxImportedFileSize)
+	if rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	query := request.URL.Query()
+	save := query.Get("save") == "true"
+	var (
+		recipe *model.BaseRecipe
+		id     string
+	)
+	switch format := query.Get("format"); format {
+	case "", "jsonld":
+		recipe, id, err = handler.importService.ImportSchemaOrgRecipe(request.Context(), data, save)
+	case "cooklang":
+		name := query.Get("name")
+		if name == "" {
+			name = strings.TrimSuffix(fileName, filepath.Ext(fileName))
+		}
+		recipe, id, err = handler.importService.ImportCooklangRecipe(request.Context(), data, name, save)
+	default:
+		err = &failure.InvalidValueError{
+			Message: "unknown import format [" + format + "], expected [jsonld] or [cooklang]",
+		}
+	}
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	if id != "" {
+		rest.WriteCreatedResponse(responseWriter, request, id)
+		return
+	}
+	rest.WriteOKResponse(responseWriter, recipe)
+}
+
+// ImportRecipeFile imports all the recipes of a file exported by another application, either uploaded as a file or sent as the request body;
+// the format query param tells which application: paprika or mealmaster. The recipes are returned as drafts along with the parts of the file
+// that could not be read, unless the save query param is true, in which case they are added and their IDs are returned too.
+func (handler *ImportHandler) ImportRecipeFile(responseWriter http.ResponseWriter, request *http.Request) {
+	data, _, err := readImportedFile(responseWriter, request, maxImportedFileSize)
+	if rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	query := request.URL.Query()
+	imported, err := handler.importService.ImportRecipeFile(request.Context(), query.Get("format"), data, query.Get("save") == "true")
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, imported)
+}
+
+// readImportedFile reads the file field of a multipart form along with its name, or the whole request body for other content types, up to the given size
+func readImportedFile(responseWriter http.ResponseWriter, request *http.Request, maxSize int64) ([]byte, string, error) {
+	request.Body = http.MaxBytesReader(responseWriter, request.Body, maxSize)
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		data, err := io.ReadAll(request.Body)
+		return data, "", err
+	}
+
+	file, header, err := request.FormFile("file")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	return data, header.Filename, err
+}
@@ -0,0 +1,459 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/pb-lite/fixture"
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+const importedJSONLD = `{
+	"@context": "https://schema.org",
+	"@type": "Recipe",
+	"name": "Crêpes",
+	"recipeIngredient": ["250 g de farine", "4 Œufs", "50 cl de lait", "1 pincée de sel", "sel"],
+	"recipeInstructions": [{"@type": "HowToStep", "text": "Mélanger."}, {"@type": "HowToStep", "text": "Cuire."}],
+	"recipeYield": "8 crêpes",
+	"prepTime": "PT10M",
+	"cookTime": "PT20M"
+}`
+
+func TestImportSchemaOrgRecipe(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "farine"), (2, "œuf"), (3, "lait entier")`,
+		`insert into ingredient_alias(ingredient_id, name) values (3, "lait")`,
+	)
+
+	uploadedPage := &bytes.Buffer{}
+	multipartWriter := multipart.NewWriter(uploadedPage)
+	fileWriter, err := multipartWriter.CreateFormFile("file", "crepes.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(fileWriter, `<html><head><script type="application/ld+json">`+importedJSONLD+`</script></head><body></body></html>`); err != nil {
+		t.Fatal(err)
+	}
+	if err := multipartWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedDraft := `{
+		"name": "Crêpes",
+		"howTo": "Mélanger.\nCuire.",
+		"ingredients": [
+			{"id": "1", "name": "farine", "quantity": "250 g"},
+			{"id": "2", "name": "œuf", "quantity": "4"},
+			{"id": "3", "name": "lait entier", "quantity": "50 cl"},
+			{"id": "", "name": "sel", "quantity": "1 pincée"}
+		],
+		"yield": "8 crêpes",
+		"prepMinutes": 10,
+		"cookMinutes": 20
+	}`
+
+	tests := map[string]struct {
+		path             string
+		contentType      string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		"raw JSON-LD": {
+			path:             "/recipe/import",
+			contentType:      "application/ld+json",
+			body:             importedJSONLD,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(expectedDraft),
+		},
+		"uploaded HTML page": {
+			path:             "/recipe/import",
+			contentType:      multipartWriter.FormDataContentType(),
+			body:             uploadedPage.String(),
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(expectedDraft),
+		},
+		"saved recipe": {
+			path:             "/recipe/import?save=true",
+			contentType:      "application/ld+json",
+			body:             importedJSONLD,
+			expectedStatus:   http.StatusCreated,
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"page without recipe": {
+			path:             "/recipe/import",
+			contentType:      "text/html",
+			body:             `<html><body><p>Crêpes</p></body></html>`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"upload without file": {
+			path:             "/recipe/import",
+			contentType:      "multipart/form-data; boundary=nothing",
+			body:             "--nothing--\r\n",
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			request := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body))
+			request.Header.Set("Content-Type", test.contentType)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestImportAndSaveSchemaOrgRecipe(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "farine")`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/recipe/import?save=true", strings.NewReader(importedJSONLD))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+	if rr.Result().StatusCode != http.StatusCreated {
+		t.Fatalf("unexpected statusCode: wanted [%d], got [%d]", http.StatusCreated, rr.Result().StatusCode)
+	}
+
+	status, body := serve(router, http.MethodGet, "/recipe/"+rr.Result().Header.Get("Location"), "")
+	if status != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+	}
+	if msg, ok := testutils.JsonResponseBodyTest(`{
+		"id": "1",
+		"name": "Crêpes",
+		"howTo": "Mélanger.\nCuire.",
+		"ingredients": [
+			{"id": "1", "name": "farine", "quantity": "250 g"},
+			{"id": "2", "name": "Œufs", "quantity": "4"},
+			{"id": "3", "name": "lait", "quantity": "50 cl"},
+			{"id": "4", "name": "sel", "quantity": "1 pincée"}
+		],
+		"yield": "8 crêpes",
+		"prepMinutes": 10,
+		"cookMinutes": 20
+	}`)(body); !ok {
+		t.Error(msg)
+	}
+}
+
+const importedCooklang = `>> servings: 4
+
+Faire revenir l'@oignon{1} dans le #faitout{}.
+Ajouter les @pommes de terre{500%g} et les @lardons{200%g}, puis cuire ~{25%minutes}.
+`
+
+func TestImportCooklangRecipe(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "oignon"), (2, "pomme de terre")`,
+		`insert into equipment(id, name, owned) values (1, "four", 1)`,
+	)
+
+	uploadedFile := &bytes.Buffer{}
+	multipartWriter := multipart.NewWriter(uploadedFile)
+	fileWriter, err := multipartWriter.CreateFormFile("file", "tartiflette.cook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(fileWriter, importedCooklang); err != nil {
+		t.Fatal(err)
+	}
+	if err := multipartWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		path             string
+		contentType      string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		"uploaded file": {
+			path:           "/recipe/import?format=cooklang",
+			contentType:    multipartWriter.FormDataContentType(),
+			body:           uploadedFile.String(),
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"name": "tartiflette",
+				"howTo": "Faire revenir l'oignon dans le faitout. Ajouter les pommes de terre et les lardons, puis cuire 25 minutes.",
+				"ingredients": [
+					{"id": "1", "name": "oignon", "quantity": "1"},
+					{"id": "2", "name": "pomme de terre", "quantity": "500 g"},
+					{"id": "", "name": "lardons", "quantity": "200 g"}
+				],
+				"yield": "4",
+				"cookMinutes": 25,
+				"equipment": [{"id": "", "name": "faitout", "owned": false}]
+			}`),
+		},
+		"raw text without name": {
+			path:             "/recipe/import?format=cooklang",
+			contentType:      "text/plain",
+			body:             importedCooklang,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"raw text with name": {
+			path:             "/recipe/import?format=cooklang&name=tartiflette&save=true",
+			contentType:      "text/plain",
+			body:             importedCooklang,
+			expectedStatus:   http.StatusCreated,
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"empty recipe": {
+			path:             "/recipe/import?format=cooklang&name=tartiflette",
+			contentType:      "text/plain",
+			body:             "-- nothing yet",
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"unknown format": {
+			path:             "/recipe/import?format=mealmaster",
+			contentType:      "text/plain",
+			body:             importedCooklang,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			request := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body))
+			request.Header.Set("Content-Type", test.contentType)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestCooklangRoundTrip(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "oignon")`,
+		`insert into equipment(id, name, owned) values (1, "faitout", 1)`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	status, _ := serve(router, http.MethodPost, "/recipe/import?format=cooklang&name=tartiflette&save=true", importedCooklang)
+	if status != http.StatusCreated {
+		t.Fatalf("unexpected statusCode: wanted [%d], got [%d]", http.StatusCreated, status)
+	}
+
+	status, body := serve(router, http.MethodGet, "/recipe/1?format=cooklang", "")
+	if status != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+	}
+	expectedBody := `>> title: tartiflette
+>> servings: 4
+>> cook time: 25 minutes
+
+Faire revenir l'@oignon{1} dans le #faitout. Ajouter les @pommes de terre{500%g} et les @lardons{200%g}, puis cuire 25 minutes.
+`
+	if body != expectedBody {
+		t.Errorf("got:\n%s\nwanted:\n%s", body, expectedBody)
+	}
+}
+
+const importedMealMaster = `MMMMM----- Recipe via Meal-Master (tm) v8.05
+
+      Title: Shortbread
+      Yield: 24 cookies
+
+    250 g  Farine
+    125 g  Beurre demi-sel
+      1 bx Sucre
+
+  Mix and bake.
+
+MMMMM
+
+MMMMM----- Recipe via Meal-Master (tm) v8.05
+
+      Title: Caramel
+
+    100 g  Sucre
+     50 g  beurre demi-sel
+
+  Melt.
+`
+
+func TestImportRecipeFile(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "farine"), (2, "sucre")`,
+	)
+
+	var paprikaRecipe bytes.Buffer
+	gzipWriter := gzip.NewWriter(&paprikaRecipe)
+	if _, err := io.WriteString(gzipWriter, `{"name": "Pain", "ingredients": "500 g farine\n1 sachet de levure", "directions": "Pétrir.\nCuire.", "cook_time": "40 mins"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		path             string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		"MealMaster file": {
+			path:           "/recipe/import/bulk?format=mealmaster",
+			body:           importedMealMaster,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"recipes": [
+					{
+						"name": "Shortbread",
+						"howTo": "Mix and bake.",
+						"ingredients": [
+							{"id": "1", "name": "farine", "quantity": "250 g"},
+							{"id": "", "name": "Beurre demi-sel", "quantity": "125 g"},
+							{"id": "", "name": "bx Sucre", "quantity": "1"}
+						],
+						"yield": "24 cookies"
+					},
+					{
+						"name": "Caramel",
+						"howTo": "Melt.",
+						"ingredients": [
+							{"id": "2", "name": "sucre", "quantity": "100 g"},
+							{"id": "", "name": "Beurre demi-sel", "quantity": "50 g"}
+						]
+					}
+				],
+				"errors": [
+					{"line": 8, "message": "unknown unit [bx]"},
+					{"line": 14, "message": "recipe is not terminated"}
+				]
+			}`),
+		},
+		"Paprika recipe": {
+			path:           "/recipe/import/bulk?format=paprika",
+			body:           paprikaRecipe.String(),
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"recipes": [
+					{
+						"name": "Pain",
+						"howTo": "Pétrir.\nCuire.",
+						"ingredients": [
+							{"id": "1", "name": "farine", "quantity": "500 g"},
+							{"id": "", "name": "levure", "quantity": "1 sachet"}
+						],
+						"cookMinutes": 40
+					}
+				]
+			}`),
+		},
+		"not a Paprika file": {
+			path:             "/recipe/import/bulk?format=paprika",
+			body:             importedMealMaster,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"unknown format": {
+			path:             "/recipe/import/bulk?format=cooklang",
+			body:             importedCooklang,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			status, body := serve(router, http.MethodPost, test.path, test.body)
+			if status != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, status)
+			}
+			if msg, ok := test.responseBodyTest(body); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestImportAndSaveRecipeFile(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "farine"), (2, "sucre")`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	status, body := serve(router, http.MethodPost, "/recipe/import/bulk?format=mealmaster&save=true", importedMealMaster)
+	if status != http.StatusOK {
+		t.Fatalf("unexpected statusCode: wanted [%d], got [%d]: %s", http.StatusOK, status, body)
+	}
+	if !strings.Contains(body, `"ids":["1","2"]`) {
+		t.Errorf("unexpected saved recipes: %s", body)
+	}
+
+	// the new ingredient used by both recipes is created once
+	status, body = serve(router, http.MethodGet, "/recipe/2", "")
+	if status != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+	}
+	if !strings.Contains(body, `"name":"Beurre demi-sel","id":"3"`) {
+		t.Errorf("unexpected saved recipe: %s", body)
+	}
+}
@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 
@@ -22,15 +23,44 @@ func newIngredientHandler(ingredientService *service.IngredientService) *Ingredi
 	}
 }
 
-// GetIngredients returns all known ingredients
+// GetIngredients returns all known ingredients; they are sorted by aisle order when the sort query param is aisle.
+// They are written as a CSV file when the format query param is csv.
 func (handler *IngredientHandler) GetIngredients(responseWriter http.ResponseWriter, request *http.Request) {
 	ingredients, err := handler.ingredientService.GetAllIngredients(request.Context())
 	if rest.HandleErrorCase(responseWriter, err) {
 		return
 	}
+	query := request.URL.Query()
+	if query.Get("sort") == "aisle" {
+		service.SortIngredientsByAisle(ingredients)
+	}
+	if query.Get("format") == "csv" {
+		var csv bytes.Buffer
+		if err := service.WriteIngredientsCSV(&csv, ingredients); rest.HandleErrorCase(responseWriter, err) {
+			return
+		}
+		responseWriter.Header().Set(rest.HeaderContentType, contentTypeCSVUTF8)
+		responseWriter.Header().Set("Content-Disposition", `attachment; filename="miam-ingredients.csv"`)
+		_, _ = csv.WriteTo(responseWriter)
+		return
+	}
 	rest.WriteOKResponse(responseWriter, ingredients)
 }
 
+// ImportIngredients creates or updates ingredients from a CSV file, either uploaded as a file or sent as the request body,
+// and returns what happened to each of its rows
+func (handler *IngredientHandler) ImportIngredients(responseWriter http.ResponseWriter, request *http.Request) {
+	data, _, err := readImportedFile(responseWriter, request, maxImportedFileSize)
+	if rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+	report, err := handler.ingredientService.ImportIngredientsCSV(request.Context(), bytes.NewReader(data))
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, report)
+}
+
 // UpdateIngredient updates an ingredient
 func (handler *IngredientHandler) UpdateIngredient(responseWriter http.ResponseWriter, request *http.Request) {
 	var baseIngredient model.BaseIngredient
@@ -54,3 +84,65 @@ func (handler *IngredientHandler) DeleteIngredient(responseWriter http.ResponseW
 	}
 	rest.WriteNoContentResponse(responseWriter)
 }
+
+// MergeIngredient merges the ingredient with the given id into another one
+func (handler *IngredientHandler) MergeIngredient(responseWriter http.ResponseWriter, request *http.Request) {
+	var merge model.IngredientMerge
+	if err := json.NewDecoder(request.Body).Decode(&merge); rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	vars := mux.Vars(request)
+	ingredient, err := handler.ingredientService.MergeIngredient(request.Context(), vars["id"], merge.TargetID)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, ingredient)
+}
+
+// AddIngredientAlias adds an alias to the ingredient with the given id
+func (handler *IngredientHandler) AddIngredientAlias(responseWriter http.ResponseWriter, request *http.Request) {
+	var alias model.IngredientAlias
+	if err := json.NewDecoder(request.Body).Decode(&alias); rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	vars := mux.Vars(request)
+	ingredient, err := handler.ingredientService.AddAlias(request.Context(), vars["id"], alias)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, ingredient)
+}
+
+// DeleteIngredientAlias removes an alias from the ingredient with the given id
+func (handler *IngredientHandler) DeleteIngredientAlias(responseWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	if err := handler.ingredientService.DeleteAlias(request.Context(), vars["id"], vars["alias"]); rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteNoContentResponse(responseWriter)
+}
+
+// GetDuplicateIngredients returns the groups of ingredients that are likely duplicates
+func (handler *IngredientHandler) GetDuplicateIngredients(responseWriter http.ResponseWriter, request *http.Request) {
+	duplicates, err := handler.ingredientService.GetDuplicateIngredients(request.Context())
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, duplicates)
+}
+
+// MergeDuplicateIngredients merges several ingredients into another one
+func (handler *IngredientHandler) MergeDuplicateIngredients(responseWriter http.ResponseWriter, request *http.Request) {
+	var merge model.IngredientDuplicatesMerge
+	if err := json.NewDecoder(request.Body).Decode(&merge); rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	ingredient, err := handler.ingredientService.MergeDuplicateIngredients(request.Context(), merge)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, ingredient)
+}
@@ -1,17 +1,16 @@
 package rest
 
 import (
-	"context"
-	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/pb-lite/failure"
 	"github.com/remieven/miam/pb-lite/fixture"
 	"github.com/remieven/miam/pb-lite/testutils"
-	"github.com/remieven/miam/service"
 )
 
 func TestGetIngredients(t *testing.T) {
@@ -43,66 +42,323 @@ func TestGetIngredients(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			dbFilePath := testutils.GetRandomDBFileName()
-			databaseHolder, err := datasource.NewDatabaseHolder(dbFilePath)
+			router, err := newTestRouter(t, test.prepareDatabase)
 			if err != nil {
 				t.Error(err)
 				return
 			}
 
-			defer func() {
-				if err := databaseHolder.Close(); err != nil {
-					t.Error(err)
-				}
-			}()
-
-			ingredientDao, err := datasource.NewIngredientDao(databaseHolder)
+			request, err := http.NewRequest(http.MethodGet, "/ingredient", nil)
 			if err != nil {
-				t.Error(fmt.Errorf("failed to initialize ingredientDao: %w", err))
+				t.Error(err)
 				return
 			}
-			recipeIngredientDao, err := datasource.NewRecipeIngredientDao(databaseHolder, ingredientDao)
+
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+
+			responseBody, err := io.ReadAll(rr.Result().Body)
 			if err != nil {
-				t.Error(fmt.Errorf("failed to initialize recipeIngredientDao: %w", err))
+				t.Error(err)
 				return
 			}
-			recipeDao, err := datasource.NewRecipeDao(databaseHolder, recipeIngredientDao)
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestMergeIngredient(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values
+			(1, "tomate"),
+			(2, "pommes d'amour"),
+			(3, "sel")
+		`,
+		`insert into recipe(id, name, how_to) values
+			(1, "salade", "cut"),
+			(2, "sauce", "cook")
+		`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values
+			(1, 1, "2"),
+			(1, 2, "1"),
+			(2, 2, "500g"),
+			(2, 3, "1 pincée")
+		`,
+	)
+
+	tests := map[string]struct {
+		prepareDatabase  func(*datasource.DatabaseHolder) error
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		"merge ingredient": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/ingredient/2/merge",
+			body:             `{"targetId": "1"}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"id": "1", "name": "tomate", "aliases": ["pommes d'amour"]}`),
+		},
+		"merge ingredient into itself": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/ingredient/1/merge",
+			body:             `{"targetId": "1"}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"merge into unknown ingredient": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/ingredient/2/merge",
+			body:             `{"targetId": "42"}`,
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"add alias": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/ingredient/3/aliases",
+			body:             `{"name": "sel fin"}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"id": "3", "name": "sel", "aliases": ["sel fin"]}`),
+		},
+		"add alias already used by another ingredient": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/ingredient/3/aliases",
+			body:             `{"name": "Tomate"}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"delete unknown alias": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodDelete,
+			path:             "/ingredient/3/aliases/gros%20sel",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, test.prepareDatabase)
 			if err != nil {
-				t.Error(fmt.Errorf("failed to initialize recipeDao: %w", err))
+				t.Error(err)
 				return
 			}
-			recipeSearchDao, err := datasource.NewRecipeSearchDao()
+
+			request, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
 			if err != nil {
-				t.Error(fmt.Errorf("failed to initialize recipeSearchDao: %w", err))
+				t.Error(err)
 				return
 			}
-			defer func() {
-				if err := recipeSearchDao.Close(); err != nil {
-					t.Error(err)
-				}
-			}()
 
-			if test.prepareDatabase != nil {
-				if err := test.prepareDatabase(databaseHolder); err != nil {
-					t.Errorf("failed to prepare database: %v", err)
-					return
-				}
-			}
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
 
-			var (
-				ingredientService = service.NewIngredientService(ingredientDao, recipeIngredientDao)
-				recipeService     = service.NewRecipeService(recipeDao, recipeSearchDao)
-			)
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
 
-			ctx := context.Background()
-			if err := recipeService.IndexAllExistingRecipes(ctx); err != nil {
-				t.Error(fmt.Errorf("failed to index recipes: %w", err))
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
 				return
 			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
 
-			router := CreateRouter(recipeService, ingredientService)
+func TestMergedIngredientIsUsedByRecipes(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "tomate"), (2, "pommes d'amour")`,
+		`insert into recipe(id, name, how_to) values (1, "salade", "cut")`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values (1, 1, "2"), (1, 2, "1")`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
 
-			request, err := http.NewRequest(http.MethodGet, "/ingredient", nil)
+	steps := []struct {
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		{
+			method:           http.MethodPost,
+			path:             "/ingredient/2/merge",
+			body:             `{"targetId": "1"}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"id": "1", "name": "tomate", "aliases": ["pommes d'amour"]}`),
+		},
+		{
+			method:         http.MethodGet,
+			path:           "/recipe/1",
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "1",
+				"name": "salade",
+				"howTo": "cut",
+				"ingredients": [{"id": "1", "name": "tomate", "quantity": "2 + 1"}]
+			}`),
+		},
+		{
+			method:           http.MethodPost,
+			path:             "/recipe",
+			body:             `{"name": "coulis", "howTo": "mix", "ingredients": [{"name": "Pomme  d'Amour", "quantity": "3"}]}`,
+			expectedStatus:   http.StatusCreated,
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		{
+			method:         http.MethodGet,
+			path:           "/recipe/2",
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "2",
+				"name": "coulis",
+				"howTo": "mix",
+				"ingredients": [{"id": "1", "name": "tomate", "quantity": "3"}]
+			}`),
+		},
+	}
+
+	for _, step := range steps {
+		request, err := http.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, request)
+
+		if rr.Result().StatusCode != step.expectedStatus {
+			t.Errorf("%s %s: unexpected statusCode: wanted [%d], got [%d]", step.method, step.path, step.expectedStatus, rr.Result().StatusCode)
+		}
+
+		responseBody, err := io.ReadAll(rr.Result().Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if msg, ok := step.responseBodyTest(string(responseBody)); !ok {
+			t.Errorf("%s %s: %s", step.method, step.path, msg)
+		}
+	}
+}
+
+func TestDuplicateIngredients(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values
+			(1, "tomate"),
+			(2, "Tomates"),
+			(3, "sel"),
+			(4, "crème fraîche"),
+			(5, "creme fraiche")
+		`,
+		`insert into recipe(id, name, how_to) values
+			(1, "salade", "cut"),
+			(2, "sauce", "cook")
+		`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values
+			(1, 2, "1"),
+			(1, 3, "1 pincée"),
+			(2, 2, "500g"),
+			(2, 4, "20cl"),
+			(2, 1, "2")
+		`,
+	)
+
+	tests := map[string]struct {
+		prepareDatabase  func(*datasource.DatabaseHolder) error
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		"no ingredients": {
+			method:           http.MethodGet,
+			path:             "/ingredient/duplicates",
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[]`),
+		},
+		"duplicate ingredients": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodGet,
+			path:            "/ingredient/duplicates",
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[
+				{
+					"targetId": "2",
+					"ingredients": [
+						{"id": "2", "name": "Tomates", "usageCount": 2},
+						{"id": "1", "name": "tomate", "usageCount": 1}
+					]
+				},
+				{
+					"targetId": "4",
+					"ingredients": [
+						{"id": "4", "name": "crème fraîche", "usageCount": 1},
+						{"id": "5", "name": "creme fraiche", "usageCount": 0}
+					]
+				}
+			]`),
+		},
+		"merge duplicate ingredients": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/ingredient/duplicates/merge",
+			body:             `{"targetId": "4", "ingredientIds": ["4", "5"]}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"id": "4", "name": "crème fraîche"}`),
+		},
+		"merge repeated duplicate ingredients": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/ingredient/duplicates/merge",
+			body:             `{"targetId": "1", "ingredientIds": ["2", "2", "1"]}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"id": "1", "name": "tomate"}`),
+		},
+		"merge duplicate ingredients without target": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/ingredient/duplicates/merge",
+			body:             `{"ingredientIds": ["4", "5"]}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, test.prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			request, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
 			if err != nil {
 				t.Error(err)
 				return
@@ -127,3 +383,113 @@ func TestGetIngredients(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeDuplicateIngredientsIsAtomic(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "tomate"), (2, "Tomates")`,
+		`insert into recipe(id, name, how_to) values (1, "salade", "cut")`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values (1, 1, "2")`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, check := range []importCheck{
+		{http.MethodPost, "/ingredient/duplicates/merge", `{"targetId": "2", "ingredientIds": ["1", "3"]}`, http.StatusNotFound, testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode)},
+		{http.MethodGet, "/ingredient", "", http.StatusOK, func(body string) (string, bool) {
+			return body, strings.Contains(body, `"name":"tomate"`)
+		}},
+		{http.MethodGet, "/recipe/1", "", http.StatusOK, func(body string) (string, bool) {
+			return body, strings.Contains(body, `"name":"tomate"`)
+		}},
+	} {
+		status, body := serve(router, check.method, check.path, check.body)
+		if status != check.expectedStatus {
+			t.Errorf("%s %s: unexpected statusCode: wanted [%d], got [%d]", check.method, check.path, check.expectedStatus, status)
+		}
+		if msg, ok := check.responseBodyTest(body); !ok {
+			t.Errorf("%s %s: %s", check.method, check.path, msg)
+		}
+	}
+}
+
+func TestGetIngredientsAsCSV(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into category(id, name, aisle_order) values (1, "Crèmerie", 1)`,
+		`insert into ingredient(id, name, category_id) values (1, "lait entier", 1), (2, "sel, fin", null)`,
+		`insert into ingredient_alias(ingredient_id, name) values (1, "lait"), (1, "lait frais")`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	status, body := serve(router, http.MethodGet, "/ingredient?format=csv", "")
+	if status != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+	}
+	if msg, ok := testutils.ExactResponseBodyTest("id,name,category,aliases\n1,lait entier,Crèmerie,lait|lait frais\n2,\"sel, fin\",,\n")(body); !ok {
+		t.Error(msg)
+	}
+}
+
+func TestImportIngredients(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into category(id, name, aisle_order) values (1, "Crèmerie", 1), (2, "Épicerie", 2)`,
+		`insert into ingredient(id, name, category_id) values (1, "lait entier", 1), (2, "sel", 2), (3, "sucre", null)`,
+		`insert into ingredient_alias(ingredient_id, name) values (1, "lait")`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	status, body := serve(router, http.MethodPost, "/ingredient/import", `name,id,category,aliases,ignored
+lait entier,1,crèmerie,lait,x
+sel fin,2,Épicerie,sel,x
+Sucre,,Épicerie,sucre en poudre|,x
+farine,,Épicerie,farine de blé,x
+poivre,,Quincaillerie,,x
+,4,,,x
+beurre,9,,,x
+crème,,Crèmerie,lait,x
+`)
+	if status != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+	}
+	if msg, ok := testutils.JsonResponseBodyTest(`{
+		"created": 1,
+		"updated": 2,
+		"unchanged": 1,
+		"errors": 4,
+		"rows": [
+			{"line": 2, "id": "1", "name": "lait entier", "status": "unchanged"},
+			{"line": 3, "id": "2", "name": "sel fin", "status": "updated"},
+			{"line": 4, "id": "3", "name": "Sucre", "status": "updated"},
+			{"line": 5, "id": "4", "name": "farine", "status": "created"},
+			{"line": 6, "name": "poivre", "status": "error", "error": "category [Quincaillerie] not found"},
+			{"line": 7, "id": "4", "status": "error", "error": "name cannot be empty"},
+			{"line": 8, "id": "9", "name": "beurre", "status": "error", "error": "ingredient [9] not found"},
+			{"line": 9, "name": "crème", "status": "error", "error": "[lait] is already the name or an alias of ingredient [1]"}
+		]
+	}`)(body); !ok {
+		t.Error(msg)
+	}
+
+	status, body = serve(router, http.MethodGet, "/ingredient?format=csv", "")
+	if status != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, status)
+	}
+	if msg, ok := testutils.ExactResponseBodyTest("id,name,category,aliases\n1,lait entier,Crèmerie,lait\n2,sel fin,Épicerie,sel\n3,Sucre,Épicerie,sucre en poudre\n4,farine,Épicerie,farine de blé\n")(body); !ok {
+		t.Error(msg)
+	}
+
+	status, body = serve(router, http.MethodPost, "/ingredient/import", "name,category\nlait,\n")
+	if status != http.StatusBadRequest {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusBadRequest, status)
+	}
+	if msg, ok := testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode)(body); !ok {
+		t.Error(msg)
+	}
+}
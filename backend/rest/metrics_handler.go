@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/gorilla/mux"
+
+	"github.com/remieven/miam/metrics"
+	"github.com/remieven/miam/pb-lite/rest"
+	"github.com/remieven/miam/service"
+)
+
+// MetricsHandler is a handler measuring the HTTP requests and exposing the metrics of the application to Prometheus
+type MetricsHandler struct {
+	metricsService *service.MetricsService
+}
+
+func newMetricsHandler(metricsService *service.MetricsService) *MetricsHandler {
+	return &MetricsHandler{
+		metricsService,
+	}
+}
+
+// Middleware measures the requests by route template, so that eg. all the requests to /recipe/{id} are counted together
+func (handler *MetricsHandler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		requestMetrics := httpsnoop.CaptureMetrics(next, responseWriter, request)
+		var template string
+		if route := mux.CurrentRoute(request); route != nil {
+			template, _ = route.GetPathTemplate()
+		}
+		handler.metricsService.ObserveRequest(template, request.Method, requestMetrics.Code, requestMetrics.Duration)
+	})
+}
+
+// GetMetrics writes the metrics in the Prometheus text exposition format
+func (handler *MetricsHandler) GetMetrics(responseWriter http.ResponseWriter, request *http.Request) {
+	var body bytes.Buffer
+	if err := handler.metricsService.WriteMetrics(request.Context(), &body); rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	responseWriter.Header().Set(rest.HeaderContentType, metrics.ContentType)
+	_, _ = body.WriteTo(responseWriter)
+}
@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/remieven/miam/metrics"
+)
+
+func TestGetMetrics(t *testing.T) {
+	router, err := newTestRouter(t, prepareExportedDatabase)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, check := range []importCheck{
+		{http.MethodGet, "/recipe/2", "", http.StatusOK, nil},
+		{http.MethodGet, "/recipe/2", "", http.StatusOK, nil},
+		{http.MethodGet, "/recipe/3", "", http.StatusNotFound, nil},
+		{http.MethodPost, "/recipe/search", `{"searchTerm": "tarte"}`, http.StatusOK, nil},
+	} {
+		if status, _ := serve(router, check.method, check.path, check.body); status != check.expectedStatus {
+			t.Errorf("%s %s: unexpected statusCode: wanted [%d], got [%d]", check.method, check.path, check.expectedStatus, status)
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != metrics.ContentType {
+		t.Errorf("unexpected content type: wanted [%s], got [%s]", metrics.ContentType, contentType)
+	}
+	body := recorder.Body.String()
+	for _, expectedLine := range []string{
+		`miam_http_requests_total{route="/recipe/{id}",method="GET",code="200"} 2`,
+		`miam_http_requests_total{route="/recipe/{id}",method="GET",code="404"} 1`,
+		`miam_http_requests_total{route="/recipe/search",method="POST",code="200"} 1`,
+		`miam_http_request_duration_seconds_count{route="/recipe/{id}",method="GET"} 3`,
+		`miam_search_duration_seconds_count 1`,
+		`miam_search_index_documents 2`,
+		`miam_recipes 2`,
+		`miam_recipes_in_trash 1`,
+		`miam_ingredients 2`,
+		`miam_db_max_open_connections 0`,
+	} {
+		if !strings.Contains(body, "\n"+expectedLine+"\n") {
+			t.Errorf("missing line [%s] in metrics:\n%s", expectedLine, body)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/pb-lite/rest"
+	"github.com/remieven/miam/service"
+)
+
+// NutritionHandler is a handler for the nutrition estimates of recipes
+type NutritionHandler struct {
+	nutritionService *service.NutritionService
+}
+
+func newNutritionHandler(nutritionService *service.NutritionService) *NutritionHandler {
+	return &NutritionHandler{
+		nutritionService,
+	}
+}
+
+// GetRecipeNutrition returns the nutrition estimates of a recipe; the servings query param defaults to 1
+func (handler *NutritionHandler) GetRecipeNutrition(responseWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	servings := 1
+	if value := request.URL.Query().Get("servings"); value != "" {
+		var err error
+		if servings, err = strconv.Atoi(value); err != nil {
+			rest.HandleErrorCase(responseWriter, &failure.InvalidValueError{
+				Message: "servings must be a number, got [" + value + "]",
+				Cause:   err,
+			})
+			return
+		}
+	}
+
+	nutrition, err := handler.nutritionService.GetRecipeNutrition(request.Context(), vars["id"], servings)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, nutrition)
+}
@@ -0,0 +1,113 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/pb-lite/fixture"
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+func TestGetRecipeNutrition(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values
+			(1, "farine"),
+			(2, "œufs"),
+			(3, "lait"),
+			(4, "fleur d'oranger"),
+			(5, "sucre"),
+			(6, "beurre")
+		`,
+		`insert into recipe(id, name, how_to) values
+			(1, "pâte à crêpes", "mix"),
+			(2, "crêpes au sucre", "cook")
+		`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, sub_recipe_id, quantity) values
+			(1, 1, null, "250g"),
+			(1, 2, null, "4"),
+			(1, 3, null, "50cl"),
+			(1, 4, null, "1 cuillère à soupe"),
+			(2, null, 1, "1"),
+			(2, 5, null, "2 cuillères à soupe"),
+			(2, 6, null, "un peu")
+		`,
+	)
+
+	tests := map[string]struct {
+		path             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		"nutrition of a recipe": {
+			path:           "/recipe/1/nutrition",
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"recipeId": "1",
+				"servings": 1,
+				"total": {"energyKcal": 1400.5, "proteinG": 70.2, "fatG": 32.3, "carbohydratesG": 203.4},
+				"perServing": {"energyKcal": 1400.5, "proteinG": 70.2, "fatG": 32.3, "carbohydratesG": 203.4},
+				"unmatched": [{"id": "4", "name": "fleur d'oranger", "quantity": "1 cuillère à soupe", "reason": "noNutrientEntry"}]
+			}`),
+		},
+		"nutrition per serving of a recipe using a sub-recipe": {
+			path:           "/recipe/2/nutrition?servings=4",
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"recipeId": "2",
+				"servings": 4,
+				"total": {"energyKcal": 1520.5, "proteinG": 70.2, "fatG": 32.3, "carbohydratesG": 233.4},
+				"perServing": {"energyKcal": 380.1, "proteinG": 17.5, "fatG": 8.1, "carbohydratesG": 58.4},
+				"unmatched": [
+					{"id": "4", "name": "fleur d'oranger", "quantity": "1 cuillère à soupe", "reason": "noNutrientEntry"},
+					{"id": "6", "name": "beurre", "quantity": "un peu", "reason": "unknownQuantity"}
+				]
+			}`),
+		},
+		"invalid servings": {
+			path:             "/recipe/1/nutrition?servings=0",
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"unknown recipe": {
+			path:             "/recipe/42/nutrition",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			request, err := http.NewRequest(http.MethodGet, test.path, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
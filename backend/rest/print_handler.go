@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/schemaorg"
+	"github.com/remieven/miam/service"
+	"github.com/remieven/miam/similarity"
+)
+
+// PrintHandler is a handler for the printable pages of recipes and cookbooks
+type PrintHandler struct {
+	recipeService *service.RecipeService
+}
+
+func newPrintHandler(recipeService *service.RecipeService) *PrintHandler {
+	return &PrintHandler{
+		recipeService,
+	}
+}
+
+// printedRecipe is a recipe as printed on its own page
+type printedRecipe struct {
+	// Number is the position of the recipe in the cookbook, starting from 1, which the ingredient index refers to; it is 0 for a single recipe
+	Number    int
+	Anchor    string
+	Recipe    schemaorg.Recipe
+	Equipment []string
+}
+
+// printedIndexEntry is an ingredient of the index of a cookbook, with the recipes using it
+type printedIndexEntry struct {
+	Name    string
+	Recipes []printedRecipe
+}
+
+// printedBook is the data of a printable cookbook
+type printedBook struct {
+	Recipes []printedRecipe
+	Index   []printedIndexEntry
+}
+
+// GetPrintedRecipe renders a recipe as a printable HTML page
+func (handler *PrintHandler) GetPrintedRecipe(responseWriter http.ResponseWriter, request *http.Request) {
+	recipe, err := handler.recipeService.GetRecipe(request.Context(), mux.Vars(request)["id"])
+	if handlePageErrorCase(responseWriter, request, err) {
+		return
+	}
+	writePage(responseWriter, "print-recipe.html", toPrintedRecipe(0, *recipe))
+}
+
+// GetPrintedBook renders a cookbook as a printable HTML page, with a table of contents, one recipe per page and an index of the ingredients.
+// The collection query param lists the IDs of the recipes to print, separated by commas; all the recipes that are not in the trash are printed without it.
+func (handler *PrintHandler) GetPrintedBook(responseWriter http.ResponseWriter, request *http.Request) {
+	var (
+		recipes []model.Recipe
+		err     error
+	)
+	if collection := request.URL.Query().Get("collection"); collection != "" {
+		recipes, err = handler.recipeService.GetRecipes(request.Context(), splitCollection(collection))
+	} else {
+		recipes, err = handler.recipeService.GetAllRecipes(request.Context())
+	}
+	if handlePageErrorCase(responseWriter, request, err) {
+		return
+	}
+	writePage(responseWriter, "print-book.html", newPrintedBook(recipes))
+}
+
+// splitCollection splits a comma-separated list of recipe IDs, ignoring blanks and duplicates
+func splitCollection(collection string) []string {
+	var IDs []string
+	seen := make(map[string]bool)
+	for _, ID := range strings.Split(collection, ",") {
+		if ID = strings.TrimSpace(ID); ID != "" && !seen[ID] {
+			seen[ID] = true
+			IDs = append(IDs, ID)
+		}
+	}
+	return IDs
+}
+
+// newPrintedBook sorts the recipes of a cookbook by name and indexes their ingredients; sub-recipes are not indexed since they are printed as recipes
+func newPrintedBook(recipes []model.Recipe) printedBook {
+	sort.SliceStable(recipes, func(i, j int) bool {
+		return similarity.NormalizeName(recipes[i].Name) < similarity.NormalizeName(recipes[j].Name)
+	})
+	book := printedBook{
+		Recipes: make([]printedRecipe, len(recipes)),
+	}
+	entries := make(map[string]*printedIndexEntry)
+	for i, recipe := range recipes {
+		book.Recipes[i] = toPrintedRecipe(i+1, recipe)
+		for _, recipeIngredient := range recipe.Ingredients {
+			if recipeIngredient.IsSubRecipe() {
+				continue
+			}
+			key := similarity.NormalizeName(recipeIngredient.Name)
+			entry, ok := entries[key]
+			if !ok {
+				entry = &printedIndexEntry{Name: recipeIngredient.Name}
+				entries[key] = entry
+			}
+			if len(entry.Recipes) == 0 || entry.Recipes[len(entry.Recipes)-1].Number != i+1 {
+				entry.Recipes = append(entry.Recipes, book.Recipes[i])
+			}
+		}
+	}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		book.Index = append(book.Index, *entries[key])
+	}
+	return book
+}
+
+func toPrintedRecipe(number int, recipe model.Recipe) printedRecipe {
+	printed := printedRecipe{
+		Number: number,
+		Anchor: "recipe-" + recipe.ID,
+		Recipe: service.ToSchemaOrgRecipe(recipe),
+	}
+	for _, equipment := range recipe.Equipment {
+		printed.Equipment = append(printed.Equipment, equipment.Name)
+	}
+	return printed
+}
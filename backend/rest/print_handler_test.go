@@ -0,0 +1,100 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/remieven/miam/pb-lite/fixture"
+)
+
+func TestGetPrintedPages(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "pommes"), (2, "beurre <doux>"), (3, "farine")`,
+		`insert into equipment(id, name) values (1, "moule à tarte")`,
+		`insert into recipe(id, name, how_to, yield, prep_minutes) values (1, "tarte aux pommes", "Couper les pommes.
+Cuire.", "6 parts", 20)`,
+		`insert into recipe(id, name, how_to) values (2, "compote", "Cuire les pommes.")`,
+		`insert into recipe(id, name, how_to) values (3, "crumble", "Émietter.")`,
+		`insert into recipe(id, name, how_to, deleted_at) values (4, "gâteau", "Cuire.", "2024-01-01T00:00:00Z")`,
+		`insert into recipe_ingredient(recipe_id, ingredient_id, quantity) values (1, 1, "4"), (1, 2, "50 g"), (2, 1, "1 kg"), (3, 3, "100 g"), (3, 2, "")`,
+		`insert into recipe_equipment(recipe_id, equipment_id) values (1, 1)`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := map[string]struct {
+		path              string
+		expectedStatus    int
+		expectedContents  []string
+		unexpectedContent []string
+	}{
+		"recipe": {
+			path:           "/print/recipe/1",
+			expectedStatus: http.StatusOK,
+			expectedContents: []string{
+				`<title>tarte aux pommes - Miam</title>`,
+				`@page`,
+				`<section class="recipe" id="recipe-1">`,
+				`<h1>tarte aux pommes</h1>`,
+				`6 parts · Préparation : 20 min</p>`,
+				`<li>50 g beurre &lt;doux&gt;</li>`,
+				`<li>moule à tarte</li>`,
+				`<li>Couper les pommes.</li>`,
+			},
+		},
+		"unknown recipe": {
+			path:           "/print/recipe/4",
+			expectedStatus: http.StatusNotFound,
+		},
+		"book": {
+			path:           "/print/book",
+			expectedStatus: http.StatusOK,
+			expectedContents: []string{
+				`<li><a href="#recipe-2">compote</a><span class="number">1</span></li>
+        <li><a href="#recipe-3">crumble</a><span class="number">2</span></li>
+        <li><a href="#recipe-1">tarte aux pommes</a><span class="number">3</span></li>`,
+				`<h1><span class="number">3.</span> tarte aux pommes</h1>`,
+				`<li>beurre &lt;doux&gt;<span class="number"><a href="#recipe-3">2</a>, <a href="#recipe-1">3</a></span></li>
+        <li>farine<span class="number"><a href="#recipe-3">2</a></span></li>
+        <li>pommes<span class="number"><a href="#recipe-2">1</a>, <a href="#recipe-1">3</a></span></li>`,
+			},
+			unexpectedContent: []string{"gâteau"},
+		},
+		"collection": {
+			path:           "/print/book?collection=1,3,1",
+			expectedStatus: http.StatusOK,
+			expectedContents: []string{
+				`<li><a href="#recipe-3">crumble</a><span class="number">1</span></li>
+        <li><a href="#recipe-1">tarte aux pommes</a><span class="number">2</span></li>`,
+				`<li>pommes<span class="number"><a href="#recipe-1">2</a></span></li>`,
+			},
+			unexpectedContent: []string{"compote"},
+		},
+		"collection with an unknown recipe": {
+			path:           "/print/book?collection=1,4",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, body := serve(router, http.MethodGet, test.path, "")
+			if status != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, status)
+			}
+			for _, expected := range test.expectedContents {
+				if !strings.Contains(body, expected) {
+					t.Errorf("page does not contain [%s]:\n%s", expected, body)
+				}
+			}
+			for _, unexpected := range test.unexpectedContent {
+				if strings.Contains(body, unexpected) {
+					t.Errorf("page contains [%s]:\n%s", unexpected, body)
+				}
+			}
+		})
+	}
+}
@@ -1,40 +1,88 @@
 package rest
 
 import (
+	"bytes"
 	"encoding/json"
-
+	"errors"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/remieven/miam/cooklang"
 	"github.com/remieven/miam/model"
 	"github.com/remieven/miam/pb-lite/rest"
+	"github.com/remieven/miam/schemaorg"
 	"github.com/remieven/miam/service"
 )
 
+const (
+	contentTypeJSONLD       = "application/ld+json;charset=utf-8"
+	contentTypeTextUTF8     = "text/plain;charset=utf-8"
+	contentTypeMarkdownUTF8 = "text/markdown;charset=utf-8"
+	contentTypeZip          = "application/zip"
+	contentTypeCSVUTF8      = "text/csv;charset=utf-8"
+)
+
 // RecipeHandler is a recipe handler
 type RecipeHandler struct {
-	recipeService *service.RecipeService
+	recipeService   *service.RecipeService
+	markdownService *service.MarkdownService
 }
 
-func newRecipeHandler(recipeService *service.RecipeService) *RecipeHandler {
+func newRecipeHandler(recipeService *service.RecipeService, markdownService *service.MarkdownService) *RecipeHandler {
 	return &RecipeHandler{
 		recipeService,
+		markdownService,
 	}
 }
 
-// GetRecipeByID handles a recipe request
+// GetRecipeByID handles a recipe request; sub-recipes are inlined when the expand query param is true,
+// ingredients are sorted by aisle order when the sort query param is aisle, and the recipe is written as schema.org JSON-LD, in Cooklang or in Markdown when the format query param is jsonld, cooklang or markdown
 func (handler *RecipeHandler) GetRecipeByID(responseWriter http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 
-	recipe, err := handler.recipeService.GetRecipe(request.Context(), vars["id"])
+	getRecipe := handler.recipeService.GetRecipe
+	if request.URL.Query().Get("expand") == "true" {
+		getRecipe = handler.recipeService.GetExpandedRecipe
+	}
+	recipe, err := getRecipe(request.Context(), vars["id"])
 	if rest.HandleErrorCase(responseWriter, err) {
 		return
 	}
+	if request.URL.Query().Get("sort") == "aisle" {
+		service.SortRecipeIngredientsByAisle(recipe.Ingredients)
+	}
+	switch request.URL.Query().Get("format") {
+	case "jsonld":
+		writeJSONLDResponse(responseWriter, service.ToSchemaOrgRecipe(*recipe))
+		return
+	case "cooklang":
+		responseWriter.Header().Set(rest.HeaderContentType, contentTypeTextUTF8)
+		_, _ = io.WriteString(responseWriter, cooklang.Format(service.ToCooklangRecipe(*recipe)))
+		return
+	case "markdown":
+		var page bytes.Buffer
+		if err := handler.markdownService.WriteRecipe(&page, *recipe); rest.HandleErrorCase(responseWriter, err) {
+			return
+		}
+		responseWriter.Header().Set(rest.HeaderContentType, contentTypeMarkdownUTF8)
+		_, _ = page.WriteTo(responseWriter)
+		return
+	}
 
 	rest.WriteOKResponse(responseWriter, recipe)
 }
 
+// writeJSONLDResponse writes a 200 response with a JSON-LD body
+func writeJSONLDResponse(responseWriter http.ResponseWriter, recipe schemaorg.Recipe) {
+	responseWriter.Header().Set(rest.HeaderContentType, contentTypeJSONLD)
+	if err := json.NewEncoder(responseWriter).Encode(recipe); err != nil {
+		rest.HandleErrorCase(responseWriter, err)
+	}
+}
+
 // AddRecipe adds a recipe
 func (handler *RecipeHandler) AddRecipe(responseWriter http.ResponseWriter, request *http.Request) {
 	var recipe model.BaseRecipe
@@ -91,3 +139,42 @@ func (handler *RecipeHandler) SearchRecipe(responseWriter http.ResponseWriter, r
 
 	rest.WriteOKResponse(responseWriter, results)
 }
+
+// ForkRecipe adds a copy of a recipe as one of its variants, optionally renamed
+func (handler *RecipeHandler) ForkRecipe(responseWriter http.ResponseWriter, request *http.Request) {
+	var fork struct {
+		Name string `json:"name"`
+	}
+	// The body is optional
+	if err := json.NewDecoder(request.Body).Decode(&fork); !errors.Is(err, io.EOF) && rest.HandleParseBodyErrorCase(responseWriter, err) {
+		return
+	}
+
+	id, err := handler.recipeService.ForkRecipe(request.Context(), mux.Vars(request)["id"], fork.Name)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+
+	rest.WriteCreatedResponse(responseWriter, request, id)
+}
+
+// GetRecipeVariants lists the variants of a recipe
+func (handler *RecipeHandler) GetRecipeVariants(responseWriter http.ResponseWriter, request *http.Request) {
+	variants, err := handler.recipeService.GetRecipeVariants(request.Context(), mux.Vars(request)["id"])
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+
+	rest.WriteOKResponse(responseWriter, variants)
+}
+
+// ExportMarkdownCookbook writes a zip archive holding all the recipes as Markdown files, along with an index file
+func (handler *RecipeHandler) ExportMarkdownCookbook(responseWriter http.ResponseWriter, request *http.Request) {
+	var archive bytes.Buffer
+	if err := handler.markdownService.WriteCookbookArchive(request.Context(), &archive); rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	responseWriter.Header().Set(rest.HeaderContentType, contentTypeZip)
+	responseWriter.Header().Set("Content-Disposition", `attachment; filename="miam-recipes-`+time.Now().Format("2006-01-02")+`.zip"`)
+	_, _ = archive.WriteTo(responseWriter)
+}
@@ -1,18 +1,21 @@
 package rest
 
 import (
-	"context"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/model"
 	"github.com/remieven/miam/pb-lite/failure"
 	"github.com/remieven/miam/pb-lite/fixture"
 	"github.com/remieven/miam/pb-lite/testutils"
-	"github.com/remieven/miam/service"
 )
 
 func TestGetRecipe(t *testing.T) {
@@ -79,66 +82,773 @@ func TestGetRecipe(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			dbFilePath := testutils.GetRandomDBFileName()
-			databaseHolder, err := datasource.NewDatabaseHolder(dbFilePath)
+			router, err := newTestRouter(t, test.prepareDatabase)
 			if err != nil {
 				t.Error(err)
 				return
 			}
 
-			defer func() {
-				if err := databaseHolder.Close(); err != nil {
-					t.Error(err)
-				}
-			}()
+			request, err := http.NewRequest(http.MethodGet, "/recipe/"+test.recipeId, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
 
-			ingredientDao, err := datasource.NewIngredientDao(databaseHolder)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+
+			responseBody, err := io.ReadAll(rr.Result().Body)
 			if err != nil {
-				t.Error(fmt.Errorf("failed to initialize ingredientDao: %w", err))
+				t.Error(err)
 				return
 			}
-			recipeIngredientDao, err := datasource.NewRecipeIngredientDao(databaseHolder, ingredientDao)
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestRecipeVariants(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values
+			(1, "farine"),
+			(2, "lait"),
+			(3, "lait d'avoine")
+		`,
+		`insert into recipe(id, name, how_to, parent_id) values
+			(1, "crêpes", "mix", null),
+			(2, "crêpes vegan", "mix", 1),
+			(3, "gaufres", "bake", null)
+		`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values
+			(1, 1, "250g"),
+			(1, 2, "50cl"),
+			(2, 1, "250g"),
+			(2, 3, "50cl"),
+			(3, 1, "300g")
+		`,
+	)
+
+	tests := map[string]struct {
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		expectedLocation string
+		responseBodyTest func(string) (string, bool)
+	}{
+		"list variants": {
+			method:         http.MethodGet,
+			path:           "/recipe/1/variants",
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[{
+				"id": "2",
+				"name": "crêpes vegan",
+				"howTo": "mix",
+				"parentId": "1",
+				"ingredients": [{"id": "1", "name": "farine", "quantity": "250g"}, {"id": "3", "name": "lait d'avoine", "quantity": "50cl"}]
+			}]`),
+		},
+		"list variants of unknown recipe": {
+			method:           http.MethodGet,
+			path:             "/recipe/4/variants",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"fork recipe": {
+			method:           http.MethodPost,
+			path:             "/recipe/3/fork",
+			body:             `{"name": "gaufres sans gluten"}`,
+			expectedStatus:   http.StatusCreated,
+			expectedLocation: "4",
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"fork recipe without body": {
+			method:           http.MethodPost,
+			path:             "/recipe/3/fork",
+			expectedStatus:   http.StatusCreated,
+			expectedLocation: "4",
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"fork unknown recipe": {
+			method:           http.MethodPost,
+			path:             "/recipe/4/fork",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"variant cannot be the parent of its parent": {
+			method:           http.MethodPut,
+			path:             "/recipe/1",
+			body:             `{"name": "crêpes", "parentId": "2"}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"search groups variants under their parent": {
+			method:         http.MethodPost,
+			path:           "/recipe/search",
+			body:           `{"searchTerm": "crêpes"}`,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"total": 2,
+				"firstResults": [{
+					"id": "1",
+					"name": "crêpes",
+					"howTo": "mix",
+					"ingredients": [{"id": "1", "name": "farine", "quantity": "250g"}, {"id": "2", "name": "lait", "quantity": "50cl"}],
+					"variants": [{
+						"id": "2",
+						"name": "crêpes vegan",
+						"howTo": "mix",
+						"parentId": "1",
+						"ingredients": [{"id": "1", "name": "farine", "quantity": "250g"}, {"id": "3", "name": "lait d'avoine", "quantity": "50cl"}]
+					}]
+				}]
+			}`),
+		},
+		"search collapses variants into their parent": {
+			method:         http.MethodPost,
+			path:           "/recipe/search",
+			body:           `{"searchTerm": "vegan", "collapseVariants": true}`,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"total": 1,
+				"firstResults": [{
+					"id": "1",
+					"name": "crêpes",
+					"howTo": "mix",
+					"ingredients": [{"id": "1", "name": "farine", "quantity": "250g"}, {"id": "2", "name": "lait", "quantity": "50cl"}]
+				}]
+			}`),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, prepareDatabase)
 			if err != nil {
-				t.Error(fmt.Errorf("failed to initialize recipeIngredientDao: %w", err))
+				t.Error(err)
+				return
+			}
+
+			request, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Error(err)
 				return
 			}
-			recipeDao, err := datasource.NewRecipeDao(databaseHolder, recipeIngredientDao)
+
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+			if location := rr.Result().Header.Get("Location"); location != test.expectedLocation {
+				t.Errorf("unexpected location: wanted [%s], got [%s]", test.expectedLocation, location)
+			}
+
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestVariantsOfTrashedRecipe(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "farine")`,
+		`insert into recipe(id, name, how_to, parent_id, deleted_at) values
+			(1, "crêpes", "mix", null, 1700000000000),
+			(2, "crêpes vegan", "mix", 1, null),
+			(3, "crêpes vegan sans gluten", "mix", 2, null),
+			(4, "gaufres", "bake", null, null)
+		`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values
+			(2, 1, "250g"),
+			(3, 1, "250g"),
+			(4, 1, "300g")
+		`,
+	)
+
+	tests := map[string]struct {
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		expectedLocation string
+		responseBodyTest func(string) (string, bool)
+	}{
+		"edit variant keeping its parent in trash": {
+			method:         http.MethodPut,
+			path:           "/recipe/2",
+			body:           `{"name": "crêpes vegan", "howTo": "mix well", "parentId": "1", "ingredients": [{"id": "1", "name": "farine", "quantity": "250g"}]}`,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "2",
+				"name": "crêpes vegan",
+				"howTo": "mix well",
+				"parentId": "1",
+				"ingredients": [{"id": "1", "name": "farine", "quantity": "250g"}]
+			}`),
+		},
+		"fork variant of recipe in trash": {
+			method:           http.MethodPost,
+			path:             "/recipe/2/fork",
+			expectedStatus:   http.StatusCreated,
+			expectedLocation: "5",
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"fork deeper variant of recipe in trash": {
+			method:           http.MethodPost,
+			path:             "/recipe/3/fork",
+			expectedStatus:   http.StatusCreated,
+			expectedLocation: "5",
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"recipe in trash cannot become a parent": {
+			method:           http.MethodPut,
+			path:             "/recipe/4",
+			body:             `{"name": "gaufres", "howTo": "bake", "parentId": "1"}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, prepareDatabase)
 			if err != nil {
-				t.Error(fmt.Errorf("failed to initialize recipeDao: %w", err))
+				t.Error(err)
 				return
 			}
-			recipeSearchDao, err := datasource.NewRecipeSearchDao()
+
+			request, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
 			if err != nil {
-				t.Error(fmt.Errorf("failed to initialize recipeSearchDao: %w", err))
+				t.Error(err)
 				return
 			}
-			defer func() {
-				if err := recipeSearchDao.Close(); err != nil {
-					t.Error(err)
-				}
-			}()
 
-			if test.prepareDatabase != nil {
-				if err := test.prepareDatabase(databaseHolder); err != nil {
-					t.Errorf("failed to prepare database: %v", err)
-					return
-				}
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+			if location := rr.Result().Header.Get("Location"); location != test.expectedLocation {
+				t.Errorf("unexpected location: wanted [%s], got [%s]", test.expectedLocation, location)
+			}
+
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
+				return
 			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
 
-			var (
-				ingredientService = service.NewIngredientService(ingredientDao, recipeIngredientDao)
-				recipeService     = service.NewRecipeService(recipeDao, recipeSearchDao)
-			)
+func TestSubRecipes(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values
+			(1, "beurre"),
+			(2, "lait"),
+			(3, "farine"),
+			(4, "pâtes à lasagne"),
+			(5, "tomate")
+		`,
+		`insert into recipe(id, name, how_to) values
+			(1, "béchamel", "whisk"),
+			(2, "lasagnes", "bake"),
+			(3, "salade", "cut")
+		`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, sub_recipe_id, quantity) values
+			(1, 1, null, "50g"),
+			(1, 3, null, "50g"),
+			(1, 2, null, "50cl"),
+			(2, 4, null, "250g"),
+			(2, null, 1, "2"),
+			(3, 5, null, "3")
+		`,
+	)
 
-			ctx := context.Background()
-			if err := recipeService.IndexAllExistingRecipes(ctx); err != nil {
-				t.Error(fmt.Errorf("failed to index recipes: %w", err))
+	tests := map[string]struct {
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		expectedLocation string
+		responseBodyTest func(string) (string, bool)
+	}{
+		"get recipe using a sub-recipe": {
+			method:         http.MethodGet,
+			path:           "/recipe/2",
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "2",
+				"name": "lasagnes",
+				"howTo": "bake",
+				"ingredients": [
+					{"id": "4", "name": "pâtes à lasagne", "quantity": "250g"},
+					{"id": "", "name": "béchamel", "quantity": "2", "subRecipeId": "1"}
+				]
+			}`),
+		},
+		"get expanded recipe": {
+			method:         http.MethodGet,
+			path:           "/recipe/2?expand=true",
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "2",
+				"name": "lasagnes",
+				"howTo": "bake",
+				"ingredients": [
+					{"id": "4", "name": "pâtes à lasagne", "quantity": "250g"},
+					{"id": "1", "name": "beurre", "quantity": "100g"},
+					{"id": "3", "name": "farine", "quantity": "100g"},
+					{"id": "2", "name": "lait", "quantity": "100cl"}
+				]
+			}`),
+		},
+		"recipe cannot use itself indirectly": {
+			method:           http.MethodPut,
+			path:             "/recipe/1",
+			body:             `{"name": "béchamel", "ingredients": [{"subRecipeId": "2", "name": "lasagnes"}]}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"sub-recipe must exist": {
+			method:           http.MethodPost,
+			path:             "/recipe",
+			body:             `{"name": "gratin", "ingredients": [{"subRecipeId": "9", "name": "sauce"}]}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"sub-recipe quantity must be a multiplier": {
+			method:           http.MethodPost,
+			path:             "/recipe",
+			body:             `{"name": "gratin", "ingredients": [{"subRecipeId": "1", "name": "béchamel", "quantity": "200g"}]}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"add recipe using a sub-recipe": {
+			method:           http.MethodPost,
+			path:             "/recipe",
+			body:             `{"name": "gratin", "ingredients": [{"subRecipeId": "1", "name": "béchamel", "quantity": "x1,5"}]}`,
+			expectedStatus:   http.StatusCreated,
+			expectedLocation: "4",
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"recipe used as a sub-recipe cannot be deleted": {
+			method:           http.MethodDelete,
+			path:             "/recipe/1",
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"excluding an ingredient sees through sub-recipes": {
+			method:         http.MethodPost,
+			path:           "/recipe/search",
+			body:           `{"excludedIngredients": ["2"]}`,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"total": 1,
+				"firstResults": [{
+					"id": "3",
+					"name": "salade",
+					"howTo": "cut",
+					"ingredients": [{"id": "5", "name": "tomate", "quantity": "3"}]
+				}]
+			}`),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, prepareDatabase)
+			if err != nil {
+				t.Error(err)
 				return
 			}
 
-			router := CreateRouter(recipeService, ingredientService)
+			request, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
 
-			request, err := http.NewRequest(http.MethodGet, "/recipe/"+test.recipeId, nil)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+			if location := rr.Result().Header.Get("Location"); location != test.expectedLocation {
+				t.Errorf("unexpected location: wanted [%s], got [%s]", test.expectedLocation, location)
+			}
+
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestSubRecipeCycleInDatabase(t *testing.T) {
+	// the cycle between the recipes 1 and 2 could only come from inconsistent data, such as an older import
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "lait")`,
+		`insert into recipe(id, name, how_to) values
+			(1, "béchamel", "whisk"),
+			(2, "lasagnes", "bake"),
+			(3, "gratin", "bake")
+		`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, sub_recipe_id, quantity) values
+			(1, 1, null, "50cl"),
+			(1, null, 2, "1"),
+			(2, null, 1, "2")
+		`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, check := range []importCheck{
+		{http.MethodPut, "/recipe/3", `{"name": "gratin", "howTo": "bake", "ingredients": [{"subRecipeId": "1", "name": "béchamel", "quantity": "1"}]}`, http.StatusOK, testutils.JsonResponseBodyTest(`{
+			"id": "3",
+			"name": "gratin",
+			"howTo": "bake",
+			"ingredients": [{"id": "", "name": "béchamel", "quantity": "1", "subRecipeId": "1"}]
+		}`)},
+		{http.MethodPut, "/recipe/2", `{"name": "lasagnes", "ingredients": [{"subRecipeId": "1", "name": "béchamel", "quantity": "2"}]}`, http.StatusBadRequest, testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode)},
+	} {
+		status, body := serve(router, check.method, check.path, check.body)
+		if status != check.expectedStatus {
+			t.Errorf("%s %s: unexpected statusCode: wanted [%d], got [%d]", check.method, check.path, check.expectedStatus, status)
+		}
+		if msg, ok := check.responseBodyTest(body); !ok {
+			t.Errorf("%s %s: %s", check.method, check.path, msg)
+		}
+	}
+}
+
+func TestDietaryInformation(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name, allergens, allergens_known) values
+			(1, "farine", "gluten", 1),
+			(2, "oeuf", "animalProduct", 1),
+			(3, "lardons", "meat", 1),
+			(4, "noisettes", "nuts", 1),
+			(5, "tomate", "", 1),
+			(6, "concombre", null, null)
+		`,
+		`insert into recipe(id, name, how_to) values
+			(1, "pâte brisée", "knead"),
+			(2, "quiche lorraine", "bake"),
+			(3, "salade de tomates", "cut"),
+			(4, "tomates à la croque", "eat"),
+			(5, "concombre à la croque", "eat")
+		`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, sub_recipe_id, quantity) values
+			(1, 1, null, "250g"),
+			(1, 2, null, "1"),
+			(2, 3, null, "200g"),
+			(2, null, 1, "1"),
+			(3, 5, null, "4"),
+			(3, 4, null, "20g"),
+			(4, 5, null, "2"),
+			(5, 6, null, "1")
+		`,
+	)
+
+	tests := map[string]struct {
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		"allergens and diets are derived from ingredients, including the ones of sub-recipes": {
+			method:         http.MethodGet,
+			path:           "/recipe/2",
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "2",
+				"name": "quiche lorraine",
+				"howTo": "bake",
+				"allergens": ["gluten", "meat", "animalProduct"],
+				"allergensKnown": true,
+				"ingredients": [
+					{"id": "3", "name": "lardons", "allergens": ["meat"], "allergensKnown": true, "quantity": "200g"},
+					{"id": "", "name": "pâte brisée", "quantity": "1", "subRecipeId": "1"}
+				]
+			}`),
+		},
+		"no diets are derived from ingredients whose allergens are unknown": {
+			method:         http.MethodGet,
+			path:           "/recipe/5",
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "5",
+				"name": "concombre à la croque",
+				"howTo": "eat",
+				"ingredients": [{"id": "6", "name": "concombre", "quantity": "1"}]
+			}`),
+		},
+		"search excluding allergens leaves out recipes whose allergens are unknown": {
+			method:         http.MethodPost,
+			path:           "/recipe/search",
+			body:           `{"excludedAllergens": ["gluten", "nuts"]}`,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"total": 1,
+				"firstResults": [{
+					"id": "4",
+					"name": "tomates à la croque",
+					"howTo": "eat",
+					"ingredients": [{"id": "5", "name": "tomate", "allergensKnown": true, "quantity": "2"}]
+				}]
+			}`),
+		},
+		"search for recipes whose allergens are unknown": {
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"unknownAllergensOnly": true}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: searchResultIDsTest(1, "5"),
+		},
+		"search for diets": {
+			method:         http.MethodPost,
+			path:           "/recipe/search",
+			body:           `{"searchTerm": "salade", "diets": ["vegan", "glutenFree"]}`,
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"total": 1,
+				"firstResults": [{
+					"id": "3",
+					"name": "salade de tomates",
+					"howTo": "cut",
+					"ingredients": [{"id": "5", "name": "tomate", "allergensKnown": true, "quantity": "4"}, {"id": "4", "name": "noisettes", "allergens": ["nuts"], "allergensKnown": true, "quantity": "20g"}]
+				}]
+			}`),
+		},
+		"search for unknown diet": {
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"diets": ["paleo"]}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"search excluding unknown allergen": {
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"excludedAllergens": ["peanuts"]}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"set ingredient allergens": {
+			method:           http.MethodPut,
+			path:             "/ingredient/3",
+			body:             `{"name": "lardons", "allergens": ["animalProduct", "meat", "meat"]}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"id": "3", "name": "lardons", "allergens": ["meat", "animalProduct"], "allergensKnown": true}`),
+		},
+		"set ingredient free of allergens": {
+			method:           http.MethodPut,
+			path:             "/ingredient/6",
+			body:             `{"name": "concombre", "allergensKnown": true}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"id": "6", "name": "concombre", "allergensKnown": true}`),
+		},
+		"set unknown ingredient allergen": {
+			method:           http.MethodPut,
+			path:             "/ingredient/3",
+			body:             `{"name": "lardons", "allergens": ["pork"]}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			request, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestSeasonality(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name, season_from, season_to) values
+			(1, "poireau", 10, 3),
+			(2, "tomate", 6, 9),
+			(3, "riz", null, null),
+			(4, "basilic", 1, 12)
+		`,
+		`insert into recipe(id, name, how_to) values
+			(1, "soupe de poireaux", "boil"),
+			(2, "salade de tomates", "cut"),
+			(3, "riz cantonais", "fry"),
+			(4, "riz au lait", "boil"),
+			(5, "riz pilaf", "cook"),
+			(6, "riz blanc", "boil"),
+			(7, "riz frit", "fry"),
+			(8, "riz basmati", "boil"),
+			(9, "riz complet", "boil"),
+			(10, "riz sauvage", "boil"),
+			(11, "riz au basilic", "cook")
+		`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values
+			(1, 1, "2"),
+			(2, 2, "4"),
+			(3, 3, "100g"),
+			(4, 3, "100g"),
+			(5, 3, "100g"),
+			(6, 3, "100g"),
+			(7, 3, "100g"),
+			(8, 3, "100g"),
+			(9, 3, "100g"),
+			(10, 3, "100g"),
+			(11, 3, "100g"),
+			(11, 4, "1 brin")
+		`,
+	)
+
+	tests := map[string]struct {
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		"recipe with seasonal ingredients has a season score": {
+			method:         http.MethodGet,
+			path:           "/recipe/11",
+			expectedStatus: http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "11",
+				"name": "riz au basilic",
+				"howTo": "cook",
+				"seasonScore": 1,
+				"ingredients": [
+					{"id": "3", "name": "riz", "quantity": "100g"},
+					{"id": "4", "name": "basilic", "season": {"from": 1, "to": 12}, "quantity": "1 brin"}
+				]
+			}`),
+		},
+		"random recipes are in season first": {
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"month": 1}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: searchResultIDsTest(11, "1", "3", "4", "5", "6", "7", "8", "9", "10", "11"),
+		},
+		"search for in-season recipes only during winter": {
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"searchTerm": "soupe", "inSeasonOnly": true, "month": 12}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: searchResultIDsTest(1, "1"),
+		},
+		"search for in-season recipes only during summer": {
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"searchTerm": "soupe", "inSeasonOnly": true, "month": 7}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: searchResultIDsTest(0),
+		},
+		"search preferring in-season recipes during winter": {
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"excludedIngredients": ["3"], "preferInSeason": true, "month": 1}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: searchResultIDsTest(2, "1", "2"),
+		},
+		"search preferring in-season recipes during summer": {
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"excludedIngredients": ["3"], "preferInSeason": true, "month": 8}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: searchResultIDsTest(2, "2", "1"),
+		},
+		"search with invalid month": {
+			method:           http.MethodPost,
+			path:             "/recipe/search",
+			body:             `{"inSeasonOnly": true, "month": 13}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"set ingredient season": {
+			method:           http.MethodPut,
+			path:             "/ingredient/2",
+			body:             `{"name": "tomate", "season": {"from": 7, "to": 9}}`,
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{"id": "2", "name": "tomate", "season": {"from": 7, "to": 9}}`),
+		},
+		"set invalid ingredient season": {
+			method:           http.MethodPut,
+			path:             "/ingredient/2",
+			body:             `{"name": "tomate", "season": {"from": 0, "to": 9}}`,
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			request, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
 			if err != nil {
 				t.Error(err)
 				return
@@ -163,3 +873,175 @@ func TestGetRecipe(t *testing.T) {
 		})
 	}
 }
+
+// searchResultIDsTest checks the total and the IDs of the recipes of a search result, in order
+func searchResultIDsTest(expectedTotal int, expectedIDs ...string) func(string) (string, bool) {
+	return func(body string) (string, bool) {
+		var result model.RecipeSearchResult
+		if err := json.Unmarshal([]byte(body), &result); err != nil {
+			return "failed to parse search result: " + err.Error(), false
+		}
+		IDs := make([]string, 0, len(result.FirstResults))
+		for _, hit := range result.FirstResults {
+			IDs = append(IDs, hit.ID)
+		}
+		if result.Total != expectedTotal || strings.Join(IDs, ",") != strings.Join(expectedIDs, ",") {
+			return fmt.Sprintf("unexpected search result: wanted [%d] recipes [%v], got [%d] recipes %v", expectedTotal, expectedIDs, result.Total, IDs), false
+		}
+		return "", true
+	}
+}
+
+func TestGetRecipeAsJSONLD(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "farine"), (2, "œuf")`,
+		`insert into recipe(id, name, how_to, yield, prep_minutes, cook_minutes) values
+			(1, "pâte brisée", "knead", null, null, null),
+			(2, "quiche", "Étaler la pâte.
+
+Cuire.", "6 parts", 15, 40)
+		`,
+		`insert into recipe_ingredient(recipe_id, ingredient_id, quantity, sub_recipe_id) values
+			(1, 1, "250 g", null),
+			(2, 2, "3", null),
+			(2, null, "1", 1)
+		`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/recipe/2?format=jsonld", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if rr.Result().StatusCode != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, rr.Result().StatusCode)
+	}
+	if contentType := rr.Result().Header.Get("Content-Type"); contentType != contentTypeJSONLD {
+		t.Errorf("unexpected content type: wanted [%s], got [%s]", contentTypeJSONLD, contentType)
+	}
+	responseBody, err := io.ReadAll(rr.Result().Body)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if msg, ok := testutils.JsonResponseBodyTest(`{
+		"@context": "https://schema.org",
+		"@type": "Recipe",
+		"name": "quiche",
+		"recipeIngredient": ["3 œuf", "1 pâte brisée"],
+		"recipeInstructions": [{"@type": "HowToStep", "text": "Étaler la pâte."}, {"@type": "HowToStep", "text": "Cuire."}],
+		"recipeYield": "6 parts",
+		"prepTime": "PT15M",
+		"cookTime": "PT40M"
+	}`)(string(responseBody)); !ok {
+		t.Error(msg)
+	}
+}
+
+func TestGetRecipeAsMarkdown(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "farine"), (2, "œuf")`,
+		`insert into recipe(id, name, how_to, yield, cook_minutes) values (1, "crêpes", "Mélanger.
+Cuire.", "8 crêpes", 20)`,
+		`insert into recipe_ingredient(recipe_id, ingredient_id, quantity) values (1, 1, "250 g"), (1, 2, "4")`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/recipe/1?format=markdown", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if rr.Result().StatusCode != http.StatusOK {
+		t.Errorf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, rr.Result().StatusCode)
+	}
+	if contentType := rr.Result().Header.Get("Content-Type"); contentType != contentTypeMarkdownUTF8 {
+		t.Errorf("unexpected content type: wanted [%s], got [%s]", contentTypeMarkdownUTF8, contentType)
+	}
+	responseBody, err := io.ReadAll(rr.Result().Body)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if msg, ok := testutils.ExactResponseBodyTest(`# crêpes
+
+Portions : 8 crêpes
+Cuisson : 20 min
+
+## Ingrédients
+
+- 250 g farine
+- 4 œuf
+
+## Instructions
+
+1. Mélanger.
+2. Cuire.
+`)(string(responseBody)); !ok {
+		t.Error(msg)
+	}
+}
+
+func TestExportMarkdownCookbook(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into recipe(id, name, how_to, deleted_at) values
+			(1, "Tarte aux pommes", "Cuire.", null),
+			(2, "crème brûlée", "Brûler.", null),
+			(3, "tarte aux pommes", "Cuire longtemps.", null),
+			(4, "gâteau oublié", "Jeter.", 1700000000000)
+		`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/admin/export/markdown", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if rr.Result().StatusCode != http.StatusOK {
+		t.Fatalf("unexpected statusCode: wanted [%d], got [%d]", http.StatusOK, rr.Result().StatusCode)
+	}
+	body, err := io.ReadAll(rr.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string, len(archive.File))
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name] = string(content)
+	}
+
+	expectedFiles := map[string]string{
+		"creme-brulee.md":       "# crème brûlée\n\n## Instructions\n\n1. Brûler.\n",
+		"tarte-aux-pommes.md":   "# Tarte aux pommes\n\n## Instructions\n\n1. Cuire.\n",
+		"tarte-aux-pommes-2.md": "# tarte aux pommes\n\n## Instructions\n\n1. Cuire longtemps.\n",
+		"index.md": `# Recettes
+
+- [crème brûlée](creme-brulee.md)
+- [Tarte aux pommes](tarte-aux-pommes.md)
+- [tarte aux pommes](tarte-aux-pommes-2.md)
+`,
+	}
+	if diff := testutils.DeepEqual(files, expectedFiles); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/schemaorg"
+	"github.com/remieven/miam/service"
+)
+
+//go:embed templates/*.html
+var templatesFs embed.FS
+
+var pageTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"minutes": func(duration time.Duration) int {
+		return int(duration / time.Minute)
+	},
+}).ParseFS(templatesFs, "templates/*.html"))
+
+// RecipePageHandler is a handler for the server-rendered pages of recipes, which can be shared with people who do not use the application
+type RecipePageHandler struct {
+	recipeService *service.RecipeService
+}
+
+func newRecipePageHandler(recipeService *service.RecipeService) *RecipePageHandler {
+	return &RecipePageHandler{
+		recipeService,
+	}
+}
+
+// recipePage is the data of a recipe page
+type recipePage struct {
+	Recipe      schemaorg.Recipe
+	Description string
+	// JSONLD is the recipe as schema.org JSON-LD, so that the page previews correctly when shared
+	JSONLD template.JS
+}
+
+// GetRecipePage renders a recipe as an HTML page embedding its schema.org JSON-LD
+func (handler *RecipePageHandler) GetRecipePage(responseWriter http.ResponseWriter, request *http.Request) {
+	recipe, err := handler.recipeService.GetRecipe(request.Context(), mux.Vars(request)["id"])
+	if handlePageErrorCase(responseWriter, request, err) {
+		return
+	}
+
+	converted := service.ToSchemaOrgRecipe(*recipe)
+	// json escapes <, > and &, so the JSON-LD cannot close the script tag it is embedded in
+	jsonLD, err := json.Marshal(converted)
+	if err != nil {
+		http.Error(responseWriter, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writePage(responseWriter, "recipe.html", recipePage{
+		Recipe:      converted,
+		Description: strings.Join(converted.Ingredients, ", "),
+		JSONLD:      template.JS(jsonLD),
+	})
+}
+
+// writePage renders an embedded template as a 200 response
+func writePage(responseWriter http.ResponseWriter, name string, data any) {
+	var page bytes.Buffer
+	if err := pageTemplates.ExecuteTemplate(&page, name, data); err != nil {
+		slog.With("error", err, "template", name).Warn("failed to render page")
+		http.Error(responseWriter, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	responseWriter.WriteHeader(http.StatusOK)
+	_, _ = page.WriteTo(responseWriter)
+}
+
+// handlePageErrorCase writes a plain error page if there is an error, unknown and invalid resources being not found; it returns whether there was one
+func handlePageErrorCase(responseWriter http.ResponseWriter, request *http.Request, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, &failure.ResourceNotFoundError{}), errors.Is(err, &failure.InvalidValueError{}):
+		http.NotFound(responseWriter, request)
+	default:
+		slog.With("error", err).Warn("failed to get recipes to render")
+		http.Error(responseWriter, "Internal server error", http.StatusInternalServerError)
+	}
+	return true
+}
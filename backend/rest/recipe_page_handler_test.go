@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/remieven/miam/pb-lite/fixture"
+)
+
+func TestGetRecipePage(t *testing.T) {
+	router, err := newTestRouter(t, fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "pommes <bio>")`,
+		`insert into recipe(id, name, how_to, yield, prep_minutes) values (1, "tarte </script>", "Couper les pommes.
+Cuire.", "6 parts", 20)`,
+		`insert into recipe_ingredient(recipe_id, ingredient_id, quantity) values (1, 1, "4")`,
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := map[string]struct {
+		path             string
+		expectedStatus   int
+		expectedContents []string
+	}{
+		"recipe page": {
+			path:           "/share/recipe/1",
+			expectedStatus: http.StatusOK,
+			expectedContents: []string{
+				`<title>tarte &lt;/script&gt; - Miam</title>`,
+				`<meta property="og:title" content="tarte &lt;/script&gt;">`,
+				`<meta property="og:description" content="4 pommes &lt;bio&gt;">`,
+				`<script type="application/ld+json">{"@context":"https://schema.org","@type":"Recipe","name":"tarte \u003c/script\u003e",`,
+				`"prepTime":"PT20M"`,
+				`<li>Préparation : 20 min</li>`,
+				`<li>4 pommes &lt;bio&gt;</li>`,
+				`<li>Couper les pommes.</li>`,
+			},
+		},
+		"unknown recipe": {
+			path:           "/share/recipe/2",
+			expectedStatus: http.StatusNotFound,
+		},
+		"invalid recipe ID": {
+			path:           "/share/recipe/tarte",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, body := serve(router, http.MethodGet, test.path, "")
+			if status != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, status)
+			}
+			for _, expected := range test.expectedContents {
+				if !strings.Contains(body, expected) {
+					t.Errorf("page does not contain [%s]:\n%s", expected, body)
+				}
+			}
+		})
+	}
+}
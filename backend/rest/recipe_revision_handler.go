@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/pb-lite/rest"
+	"github.com/remieven/miam/service"
+)
+
+// RecipeRevisionHandler is a recipe revision handler
+type RecipeRevisionHandler struct {
+	recipeService *service.RecipeService
+}
+
+func newRecipeRevisionHandler(recipeService *service.RecipeService) *RecipeRevisionHandler {
+	return &RecipeRevisionHandler{
+		recipeService,
+	}
+}
+
+// GetRecipeRevisions lists the revisions of a recipe
+func (handler *RecipeRevisionHandler) GetRecipeRevisions(responseWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	revisions, err := handler.recipeService.GetRecipeRevisions(request.Context(), vars["id"])
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+
+	rest.WriteOKResponse(responseWriter, revisions)
+}
+
+// GetRecipeRevision returns a revision of a recipe
+func (handler *RecipeRevisionHandler) GetRecipeRevision(responseWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	revision, err := handler.recipeService.GetRecipeRevision(request.Context(), vars["id"], vars["rev"])
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+
+	rest.WriteOKResponse(responseWriter, revision)
+}
+
+// DiffRecipeRevisions returns the changes between the two revisions given as query params
+func (handler *RecipeRevisionHandler) DiffRecipeRevisions(responseWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	query := request.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	if from == "" || to == "" {
+		rest.HandleErrorCase(responseWriter, &failure.InvalidValueError{
+			Message: "both from and to query params are required",
+		})
+		return
+	}
+
+	diff, err := handler.recipeService.DiffRecipeRevisions(request.Context(), vars["id"], from, to)
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+
+	rest.WriteOKResponse(responseWriter, diff)
+}
+
+// RestoreRecipeRevision makes a past revision the current version of a recipe
+func (handler *RecipeRevisionHandler) RestoreRecipeRevision(responseWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	restored, err := handler.recipeService.RestoreRecipeRevision(request.Context(), vars["id"], vars["rev"])
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+
+	rest.WriteOKResponse(responseWriter, restored)
+}
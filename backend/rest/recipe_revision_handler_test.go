@@ -0,0 +1,134 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/pb-lite/fixture"
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+func TestRecipeRevisions(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values
+			(1, "farine"),
+			(2, "lait")
+		`,
+		`insert into recipe(id, name, how_to) values (1, "crêpes", "mix")`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values
+			(1, 1, "250g"),
+			(1, 2, "50cl")
+		`,
+		`insert into recipe_revision (id, recipe_id, created_at, content) values
+			(1, 1, 1700000000000, '{"id":"1","name":"crêpes","howTo":"mélanger","ingredients":[{"id":"1","name":"farine","quantity":"200g"},{"id":"3","name":"sucre"}]}'),
+			(2, 1, 1700000060000, '{"id":"1","name":"crêpes","howTo":"mix","ingredients":[{"id":"1","name":"farine","quantity":"250g"},{"id":"2","name":"lait","quantity":"50cl"}]}')
+		`,
+	)
+
+	tests := map[string]struct {
+		prepareDatabase  func(*datasource.DatabaseHolder) error
+		method           string
+		path             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		"list revisions of unknown recipe": {
+			method:           http.MethodGet,
+			path:             "/recipe/1/revisions",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"list revisions": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodGet,
+			path:            "/recipe/1/revisions",
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[
+				{"id": "1", "recipeId": "1", "createdAt": "2023-11-14T22:13:20Z"},
+				{"id": "2", "recipeId": "1", "createdAt": "2023-11-14T22:14:20Z"}
+			]`),
+		},
+		"diff without to revision": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodGet,
+			path:             "/recipe/1/revisions/diff?from=1",
+			expectedStatus:   http.StatusBadRequest,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.InvalidArgumentErrorCode),
+		},
+		"diff revisions": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodGet,
+			path:            "/recipe/1/revisions/diff?from=1&to=2",
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"from": "1",
+				"to": "2",
+				"changes": [
+					{"field": "howTo", "kind": "modified", "before": "mélanger", "after": "mix"},
+					{"field": "ingredients", "kind": "modified", "ingredient": {"id": "1", "name": "farine"}, "before": "200g", "after": "250g"},
+					{"field": "ingredients", "kind": "removed", "ingredient": {"id": "3", "name": "sucre"}},
+					{"field": "ingredients", "kind": "added", "ingredient": {"id": "2", "name": "lait"}, "after": "50cl"}
+				]
+			}`),
+		},
+		"restore unknown revision": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/recipe/1/revisions/3/restore",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"restore revision": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodPost,
+			path:            "/recipe/1/revisions/1/restore",
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "1",
+				"name": "crêpes",
+				"howTo": "mélanger",
+				"ingredients": [
+					{"id": "1", "name": "farine", "quantity": "200g"},
+					{"id": "3", "name": "sucre"}
+				]
+			}`),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, test.prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			request, err := http.NewRequest(test.method, test.path, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
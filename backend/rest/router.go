@@ -12,15 +12,30 @@ import (
 
 var defaultAllowedHosts = []string{"http://localhost:8080"}
 
-// CreateRouter creates a new HTTP router
-func CreateRouter(recipeService *service.RecipeService, ingredientService *service.IngredientService) http.Handler {
+// CreateRouter creates a new HTTP router; requests are measured and metrics are exposed on /metrics unless metricsService is nil
+func CreateRouter(recipeService *service.RecipeService, ingredientService *service.IngredientService, categoryService *service.CategoryService, nutritionService *service.NutritionService, equipmentService *service.EquipmentService, adminService *service.AdminService, importService *service.ImportService, markdownService *service.MarkdownService, backupService *service.BackupService, healthService *service.HealthService, metricsService *service.MetricsService) http.Handler {
 	router := mux.NewRouter()
 
 	var (
-		recipeHandler     = newRecipeHandler(recipeService)
-		ingredientHandler = newIngredientHandler(ingredientService)
+		recipeHandler         = newRecipeHandler(recipeService, markdownService)
+		recipeRevisionHandler = newRecipeRevisionHandler(recipeService)
+		ingredientHandler     = newIngredientHandler(ingredientService)
+		trashHandler          = newTrashHandler(recipeService)
+		categoryHandler       = newCategoryHandler(categoryService)
+		nutritionHandler      = newNutritionHandler(nutritionService)
+		equipmentHandler      = newEquipmentHandler(equipmentService)
+		adminHandler          = newAdminHandler(adminService, backupService)
+		importHandler         = newImportHandler(importService)
+		recipePageHandler     = newRecipePageHandler(recipeService)
+		printHandler          = newPrintHandler(recipeService)
+		healthHandler         = newHealthHandler(healthService)
 	)
 
+	if metricsService != nil {
+		metricsHandler := newMetricsHandler(metricsService)
+		router.Use(metricsHandler.Middleware)
+		router.HandleFunc("/metrics", metricsHandler.GetMetrics).Methods(http.MethodGet)
+	}
 	router.Use(handlers.CompressHandler)
 	router.NotFoundHandler = http.HandlerFunc(rest.NotFoundHandler)
 	router.MethodNotAllowedHandler = http.HandlerFunc(rest.MethodNotAllowedHandler)
@@ -31,9 +46,52 @@ func CreateRouter(recipeService *service.RecipeService, ingredientService *servi
 	router.HandleFunc("/recipe/{id}", recipeHandler.UpdateRecipe).Methods(http.MethodPut)
 	router.HandleFunc("/recipe/{id}", recipeHandler.DeleteRecipe).Methods(http.MethodDelete)
 	router.HandleFunc("/recipe/search", recipeHandler.SearchRecipe).Methods(http.MethodPost)
+	router.HandleFunc("/recipe/import", importHandler.ImportRecipe).Methods(http.MethodPost)
+	router.HandleFunc("/recipe/import/bulk", importHandler.ImportRecipeFile).Methods(http.MethodPost)
+	router.HandleFunc("/recipe/{id}/fork", recipeHandler.ForkRecipe).Methods(http.MethodPost)
+	router.HandleFunc("/recipe/{id}/variants", recipeHandler.GetRecipeVariants).Methods(http.MethodGet)
+	router.HandleFunc("/recipe/{id}/nutrition", nutritionHandler.GetRecipeNutrition).Methods(http.MethodGet)
+	router.HandleFunc("/recipe/{id}/revisions", recipeRevisionHandler.GetRecipeRevisions).Methods(http.MethodGet)
+	router.HandleFunc("/recipe/{id}/revisions/diff", recipeRevisionHandler.DiffRecipeRevisions).Methods(http.MethodGet)
+	router.HandleFunc("/recipe/{id}/revisions/{rev}", recipeRevisionHandler.GetRecipeRevision).Methods(http.MethodGet)
+	router.HandleFunc("/recipe/{id}/revisions/{rev}/restore", recipeRevisionHandler.RestoreRecipeRevision).Methods(http.MethodPost)
+	router.HandleFunc("/trash", trashHandler.GetDeletedRecipes).Methods(http.MethodGet)
+	router.HandleFunc("/trash/{id}", trashHandler.PurgeDeletedRecipe).Methods(http.MethodDelete)
+	router.HandleFunc("/trash/{id}/restore", trashHandler.RestoreDeletedRecipe).Methods(http.MethodPost)
 	router.HandleFunc("/ingredient", ingredientHandler.GetIngredients).Methods(http.MethodGet)
+	router.HandleFunc("/ingredient/import", ingredientHandler.ImportIngredients).Methods(http.MethodPost)
+	router.HandleFunc("/ingredient/duplicates", ingredientHandler.GetDuplicateIngredients).Methods(http.MethodGet)
+	router.HandleFunc("/ingredient/duplicates/merge", ingredientHandler.MergeDuplicateIngredients).Methods(http.MethodPost)
 	router.HandleFunc("/ingredient/{id}", ingredientHandler.UpdateIngredient).Methods(http.MethodPut)
 	router.HandleFunc("/ingredient/{id}", ingredientHandler.DeleteIngredient).Methods(http.MethodDelete)
+	router.HandleFunc("/ingredient/{id}/merge", ingredientHandler.MergeIngredient).Methods(http.MethodPost)
+	router.HandleFunc("/ingredient/{id}/aliases", ingredientHandler.AddIngredientAlias).Methods(http.MethodPost)
+	router.HandleFunc("/ingredient/{id}/aliases/{alias}", ingredientHandler.DeleteIngredientAlias).Methods(http.MethodDelete)
+	router.HandleFunc("/category", categoryHandler.GetCategories).Methods(http.MethodGet)
+	router.HandleFunc("/category", categoryHandler.AddCategory).Methods(http.MethodPost)
+	router.HandleFunc("/category/order", categoryHandler.ReorderCategories).Methods(http.MethodPut)
+	router.HandleFunc("/category/{id}", categoryHandler.UpdateCategory).Methods(http.MethodPut)
+	router.HandleFunc("/category/{id}", categoryHandler.DeleteCategory).Methods(http.MethodDelete)
+	router.HandleFunc("/equipment", equipmentHandler.GetAllEquipment).Methods(http.MethodGet)
+	router.HandleFunc("/equipment", equipmentHandler.AddEquipment).Methods(http.MethodPost)
+	router.HandleFunc("/equipment/owned", equipmentHandler.SetOwnedEquipment).Methods(http.MethodPut)
+	router.HandleFunc("/equipment/{id}", equipmentHandler.UpdateEquipment).Methods(http.MethodPut)
+	router.HandleFunc("/equipment/{id}", equipmentHandler.DeleteEquipment).Methods(http.MethodDelete)
+	router.HandleFunc("/admin/export", adminHandler.Export).Methods(http.MethodGet)
+	router.HandleFunc("/admin/import", adminHandler.Import).Methods(http.MethodPost)
+	router.HandleFunc("/admin/export/markdown", recipeHandler.ExportMarkdownCookbook).Methods(http.MethodGet)
+	router.HandleFunc("/admin/export/bundle", adminHandler.ExportBundle).Methods(http.MethodGet)
+	router.HandleFunc("/admin/import/bundle", adminHandler.ImportBundle).Methods(http.MethodPost)
+	router.HandleFunc("/admin/check", adminHandler.CheckIntegrity).Methods(http.MethodGet)
+	router.HandleFunc("/admin/check/repair", adminHandler.RepairIntegrity).Methods(http.MethodPost)
+	router.HandleFunc("/admin/backup", adminHandler.Backup).Methods(http.MethodPost)
+	router.HandleFunc("/healthz", healthHandler.GetHealth).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", healthHandler.GetReadiness).Methods(http.MethodGet)
+	router.HandleFunc("/version", healthHandler.GetVersion).Methods(http.MethodGet)
+
+	router.HandleFunc("/share/recipe/{id}", recipePageHandler.GetRecipePage).Methods(http.MethodGet)
+	router.HandleFunc("/print/recipe/{id}", printHandler.GetPrintedRecipe).Methods(http.MethodGet)
+	router.HandleFunc("/print/book", printHandler.GetPrintedBook).Methods(http.MethodGet)
 
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", SpaHandler{})).Methods(http.MethodGet)
 
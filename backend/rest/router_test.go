@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/markdown"
+	"github.com/remieven/miam/nutrition"
+	"github.com/remieven/miam/pb-lite/testutils"
+	"github.com/remieven/miam/service"
+)
+
+// newTestRouter creates a router backed by a fresh database prepared with the given function (which can be nil)
+func newTestRouter(t *testing.T, prepareDatabase func(*datasource.DatabaseHolder) error) (http.Handler, error) {
+	dbFilePath := testutils.GetRandomDBFileName()
+	databaseHolder, err := datasource.NewDatabaseHolder(dbFilePath)
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() {
+		if err := databaseHolder.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	categoryDao, err := datasource.NewCategoryDao(databaseHolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize categoryDao: %w", err)
+	}
+	ingredientDao, err := datasource.NewIngredientDao(databaseHolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize ingredientDao: %w", err)
+	}
+	ingredientAliasDao, err := datasource.NewIngredientAliasDao(databaseHolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize ingredientAliasDao: %w", err)
+	}
+	recipeIngredientDao, err := datasource.NewRecipeIngredientDao(databaseHolder, ingredientDao, ingredientAliasDao)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize recipeIngredientDao: %w", err)
+	}
+	equipmentDao, err := datasource.NewEquipmentDao(databaseHolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize equipmentDao: %w", err)
+	}
+	recipeRevisionDao, err := datasource.NewRecipeRevisionDao(databaseHolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize recipeRevisionDao: %w", err)
+	}
+	recipeDao, err := datasource.NewRecipeDao(databaseHolder, recipeIngredientDao, recipeRevisionDao, equipmentDao)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize recipeDao: %w", err)
+	}
+	recipeSearchDao, err := datasource.NewRecipeSearchDao()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize recipeSearchDao: %w", err)
+	}
+	t.Cleanup(func() {
+		if err := recipeSearchDao.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if prepareDatabase != nil {
+		if err := prepareDatabase(databaseHolder); err != nil {
+			return nil, fmt.Errorf("failed to prepare database: %w", err)
+		}
+	}
+
+	nutrientTable, err := nutrition.LoadDefault()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load nutrient table: %w", err)
+	}
+	markdownTemplates, err := markdown.LoadDefault()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load markdown templates: %w", err)
+	}
+
+	var (
+		recipeService     = service.NewRecipeService(recipeDao, recipeSearchDao, recipeRevisionDao, ingredientDao, recipeIngredientDao, equipmentDao)
+		ingredientService = service.NewIngredientService(ingredientDao, recipeIngredientDao, ingredientAliasDao, categoryDao, recipeService)
+		categoryService   = service.NewCategoryService(categoryDao)
+		nutritionService  = service.NewNutritionService(recipeService, ingredientAliasDao, nutrientTable)
+		equipmentService  = service.NewEquipmentService(equipmentDao, recipeService)
+		importService     = service.NewImportService(recipeService, ingredientDao, ingredientAliasDao, equipmentDao)
+		adminService      = service.NewAdminService(datasource.NewExportDao(databaseHolder), datasource.NewIntegrityDao(databaseHolder), recipeService, importService)
+		markdownService   = service.NewMarkdownService(recipeService, markdownTemplates)
+	)
+	backupService, err := service.NewBackupService(databaseHolder, t.TempDir(), 2, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup service: %w", err)
+	}
+
+	healthService := service.NewHealthService(databaseHolder, recipeService, backupService)
+	metricsService := service.NewMetricsService(datasource.NewMetricsDao(databaseHolder), recipeSearchDao)
+
+	ctx := context.Background()
+	if err := recipeService.IndexAllExistingRecipes(ctx); err != nil {
+		return nil, fmt.Errorf("failed to index recipes: %w", err)
+	}
+
+	return CreateRouter(recipeService, ingredientService, categoryService, nutritionService, equipmentService, adminService, importService, markdownService, backupService, healthService, metricsService), nil
+}
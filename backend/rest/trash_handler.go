@@ -0,0 +1,51 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/remieven/miam/pb-lite/rest"
+	"github.com/remieven/miam/service"
+)
+
+// TrashHandler is a handler for deleted recipes
+type TrashHandler struct {
+	recipeService *service.RecipeService
+}
+
+func newTrashHandler(recipeService *service.RecipeService) *TrashHandler {
+	return &TrashHandler{
+		recipeService,
+	}
+}
+
+// GetDeletedRecipes lists the recipes that are in the trash
+func (handler *TrashHandler) GetDeletedRecipes(responseWriter http.ResponseWriter, request *http.Request) {
+	recipes, err := handler.recipeService.GetDeletedRecipes(request.Context())
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, recipes)
+}
+
+// RestoreDeletedRecipe takes a recipe out of the trash
+func (handler *TrashHandler) RestoreDeletedRecipe(responseWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	recipe, err := handler.recipeService.RestoreDeletedRecipe(request.Context(), vars["id"])
+	if rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteOKResponse(responseWriter, recipe)
+}
+
+// PurgeDeletedRecipe permanently deletes a recipe that is in the trash
+func (handler *TrashHandler) PurgeDeletedRecipe(responseWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	if err := handler.recipeService.PurgeDeletedRecipe(request.Context(), vars["id"]); rest.HandleErrorCase(responseWriter, err) {
+		return
+	}
+	rest.WriteNoContentResponse(responseWriter)
+}
@@ -0,0 +1,149 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/pb-lite/fixture"
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+func TestTrash(t *testing.T) {
+	prepareDatabase := fixture.PrepareDatabase(
+		`insert into ingredient(id, name) values (1, "farine")`,
+		`insert into recipe(id, name, how_to, deleted_at) values
+			(1, "crêpes", "mix", null),
+			(2, "gaufres", "bake", 1700000000000)
+		`,
+		`insert into recipe_ingredient (recipe_id, ingredient_id, quantity) values
+			(1, 1, "250g"),
+			(2, 1, "300g")
+		`,
+	)
+
+	tests := map[string]struct {
+		prepareDatabase  func(*datasource.DatabaseHolder) error
+		method           string
+		path             string
+		expectedStatus   int
+		responseBodyTest func(string) (string, bool)
+	}{
+		"empty trash": {
+			method:           http.MethodGet,
+			path:             "/trash",
+			expectedStatus:   http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[]`),
+		},
+		"list deleted recipes": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodGet,
+			path:            "/trash",
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`[{
+				"id": "2",
+				"name": "gaufres",
+				"howTo": "bake",
+				"ingredients": [{"id": "1", "name": "farine", "quantity": "300g"}],
+				"deletedAt": "2023-11-14T22:13:20Z"
+			}]`),
+		},
+		"deleted recipe cannot be retrieved": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodGet,
+			path:             "/recipe/2",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"delete recipe": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodDelete,
+			path:             "/recipe/1",
+			expectedStatus:   http.StatusNoContent,
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"delete recipe that is already in trash": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodDelete,
+			path:             "/recipe/2",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"delete unknown recipe": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodDelete,
+			path:             "/recipe/999",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"restore deleted recipe": {
+			prepareDatabase: prepareDatabase,
+			method:          http.MethodPost,
+			path:            "/trash/2/restore",
+			expectedStatus:  http.StatusOK,
+			responseBodyTest: testutils.JsonResponseBodyTest(`{
+				"id": "2",
+				"name": "gaufres",
+				"howTo": "bake",
+				"ingredients": [{"id": "1", "name": "farine", "quantity": "300g"}]
+			}`),
+		},
+		"restore recipe that is not in trash": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodPost,
+			path:             "/trash/1/restore",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+		"purge deleted recipe": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodDelete,
+			path:             "/trash/2",
+			expectedStatus:   http.StatusNoContent,
+			responseBodyTest: testutils.EmptyResponseBodyTest,
+		},
+		"purge recipe that is not in trash": {
+			prepareDatabase:  prepareDatabase,
+			method:           http.MethodDelete,
+			path:             "/trash/1",
+			expectedStatus:   http.StatusNotFound,
+			responseBodyTest: testutils.ErrorResponseBodyTest(failure.ResourceNotFoundErrorCode),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, err := newTestRouter(t, test.prepareDatabase)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			request, err := http.NewRequest(test.method, test.path, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			if rr.Result().StatusCode != test.expectedStatus {
+				t.Errorf("unexpected statusCode: wanted [%d], got [%d]", test.expectedStatus, rr.Result().StatusCode)
+			}
+
+			responseBody, err := io.ReadAll(rr.Result().Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if msg, ok := test.responseBodyTest(string(responseBody)); !ok {
+				t.Error(msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package schemaorg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// ErrNoRecipe is returned when a document does not contain any schema.org recipe
+var ErrNoRecipe = errors.New("no schema.org recipe found")
+
+// Recipe is the part of a schema.org recipe that can be imported
+type Recipe struct {
+	Name         string
+	Ingredients  []string
+	Instructions []string
+	Yield        string
+	PrepTime     time.Duration
+	CookTime     time.Duration
+	TotalTime    time.Duration
+}
+
+// Parse extracts the first recipe found either in the JSON-LD blocks of an HTML page, or in a raw JSON-LD document
+func Parse(data []byte) (*Recipe, error) {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff")))
+	if len(data) != 0 && (data[0] == '{' || data[0] == '[') {
+		return parseJSONLD(data)
+	}
+
+	blocks, err := extractJSONLD(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range blocks {
+		recipe, err := parseJSONLD(block)
+		if err == nil {
+			return recipe, nil
+		}
+	}
+	return nil, ErrNoRecipe
+}
+
+// extractJSONLD returns the content of the JSON-LD script blocks of an HTML page
+func extractJSONLD(page []byte) ([][]byte, error) {
+	var blocks [][]byte
+	tokenizer := html.NewTokenizer(bytes.NewReader(page))
+	inJSONLD := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if errors.Is(tokenizer.Err(), io.EOF) {
+				return blocks, nil
+			}
+			return nil, fmt.Errorf("failed to read html page: %w", tokenizer.Err())
+		case html.StartTagToken:
+			token := tokenizer.Token()
+			inJSONLD = token.Data == "script" && isJSONLDScript(token)
+		case html.TextToken:
+			if inJSONLD {
+				blocks = append(blocks, bytes.Clone(tokenizer.Text()))
+			}
+		case html.EndTagToken:
+			inJSONLD = false
+		}
+	}
+}
+
+// isJSONLDScript tells whether a script tag holds JSON-LD data
+func isJSONLDScript(token html.Token) bool {
+	for _, attribute := range token.Attr {
+		if attribute.Key == "type" {
+			mediaType, _, _ := strings.Cut(attribute.Val, ";")
+			return strings.EqualFold(strings.TrimSpace(mediaType), "application/ld+json")
+		}
+	}
+	return false
+}
+
+// parseJSONLD finds the first recipe of a JSON-LD document
+func parseJSONLD(data []byte) (*Recipe, error) {
+	var document any
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid JSON-LD document: %w", err)
+	}
+	node := findRecipe(document)
+	if node == nil {
+		return nil, ErrNoRecipe
+	}
+
+	ingredients := node["recipeIngredient"]
+	if ingredients == nil {
+		ingredients = node["ingredients"]
+	}
+	recipe := &Recipe{
+		Name:         text(node["name"]),
+		Ingredients:  texts(ingredients),
+		Instructions: instructions(node["recipeInstructions"]),
+		Yield:        yield(node["recipeYield"]),
+	}
+	recipe.PrepTime, _ = ParseDuration(text(node["prepTime"]))
+	recipe.CookTime, _ = ParseDuration(text(node["cookTime"]))
+	recipe.TotalTime, _ = ParseDuration(text(node["totalTime"]))
+	return recipe, nil
+}
+
+// findRecipe walks through a JSON-LD document, including graphs and main entities, to find a node typed as a recipe
+func findRecipe(node any) map[string]any {
+	switch value := node.(type) {
+	case []any:
+		for _, item := range value {
+			if recipe := findRecipe(item); recipe != nil {
+				return recipe
+			}
+		}
+	case map[string]any:
+		if hasType(value, "Recipe") {
+			return value
+		}
+		for _, key := range []string{"@graph", "mainEntity"} {
+			if recipe := findRecipe(value[key]); recipe != nil {
+				return recipe
+			}
+		}
+	}
+	return nil
+}
+
+// hasType tells whether a JSON-LD node has the given type, which may be one among several
+func hasType(node map[string]any, expected string) bool {
+	for _, nodeType := range texts(node["@type"]) {
+		if nodeType == expected || nodeType == "http://schema.org/"+expected || nodeType == "https://schema.org/"+expected {
+			return true
+		}
+	}
+	return false
+}
+
+// text returns a JSON-LD value as text; HTML entities, which many websites leave in their JSON-LD, are unescaped
+func text(value any) string {
+	switch typed := value.(type) {
+	case string:
+		return strings.TrimSpace(html.UnescapeString(typed))
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	}
+	return ""
+}
+
+// texts returns a JSON-LD value that may be a single value or a list as a list of texts, skipping blank ones
+func texts(value any) []string {
+	values, isList := value.([]any)
+	if !isList {
+		values = []any{value}
+	}
+	var result []string
+	for _, item := range values {
+		if itemText := text(item); itemText != "" {
+			result = append(result, itemText)
+		}
+	}
+	return result
+}
+
+// instructions flattens recipe instructions, which may be a text, a list of texts, of steps or of sections of steps
+func instructions(value any) []string {
+	switch typed := value.(type) {
+	case string:
+		var steps []string
+		for _, line := range strings.Split(text(typed), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				steps = append(steps, line)
+			}
+		}
+		return steps
+	case []any:
+		var steps []string
+		for _, item := range typed {
+			steps = append(steps, instructions(item)...)
+		}
+		return steps
+	case map[string]any:
+		if hasType(typed, "HowToSection") {
+			return instructions(typed["itemListElement"])
+		}
+		if step := text(typed["text"]); step != "" {
+			return []string{step}
+		}
+		return texts(typed["name"])
+	}
+	return nil
+}
+
+// yield returns the recipe yield; when several are given (eg. "4" and "4 servings"), the most descriptive one is chosen
+func yield(value any) string {
+	yields := texts(value)
+	for _, candidate := range yields {
+		if _, err := strconv.ParseFloat(candidate, 64); err != nil {
+			return candidate
+		}
+	}
+	if len(yields) != 0 {
+		return yields[0]
+	}
+	return ""
+}
+
+// isoDuration matches the ISO 8601 durations used by schema.org, eg. PT1H30M or P0DT0H20M
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// ParseDuration parses an ISO 8601 duration such as PT1H30M; it returns false if the duration is missing or invalid
+func ParseDuration(duration string) (time.Duration, bool) {
+	duration = strings.ToUpper(strings.TrimSpace(duration))
+	matches := isoDuration.FindStringSubmatch(duration)
+	if matches == nil || strings.Trim(duration, "PT") == "" {
+		return 0, false
+	}
+	var result time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if matches[i+1] == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(matches[i+1], 64)
+		if err != nil {
+			return 0, false
+		}
+		result += time.Duration(value * float64(unit))
+	}
+	return result, true
+}
+
+// FormatDuration formats a duration as an ISO 8601 duration such as PT1H30M; it returns an empty text for a zero duration
+func FormatDuration(duration time.Duration) string {
+	if duration <= 0 {
+		return ""
+	}
+	var formatted strings.Builder
+	formatted.WriteString("PT")
+	if hours := int(duration / time.Hour); hours != 0 {
+		formatted.WriteString(strconv.Itoa(hours) + "H")
+	}
+	if minutes := int(duration % time.Hour / time.Minute); minutes != 0 {
+		formatted.WriteString(strconv.Itoa(minutes) + "M")
+	}
+	if seconds := int(duration % time.Minute / time.Second); seconds != 0 {
+		formatted.WriteString(strconv.Itoa(seconds) + "S")
+	}
+	return formatted.String()
+}
+
+// jsonLDRecipe is a recipe as written in JSON-LD
+type jsonLDRecipe struct {
+	Context      string      `json:"@context"`
+	Type         string      `json:"@type"`
+	Name         string      `json:"name"`
+	Ingredients  []string    `json:"recipeIngredient,omitempty"`
+	Instructions []howToStep `json:"recipeInstructions,omitempty"`
+	Yield        string      `json:"recipeYield,omitempty"`
+	PrepTime     string      `json:"prepTime,omitempty"`
+	CookTime     string      `json:"cookTime,omitempty"`
+	TotalTime    string      `json:"totalTime,omitempty"`
+}
+
+// howToStep is a step of the instructions of a recipe
+type howToStep struct {
+	Type string `json:"@type"`
+	Text string `json:"text"`
+}
+
+// MarshalJSON writes the recipe as a schema.org JSON-LD document
+func (recipe Recipe) MarshalJSON() ([]byte, error) {
+	document := jsonLDRecipe{
+		Context:     "https://schema.org",
+		Type:        "Recipe",
+		Name:        recipe.Name,
+		Ingredients: recipe.Ingredients,
+		Yield:       recipe.Yield,
+		PrepTime:    FormatDuration(recipe.PrepTime),
+		CookTime:    FormatDuration(recipe.CookTime),
+		TotalTime:   FormatDuration(recipe.TotalTime),
+	}
+	for _, instruction := range recipe.Instructions {
+		document.Instructions = append(document.Instructions, howToStep{
+			Type: "HowToStep",
+			Text: instruction,
+		})
+	}
+	return json.Marshal(document)
+}
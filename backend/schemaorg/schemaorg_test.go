@@ -0,0 +1,213 @@
+package schemaorg
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/remieven/miam/pb-lite/testutils"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		data           string
+		expectedRecipe *Recipe
+		expectedError  error
+	}{
+		"raw JSON-LD": {
+			data: `{
+				"@context": "https://schema.org",
+				"@type": "Recipe",
+				"name": "Crêpes",
+				"recipeIngredient": ["250 g de farine", "4 œufs", "50 cl de lait"],
+				"recipeInstructions": "Mélanger la farine et les œufs.\nAjouter le lait.",
+				"recipeYield": 4,
+				"prepTime": "PT10M",
+				"cookTime": "PT1H",
+				"totalTime": "P0DT1H10M"
+			}`,
+			expectedRecipe: &Recipe{
+				Name:         "Crêpes",
+				Ingredients:  []string{"250 g de farine", "4 œufs", "50 cl de lait"},
+				Instructions: []string{"Mélanger la farine et les œufs.", "Ajouter le lait."},
+				Yield:        "4",
+				PrepTime:     10 * time.Minute,
+				CookTime:     time.Hour,
+				TotalTime:    70 * time.Minute,
+			},
+		},
+		"recipe in a graph, with steps and sections": {
+			data: `{
+				"@context": "https://schema.org",
+				"@graph": [
+					{"@type": "WebSite", "name": "Cuisine"},
+					{
+						"@type": ["Recipe", "NewsArticle"],
+						"name": "Tarte aux pommes",
+						"recipeIngredient": ["3 pommes", "1 pâte brisée"],
+						"recipeInstructions": [
+							{"@type": "HowToStep", "text": "Étaler la pâte."},
+							{"@type": "HowToSection", "name": "Garniture", "itemListElement": [
+								{"@type": "HowToStep", "text": "Couper les pommes &amp; les disposer."}
+							]}
+						],
+						"recipeYield": ["6", "6 parts"]
+					}
+				]
+			}`,
+			expectedRecipe: &Recipe{
+				Name:         "Tarte aux pommes",
+				Ingredients:  []string{"3 pommes", "1 pâte brisée"},
+				Instructions: []string{"Étaler la pâte.", "Couper les pommes & les disposer."},
+				Yield:        "6 parts",
+			},
+		},
+		"html page": {
+			data: `<!DOCTYPE html>
+				<html><head>
+				<script type="application/ld+json">{"@type": "Organization", "name": "Cuisine"}</script>
+				<script type="application/ld+json; charset=utf-8">
+					[{"@type": "Recipe", "name": "Salade", "recipeIngredient": ["1 laitue"], "recipeInstructions": ["Laver la laitue."]}]
+				</script>
+				</head><body><h1>Salade</h1></body></html>`,
+			expectedRecipe: &Recipe{
+				Name:         "Salade",
+				Ingredients:  []string{"1 laitue"},
+				Instructions: []string{"Laver la laitue."},
+			},
+		},
+		"html page without recipe": {
+			data:          `<html><body><p>Nothing to see</p></body></html>`,
+			expectedError: ErrNoRecipe,
+		},
+		"JSON-LD without recipe": {
+			data:          `{"@type": "Person", "name": "Rémi"}`,
+			expectedError: ErrNoRecipe,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actualRecipe, err := Parse([]byte(test.data))
+			if !errors.Is(err, test.expectedError) {
+				t.Fatalf("got error [%v], wanted [%v]", err, test.expectedError)
+			}
+			if diff := testutils.DeepEqual(actualRecipe, test.expectedRecipe); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]struct {
+		duration         string
+		expectedDuration time.Duration
+		expectedOk       bool
+	}{
+		"empty": {
+			duration: "",
+		},
+		"no value": {
+			duration: "PT",
+		},
+		"invalid": {
+			duration: "20 minutes",
+		},
+		"minutes": {
+			duration:         "PT20M",
+			expectedDuration: 20 * time.Minute,
+			expectedOk:       true,
+		},
+		"hours and minutes": {
+			duration:         "PT1H30M",
+			expectedDuration: 90 * time.Minute,
+			expectedOk:       true,
+		},
+		"days and seconds": {
+			duration:         "P1DT30S",
+			expectedDuration: 24*time.Hour + 30*time.Second,
+			expectedOk:       true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actualDuration, actualOk := ParseDuration(test.duration)
+			if actualOk != test.expectedOk {
+				t.Errorf("got ok [%v], wanted [%v]", actualOk, test.expectedOk)
+			}
+			if actualDuration != test.expectedDuration {
+				t.Errorf("got duration [%v], wanted [%v]", actualDuration, test.expectedDuration)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[string]struct {
+		duration          time.Duration
+		expectedFormatted string
+	}{
+		"zero": {
+			duration: 0,
+		},
+		"minutes": {
+			duration:          20 * time.Minute,
+			expectedFormatted: "PT20M",
+		},
+		"hours and minutes": {
+			duration:          90 * time.Minute,
+			expectedFormatted: "PT1H30M",
+		},
+		"more than a day": {
+			duration:          25*time.Hour + 30*time.Second,
+			expectedFormatted: "PT25H30S",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actualFormatted := FormatDuration(test.duration); actualFormatted != test.expectedFormatted {
+				t.Errorf("got [%s], wanted [%s]", actualFormatted, test.expectedFormatted)
+			}
+		})
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	recipe := Recipe{
+		Name:         "Crêpes",
+		Ingredients:  []string{"250 g farine", "4 œufs"},
+		Instructions: []string{"Mélanger.", "Cuire."},
+		Yield:        "8 crêpes",
+		PrepTime:     10 * time.Minute,
+		TotalTime:    30 * time.Minute,
+	}
+
+	marshalled, err := json.Marshal(recipe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, ok := testutils.JsonResponseBodyTest(`{
+		"@context": "https://schema.org",
+		"@type": "Recipe",
+		"name": "Crêpes",
+		"recipeIngredient": ["250 g farine", "4 œufs"],
+		"recipeInstructions": [{"@type": "HowToStep", "text": "Mélanger."}, {"@type": "HowToStep", "text": "Cuire."}],
+		"recipeYield": "8 crêpes",
+		"prepTime": "PT10M",
+		"totalTime": "PT30M"
+	}`)(string(marshalled)); !ok {
+		t.Error(msg)
+	}
+
+	parsed, err := Parse(marshalled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := testutils.DeepEqual(*parsed, recipe); diff != "" {
+		t.Error(diff)
+	}
+}
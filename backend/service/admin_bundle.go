@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/remieven/miam/bundle"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/quantity"
+	"github.com/remieven/miam/similarity"
+)
+
+// WriteBundle writes all the recipes as a bundle, which can be imported into another instance of the application
+func (service *AdminService) WriteBundle(ctx context.Context, writer io.Writer) error {
+	recipes, err := service.recipeService.GetAllRecipes(ctx)
+	if err != nil {
+		return err
+	}
+	exported := make(map[string]bool, len(recipes))
+	for _, recipe := range recipes {
+		exported[recipe.ID] = true
+	}
+
+	archive := bundle.Bundle{
+		Manifest: bundle.Manifest{
+			CreatedAt: time.Now().UTC(),
+		},
+		Recipes: make([]bundle.Recipe, 0, len(recipes)),
+	}
+	for _, recipe := range recipes {
+		archive.Recipes = append(archive.Recipes, toBundleRecipe(recipe, exported))
+	}
+	if err := bundle.Write(writer, archive); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// toBundleRecipe converts a recipe for a bundle; references to recipes that are not exported, such as the ones in the trash, are dropped,
+// sub-recipes then being kept as named ingredients
+func toBundleRecipe(recipe model.Recipe, exported map[string]bool) bundle.Recipe {
+	bundled := bundle.Recipe{
+		ID:           recipe.ID,
+		Name:         recipe.Name,
+		HowTo:        recipe.HowTo,
+		Yield:        recipe.Yield,
+		PrepMinutes:  recipe.PrepMinutes,
+		CookMinutes:  recipe.CookMinutes,
+		TotalMinutes: recipe.TotalMinutes,
+	}
+	if exported[recipe.ParentID] {
+		bundled.ParentID = recipe.ParentID
+	}
+	for _, ingredient := range recipe.Ingredients {
+		bundledIngredient := bundle.Ingredient{
+			Name:     ingredient.Name,
+			Quantity: ingredient.Quantity,
+		}
+		if exported[ingredient.SubRecipeID] {
+			bundledIngredient.SubRecipeID = ingredient.SubRecipeID
+		}
+		bundled.Ingredients = append(bundled.Ingredients, bundledIngredient)
+	}
+	for _, equipment := range recipe.Equipment {
+		bundled.Equipment = append(bundled.Equipment, equipment.Name)
+	}
+	return bundled
+}
+
+// ImportBundle adds the recipes of a bundle, matching their ingredients and equipment by name and creating the missing ones.
+// The conflict strategy tells what to do with the recipes having the same name as an existing recipe: skip them (default), overwrite the existing recipe, or add them as new recipes;
+// an existing recipe is overwritten once, the other recipes of the bundle with its name being added as new recipes.
+// All the recipes are converted before being saved in a single transaction, so that either the whole bundle or nothing is imported.
+func (service *AdminService) ImportBundle(ctx context.Context, data []byte, conflict string) (*model.BundleImportReport, error) {
+	conflictStatuses := map[string]string{
+		model.BundleConflictSkip:      model.BundleRecipeSkipped,
+		model.BundleConflictOverwrite: model.BundleRecipeOverwritten,
+		model.BundleConflictDuplicate: model.BundleRecipeDuplicated,
+	}
+	if conflict == "" {
+		conflict = model.BundleConflictSkip
+	}
+	conflictStatus, known := conflictStatuses[conflict]
+	if !known {
+		return nil, &failure.InvalidValueError{
+			Message: "unknown conflict strategy [" + conflict + "], expected [" + model.BundleConflictSkip + "], [" + model.BundleConflictOverwrite + "] or [" + model.BundleConflictDuplicate + "]",
+		}
+	}
+
+	archive, err := bundle.Read(data)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: "failed to read bundle",
+			Cause:   err,
+		}
+	}
+	ordered, err := orderBundleRecipes(archive.Recipes)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: "failed to read bundle",
+			Cause:   err,
+		}
+	}
+
+	existing, err := service.recipeService.GetAllRecipes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existingIDs := make(map[string]string, len(existing))
+	for _, recipe := range existing {
+		name := similarity.NormalizeName(recipe.Name)
+		if _, found := existingIDs[name]; !found {
+			existingIDs[name] = recipe.ID
+		}
+	}
+	matcher, err := service.importService.newIngredientMatcher(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// imported maps the IDs of the recipes in the bundle to the recipes they are imported as, whose IDs are only known for the skipped ones until they are saved
+	imported := make(map[string]model.BundleImportedRecipe, len(ordered))
+	// converted are the recipes to save, in the order of their dependencies, referencing the other recipes by their IDs in the bundle
+	converted := make([]model.Recipe, 0, len(ordered))
+	convertedIndexes := make(map[string]int, len(ordered))
+	for _, recipe := range ordered {
+		result := model.BundleImportedRecipe{
+			Name:   recipe.Name,
+			Status: model.BundleRecipeCreated,
+		}
+		name := similarity.NormalizeName(recipe.Name)
+		existingID, conflicting := existingIDs[name]
+		if conflicting {
+			result.Status = conflictStatus
+		}
+		if result.Status == model.BundleRecipeSkipped {
+			result.ID = existingID
+			imported[recipe.ID] = result
+			continue
+		}
+
+		convertedRecipe, err := service.fromBundleRecipe(ctx, recipe, imported, matcher)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import recipe [%s]: %w", recipe.Name, err)
+		}
+		if result.Status == model.BundleRecipeOverwritten {
+			convertedRecipe.ID = existingID
+			delete(existingIDs, name)
+		}
+		if err := service.checkBundleRecipe(ctx, convertedRecipe.ID, convertedRecipe, imported); err != nil {
+			return nil, fmt.Errorf("failed to import recipe [%s]: %w", recipe.Name, err)
+		}
+		imported[recipe.ID] = result
+		convertedIndexes[recipe.ID] = len(converted)
+		converted = append(converted, convertedRecipe)
+	}
+
+	savedIDs, err := service.recipeService.SaveRecipes(ctx, len(converted), func(savedIDs []string) model.Recipe {
+		// the recipes referenced by the next recipe are either skipped or saved before it
+		resolve := func(bundleID string) string {
+			if index, found := convertedIndexes[bundleID]; found {
+				return savedIDs[index]
+			}
+			return imported[bundleID].ID
+		}
+		recipe := converted[len(savedIDs)]
+		if recipe.ParentID != "" {
+			recipe.ParentID = resolve(recipe.ParentID)
+		}
+		recipe.Ingredients = append([]model.RecipeIngredient(nil), recipe.Ingredients...)
+		for i := range recipe.Ingredients {
+			if recipe.Ingredients[i].SubRecipeID != "" {
+				recipe.Ingredients[i].SubRecipeID = resolve(recipe.Ingredients[i].SubRecipeID)
+			}
+		}
+		return recipe
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save the recipes of the bundle: %w", err)
+	}
+	for bundleID, index := range convertedIndexes {
+		result := imported[bundleID]
+		result.ID = savedIDs[index]
+		imported[bundleID] = result
+	}
+
+	report := &model.BundleImportReport{
+		Recipes: make([]model.BundleImportedRecipe, 0, len(archive.Recipes)),
+	}
+	for _, recipe := range archive.Recipes {
+		result := imported[recipe.ID]
+		switch result.Status {
+		case model.BundleRecipeCreated:
+			report.Created++
+		case model.BundleRecipeSkipped:
+			report.Skipped++
+		case model.BundleRecipeOverwritten:
+			report.Overwritten++
+		case model.BundleRecipeDuplicated:
+			report.Duplicated++
+		}
+		report.Recipes = append(report.Recipes, result)
+	}
+	return report, nil
+}
+
+// fromBundleRecipe converts a recipe of a bundle, whose parent and sub-recipes have already been converted or skipped; they are referenced by their IDs in the bundle
+func (service *AdminService) fromBundleRecipe(ctx context.Context, recipe bundle.Recipe, imported map[string]model.BundleImportedRecipe, matcher *ingredientMatcher) (model.Recipe, error) {
+	converted := model.BaseRecipe{
+		Name:         recipe.Name,
+		HowTo:        recipe.HowTo,
+		ParentID:     recipe.ParentID,
+		Yield:        recipe.Yield,
+		PrepMinutes:  recipe.PrepMinutes,
+		CookMinutes:  recipe.CookMinutes,
+		TotalMinutes: recipe.TotalMinutes,
+	}
+	for _, ingredient := range recipe.Ingredients {
+		if ingredient.SubRecipeID != "" {
+			converted.Ingredients = append(converted.Ingredients, model.RecipeIngredient{
+				Quantity: ingredient.Quantity,
+				Ingredient: model.Ingredient{
+					BaseIngredient: model.BaseIngredient{
+						Name: imported[ingredient.SubRecipeID].Name,
+					},
+				},
+				SubRecipeID: ingredient.SubRecipeID,
+			})
+			continue
+		}
+		converted.Ingredients = append(converted.Ingredients, model.RecipeIngredient{
+			Quantity:   ingredient.Quantity,
+			Ingredient: matcher.match(ingredient.Name),
+		})
+	}
+	equipment, err := service.importService.matchEquipment(ctx, recipe.Equipment)
+	if err != nil {
+		return model.Recipe{}, err
+	}
+	if err := service.importService.addMissingEquipment(ctx, equipment); err != nil {
+		return model.Recipe{}, err
+	}
+	converted.Equipment = equipment
+	return model.Recipe{BaseRecipe: converted}, nil
+}
+
+// checkBundleRecipe checks a converted recipe of a bundle as adding or updating it would: its sub-recipes must be used with a multiplier,
+// and overwriting an existing recipe must not make it a variant or a sub-recipe of itself through the existing recipes the bundle references.
+// The recipes saved along with it cannot lead back to it since the bundle has no cycle.
+func (service *AdminService) checkBundleRecipe(ctx context.Context, ID string, recipe model.Recipe, imported map[string]model.BundleImportedRecipe) error {
+	if parent := imported[recipe.ParentID]; ID != "" && parent.Status == model.BundleRecipeSkipped {
+		if err := service.recipeService.checkParentRecipe(ctx, ID, parent.ID); err != nil {
+			return err
+		}
+	}
+	var skippedSubRecipes []model.RecipeIngredient
+	for _, ingredient := range recipe.Ingredients {
+		if !ingredient.IsSubRecipe() {
+			continue
+		}
+		if subRecipe := imported[ingredient.SubRecipeID]; subRecipe.Status == model.BundleRecipeSkipped {
+			skippedSubRecipes = append(skippedSubRecipes, model.RecipeIngredient{Quantity: ingredient.Quantity, SubRecipeID: subRecipe.ID})
+		} else if _, ok := quantity.ParseFactor(ingredient.Quantity); !ok {
+			return &failure.InvalidValueError{
+				Message: "quantity [" + ingredient.Quantity + "] of sub-recipe [" + subRecipe.Name + "] must be a multiplier such as 2 or 0.5",
+			}
+		}
+	}
+	return service.recipeService.checkSubRecipes(ctx, ID, skippedSubRecipes)
+}
+
+// orderBundleRecipes sorts the recipes of a bundle so that their parents and sub-recipes come before them
+func orderBundleRecipes(recipes []bundle.Recipe) ([]bundle.Recipe, error) {
+	recipesByID := make(map[string]bundle.Recipe, len(recipes))
+	for _, recipe := range recipes {
+		recipesByID[recipe.ID] = recipe
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := make(map[string]int, len(recipes))
+	ordered := make([]bundle.Recipe, 0, len(recipes))
+	var visit func(recipe bundle.Recipe) error
+	visit = func(recipe bundle.Recipe) error {
+		switch states[recipe.ID] {
+		case visiting:
+			return fmt.Errorf("recipe [%s] depends on itself", recipe.ID)
+		case visited:
+			return nil
+		}
+		states[recipe.ID] = visiting
+		dependencies := []string{recipe.ParentID}
+		for _, ingredient := range recipe.Ingredients {
+			dependencies = append(dependencies, ingredient.SubRecipeID)
+		}
+		for _, dependency := range dependencies {
+			if dependency == "" {
+				continue
+			}
+			if err := visit(recipesByID[dependency]); err != nil {
+				return err
+			}
+		}
+		states[recipe.ID] = visited
+		ordered = append(ordered, recipe)
+		return nil
+	}
+	for _, recipe := range recipes {
+		if err := visit(recipe); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
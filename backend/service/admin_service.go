@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+)
+
+// AdminService is a service for administration tasks such as exporting and importing all the data
+type AdminService struct {
+	exportDao     *datasource.ExportDao
+	integrityDao  *datasource.IntegrityDao
+	recipeService *RecipeService
+	importService *ImportService
+}
+
+// NewAdminService creates a new administration service
+func NewAdminService(exportDao *datasource.ExportDao, integrityDao *datasource.IntegrityDao, recipeService *RecipeService, importService *ImportService) *AdminService {
+	return &AdminService{
+		exportDao,
+		integrityDao,
+		recipeService,
+		importService,
+	}
+}
+
+// WriteExport writes an export of all the data as JSON, streaming it as it is read
+func (service *AdminService) WriteExport(ctx context.Context, writer io.Writer) error {
+	return service.exportDao.WriteExport(ctx, writer)
+}
+
+// Import imports an export, merging it into the existing data or replacing it according to the given mode, then rebuilds the search index
+func (service *AdminService) Import(ctx context.Context, export model.Export, mode string) (*model.ImportReport, error) {
+	if export.Version != model.ExportVersion {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("unsupported export version [%d], expected [%d]", export.Version, model.ExportVersion),
+		}
+	}
+	if mode == "" {
+		mode = model.ImportModeMerge
+	}
+	if mode != model.ImportModeMerge && mode != model.ImportModeReplace {
+		return nil, &failure.InvalidValueError{
+			Message: "unknown import mode [" + mode + "], expected [" + model.ImportModeMerge + "] or [" + model.ImportModeReplace + "]",
+		}
+	}
+	report, err := service.exportDao.Import(ctx, export, mode == model.ImportModeReplace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import: %w", err)
+	}
+	if err := service.recipeService.RebuildIndex(ctx); err != nil {
+		return nil, fmt.Errorf("failed to index imported recipes: %w", err)
+	}
+	return report, nil
+}
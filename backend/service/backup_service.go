@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/model"
+)
+
+// names of the backup files, which sort chronologically within each kind
+const (
+	dailyBackupPrefix  = "miam-daily-"
+	weeklyBackupPrefix = "miam-weekly-"
+	backupExtension    = ".db"
+)
+
+// BackupService backs up the database into a directory, keeping a number of daily and weekly copies
+type BackupService struct {
+	databaseHolder *datasource.DatabaseHolder
+	directory      string
+	dailyCopies    int
+	weeklyCopies   int
+	// backupMutex prevents concurrent backups; it is held for the whole copy, so lastBackupAt is kept apart to be read without waiting for it
+	backupMutex  sync.Mutex
+	lastBackupAt atomic.Pointer[time.Time]
+}
+
+// NewBackupService creates a new backup service; the last backup time is the one of the most recent copy already in the directory.
+// At least one daily copy is kept, and weekly copies are not made if none are to be kept.
+func NewBackupService(databaseHolder *datasource.DatabaseHolder, directory string, dailyCopies, weeklyCopies int) (*BackupService, error) {
+	service := &BackupService{
+		databaseHolder: databaseHolder,
+		directory:      directory,
+		dailyCopies:    max(dailyCopies, 1),
+		weeklyCopies:   weeklyCopies,
+	}
+	backups, err := service.listBackups()
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) != 0 {
+		service.lastBackupAt.Store(&backups[0].CreatedAt)
+	}
+	return service, nil
+}
+
+// Backup copies the database as the daily copy of the current day, and as the weekly copy of the current week if there is none yet,
+// then removes the oldest copies
+func (service *BackupService) Backup(ctx context.Context) (*model.BackupStatus, error) {
+	service.backupMutex.Lock()
+	defer service.backupMutex.Unlock()
+
+	if err := os.MkdirAll(service.directory, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	now := time.Now()
+	dailyPath := filepath.Join(service.directory, dailyBackupPrefix+now.Format("2006-01-02")+backupExtension)
+	if err := service.databaseHolder.Backup(ctx, dailyPath); err != nil {
+		return nil, fmt.Errorf("failed to back up database: %w", err)
+	}
+	if service.weeklyCopies > 0 {
+		year, week := now.ISOWeek()
+		weeklyPath := filepath.Join(service.directory, fmt.Sprintf("%s%04d-W%02d%s", weeklyBackupPrefix, year, week, backupExtension))
+		if _, err := os.Stat(weeklyPath); errors.Is(err, fs.ErrNotExist) {
+			if err := copyFile(dailyPath, weeklyPath); err != nil {
+				return nil, fmt.Errorf("failed to make weekly backup: %w", err)
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to look for weekly backup: %w", err)
+		}
+	}
+	service.lastBackupAt.Store(&now)
+	slog.With("path", dailyPath).Info("backed up database")
+
+	if err := service.removeOldBackups(); err != nil {
+		return nil, err
+	}
+	backups, err := service.listBackups()
+	if err != nil {
+		return nil, err
+	}
+	return &model.BackupStatus{
+		LastBackupAt: &now,
+		Backups:      backups,
+	}, nil
+}
+
+// LastBackupAt returns the time of the last successful backup, or nil if the database was never backed up
+func (service *BackupService) LastBackupAt() *time.Time {
+	lastBackupAt := service.lastBackupAt.Load()
+	if lastBackupAt == nil {
+		return nil
+	}
+	// a copy is returned so that callers cannot change the stored time
+	copied := *lastBackupAt
+	return &copied
+}
+
+// BackupPeriodically backs up the database every period, the first time as soon as the last backup is older than the period, until the context is done
+func (service *BackupService) BackupPeriodically(ctx context.Context, period time.Duration) {
+	next := time.Now()
+	if lastBackupAt := service.LastBackupAt(); lastBackupAt != nil {
+		next = lastBackupAt.Add(period)
+	}
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if _, err := service.Backup(ctx); err != nil {
+			slog.With("error", err).Error("failed to back up database")
+		}
+		next = time.Now().Add(period)
+	}
+}
+
+// removeOldBackups removes the oldest daily and weekly copies beyond the numbers to keep
+func (service *BackupService) removeOldBackups() error {
+	entries, err := os.ReadDir(service.directory)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	for prefix, kept := range map[string]int{dailyBackupPrefix: service.dailyCopies, weeklyBackupPrefix: service.weeklyCopies} {
+		var names []string
+		for _, entry := range entries {
+			if isBackup(entry, prefix) {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(names)))
+		for _, name := range names[min(kept, len(names)):] {
+			if err := os.Remove(filepath.Join(service.directory, name)); err != nil {
+				return fmt.Errorf("failed to remove old backup [%s]: %w", name, err)
+			}
+			slog.With("name", name).Info("removed old backup")
+		}
+	}
+	return nil
+}
+
+// listBackups lists the copies of the database, the most recent ones first
+func (service *BackupService) listBackups() ([]model.Backup, error) {
+	entries, err := os.ReadDir(service.directory)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []model.Backup{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	backups := make([]model.Backup, 0, len(entries))
+	for _, entry := range entries {
+		kind := model.BackupDaily
+		if isBackup(entry, weeklyBackupPrefix) {
+			kind = model.BackupWeekly
+		} else if !isBackup(entry, dailyBackupPrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup [%s]: %w", entry.Name(), err)
+		}
+		backups = append(backups, model.Backup{
+			Name:      entry.Name(),
+			Kind:      kind,
+			Size:      info.Size(),
+			CreatedAt: info.ModTime(),
+		})
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+func isBackup(entry fs.DirEntry, prefix string) bool {
+	return entry.Type().IsRegular() && strings.HasPrefix(entry.Name(), prefix) && strings.HasSuffix(entry.Name(), backupExtension)
+}
+
+// copyFile copies a file through a temporary file, so that the destination is only written once complete
+func copyFile(sourcePath, destinationPath string) (err error) {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	temporaryPath := destinationPath + ".tmp"
+	destination, err := os.Create(temporaryPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(temporaryPath)
+		}
+	}()
+	if _, err := io.Copy(destination, source); err != nil {
+		destination.Close()
+		return err
+	}
+	if err := destination.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporaryPath, destinationPath)
+}
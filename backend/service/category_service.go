@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+)
+
+// CategoryService is an ingredient category service
+type CategoryService struct {
+	categoryDao *datasource.CategoryDao
+}
+
+// NewCategoryService creates a new ingredient category service
+func NewCategoryService(categoryDao *datasource.CategoryDao) *CategoryService {
+	return &CategoryService{
+		categoryDao,
+	}
+}
+
+// GetCategories returns all categories, sorted by aisle order
+func (service *CategoryService) GetCategories(ctx context.Context) ([]model.Category, error) {
+	return service.categoryDao.GetCategories(ctx)
+}
+
+// AddCategory adds a category
+func (service *CategoryService) AddCategory(ctx context.Context, category model.BaseCategory) (string, error) {
+	if err := checkCategory(category); err != nil {
+		return "", err
+	}
+	return service.categoryDao.AddCategory(ctx, category)
+}
+
+// UpdateCategory updates a category
+func (service *CategoryService) UpdateCategory(ctx context.Context, ID string, update model.BaseCategory) (*model.Category, error) {
+	if err := checkCategory(update); err != nil {
+		return nil, err
+	}
+	category := model.Category{
+		ID:           ID,
+		BaseCategory: update,
+	}
+	if err := service.categoryDao.UpdateCategory(ctx, category); err != nil {
+		return nil, fmt.Errorf("failed to update category: %w", err)
+	}
+	return &category, nil
+}
+
+// ReorderCategories sets the aisle order of the categories according to their position in the given list, and returns all categories
+func (service *CategoryService) ReorderCategories(ctx context.Context, IDs []string) ([]model.Category, error) {
+	if err := service.categoryDao.ReorderCategories(ctx, IDs); err != nil {
+		return nil, fmt.Errorf("failed to reorder categories: %w", err)
+	}
+	return service.categoryDao.GetCategories(ctx)
+}
+
+// DeleteCategory deletes a category, leaving its ingredients without category
+func (service *CategoryService) DeleteCategory(ctx context.Context, ID string) error {
+	return service.categoryDao.DeleteCategory(ctx, ID)
+}
+
+func checkCategory(category model.BaseCategory) error {
+	if strings.TrimSpace(category.Name) == "" {
+		return &failure.InvalidValueError{
+			Message: "category name is required",
+		}
+	}
+	return nil
+}
+
+// SortIngredientsByAisle sorts ingredients by the aisle order of their category, ingredients without category coming last
+func SortIngredientsByAisle(ingredients []model.Ingredient) {
+	sortByAisle(ingredients, func(ingredient model.Ingredient) *model.Category {
+		return ingredient.Category
+	})
+}
+
+// SortRecipeIngredientsByAisle sorts recipe ingredients by the aisle order of their category, ingredients without category and sub-recipes coming last
+func SortRecipeIngredientsByAisle(ingredients []model.RecipeIngredient) {
+	sortByAisle(ingredients, func(ingredient model.RecipeIngredient) *model.Category {
+		return ingredient.Category
+	})
+}
+
+// sortByAisle sorts items by the aisle order of their category, keeping the original order among items of the same aisle
+func sortByAisle[T any](items []T, category func(T) *model.Category) {
+	sort.SliceStable(items, func(i, j int) bool {
+		first, second := category(items[i]), category(items[j])
+		switch {
+		case first == nil:
+			return false
+		case second == nil:
+			return true
+		case first.AisleOrder != second.AisleOrder:
+			return first.AisleOrder < second.AisleOrder
+		default:
+			return first.ID < second.ID
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+)
+
+// EquipmentService is a kitchen equipment service
+type EquipmentService struct {
+	equipmentDao  *datasource.EquipmentDao
+	recipeService *RecipeService
+}
+
+// NewEquipmentService creates a new kitchen equipment service
+func NewEquipmentService(equipmentDao *datasource.EquipmentDao, recipeService *RecipeService) *EquipmentService {
+	return &EquipmentService{
+		equipmentDao,
+		recipeService,
+	}
+}
+
+// GetAllEquipment returns all the equipment, sorted by name
+func (service *EquipmentService) GetAllEquipment(ctx context.Context) ([]model.Equipment, error) {
+	return service.equipmentDao.GetAllEquipment(ctx)
+}
+
+// AddEquipment adds a piece of equipment
+func (service *EquipmentService) AddEquipment(ctx context.Context, equipment model.BaseEquipment) (string, error) {
+	if err := checkEquipment(equipment); err != nil {
+		return "", err
+	}
+	return service.equipmentDao.AddEquipment(ctx, equipment)
+}
+
+// UpdateEquipment updates a piece of equipment
+func (service *EquipmentService) UpdateEquipment(ctx context.Context, ID string, update model.BaseEquipment) (*model.Equipment, error) {
+	if err := checkEquipment(update); err != nil {
+		return nil, err
+	}
+	equipment := model.Equipment{
+		ID:            ID,
+		BaseEquipment: update,
+	}
+	if err := service.equipmentDao.UpdateEquipment(ctx, equipment); err != nil {
+		return nil, fmt.Errorf("failed to update equipment: %w", err)
+	}
+	return &equipment, nil
+}
+
+// SetOwnedEquipment sets the equipment with the given IDs as the only owned one, and returns all the equipment
+func (service *EquipmentService) SetOwnedEquipment(ctx context.Context, IDs []string) ([]model.Equipment, error) {
+	if err := service.equipmentDao.SetOwnedEquipment(ctx, IDs); err != nil {
+		return nil, fmt.Errorf("failed to set owned equipment: %w", err)
+	}
+	return service.equipmentDao.GetAllEquipment(ctx)
+}
+
+// DeleteEquipment deletes a piece of equipment, which is no longer required by any recipe
+func (service *EquipmentService) DeleteEquipment(ctx context.Context, ID string) error {
+	recipeIDs, err := service.equipmentDao.ListRecipeIdsUsingEquipment(ctx, ID)
+	if err != nil {
+		return fmt.Errorf("failed to list recipes requiring equipment: %w", err)
+	}
+	if err := service.equipmentDao.DeleteEquipment(ctx, ID); err != nil {
+		return fmt.Errorf("failed to delete equipment: %w", err)
+	}
+	if err := service.recipeService.ReindexRecipes(ctx, recipeIDs); err != nil {
+		return fmt.Errorf("failed to index recipes that required deleted equipment: %w", err)
+	}
+	return nil
+}
+
+func checkEquipment(equipment model.BaseEquipment) error {
+	if strings.TrimSpace(equipment.Name) == "" {
+		return &failure.InvalidValueError{
+			Message: "equipment name is required",
+		}
+	}
+	return nil
+}
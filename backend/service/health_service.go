@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/model"
+)
+
+// develVersion is the version of an application built from a working tree rather than installed from a tagged module
+const develVersion = "(devel)"
+
+// HealthService tells whether the application is alive and ready to serve requests, and how it was built
+type HealthService struct {
+	databaseHolder *datasource.DatabaseHolder
+	recipeService  *RecipeService
+	backupService  *BackupService
+	version        model.Version
+}
+
+// NewHealthService creates a new health service
+func NewHealthService(databaseHolder *datasource.DatabaseHolder, recipeService *RecipeService, backupService *BackupService) *HealthService {
+	return &HealthService{
+		databaseHolder: databaseHolder,
+		recipeService:  recipeService,
+		backupService:  backupService,
+		version:        readVersion(),
+	}
+}
+
+// GetHealth tells that the application is alive, along with the time of the last backup of the database
+func (service *HealthService) GetHealth() model.Health {
+	return model.Health{
+		Status:       "ok",
+		LastBackupAt: service.backupService.LastBackupAt(),
+	}
+}
+
+// GetReadiness checks that the database can be reached and that the recipes were indexed, so that searches find all of them
+func (service *HealthService) GetReadiness(ctx context.Context) model.Readiness {
+	database := model.ReadinessCheck{
+		Name:  "database",
+		Ready: true,
+	}
+	if err := service.databaseHolder.Ping(ctx); err != nil {
+		database.Ready = false
+		database.Message = err.Error()
+	}
+	index := model.ReadinessCheck{
+		Name:  "index",
+		Ready: service.recipeService.IsIndexed(),
+	}
+	if !index.Ready {
+		index.Message = "recipes are being indexed"
+	}
+
+	readiness := model.Readiness{
+		Status: model.ReadinessReady,
+		Checks: []model.ReadinessCheck{database, index},
+	}
+	if !database.Ready || !index.Ready {
+		readiness.Status = model.ReadinessNotReady
+	}
+	return readiness
+}
+
+// GetVersion returns the version of the application, along with the commit and the version of Go it was built with
+func (service *HealthService) GetVersion() model.Version {
+	return service.version
+}
+
+// readVersion reads the version of the application from the information embedded by the Go toolchain at build time
+func readVersion() model.Version {
+	version := model.Version{
+		Version:   develVersion,
+		GoVersion: runtime.Version(),
+	}
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version
+	}
+	if buildInfo.Main.Version != "" {
+		version.Version = buildInfo.Main.Version
+	}
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			version.Commit = setting.Value
+		case "vcs.time":
+			if commitTime, err := time.Parse(time.RFC3339, setting.Value); err == nil {
+				version.CommitTime = &commitTime
+			}
+		case "vcs.modified":
+			version.Modified = setting.Value == "true"
+		}
+	}
+	return version
+}
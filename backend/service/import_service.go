@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/remieven/miam/cooklang"
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/quantity"
+	"github.com/remieven/miam/schemaorg"
+	"github.com/remieven/miam/similarity"
+)
+
+// ImportService is a service importing recipes written in other formats
+type ImportService struct {
+	recipeService      *RecipeService
+	ingredientDao      *datasource.IngredientDao
+	ingredientAliasDao *datasource.IngredientAliasDao
+	equipmentDao       *datasource.EquipmentDao
+}
+
+// NewImportService creates a new import service
+func NewImportService(recipeService *RecipeService, ingredientDao *datasource.IngredientDao, ingredientAliasDao *datasource.IngredientAliasDao, equipmentDao *datasource.EquipmentDao) *ImportService {
+	return &ImportService{
+		recipeService,
+		ingredientDao,
+		ingredientAliasDao,
+		equipmentDao,
+	}
+}
+
+// ImportSchemaOrgRecipe converts the schema.org recipe of an HTML page or of a JSON-LD document into a recipe;
+// when save is true the recipe is added and its ID is returned, otherwise it is only returned as a draft to review
+func (service *ImportService) ImportSchemaOrgRecipe(ctx context.Context, data []byte, save bool) (*model.BaseRecipe, string, error) {
+	parsed, err := schemaorg.Parse(data)
+	if err != nil {
+		return nil, "", &failure.InvalidValueError{
+			Message: "failed to read a schema.org recipe",
+			Cause:   err,
+		}
+	}
+	if parsed.Name == "" {
+		return nil, "", &failure.InvalidValueError{
+			Message: "the imported recipe has no name",
+			Cause:   schemaorg.ErrNoRecipe,
+		}
+	}
+
+	matcher, err := service.newIngredientMatcher(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	recipe := model.BaseRecipe{
+		Name:         parsed.Name,
+		HowTo:        strings.Join(parsed.Instructions, "\n"),
+		Ingredients:  matcher.matchLines(parsed.Ingredients),
+		Yield:        parsed.Yield,
+		PrepMinutes:  toMinutes(parsed.PrepTime),
+		CookMinutes:  toMinutes(parsed.CookTime),
+		TotalMinutes: toMinutes(parsed.TotalTime),
+	}
+	return service.saveImportedRecipe(ctx, recipe, save)
+}
+
+// ImportCooklangRecipe converts a Cooklang recipe into a recipe, named after the given name when it has no title metadata;
+// when save is true the recipe is added along with its missing equipment and its ID is returned, otherwise it is only returned as a draft to review
+func (service *ImportService) ImportCooklangRecipe(ctx context.Context, data []byte, name string, save bool) (*model.BaseRecipe, string, error) {
+	parsed, err := cooklang.Parse(string(data))
+	if err != nil {
+		return nil, "", &failure.InvalidValueError{
+			Message: "failed to read a Cooklang recipe",
+			Cause:   err,
+		}
+	}
+	if parsed.Name == "" {
+		parsed.Name = name
+	}
+	if parsed.Name == "" {
+		return nil, "", &failure.InvalidValueError{
+			Message: "the imported recipe has no name",
+		}
+	}
+
+	matcher, err := service.newIngredientMatcher(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	ingredients := make([]splitIngredient, len(parsed.Ingredients))
+	for i, ingredient := range parsed.Ingredients {
+		ingredients[i] = splitIngredient{
+			quantity: strings.TrimSpace(ingredient.Quantity + " " + ingredient.Unit),
+			name:     ingredient.Name,
+		}
+	}
+	equipment, err := service.matchEquipment(ctx, parsed.Cookware)
+	if err != nil {
+		return nil, "", err
+	}
+	recipe := model.BaseRecipe{
+		Name:         parsed.Name,
+		HowTo:        strings.Join(parsed.Steps, "\n"),
+		Ingredients:  matcher.matchIngredients(ingredients),
+		Yield:        parsed.Yield,
+		PrepMinutes:  toMinutes(parsed.PrepTime),
+		CookMinutes:  toMinutes(parsed.CookTime),
+		TotalMinutes: toMinutes(parsed.TotalTime),
+		Equipment:    equipment,
+	}
+	if recipe.CookMinutes == 0 {
+		// without cook time metadata, the timers of the steps give the cook time
+		var timers time.Duration
+		for _, timer := range parsed.Timers {
+			if duration, ok := timer.Duration(); ok {
+				timers += duration
+			}
+		}
+		recipe.CookMinutes = toMinutes(timers)
+	}
+	return service.saveImportedRecipe(ctx, recipe, save)
+}
+
+// ImportRecipeFile reads the recipes of a file exported by another application in the given format, such as paprika or mealmaster.
+// Their ingredients are matched with the existing ones, a new ingredient used by several recipes being created only once.
+// When save is true the recipes are added in a single transaction, all of them or none, and their IDs are returned; otherwise they are only returned as drafts to review;
+// the parts of the file that cannot be read are reported along with the recipes.
+func (service *ImportService) ImportRecipeFile(ctx context.Context, format string, data []byte, save bool) (*model.ImportedRecipes, error) {
+	importer, found := recipeImporters[format]
+	if !found {
+		return nil, &failure.InvalidValueError{
+			Message: "unknown import format [" + format + "]",
+		}
+	}
+	recipes, importErrors, err := importer.readRecipes(data)
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: "failed to read " + format + " file",
+			Cause:   err,
+		}
+	}
+
+	matcher, err := service.newIngredientMatcher(ctx)
+	if err != nil {
+		return nil, err
+	}
+	imported := &model.ImportedRecipes{
+		Recipes: make([]model.BaseRecipe, len(recipes)),
+		Errors:  importErrors,
+	}
+	for i, recipe := range recipes {
+		imported.Recipes[i] = model.BaseRecipe{
+			Name:         recipe.name,
+			HowTo:        strings.Join(recipe.steps, "\n"),
+			Ingredients:  matcher.matchIngredients(recipe.ingredients),
+			Yield:        recipe.yield,
+			PrepMinutes:  toMinutes(recipe.prepTime),
+			CookMinutes:  toMinutes(recipe.cookTime),
+			TotalMinutes: toMinutes(recipe.totalTime),
+		}
+	}
+	if !save {
+		return imported, nil
+	}
+	for _, recipe := range imported.Recipes {
+		if err := service.addMissingEquipment(ctx, recipe.Equipment); err != nil {
+			return nil, err
+		}
+	}
+	if imported.IDs, err = service.recipeService.AddRecipes(ctx, imported.Recipes); err != nil {
+		return nil, fmt.Errorf("failed to save imported recipes: %w", err)
+	}
+	return imported, nil
+}
+
+// matchEquipment maps equipment names onto the existing equipment; the other ones have no ID and are added when the recipe is saved
+func (service *ImportService) matchEquipment(ctx context.Context, names []string) ([]model.Equipment, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	existing, err := service.equipmentDao.GetAllEquipment(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get equipment: %w", err)
+	}
+	equipmentByName := make(map[string]model.Equipment, len(existing))
+	for _, equipment := range existing {
+		equipmentByName[similarity.NormalizeName(equipment.Name)] = equipment
+	}
+	matched := make([]model.Equipment, 0, len(names))
+	for _, name := range names {
+		equipment, found := equipmentByName[similarity.NormalizeName(name)]
+		if !found {
+			equipment = model.Equipment{
+				BaseEquipment: model.BaseEquipment{
+					Name: name,
+				},
+			}
+			equipmentByName[similarity.NormalizeName(name)] = equipment
+		} else if containsEquipment(matched, equipment.ID) {
+			continue
+		}
+		matched = append(matched, equipment)
+	}
+	return matched, nil
+}
+
+func containsEquipment(equipment []model.Equipment, ID string) bool {
+	for _, candidate := range equipment {
+		if candidate.ID == ID {
+			return true
+		}
+	}
+	return false
+}
+
+// saveImportedRecipe adds an imported recipe, along with its equipment that does not exist yet, if asked to
+func (service *ImportService) saveImportedRecipe(ctx context.Context, recipe model.BaseRecipe, save bool) (*model.BaseRecipe, string, error) {
+	if !save {
+		return &recipe, "", nil
+	}
+	if err := service.addMissingEquipment(ctx, recipe.Equipment); err != nil {
+		return nil, "", err
+	}
+	id, err := service.recipeService.AddRecipe(ctx, recipe)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to add imported recipe: %w", err)
+	}
+	return &recipe, id, nil
+}
+
+// addMissingEquipment adds the equipment matched by matchEquipment that does not exist yet, setting its ID
+func (service *ImportService) addMissingEquipment(ctx context.Context, equipment []model.Equipment) error {
+	for i := range equipment {
+		if equipment[i].ID != "" {
+			continue
+		}
+		id, err := service.equipmentDao.AddEquipment(ctx, equipment[i].BaseEquipment)
+		if err != nil {
+			return fmt.Errorf("failed to add equipment of imported recipe: %w", err)
+		}
+		equipment[i].ID = id
+	}
+	return nil
+}
+
+// toMinutes rounds a duration to the closest minute
+func toMinutes(duration time.Duration) int {
+	return int(duration.Round(time.Minute) / time.Minute)
+}
+
+// ingredientMatcher maps ingredient names onto the existing ingredients, using their names and aliases
+type ingredientMatcher struct {
+	ingredientsByName map[string]model.Ingredient
+}
+
+// newIngredientMatcher loads the existing ingredients along with their aliases
+func (service *ImportService) newIngredientMatcher(ctx context.Context) (*ingredientMatcher, error) {
+	ingredients, err := service.ingredientDao.GetAllIngredients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingredients: %w", err)
+	}
+	aliases, err := service.ingredientAliasDao.GetAllAliases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingredient aliases: %w", err)
+	}
+	matcher := &ingredientMatcher{
+		ingredientsByName: make(map[string]model.Ingredient, len(ingredients)),
+	}
+	for _, ingredient := range ingredients {
+		for _, name := range append([]string{ingredient.Name}, aliases[ingredient.ID]...) {
+			normalized := similarity.NormalizeName(name)
+			if _, exists := matcher.ingredientsByName[normalized]; !exists {
+				matcher.ingredientsByName[normalized] = model.Ingredient{
+					ID: ingredient.ID,
+					BaseIngredient: model.BaseIngredient{
+						Name: ingredient.Name,
+					},
+				}
+			}
+		}
+	}
+	return matcher, nil
+}
+
+// match returns the existing ingredient with the given name, or a new ingredient without ID that will be created along with the recipe;
+// new ingredients are remembered, so that the same new ingredient is always written the same way
+func (matcher *ingredientMatcher) match(name string) model.Ingredient {
+	normalized := similarity.NormalizeName(name)
+	if ingredient, found := matcher.ingredientsByName[normalized]; found {
+		return ingredient
+	}
+	ingredient := model.Ingredient{
+		BaseIngredient: model.BaseIngredient{
+			Name: name,
+		},
+	}
+	matcher.ingredientsByName[normalized] = ingredient
+	return ingredient
+}
+
+// splitIngredient is an imported ingredient, with its quantity apart from its name
+type splitIngredient struct {
+	quantity string
+	name     string
+}
+
+// matchLines turns ingredient lines such as "250 g de farine" into recipe ingredients
+func (matcher *ingredientMatcher) matchLines(lines []string) []model.RecipeIngredient {
+	ingredients := make([]splitIngredient, len(lines))
+	for i, line := range lines {
+		ingredients[i].quantity, ingredients[i].name = quantity.SplitIngredient(line)
+	}
+	return matcher.matchIngredients(ingredients)
+}
+
+// matchIngredients turns imported ingredients into recipe ingredients;
+// an ingredient appearing several times is kept once, with the quantities joined
+func (matcher *ingredientMatcher) matchIngredients(ingredients []splitIngredient) []model.RecipeIngredient {
+	var recipeIngredients []model.RecipeIngredient
+	positions := make(map[string]int, len(ingredients))
+	for _, imported := range ingredients {
+		if imported.name == "" {
+			continue
+		}
+		ingredient := matcher.match(imported.name)
+		key := similarity.NormalizeName(ingredient.Name)
+		if position, found := positions[key]; found {
+			recipeIngredients[position].Quantity = joinQuantities(recipeIngredients[position].Quantity, imported.quantity)
+			continue
+		}
+		positions[key] = len(recipeIngredients)
+		recipeIngredients = append(recipeIngredients, model.RecipeIngredient{
+			Quantity:   imported.quantity,
+			Ingredient: ingredient,
+		})
+	}
+	return recipeIngredients
+}
+
+// joinQuantities joins the quantities of an ingredient appearing several times, ignoring the empty ones
+func joinQuantities(first, second string) string {
+	if first == "" || second == "" {
+		return first + second
+	}
+	return first + " + " + second
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+)
+
+// ingredientCSVColumns are the columns of the CSV files of ingredients; categories are written by name
+var ingredientCSVColumns = []string{"id", "name", "category", "aliases"}
+
+// aliasSeparator separates the aliases of an ingredient in a CSV cell
+const aliasSeparator = "|"
+
+// WriteIngredientsCSV writes ingredients as CSV, with a header row and the aliases of each ingredient separated by |
+func WriteIngredientsCSV(writer io.Writer, ingredients []model.Ingredient) error {
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write(ingredientCSVColumns); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for _, ingredient := range ingredients {
+		var category string
+		if ingredient.Category != nil {
+			category = ingredient.Category.Name
+		}
+		if err := csvWriter.Write([]string{ingredient.ID, ingredient.Name, category, strings.Join(ingredient.Aliases, aliasSeparator)}); err != nil {
+			return fmt.Errorf("failed to write ingredient [%s] as CSV: %w", ingredient.ID, err)
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// ImportIngredientsCSV creates or updates the ingredients of a CSV file in a single transaction, see WriteIngredientsCSV for its columns.
+// Rows with an ID update that ingredient; the other ones update the ingredient with the same name or alias, or create a new one.
+// Each row replaces the category and the aliases of its ingredient; the rows that cannot be imported are reported without stopping the import.
+func (service *IngredientService) ImportIngredientsCSV(ctx context.Context, reader io.Reader) (*model.IngredientImportReport, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, &failure.InvalidValueError{
+			Message: "failed to read CSV header",
+			Cause:   err,
+		}
+	}
+	positions := make(map[string]int, len(header))
+	for i, column := range header {
+		positions[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))] = i
+	}
+	for _, column := range ingredientCSVColumns {
+		if _, found := positions[column]; !found {
+			return nil, &failure.InvalidValueError{
+				Message: "missing CSV column [" + column + "], expected columns are " + strings.Join(ingredientCSVColumns, ", "),
+			}
+		}
+	}
+
+	categories, err := service.categoryDao.GetCategories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get categories: %w", err)
+	}
+	categoryIDs := make(map[string]string, len(categories))
+	for _, category := range categories {
+		categoryIDs[strings.ToLower(strings.TrimSpace(category.Name))] = category.ID
+	}
+
+	var (
+		upserts []model.IngredientUpsert
+		invalid []model.IngredientImportRow
+	)
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, &failure.InvalidValueError{
+				Message: "failed to read CSV",
+				Cause:   err,
+			}
+		}
+		line, _ := csvReader.FieldPos(0)
+		upsert, err := parseIngredientRecord(record, positions, categoryIDs)
+		if err != nil {
+			invalid = append(invalid, model.IngredientImportRow{
+				Line:   line,
+				ID:     upsert.ID,
+				Name:   upsert.Name,
+				Status: model.IngredientImportError,
+				Error:  err.Error(),
+			})
+			continue
+		}
+		upsert.Line = line
+		upserts = append(upserts, upsert)
+	}
+
+	rows, err := service.ingredientDao.UpsertIngredients(ctx, upserts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import ingredients: %w", err)
+	}
+	report := &model.IngredientImportReport{
+		Rows: mergeImportRows(rows, invalid),
+	}
+	var updatedIDs []string
+	for _, row := range report.Rows {
+		switch row.Status {
+		case model.IngredientImportCreated:
+			report.Created++
+		case model.IngredientImportUpdated:
+			report.Updated++
+			updatedIDs = append(updatedIDs, row.ID)
+		case model.IngredientImportUnchanged:
+			report.Unchanged++
+		default:
+			report.Errors++
+		}
+	}
+
+	for _, ID := range updatedIDs {
+		recipeIDs, err := service.recipeIngredientDao.ListRecipeIdsUsingIngredient(ctx, ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recipes using ingredient: %w", err)
+		}
+		if err := service.recipeService.ReindexRecipes(ctx, recipeIDs); err != nil {
+			return nil, fmt.Errorf("failed to index recipes using updated ingredient: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// parseIngredientRecord reads a CSV row of ingredient; the ID and name are returned even when the row is invalid, so that it can be reported
+func parseIngredientRecord(record []string, positions map[string]int, categoryIDs map[string]string) (model.IngredientUpsert, error) {
+	cell := func(column string) string {
+		if position := positions[column]; position < len(record) {
+			return strings.TrimSpace(record[position])
+		}
+		return ""
+	}
+	upsert := model.IngredientUpsert{
+		ID:   cell("id"),
+		Name: cell("name"),
+	}
+	if upsert.Name == "" {
+		return upsert, errors.New("name cannot be empty")
+	}
+	if category := cell("category"); category != "" {
+		categoryID, found := categoryIDs[strings.ToLower(category)]
+		if !found {
+			return upsert, errors.New("category [" + category + "] not found")
+		}
+		upsert.CategoryID = categoryID
+	}
+	if aliases := cell("aliases"); aliases != "" {
+		upsert.Aliases = strings.Split(aliases, aliasSeparator)
+	}
+	return upsert, nil
+}
+
+// mergeImportRows merges the reports of the imported and the invalid rows, in the order of their lines
+func mergeImportRows(imported, invalid []model.IngredientImportRow) []model.IngredientImportRow {
+	merged := make([]model.IngredientImportRow, 0, len(imported)+len(invalid))
+	for len(imported) != 0 || len(invalid) != 0 {
+		if len(invalid) == 0 || (len(imported) != 0 && imported[0].Line < invalid[0].Line) {
+			merged, imported = append(merged, imported[0]), imported[1:]
+		} else {
+			merged, invalid = append(merged, invalid[0]), invalid[1:]
+		}
+	}
+	return merged
+}
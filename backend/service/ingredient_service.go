@@ -2,34 +2,185 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/remieven/miam/datasource"
 	"github.com/remieven/miam/model"
 	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/similarity"
 )
 
 // IngredientService struct
 type IngredientService struct {
 	ingredientDao       *datasource.IngredientDao
 	recipeIngredientDao *datasource.RecipeIngredientDao
+	ingredientAliasDao  *datasource.IngredientAliasDao
+	categoryDao         *datasource.CategoryDao
+	recipeService       *RecipeService
 }
 
 // NewIngredientService creates a new ingredient service
-func NewIngredientService(ingredientDao *datasource.IngredientDao, recipeIngredientDao *datasource.RecipeIngredientDao) *IngredientService {
+func NewIngredientService(ingredientDao *datasource.IngredientDao, recipeIngredientDao *datasource.RecipeIngredientDao, ingredientAliasDao *datasource.IngredientAliasDao, categoryDao *datasource.CategoryDao, recipeService *RecipeService) *IngredientService {
 	return &IngredientService{
 		ingredientDao,
 		recipeIngredientDao,
+		ingredientAliasDao,
+		categoryDao,
+		recipeService,
 	}
 }
 
-// GetAllIngredients returns all known ingredients
+// GetAllIngredients returns all known ingredients along with their aliases
 func (service *IngredientService) GetAllIngredients(ctx context.Context) ([]model.Ingredient, error) {
-	return service.ingredientDao.GetAllIngredients(ctx)
+	ingredients, err := service.ingredientDao.GetAllIngredients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := service.ingredientAliasDao.GetAllAliases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingredient aliases: %w", err)
+	}
+	for i := range ingredients {
+		ingredients[i].Aliases = aliases[ingredients[i].ID]
+	}
+	return ingredients, nil
+}
+
+// getIngredientWithAliases returns an ingredient along with its aliases
+func (service *IngredientService) getIngredientWithAliases(ctx context.Context, ID string) (*model.Ingredient, error) {
+	ingredient, err := service.ingredientDao.GetIngredient(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := service.ingredientAliasDao.GetAllAliases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingredient aliases: %w", err)
+	}
+	ingredient.Aliases = aliases[ID]
+	return ingredient, nil
+}
+
+// MergeIngredient replaces an ingredient by another one in all recipes, and keeps the name of the former as an alias of the latter
+func (service *IngredientService) MergeIngredient(ctx context.Context, ID, targetID string) (*model.Ingredient, error) {
+	if ID == targetID {
+		return nil, &failure.InvalidValueError{
+			Message: "cannot merge ingredient [" + ID + "] into itself",
+		}
+	}
+	recipeIDs, err := service.recipeIngredientDao.MergeIngredients(ctx, []string{ID}, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge ingredients: %w", err)
+	}
+	if err := service.recipeService.ReindexRecipes(ctx, recipeIDs); err != nil {
+		return nil, fmt.Errorf("failed to index recipes using merged ingredient: %w", err)
+	}
+	return service.getIngredientWithAliases(ctx, targetID)
+}
+
+// GetDuplicateIngredients returns the groups of ingredients whose names are so close they likely are the same ingredient
+func (service *IngredientService) GetDuplicateIngredients(ctx context.Context) ([]model.IngredientDuplicates, error) {
+	ingredients, err := service.GetAllIngredients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	usageCounts, err := service.recipeIngredientDao.GetIngredientUsageCounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingredient usage counts: %w", err)
+	}
+
+	names := make([]string, len(ingredients))
+	for i, ingredient := range ingredients {
+		names[i] = ingredient.Name
+	}
+	duplicates := make([]model.IngredientDuplicates, 0)
+	for _, cluster := range similarity.Cluster(names) {
+		usages := make([]model.IngredientUsage, 0, len(cluster))
+		for _, index := range cluster {
+			usages = append(usages, model.IngredientUsage{
+				Ingredient: ingredients[index],
+				UsageCount: usageCounts[ingredients[index].ID],
+			})
+		}
+		sort.SliceStable(usages, func(i, j int) bool {
+			return usages[i].UsageCount > usages[j].UsageCount
+		})
+		duplicates = append(duplicates, model.IngredientDuplicates{
+			TargetID:    usages[0].ID,
+			Ingredients: usages,
+		})
+	}
+	return duplicates, nil
+}
+
+// MergeDuplicateIngredients merges several ingredients into another one, typically the ones of a group returned by GetDuplicateIngredients
+func (service *IngredientService) MergeDuplicateIngredients(ctx context.Context, merge model.IngredientDuplicatesMerge) (*model.Ingredient, error) {
+	if merge.TargetID == "" {
+		return nil, &failure.InvalidValueError{
+			Message: "the ingredient to merge into is required",
+		}
+	}
+	sourceIDs := make([]string, 0, len(merge.IngredientIDs))
+	seen := map[string]bool{merge.TargetID: true}
+	for _, ID := range merge.IngredientIDs {
+		if !seen[ID] {
+			seen[ID] = true
+			sourceIDs = append(sourceIDs, ID)
+		}
+	}
+	recipeIDs, err := service.recipeIngredientDao.MergeIngredients(ctx, sourceIDs, merge.TargetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge ingredients: %w", err)
+	}
+	if err := service.recipeService.ReindexRecipes(ctx, recipeIDs); err != nil {
+		return nil, fmt.Errorf("failed to index recipes using merged ingredients: %w", err)
+	}
+	return service.getIngredientWithAliases(ctx, merge.TargetID)
+}
+
+// AddAlias adds an alternative name to an ingredient
+func (service *IngredientService) AddAlias(ctx context.Context, ID string, alias model.IngredientAlias) (*model.Ingredient, error) {
+	if _, err := service.ingredientDao.GetIngredient(ctx, ID); err != nil {
+		return nil, fmt.Errorf("failed to get ingredient: %w", err)
+	}
+	if err := service.ingredientAliasDao.AddAliasToIngredient(ctx, ID, alias.Name); err != nil {
+		return nil, fmt.Errorf("failed to add alias: %w", err)
+	}
+	return service.getIngredientWithAliases(ctx, ID)
+}
+
+// DeleteAlias removes an alternative name from an ingredient
+func (service *IngredientService) DeleteAlias(ctx context.Context, ID, alias string) error {
+	return service.ingredientAliasDao.DeleteAlias(ctx, ID, alias)
 }
 
 // UpdateIngredient updates an ingredient
 func (service *IngredientService) UpdateIngredient(ctx context.Context, ID string, update model.BaseIngredient) (*model.Ingredient, error) {
+	if update.CategoryID != "" {
+		if _, err := service.categoryDao.GetCategory(ctx, update.CategoryID); errors.Is(err, &failure.ResourceNotFoundError{}) {
+			return nil, &failure.InvalidValueError{
+				Message: "category [" + update.CategoryID + "] not found",
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get category: %w", err)
+		}
+	}
+	for _, allergen := range update.Allergens {
+		if !model.IsAllergen(allergen) {
+			return nil, &failure.InvalidValueError{
+				Message: "unknown allergen [" + allergen + "]",
+			}
+		}
+	}
+	update.Allergens = model.SortAllergens(update.Allergens)
+	// Listing allergens is reviewing them
+	update.AllergensKnown = update.AllergensKnown || len(update.Allergens) != 0
+	if update.Season != nil && !update.Season.IsValid() {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("season months must be between 1 and 12, got [%d] and [%d]", update.Season.From, update.Season.To),
+		}
+	}
 	ingredient := model.Ingredient{
 		ID:             ID,
 		BaseIngredient: update,
@@ -37,7 +188,14 @@ func (service *IngredientService) UpdateIngredient(ctx context.Context, ID strin
 	if err := service.ingredientDao.UpdateIngredient(ctx, ingredient); err != nil {
 		return nil, fmt.Errorf("failed to update ingredient: %w", err)
 	}
-	return &ingredient, nil
+	recipeIDs, err := service.recipeIngredientDao.ListRecipeIdsUsingIngredient(ctx, ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipes using ingredient: %w", err)
+	}
+	if err := service.recipeService.ReindexRecipes(ctx, recipeIDs); err != nil {
+		return nil, fmt.Errorf("failed to index recipes using updated ingredient: %w", err)
+	}
+	return service.getIngredientWithAliases(ctx, ID)
 }
 
 // DeleteIngredient deletes the ingredient with the given id
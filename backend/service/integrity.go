@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+)
+
+// CheckIntegrity looks for inconsistencies in the database and between the database and the search index.
+// When repair is true, the database is repaired in a single transaction, then the search index is updated, and the problems left are reported.
+func (service *AdminService) CheckIntegrity(ctx context.Context, repair bool) (*model.IntegrityReport, error) {
+	problems, err := service.findProblems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &model.IntegrityReport{
+		Problems: problems,
+	}
+	if !repair {
+		return report, nil
+	}
+
+	if err := service.integrityDao.Repair(ctx); err != nil {
+		return nil, fmt.Errorf("failed to repair database: %w", err)
+	}
+	// the repair of the database changes recipes, so the index is checked again before being repaired
+	indexProblems, err := service.recipeService.checkIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.recipeService.repairIndex(ctx, indexProblems); err != nil {
+		return nil, err
+	}
+	report.Repaired = true
+	if report.Remaining, err = service.findProblems(ctx); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (service *AdminService) findProblems(ctx context.Context) ([]model.IntegrityProblem, error) {
+	problems, err := service.integrityDao.FindProblems(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check database: %w", err)
+	}
+	indexProblems, err := service.recipeService.checkIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(problems, indexProblems...), nil
+}
+
+// checkIndex compares the search index with the recipes that are not in the trash
+func (service *RecipeService) checkIndex(ctx context.Context) ([]model.IntegrityProblem, error) {
+	IDs, err := service.recipeDao.ListRecipeIds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipes: %w", err)
+	}
+	indexedIDs, err := service.searchDao.ListIndexedRecipeIDs()
+	if err != nil {
+		return nil, err
+	}
+	indexed := make(map[string]bool, len(indexedIDs))
+	for _, ID := range indexedIDs {
+		indexed[ID] = true
+	}
+
+	var problems []model.IntegrityProblem
+	stored := make(map[string]bool, len(IDs))
+	for _, ID := range IDs {
+		stored[ID] = true
+		if !indexed[ID] {
+			problems = append(problems, model.IntegrityProblem{
+				Kind:    model.IntegrityMissingIndexEntry,
+				Table:   "recipe",
+				ID:      ID,
+				Message: "recipe is not indexed",
+			})
+			continue
+		}
+		recipe, err := service.recipeDao.GetRecipe(ctx, ID)
+		if errors.Is(err, &failure.ResourceNotFoundError{}) {
+			// the recipe was moved to the trash in the meantime
+			continue
+		} else if err != nil {
+			// such as a recipe whose name is null, which is repaired along with the empty names
+			problems = append(problems, model.IntegrityProblem{
+				Kind:    model.IntegrityStaleIndexEntry,
+				Table:   "recipe",
+				ID:      ID,
+				Message: "recipe cannot be read to check its index entry: " + err.Error(),
+			})
+			continue
+		}
+		expected, inSeasonMonths, err := service.toIndexedRecipe(ctx, *recipe)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check index of recipe [%s]: %w", ID, err)
+		}
+		upToDate, err := service.searchDao.IsIndexedAs(expected, inSeasonMonths)
+		if err != nil {
+			return nil, err
+		}
+		if !upToDate {
+			problems = append(problems, model.IntegrityProblem{
+				Kind:    model.IntegrityStaleIndexEntry,
+				Table:   "recipe",
+				ID:      ID,
+				Message: "recipe is indexed differently from the database",
+			})
+		}
+	}
+	for _, ID := range indexedIDs {
+		if !stored[ID] {
+			problems = append(problems, model.IntegrityProblem{
+				Kind:    model.IntegrityStaleIndexEntry,
+				Table:   "recipe",
+				ID:      ID,
+				Message: "indexed recipe is in the trash or does not exist",
+			})
+		}
+	}
+	return problems, nil
+}
+
+// repairIndex indexes again the recipes with missing or stale index entries, and removes the entries of the recipes that are not in the database
+func (service *RecipeService) repairIndex(ctx context.Context, problems []model.IntegrityProblem) error {
+	for _, problem := range problems {
+		recipe, err := service.recipeDao.GetRecipe(ctx, problem.ID)
+		if errors.Is(err, &failure.ResourceNotFoundError{}) {
+			if err := service.searchDao.DeleteRecipe(problem.ID); err != nil {
+				return fmt.Errorf("failed to remove recipe [%s] from index: %w", problem.ID, err)
+			}
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to get recipe [%s]: %w", problem.ID, err)
+		}
+		if err := service.indexRecipe(ctx, *recipe); err != nil {
+			return fmt.Errorf("failed to index recipe [%s]: %w", problem.ID, err)
+		}
+	}
+	return nil
+}
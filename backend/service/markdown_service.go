@@ -0,0 +1,75 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/remieven/miam/markdown"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/similarity"
+)
+
+// markdownIndexFileName is the name of the index file of a Markdown cookbook archive
+const markdownIndexFileName = "index.md"
+
+// MarkdownService is a service writing recipes as Markdown, using configurable templates
+type MarkdownService struct {
+	recipeService *RecipeService
+	templates     *markdown.Templates
+}
+
+// NewMarkdownService creates a new Markdown service
+func NewMarkdownService(recipeService *RecipeService, templates *markdown.Templates) *MarkdownService {
+	return &MarkdownService{
+		recipeService,
+		templates,
+	}
+}
+
+// WriteRecipe writes a recipe as Markdown
+func (service *MarkdownService) WriteRecipe(writer io.Writer, recipe model.Recipe) error {
+	if err := service.templates.WriteRecipe(writer, toMarkdownRecipe(recipe)); err != nil {
+		return fmt.Errorf("failed to write recipe [%s] as markdown: %w", recipe.ID, err)
+	}
+	return nil
+}
+
+// WriteCookbookArchive writes all the recipes that are not in the trash as a zip archive holding one Markdown file per recipe,
+// along with an index file listing them by name
+func (service *MarkdownService) WriteCookbookArchive(ctx context.Context, writer io.Writer) error {
+	recipes, err := service.recipeService.GetAllRecipes(ctx)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(recipes, func(i, j int) bool {
+		return similarity.NormalizeName(recipes[i].Name) < similarity.NormalizeName(recipes[j].Name)
+	})
+
+	archive := zip.NewWriter(writer)
+	fileNames := markdown.NewFileNames(markdownIndexFileName)
+	index := make([]markdown.IndexEntry, len(recipes))
+	for i, recipe := range recipes {
+		index[i] = markdown.IndexEntry{
+			Name:     recipe.Name,
+			FileName: fileNames.For(recipe.Name),
+		}
+		file, err := archive.Create(index[i].FileName)
+		if err != nil {
+			return fmt.Errorf("failed to add [%s] to archive: %w", index[i].FileName, err)
+		}
+		if err := service.WriteRecipe(file, recipe); err != nil {
+			return err
+		}
+	}
+	file, err := archive.Create(markdownIndexFileName)
+	if err != nil {
+		return fmt.Errorf("failed to add index to archive: %w", err)
+	}
+	if err := service.templates.WriteIndex(file, index); err != nil {
+		return fmt.Errorf("failed to write markdown index: %w", err)
+	}
+	return archive.Close()
+}
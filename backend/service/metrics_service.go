@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/metrics"
+)
+
+// searchBuckets are the upper bounds, in seconds, of the histogram of search durations, searches in the in-memory index being much faster than requests
+var searchBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// MetricsService measures the HTTP requests and the searches, and writes them along with figures of the database and of the search index
+type MetricsService struct {
+	metricsDao *datasource.MetricsDao
+	searchDao  *datasource.RecipeSearchDao
+	registry   *metrics.Registry
+
+	httpRequests        *metrics.Counter
+	httpRequestDuration *metrics.Histogram
+	searchDuration      *metrics.Histogram
+	indexedRecipes      *metrics.Gauge
+	recipes             *metrics.Gauge
+	deletedRecipes      *metrics.Gauge
+	ingredients         *metrics.Gauge
+
+	dbMaxOpenConnections *metrics.Gauge
+	dbOpenConnections    *metrics.Gauge
+	dbInUseConnections   *metrics.Gauge
+	dbIdleConnections    *metrics.Gauge
+	dbWaits              *metrics.Counter
+	dbWaitDuration       *metrics.Counter
+	dbMaxIdleClosed      *metrics.Counter
+	dbMaxIdleTimeClosed  *metrics.Counter
+	dbMaxLifetimeClosed  *metrics.Counter
+}
+
+// NewMetricsService creates a new metrics service, which measures the duration of the searches of the given dao
+func NewMetricsService(metricsDao *datasource.MetricsDao, searchDao *datasource.RecipeSearchDao) *MetricsService {
+	registry := metrics.NewRegistry()
+	service := &MetricsService{
+		metricsDao: metricsDao,
+		searchDao:  searchDao,
+		registry:   registry,
+
+		httpRequests:        registry.NewCounter("miam_http_requests_total", "Number of HTTP requests by route template, method and status code.", "route", "method", "code"),
+		httpRequestDuration: registry.NewHistogram("miam_http_request_duration_seconds", "Duration of HTTP requests by route template and method.", metrics.DefaultBuckets, "route", "method"),
+		searchDuration:      registry.NewHistogram("miam_search_duration_seconds", "Duration of the searches in the recipe index.", searchBuckets),
+		indexedRecipes:      registry.NewGauge("miam_search_index_documents", "Number of recipes in the search index."),
+		recipes:             registry.NewGauge("miam_recipes", "Number of recipes, the ones in the trash excluded."),
+		deletedRecipes:      registry.NewGauge("miam_recipes_in_trash", "Number of recipes in the trash."),
+		ingredients:         registry.NewGauge("miam_ingredients", "Number of ingredients."),
+
+		dbMaxOpenConnections: registry.NewGauge("miam_db_max_open_connections", "Maximum number of open connections to the database, 0 meaning unlimited."),
+		dbOpenConnections:    registry.NewGauge("miam_db_open_connections", "Number of established connections to the database, in use or idle."),
+		dbInUseConnections:   registry.NewGauge("miam_db_in_use_connections", "Number of connections to the database currently in use."),
+		dbIdleConnections:    registry.NewGauge("miam_db_idle_connections", "Number of idle connections to the database."),
+		dbWaits:              registry.NewCounter("miam_db_waits_total", "Number of times a connection to the database was waited for."),
+		dbWaitDuration:       registry.NewCounter("miam_db_wait_duration_seconds_total", "Time spent waiting for connections to the database."),
+		dbMaxIdleClosed:      registry.NewCounter("miam_db_max_idle_closed_total", "Number of connections to the database closed because of the maximum number of idle connections."),
+		dbMaxIdleTimeClosed:  registry.NewCounter("miam_db_max_idle_time_closed_total", "Number of connections to the database closed because of the maximum idle time."),
+		dbMaxLifetimeClosed:  registry.NewCounter("miam_db_max_lifetime_closed_total", "Number of connections to the database closed because of the maximum lifetime."),
+	}
+	searchDao.ObserveSearches(func(duration time.Duration) {
+		service.searchDuration.Observe(duration.Seconds())
+	})
+	return service
+}
+
+// ObserveRequest records an HTTP request to the given route template, which is empty when the request matched no route
+func (service *MetricsService) ObserveRequest(route, method string, statusCode int, duration time.Duration) {
+	service.httpRequests.Inc(route, method, strconv.Itoa(statusCode))
+	service.httpRequestDuration.Observe(duration.Seconds(), route, method)
+}
+
+// WriteMetrics reads the current figures of the database and of the search index, then writes all the metrics in the Prometheus text format
+func (service *MetricsService) WriteMetrics(ctx context.Context, w io.Writer) error {
+	counts, err := service.metricsDao.CountRows(ctx)
+	if err != nil {
+		return err
+	}
+	indexedRecipes, err := service.searchDao.CountIndexedRecipes()
+	if err != nil {
+		return err
+	}
+	service.recipes.Set(float64(counts.Recipes))
+	service.deletedRecipes.Set(float64(counts.DeletedRecipes))
+	service.ingredients.Set(float64(counts.Ingredients))
+	service.indexedRecipes.Set(float64(indexedRecipes))
+
+	stats := service.metricsDao.Stats()
+	service.dbMaxOpenConnections.Set(float64(stats.MaxOpenConnections))
+	service.dbOpenConnections.Set(float64(stats.OpenConnections))
+	service.dbInUseConnections.Set(float64(stats.InUse))
+	service.dbIdleConnections.Set(float64(stats.Idle))
+	service.dbWaits.Set(float64(stats.WaitCount))
+	service.dbWaitDuration.Set(stats.WaitDuration.Seconds())
+	service.dbMaxIdleClosed.Set(float64(stats.MaxIdleClosed))
+	service.dbMaxIdleTimeClosed.Set(float64(stats.MaxIdleTimeClosed))
+	service.dbMaxLifetimeClosed.Set(float64(stats.MaxLifetimeClosed))
+
+	return service.registry.Write(w)
+}
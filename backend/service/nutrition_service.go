@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/remieven/miam/datasource"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/nutrition"
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/quantity"
+)
+
+// NutritionService estimates the nutrients of recipes from a nutrient table
+type NutritionService struct {
+	recipeService      *RecipeService
+	ingredientAliasDao *datasource.IngredientAliasDao
+	nutrientTable      *nutrition.Table
+}
+
+// NewNutritionService creates a new nutrition service
+func NewNutritionService(recipeService *RecipeService, ingredientAliasDao *datasource.IngredientAliasDao, nutrientTable *nutrition.Table) *NutritionService {
+	return &NutritionService{
+		recipeService,
+		ingredientAliasDao,
+		nutrientTable,
+	}
+}
+
+// GetRecipeNutrition estimates the nutrients of a recipe, in total and per serving.
+// Ingredients of sub-recipes are taken into account; ingredients that have no nutrient entry or whose quantity cannot be converted to grams are listed as unmatched.
+func (service *NutritionService) GetRecipeNutrition(ctx context.Context, ID string, servings int) (*model.RecipeNutrition, error) {
+	if servings <= 0 {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("number of servings must be positive, got [%d]", servings),
+		}
+	}
+	recipe, err := service.recipeService.GetExpandedRecipe(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := service.ingredientAliasDao.GetAllAliases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingredient aliases: %w", err)
+	}
+
+	var total model.Nutrients
+	unmatched := make([]model.UnmatchedIngredient, 0)
+	for _, ingredient := range recipe.Ingredients {
+		entry, ok := service.nutrientTable.Match(append([]string{ingredient.Name}, aliases[ingredient.ID]...)...)
+		if !ok {
+			unmatched = append(unmatched, model.UnmatchedIngredient{RecipeIngredient: ingredient, Reason: model.UnmatchedReasonNoNutrientEntry})
+			continue
+		}
+		parsed, ok := quantity.Parse(ingredient.Quantity)
+		if !ok {
+			unmatched = append(unmatched, model.UnmatchedIngredient{RecipeIngredient: ingredient, Reason: model.UnmatchedReasonUnknownQuantity})
+			continue
+		}
+		grams, ok := nutrition.Grams(parsed, entry)
+		if !ok {
+			unmatched = append(unmatched, model.UnmatchedIngredient{RecipeIngredient: ingredient, Reason: model.UnmatchedReasonUnknownQuantity})
+			continue
+		}
+		nutrients := entry.Of(grams)
+		total.Energy += nutrients.Energy
+		total.Protein += nutrients.Protein
+		total.Fat += nutrients.Fat
+		total.Carbohydrates += nutrients.Carbohydrates
+	}
+
+	perServing := model.Nutrients{
+		Energy:        total.Energy / float64(servings),
+		Protein:       total.Protein / float64(servings),
+		Fat:           total.Fat / float64(servings),
+		Carbohydrates: total.Carbohydrates / float64(servings),
+	}
+	return &model.RecipeNutrition{
+		RecipeID:   ID,
+		Servings:   servings,
+		Total:      nutrition.Round(total),
+		PerServing: nutrition.Round(perServing),
+		Unmatched:  unmatched,
+	}, nil
+}
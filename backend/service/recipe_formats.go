@@ -0,0 +1,87 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/remieven/miam/cooklang"
+	"github.com/remieven/miam/markdown"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/quantity"
+	"github.com/remieven/miam/schemaorg"
+)
+
+// ToSchemaOrgRecipe converts a recipe into a schema.org recipe, so that it can be read by other applications
+func ToSchemaOrgRecipe(recipe model.Recipe) schemaorg.Recipe {
+	converted := schemaorg.Recipe{
+		Name:         recipe.Name,
+		Instructions: howToSteps(recipe.HowTo),
+		Yield:        recipe.Yield,
+		PrepTime:     time.Duration(recipe.PrepMinutes) * time.Minute,
+		CookTime:     time.Duration(recipe.CookMinutes) * time.Minute,
+		TotalTime:    time.Duration(recipe.TotalMinutes) * time.Minute,
+	}
+	for _, recipeIngredient := range recipe.Ingredients {
+		converted.Ingredients = append(converted.Ingredients, ingredientLine(recipeIngredient))
+	}
+	return converted
+}
+
+// ToCooklangRecipe converts a recipe into a Cooklang recipe; sub-recipes are written as ingredients
+func ToCooklangRecipe(recipe model.Recipe) cooklang.Recipe {
+	converted := cooklang.Recipe{
+		Name:      recipe.Name,
+		Yield:     recipe.Yield,
+		PrepTime:  time.Duration(recipe.PrepMinutes) * time.Minute,
+		CookTime:  time.Duration(recipe.CookMinutes) * time.Minute,
+		TotalTime: time.Duration(recipe.TotalMinutes) * time.Minute,
+		Steps:     howToSteps(recipe.HowTo),
+	}
+	for _, recipeIngredient := range recipe.Ingredients {
+		amount, unit := quantity.SplitAmount(recipeIngredient.Quantity)
+		converted.Ingredients = append(converted.Ingredients, cooklang.Ingredient{
+			Name:     recipeIngredient.Name,
+			Quantity: amount,
+			Unit:     unit,
+		})
+	}
+	for _, equipment := range recipe.Equipment {
+		converted.Cookware = append(converted.Cookware, equipment.Name)
+	}
+	return converted
+}
+
+// toMarkdownRecipe converts a recipe into the data given to the Markdown recipe template
+func toMarkdownRecipe(recipe model.Recipe) markdown.Recipe {
+	converted := markdown.Recipe{
+		Name:         recipe.Name,
+		Yield:        recipe.Yield,
+		PrepMinutes:  recipe.PrepMinutes,
+		CookMinutes:  recipe.CookMinutes,
+		TotalMinutes: recipe.TotalMinutes,
+		Steps:        howToSteps(recipe.HowTo),
+	}
+	for _, recipeIngredient := range recipe.Ingredients {
+		converted.Ingredients = append(converted.Ingredients, ingredientLine(recipeIngredient))
+	}
+	for _, equipment := range recipe.Equipment {
+		converted.Equipment = append(converted.Equipment, equipment.Name)
+	}
+	return converted
+}
+
+// ingredientLine writes a recipe ingredient as a single line, such as "250 g farine"
+func ingredientLine(recipeIngredient model.RecipeIngredient) string {
+	return strings.TrimSpace(recipeIngredient.Quantity + " " + recipeIngredient.Name)
+}
+
+// howToSteps splits the instructions of a recipe into steps, one per non-blank line
+func howToSteps(howTo string) []string {
+	var steps []string
+	for _, line := range strings.Split(howTo, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			steps = append(steps, line)
+		}
+	}
+	return steps
+}
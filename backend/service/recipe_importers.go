@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/remieven/miam/mealmaster"
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/paprika"
+	"github.com/remieven/miam/quantity"
+)
+
+// recipeImporter reads the recipes of a file exported by another application
+type recipeImporter interface {
+	// readRecipes reads the recipes of a file; the parts that cannot be read are reported, an error being returned only if nothing can be read
+	readRecipes(data []byte) ([]importedRecipe, []model.ImportError, error)
+}
+
+// importedRecipe is a recipe read by an importer, whose ingredients are not matched with the existing ones yet
+type importedRecipe struct {
+	name        string
+	yield       string
+	prepTime    time.Duration
+	cookTime    time.Duration
+	totalTime   time.Duration
+	ingredients []splitIngredient
+	steps       []string
+}
+
+// recipeImporters are the importers of files holding several recipes, by format
+var recipeImporters = map[string]recipeImporter{
+	"paprika":    paprikaImporter{},
+	"mealmaster": mealMasterImporter{},
+}
+
+// paprikaImporter reads Paprika exports (.paprikarecipes) and single Paprika recipes (.paprikarecipe)
+type paprikaImporter struct{}
+
+func (paprikaImporter) readRecipes(data []byte) ([]importedRecipe, []model.ImportError, error) {
+	recipes, parseErrors, err := paprika.Parse(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	imported := make([]importedRecipe, len(recipes))
+	for i, recipe := range recipes {
+		imported[i] = importedRecipe{
+			name:      recipe.Name,
+			yield:     recipe.Yield,
+			prepTime:  recipe.PrepTime,
+			cookTime:  recipe.CookTime,
+			totalTime: recipe.TotalTime,
+			steps:     recipe.Steps,
+		}
+		if recipe.Notes != "" {
+			imported[i].steps = append(imported[i].steps, howToSteps(recipe.Notes)...)
+		}
+		for _, line := range recipe.Ingredients {
+			var ingredient splitIngredient
+			ingredient.quantity, ingredient.name = quantity.SplitIngredient(line)
+			imported[i].ingredients = append(imported[i].ingredients, ingredient)
+		}
+	}
+	var importErrors []model.ImportError
+	for _, parseError := range parseErrors {
+		importErrors = append(importErrors, model.ImportError{
+			Entry:   parseError.Entry,
+			Message: parseError.Message,
+		})
+	}
+	return imported, importErrors, nil
+}
+
+// mealMasterImporter reads MealMaster text files, which may hold several recipes
+type mealMasterImporter struct{}
+
+func (mealMasterImporter) readRecipes(data []byte) ([]importedRecipe, []model.ImportError, error) {
+	recipes, parseErrors := mealmaster.Parse(string(data))
+	var importErrors []model.ImportError
+	for _, parseError := range parseErrors {
+		importErrors = append(importErrors, model.ImportError{
+			Line:    parseError.Line,
+			Message: parseError.Message,
+		})
+	}
+	if len(recipes) == 0 && len(importErrors) == 0 {
+		return nil, nil, errors.New("no MealMaster recipe found")
+	}
+	imported := make([]importedRecipe, len(recipes))
+	for i, recipe := range recipes {
+		imported[i] = importedRecipe{
+			name:  recipe.Name,
+			yield: recipe.Yield,
+			steps: recipe.Steps,
+		}
+		for _, ingredient := range recipe.Ingredients {
+			imported[i].ingredients = append(imported[i].ingredients, splitIngredient{
+				quantity: strings.TrimSpace(ingredient.Quantity + " " + ingredient.Unit),
+				name:     ingredient.Name,
+			})
+		}
+	}
+	return imported, importErrors, nil
+}
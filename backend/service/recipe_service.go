@@ -2,24 +2,39 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/remieven/miam/datasource"
 	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
 )
 
 // RecipeService struct
 type RecipeService struct {
-	recipeDao *datasource.RecipeDao
-	searchDao *datasource.RecipeSearchDao
+	recipeDao           *datasource.RecipeDao
+	searchDao           *datasource.RecipeSearchDao
+	recipeRevisionDao   *datasource.RecipeRevisionDao
+	ingredientDao       *datasource.IngredientDao
+	recipeIngredientDao *datasource.RecipeIngredientDao
+	equipmentDao        *datasource.EquipmentDao
+	// indexed tells whether all the recipes of the database were indexed once
+	indexed atomic.Bool
 }
 
 // NewRecipeService creates a new recipe service
-func NewRecipeService(recipeDao *datasource.RecipeDao, searchDao *datasource.RecipeSearchDao) *RecipeService {
+func NewRecipeService(recipeDao *datasource.RecipeDao, searchDao *datasource.RecipeSearchDao, recipeRevisionDao *datasource.RecipeRevisionDao, ingredientDao *datasource.IngredientDao, recipeIngredientDao *datasource.RecipeIngredientDao, equipmentDao *datasource.EquipmentDao) *RecipeService {
 	return &RecipeService{
-		recipeDao,
-		searchDao,
+		recipeDao:           recipeDao,
+		searchDao:           searchDao,
+		recipeRevisionDao:   recipeRevisionDao,
+		ingredientDao:       ingredientDao,
+		recipeIngredientDao: recipeIngredientDao,
+		equipmentDao:        equipmentDao,
 	}
 }
 
@@ -38,57 +53,325 @@ func (service *RecipeService) IndexAllExistingRecipes(ctx context.Context) error
 		if recipe == nil {
 			continue
 		}
-		if err := service.searchDao.IndexRecipe(*recipe); err != nil {
+		if err := service.indexRecipe(ctx, *recipe); err != nil {
 			return fmt.Errorf("failed to index recipe with id [%s]: %w", id, err)
 		}
 		slog.With("id", id).Debug("indexed recipe")
 	}
 
+	service.indexed.Store(true)
 	return nil
 }
 
+// IsIndexed tells whether the initial indexing of the recipes has finished, so that searches find all of them
+func (service *RecipeService) IsIndexed() bool {
+	return service.indexed.Load()
+}
+
+// RebuildIndex removes all recipes from the search engine and indexes again the ones that are in the database
+func (service *RecipeService) RebuildIndex(ctx context.Context) error {
+	if err := service.searchDao.DeleteAllRecipes(); err != nil {
+		return fmt.Errorf("failed to clear index: %w", err)
+	}
+	return service.IndexAllExistingRecipes(ctx)
+}
+
 // SearchRecipe searches for recipes
 func (service *RecipeService) SearchRecipe(ctx context.Context, search model.RecipeSearch) (*model.RecipeSearchResult, error) {
-	if search.IsEmpty() {
-		return service.recipeDao.GetRandomRecipes(ctx, search)
+	for _, diet := range search.Diets {
+		if !model.IsDiet(diet) {
+			return nil, &failure.InvalidValueError{
+				Message: "unknown diet [" + diet + "]",
+			}
+		}
 	}
-	IDs, total, err := service.searchDao.SearchRecipes(search)
-	if err != nil {
-		return nil, fmt.Errorf("failed to search for recipes: %w", err)
+	for _, allergen := range search.ExcludedAllergens {
+		if !model.IsAllergen(allergen) {
+			return nil, &failure.InvalidValueError{
+				Message: "unknown allergen [" + allergen + "]",
+			}
+		}
 	}
-	recipes, err := service.recipeDao.GetRecipes(ctx, IDs)
-	if err != nil {
-		return nil, fmt.Errorf("failed to hydrate matching recipes: *%w", err)
+	if search.OwnedEquipmentOnly {
+		equipment, err := service.equipmentDao.GetAllEquipment(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get equipment: %w", err)
+		}
+		for _, piece := range equipment {
+			if !piece.Owned {
+				search.ExcludedEquipment = append(search.ExcludedEquipment, piece.ID)
+			}
+		}
+		// Once turned into excluded equipment, the filter is no longer a criteria by itself
+		search.OwnedEquipmentOnly = false
+	}
+	if search.Month == 0 {
+		search.Month = int(time.Now().Month())
+	} else if search.Month < 1 || search.Month > 12 {
+		return nil, &failure.InvalidValueError{
+			Message: fmt.Sprintf("month must be between 1 and 12, got [%d]", search.Month),
+		}
+	}
+	var (
+		recipes []model.Recipe
+		total   int
+	)
+	if search.IsEmpty() {
+		var err error
+		if recipes, total, err = service.recipeDao.GetRandomRecipes(ctx, time.Month(search.Month)); err != nil {
+			return nil, fmt.Errorf("failed to get random recipes: %w", err)
+		}
+	} else {
+		IDs, searchTotal, err := service.searchDao.SearchRecipes(search)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for recipes: %w", err)
+		}
+		if recipes, err = service.recipeDao.GetRecipes(ctx, IDs); err != nil {
+			return nil, fmt.Errorf("failed to hydrate matching recipes: *%w", err)
+		}
+		total = searchTotal
+	}
+
+	var hits []model.RecipeSearchHit
+	if search.CollapseVariants {
+		var err error
+		if hits, err = service.collapseVariants(ctx, recipes); err != nil {
+			return nil, fmt.Errorf("failed to collapse variants: %w", err)
+		}
+	} else {
+		hits = groupVariants(recipes)
 	}
 	return &model.RecipeSearchResult{
-		FirstResults: recipes,
+		FirstResults: hits,
 		Total:        total,
 	}, nil
 }
 
-// GetRecipe gets a recipe by its ID
+// groupVariants turns recipes into search hits, variants being grouped under their parent when it is also part of the recipes
+func groupVariants(recipes []model.Recipe) []model.RecipeSearchHit {
+	hitIndexByID := make(map[string]int, len(recipes))
+	for _, recipe := range recipes {
+		if recipe.ParentID == "" || !containsRecipe(recipes, recipe.ParentID) {
+			hitIndexByID[recipe.ID] = len(hitIndexByID)
+		}
+	}
+	hits := make([]model.RecipeSearchHit, len(hitIndexByID))
+	for _, recipe := range recipes {
+		if index, ok := hitIndexByID[recipe.ID]; ok {
+			hits[index].Recipe = recipe
+		}
+	}
+	for _, recipe := range recipes {
+		if _, ok := hitIndexByID[recipe.ID]; !ok {
+			parentHit := &hits[hitIndexByID[recipe.ParentID]]
+			parentHit.Variants = append(parentHit.Variants, recipe)
+		}
+	}
+	return hits
+}
+
+// collapseVariants turns recipes into search hits, variants being replaced by their parent
+func (service *RecipeService) collapseVariants(ctx context.Context, recipes []model.Recipe) ([]model.RecipeSearchHit, error) {
+	missingParentIDs := make([]string, 0)
+	for _, recipe := range recipes {
+		if recipe.ParentID != "" && !containsRecipe(recipes, recipe.ParentID) {
+			missingParentIDs = append(missingParentIDs, recipe.ParentID)
+		}
+	}
+	parents, err := service.recipeDao.GetRecipes(ctx, missingParentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve parent recipes: %w", err)
+	}
+	parentByID := make(map[string]model.Recipe, len(parents))
+	for _, parent := range parents {
+		parentByID[parent.ID] = parent
+	}
+	for _, recipe := range recipes {
+		parentByID[recipe.ID] = recipe
+	}
+
+	hits := make([]model.RecipeSearchHit, 0, len(recipes))
+	seen := make(map[string]bool, len(recipes))
+	for _, recipe := range recipes {
+		// Variants whose parent is in the trash stand for themselves
+		if parent, ok := parentByID[recipe.ParentID]; ok {
+			recipe = parent
+		}
+		if !seen[recipe.ID] {
+			seen[recipe.ID] = true
+			hits = append(hits, model.RecipeSearchHit{Recipe: recipe})
+		}
+	}
+	return hits, nil
+}
+
+// containsRecipe returns whether a recipe with the given ID is present in a slice of recipes
+func containsRecipe(recipes []model.Recipe, ID string) bool {
+	for _, recipe := range recipes {
+		if recipe.ID == ID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRecipe gets a recipe by its ID, along with the allergens and diets derived from its ingredients
 func (service *RecipeService) GetRecipe(ctx context.Context, ID string) (*model.Recipe, error) {
-	return service.recipeDao.GetRecipe(ctx, ID)
+	recipe, err := service.recipeDao.GetRecipe(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := service.expandIngredients(ctx, recipe.Ingredients, 1, map[string]bool{ID: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand sub-recipes: %w", err)
+	}
+	setDietaryInformation(recipe, expanded)
+	setSeasonScore(recipe, expanded, time.Now().Month())
+	return recipe, nil
+}
+
+// GetAllRecipes gets all the recipes that are not in the trash
+func (service *RecipeService) GetAllRecipes(ctx context.Context) ([]model.Recipe, error) {
+	ids, err := service.recipeDao.ListRecipeIds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipes: %w", err)
+	}
+	return service.recipeDao.GetRecipes(ctx, ids)
+}
+
+// GetRecipes gets the recipes with the given IDs, in the same order; it fails if one of them does not exist or is in the trash
+func (service *RecipeService) GetRecipes(ctx context.Context, IDs []string) ([]model.Recipe, error) {
+	recipes, err := service.recipeDao.GetRecipes(ctx, IDs)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(recipes))
+	for _, recipe := range recipes {
+		found[recipe.ID] = true
+	}
+	for _, ID := range IDs {
+		if !found[ID] {
+			return nil, &failure.ResourceNotFoundError{
+				Message: "recipe [" + ID + "] not found",
+			}
+		}
+	}
+	return recipes, nil
+}
+
+// GetExpandedRecipe gets a recipe by its ID, with the ingredients of its sub-recipes inlined in place of them
+func (service *RecipeService) GetExpandedRecipe(ctx context.Context, ID string) (*model.Recipe, error) {
+	recipe, err := service.recipeDao.GetRecipe(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+	if recipe.Ingredients, err = service.expandIngredients(ctx, recipe.Ingredients, 1, map[string]bool{ID: true}); err != nil {
+		return nil, fmt.Errorf("failed to expand sub-recipes: %w", err)
+	}
+	setDietaryInformation(recipe, recipe.Ingredients)
+	setSeasonScore(recipe, recipe.Ingredients, time.Now().Month())
+	return recipe, nil
+}
+
+// setSeasonScore sets the share of the seasonal ingredients of a recipe that are in season during the given month, if it has some
+func setSeasonScore(recipe *model.Recipe, expandedIngredients []model.RecipeIngredient, month time.Month) {
+	if score, seasonal := model.SeasonScore(expandedIngredients, month); seasonal {
+		recipe.SeasonScore = &score
+	}
+}
+
+// setDietaryInformation sets the allergens of a recipe and the diets it is compatible with from its expanded ingredients.
+// Ingredients whose allergens are unknown may contain any of them, so diets are only derived when the allergens of all ingredients are known.
+func setDietaryInformation(recipe *model.Recipe, expandedIngredients []model.RecipeIngredient) {
+	recipe.Allergens, recipe.AllergensKnown = model.RecipeAllergens(expandedIngredients)
+	recipe.Diets = nil
+	if recipe.AllergensKnown {
+		recipe.Diets = model.CompatibleDiets(recipe.Allergens)
+	}
 }
 
 // AddRecipe adds a new recipe
 func (service *RecipeService) AddRecipe(ctx context.Context, recipe model.BaseRecipe) (string, error) {
+	if err := service.checkParentRecipe(ctx, "", recipe.ParentID); err != nil {
+		return "", err
+	}
+	if err := service.checkSubRecipes(ctx, "", recipe.Ingredients); err != nil {
+		return "", err
+	}
+	if err := service.checkEquipment(ctx, &recipe); err != nil {
+		return "", err
+	}
 	id, err := service.recipeDao.AddRecipe(ctx, &recipe)
 	if err != nil {
 		return "", fmt.Errorf("failed to add recipe: %w", err)
 	}
-	addedRecipe, err := service.recipeDao.GetRecipe(ctx, id)
+	if err := service.indexAddedRecipe(ctx, id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// AddRecipes adds several new recipes at once, either all of them or none being added, and returns their IDs in the same order
+func (service *RecipeService) AddRecipes(ctx context.Context, recipes []model.BaseRecipe) ([]string, error) {
+	for i := range recipes {
+		if err := service.checkParentRecipe(ctx, "", recipes[i].ParentID); err != nil {
+			return nil, err
+		}
+		if err := service.checkSubRecipes(ctx, "", recipes[i].Ingredients); err != nil {
+			return nil, err
+		}
+		if err := service.checkEquipment(ctx, &recipes[i]); err != nil {
+			return nil, err
+		}
+	}
+	IDs, err := service.recipeDao.AddRecipes(ctx, recipes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add recipes: %w", err)
+	}
+	for _, ID := range IDs {
+		if err := service.indexAddedRecipe(ctx, ID); err != nil {
+			return nil, err
+		}
+	}
+	return IDs, nil
+}
+
+// SaveRecipes adds or overwrites several recipes at once, either all of them or none being saved, and returns their IDs in the same order.
+// The recipes are built from the IDs of the recipes saved before them, see RecipeDao.SaveRecipes; it is up to the caller to check their parents and sub-recipes.
+func (service *RecipeService) SaveRecipes(ctx context.Context, count int, build func(savedIDs []string) model.Recipe) ([]string, error) {
+	IDs, err := service.recipeDao.SaveRecipes(ctx, count, build)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save recipes: %w", err)
+	}
+	if err := service.ReindexRecipes(ctx, IDs); err != nil {
+		return nil, fmt.Errorf("failed to index saved recipes: %w", err)
+	}
+	return IDs, nil
+}
+
+// indexAddedRecipe reads a recipe that was just added and indexes it
+func (service *RecipeService) indexAddedRecipe(ctx context.Context, ID string) error {
+	addedRecipe, err := service.recipeDao.GetRecipe(ctx, ID)
 	if err != nil {
-		return "", fmt.Errorf("failed to retrieve added recipe: %w", err)
+		return fmt.Errorf("failed to retrieve added recipe: %w", err)
 	}
-	if err = service.searchDao.IndexRecipe(*addedRecipe); err != nil {
-		return "", fmt.Errorf("failed to index recipe: %w", err)
+	if err := service.indexRecipe(ctx, *addedRecipe); err != nil {
+		return fmt.Errorf("failed to index recipe: %w", err)
 	}
-	return addedRecipe.ID, nil
+	return nil
 }
 
 // UpdateRecipe updates an existing recipe
 func (service *RecipeService) UpdateRecipe(ctx context.Context, ID string, recipe model.BaseRecipe) (*model.Recipe, error) {
+	if err := service.checkParentRecipe(ctx, ID, recipe.ParentID); err != nil {
+		return nil, err
+	}
+	if err := service.checkSubRecipes(ctx, ID, recipe.Ingredients); err != nil {
+		return nil, err
+	}
+	if err := service.checkEquipment(ctx, &recipe); err != nil {
+		return nil, err
+	}
 	updated, err := service.recipeDao.UpdateRecipe(ctx, model.Recipe{
 		ID:         ID,
 		BaseRecipe: recipe,
@@ -96,14 +379,107 @@ func (service *RecipeService) UpdateRecipe(ctx context.Context, ID string, recip
 	if err != nil {
 		return nil, fmt.Errorf("failed to update recipe: %w", err)
 	}
-	if err = service.searchDao.IndexRecipe(*updated); err != nil {
+	if err = service.indexRecipe(ctx, *updated); err != nil {
 		return nil, fmt.Errorf("failed to index updated recipe: %w", err)
 	}
+	if err = service.reindexRecipesUsing(ctx, ID, map[string]bool{ID: true}); err != nil {
+		return nil, fmt.Errorf("failed to index recipes using updated recipe: %w", err)
+	}
 	return updated, nil
 }
 
-// DeleteRecipe deletes a recipe
+// checkEquipment checks that the equipment required by a recipe exists, and replaces it by the stored equipment without duplicates
+func (service *RecipeService) checkEquipment(ctx context.Context, recipe *model.BaseRecipe) error {
+	if len(recipe.Equipment) == 0 {
+		return nil
+	}
+	equipment := make([]model.Equipment, 0, len(recipe.Equipment))
+	seen := make(map[string]bool, len(recipe.Equipment))
+	for _, piece := range recipe.Equipment {
+		if seen[piece.ID] {
+			continue
+		}
+		seen[piece.ID] = true
+		stored, err := service.equipmentDao.GetEquipment(ctx, piece.ID)
+		if errors.Is(err, &failure.ResourceNotFoundError{}) {
+			return &failure.InvalidValueError{
+				Message: "equipment [" + piece.ID + "] not found",
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to get equipment: %w", err)
+		}
+		equipment = append(equipment, *stored)
+	}
+	recipe.Equipment = equipment
+	return nil
+}
+
+// checkParentRecipe checks that the given parent of a recipe exists and is not one of its variants.
+// Ancestors in the trash are walked through, and a parent in the trash is only accepted if the recipe already had it.
+func (service *RecipeService) checkParentRecipe(ctx context.Context, recipeID, parentID string) error {
+	if parentID == "" {
+		return nil
+	}
+	currentParentID := ""
+	if recipeID != "" {
+		var err error
+		currentParentID, _, err = service.recipeDao.GetRecipeParent(ctx, recipeID)
+		if err != nil && !errors.Is(err, &failure.ResourceNotFoundError{}) {
+			return fmt.Errorf("failed to get recipe parent: %w", err)
+		}
+	}
+	for ancestorID := parentID; ancestorID != ""; {
+		if ancestorID == recipeID {
+			return &failure.InvalidValueError{
+				Message: "recipe [" + parentID + "] cannot be the parent of recipe [" + recipeID + "] since it is one of its variants",
+			}
+		}
+		nextID, deleted, err := service.recipeDao.GetRecipeParent(ctx, ancestorID)
+		if errors.Is(err, &failure.ResourceNotFoundError{}) || (deleted && ancestorID == parentID && parentID != currentParentID) {
+			return &failure.InvalidValueError{
+				Message: "parent recipe [" + ancestorID + "] not found",
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to get parent recipe: %w", err)
+		}
+		ancestorID = nextID
+	}
+	return nil
+}
+
+// ForkRecipe adds a copy of a recipe as one of its variants
+func (service *RecipeService) ForkRecipe(ctx context.Context, ID string, name string) (string, error) {
+	recipe, err := service.recipeDao.GetRecipe(ctx, ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get recipe to fork: %w", err)
+	}
+	variant := recipe.BaseRecipe
+	variant.ParentID = ID
+	if name != "" {
+		variant.Name = name
+	}
+	return service.AddRecipe(ctx, variant)
+}
+
+// GetRecipeVariants lists the variants of a recipe
+func (service *RecipeService) GetRecipeVariants(ctx context.Context, ID string) ([]model.Recipe, error) {
+	if _, err := service.recipeDao.GetRecipe(ctx, ID); err != nil {
+		return nil, fmt.Errorf("failed to get recipe: %w", err)
+	}
+	return service.recipeDao.GetRecipeVariants(ctx, ID)
+}
+
+// DeleteRecipe moves a recipe to the trash
 func (service *RecipeService) DeleteRecipe(ctx context.Context, id string) error {
+	used, err := service.recipeIngredientDao.IsUsedAsSubRecipe(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to check if recipe is used in other recipes: %w", err)
+	}
+	if used {
+		return &failure.InvalidValueError{
+			Message: "cannot delete recipe [" + id + "] because it's still used as an ingredient of other recipes",
+		}
+	}
 	if err := service.recipeDao.DeleteRecipe(ctx, id); err != nil {
 		return fmt.Errorf("failed to delete recipe: %w", err)
 	}
@@ -112,3 +488,175 @@ func (service *RecipeService) DeleteRecipe(ctx context.Context, id string) error
 	}
 	return nil
 }
+
+// GetDeletedRecipes lists the recipes that are in the trash
+func (service *RecipeService) GetDeletedRecipes(ctx context.Context) ([]model.DeletedRecipe, error) {
+	return service.recipeDao.GetDeletedRecipes(ctx)
+}
+
+// RestoreDeletedRecipe takes a recipe out of the trash and makes it searchable again
+func (service *RecipeService) RestoreDeletedRecipe(ctx context.Context, id string) (*model.Recipe, error) {
+	if err := service.recipeDao.RestoreRecipe(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to restore recipe: %w", err)
+	}
+	restored, err := service.recipeDao.GetRecipe(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve restored recipe: %w", err)
+	}
+	if err = service.indexRecipe(ctx, *restored); err != nil {
+		return nil, fmt.Errorf("failed to index restored recipe: %w", err)
+	}
+	return restored, nil
+}
+
+// PurgeDeletedRecipe permanently deletes a recipe that is in the trash
+func (service *RecipeService) PurgeDeletedRecipe(ctx context.Context, id string) error {
+	if err := service.recipeDao.PurgeRecipe(ctx, id); err != nil {
+		return fmt.Errorf("failed to purge recipe: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpiredDeletedRecipes permanently deletes the recipes that have been in the trash for longer than the given retention
+func (service *RecipeService) PurgeExpiredDeletedRecipes(ctx context.Context, retention time.Duration) error {
+	ids, err := service.recipeDao.ListRecipeIdsDeletedBefore(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return fmt.Errorf("failed to list expired deleted recipes: %w", err)
+	}
+	for _, id := range ids {
+		if err := service.recipeDao.PurgeRecipe(ctx, id); err != nil {
+			return fmt.Errorf("failed to purge recipe with id [%s]: %w", id, err)
+		}
+		slog.With("id", id).Info("purged recipe from trash")
+	}
+	return nil
+}
+
+// PurgeExpiredDeletedRecipesPeriodically purges expired deleted recipes every period, until the context is done
+func (service *RecipeService) PurgeExpiredDeletedRecipesPeriodically(ctx context.Context, retention, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		if err := service.PurgeExpiredDeletedRecipes(ctx, retention); err != nil {
+			slog.With("error", err).Error("failed to purge trash")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetRecipeRevisions lists the revisions of a recipe
+func (service *RecipeService) GetRecipeRevisions(ctx context.Context, recipeID string) ([]model.RecipeRevision, error) {
+	if _, err := service.recipeDao.GetRecipe(ctx, recipeID); err != nil {
+		return nil, fmt.Errorf("failed to get recipe: %w", err)
+	}
+	return service.recipeRevisionDao.GetRecipeRevisions(ctx, recipeID)
+}
+
+// GetRecipeRevision gets a revision of a recipe along with its content
+func (service *RecipeService) GetRecipeRevision(ctx context.Context, recipeID, revisionID string) (*model.RecipeRevision, error) {
+	return service.recipeRevisionDao.GetRecipeRevision(ctx, recipeID, revisionID)
+}
+
+// DiffRecipeRevisions lists the changes made to a recipe between two of its revisions
+func (service *RecipeService) DiffRecipeRevisions(ctx context.Context, recipeID, fromRevisionID, toRevisionID string) (*model.RecipeRevisionDiff, error) {
+	from, err := service.recipeRevisionDao.GetRecipeRevision(ctx, recipeID, fromRevisionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revision to diff from: %w", err)
+	}
+	to, err := service.recipeRevisionDao.GetRecipeRevision(ctx, recipeID, toRevisionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revision to diff to: %w", err)
+	}
+	return &model.RecipeRevisionDiff{
+		From:    fromRevisionID,
+		To:      toRevisionID,
+		Changes: diffRecipes(*from.Recipe, *to.Recipe),
+	}, nil
+}
+
+// RestoreRecipeRevision makes a past revision of a recipe its current version
+func (service *RecipeService) RestoreRecipeRevision(ctx context.Context, recipeID, revisionID string) (*model.Recipe, error) {
+	revision, err := service.recipeRevisionDao.GetRecipeRevision(ctx, recipeID, revisionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revision to restore: %w", err)
+	}
+	restored := revision.Recipe.BaseRecipe
+	for i, ingredient := range restored.Ingredients {
+		if ingredient.ID == "" {
+			continue
+		}
+		// Ingredients deleted since the revision was saved are created again from their name
+		if _, err := service.ingredientDao.GetIngredient(ctx, ingredient.ID); errors.Is(err, &failure.ResourceNotFoundError{}) {
+			restored.Ingredients[i].ID = ""
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to check existence of ingredient [%s]: %w", ingredient.ID, err)
+		}
+	}
+	return service.UpdateRecipe(ctx, recipeID, restored)
+}
+
+// diffRecipes returns the field-level changes needed to go from a recipe to another
+func diffRecipes(from, to model.Recipe) []model.RecipeChange {
+	changes := make([]model.RecipeChange, 0)
+	if from.Name != to.Name {
+		changes = append(changes, model.RecipeChange{Field: "name", Kind: model.ChangeKindModified, Before: from.Name, After: to.Name})
+	}
+	if from.HowTo != to.HowTo {
+		changes = append(changes, model.RecipeChange{Field: "howTo", Kind: model.ChangeKindModified, Before: from.HowTo, After: to.HowTo})
+	}
+	if from.ParentID != to.ParentID {
+		changes = append(changes, model.RecipeChange{Field: "parentId", Kind: model.ChangeKindModified, Before: from.ParentID, After: to.ParentID})
+	}
+	if from.Yield != to.Yield {
+		changes = append(changes, model.RecipeChange{Field: "yield", Kind: model.ChangeKindModified, Before: from.Yield, After: to.Yield})
+	}
+	for _, times := range []struct {
+		field    string
+		from, to int
+	}{
+		{"prepMinutes", from.PrepMinutes, to.PrepMinutes},
+		{"cookMinutes", from.CookMinutes, to.CookMinutes},
+		{"totalMinutes", from.TotalMinutes, to.TotalMinutes},
+	} {
+		if times.from != times.to {
+			changes = append(changes, model.RecipeChange{Field: times.field, Kind: model.ChangeKindModified, Before: formatMinutes(times.from), After: formatMinutes(times.to)})
+		}
+	}
+	for _, fromIngredient := range from.Ingredients {
+		ingredient := fromIngredient.Ingredient
+		if stillThere, toIngredient := containsIngredient(fromIngredient, to.Ingredients); !stillThere {
+			changes = append(changes, model.RecipeChange{Field: "ingredients", Kind: model.ChangeKindRemoved, Ingredient: &ingredient, Before: fromIngredient.Quantity})
+		} else if toIngredient.Quantity != fromIngredient.Quantity {
+			changes = append(changes, model.RecipeChange{Field: "ingredients", Kind: model.ChangeKindModified, Ingredient: &ingredient, Before: fromIngredient.Quantity, After: toIngredient.Quantity})
+		}
+	}
+	for _, toIngredient := range to.Ingredients {
+		ingredient := toIngredient.Ingredient
+		if alreadyThere, _ := containsIngredient(toIngredient, from.Ingredients); !alreadyThere {
+			changes = append(changes, model.RecipeChange{Field: "ingredients", Kind: model.ChangeKindAdded, Ingredient: &ingredient, After: toIngredient.Quantity})
+		}
+	}
+	return changes
+}
+
+// formatMinutes formats a time in minutes for a recipe change, an unset time being empty
+func formatMinutes(minutes int) string {
+	if minutes == 0 {
+		return ""
+	}
+	return strconv.Itoa(minutes)
+}
+
+// containsIngredient returns whether a given recipe ingredient is present in a slice of recipe ingredients
+func containsIngredient(searched model.RecipeIngredient, ingredients []model.RecipeIngredient) (bool, model.RecipeIngredient) {
+	for _, ingredient := range ingredients {
+		if ingredient.ID == searched.ID && ingredient.SubRecipeID == searched.SubRecipeID {
+			return true, ingredient
+		}
+	}
+	return false, model.RecipeIngredient{}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/remieven/miam/model"
+	"github.com/remieven/miam/pb-lite/failure"
+	"github.com/remieven/miam/quantity"
+)
+
+// checkSubRecipes checks that the recipes used as ingredients exist, that their quantities are multipliers and that they do not use the recipe itself
+func (service *RecipeService) checkSubRecipes(ctx context.Context, recipeID string, ingredients []model.RecipeIngredient) error {
+	visited := make(map[string]bool)
+	for _, ingredient := range ingredients {
+		if !ingredient.IsSubRecipe() {
+			continue
+		}
+		if _, ok := quantity.ParseFactor(ingredient.Quantity); !ok {
+			return &failure.InvalidValueError{
+				Message: "quantity [" + ingredient.Quantity + "] of sub-recipe [" + ingredient.SubRecipeID + "] must be a multiplier such as 2 or 0.5",
+			}
+		}
+		if err := service.checkSubRecipeDoesNotUse(ctx, ingredient.SubRecipeID, recipeID, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSubRecipeDoesNotUse checks that a sub-recipe exists and that neither it nor its own sub-recipes are the given recipe.
+// visited holds the ids of the sub-recipes already checked, so that cycles between other recipes, introduced by inconsistent data, do not loop forever.
+func (service *RecipeService) checkSubRecipeDoesNotUse(ctx context.Context, subRecipeID, recipeID string, visited map[string]bool) error {
+	if subRecipeID == recipeID {
+		return &failure.InvalidValueError{
+			Message: "recipe [" + recipeID + "] cannot be used as an ingredient of itself, even indirectly",
+		}
+	}
+	subRecipe, err := service.recipeDao.GetRecipe(ctx, subRecipeID)
+	if errors.Is(err, &failure.ResourceNotFoundError{}) {
+		return &failure.InvalidValueError{
+			Message: "sub-recipe [" + subRecipeID + "] not found",
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get sub-recipe: %w", err)
+	}
+	if recipeID == "" || visited[subRecipeID] {
+		return nil
+	}
+	visited[subRecipeID] = true
+	for _, ingredient := range subRecipe.Ingredients {
+		if ingredient.IsSubRecipe() {
+			if err := service.checkSubRecipeDoesNotUse(ctx, ingredient.SubRecipeID, recipeID, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expandIngredients replaces the sub-recipes of a list of ingredients by their own ingredients, recursively, scaling all quantities by the given factor.
+// visited holds the ids of the recipes being expanded, so that cycles introduced by inconsistent data do not loop forever.
+func (service *RecipeService) expandIngredients(ctx context.Context, ingredients []model.RecipeIngredient, factor float64, visited map[string]bool) ([]model.RecipeIngredient, error) {
+	expanded := make([]model.RecipeIngredient, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		if !ingredient.IsSubRecipe() {
+			ingredient.Quantity = quantity.Scale(ingredient.Quantity, factor)
+			expanded = append(expanded, ingredient)
+			continue
+		}
+		if visited[ingredient.SubRecipeID] {
+			continue
+		}
+		subRecipe, err := service.recipeDao.GetRecipe(ctx, ingredient.SubRecipeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sub-recipe [%s]: %w", ingredient.SubRecipeID, err)
+		}
+		subFactor, ok := quantity.ParseFactor(ingredient.Quantity)
+		if !ok {
+			subFactor = 1
+		}
+		visited[ingredient.SubRecipeID] = true
+		subIngredients, err := service.expandIngredients(ctx, subRecipe.Ingredients, factor*subFactor, visited)
+		delete(visited, ingredient.SubRecipeID)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, subIngredients...)
+	}
+	return expanded, nil
+}
+
+// indexRecipe indexes a recipe along with the ingredients and equipment of its sub-recipes, so that searches on them see through sub-recipes
+func (service *RecipeService) indexRecipe(ctx context.Context, recipe model.Recipe) error {
+	indexed, inSeasonMonths, err := service.toIndexedRecipe(ctx, recipe)
+	if err != nil {
+		return err
+	}
+	return service.searchDao.IndexRecipe(indexed, inSeasonMonths)
+}
+
+// toIndexedRecipe returns a recipe as it is indexed, along with the months during which it is in season
+func (service *RecipeService) toIndexedRecipe(ctx context.Context, recipe model.Recipe) (model.Recipe, []time.Month, error) {
+	expanded, err := service.expandIngredients(ctx, recipe.Ingredients, 1, map[string]bool{recipe.ID: true})
+	if err != nil {
+		return model.Recipe{}, nil, fmt.Errorf("failed to expand sub-recipes: %w", err)
+	}
+	// Sub-recipes have no allergens of their own, so they are only indexed by name once the dietary information is set
+	setDietaryInformation(&recipe, expanded)
+	for _, ingredient := range recipe.Ingredients {
+		if ingredient.IsSubRecipe() {
+			expanded = append(expanded, ingredient)
+		}
+	}
+	if recipe.Equipment, err = service.expandEquipment(ctx, recipe, map[string]bool{recipe.ID: true}); err != nil {
+		return model.Recipe{}, nil, fmt.Errorf("failed to expand equipment of sub-recipes: %w", err)
+	}
+	recipe.Ingredients = expanded
+	return recipe, model.InSeasonMonths(expanded), nil
+}
+
+// expandEquipment returns the equipment required by a recipe along with the one required by its sub-recipes, recursively.
+// visited holds the ids of the recipes being expanded, as in expandIngredients.
+func (service *RecipeService) expandEquipment(ctx context.Context, recipe model.Recipe, visited map[string]bool) ([]model.Equipment, error) {
+	equipment := append([]model.Equipment{}, recipe.Equipment...)
+	for _, ingredient := range recipe.Ingredients {
+		if !ingredient.IsSubRecipe() || visited[ingredient.SubRecipeID] {
+			continue
+		}
+		subRecipe, err := service.recipeDao.GetRecipe(ctx, ingredient.SubRecipeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sub-recipe [%s]: %w", ingredient.SubRecipeID, err)
+		}
+		visited[ingredient.SubRecipeID] = true
+		subEquipment, err := service.expandEquipment(ctx, *subRecipe, visited)
+		delete(visited, ingredient.SubRecipeID)
+		if err != nil {
+			return nil, err
+		}
+		equipment = append(equipment, subEquipment...)
+	}
+	return equipment, nil
+}
+
+// reindexRecipesUsing indexes again the recipes that use, even indirectly, the given recipe as an ingredient
+func (service *RecipeService) reindexRecipesUsing(ctx context.Context, subRecipeID string, visited map[string]bool) error {
+	ids, err := service.recipeIngredientDao.ListRecipeIdsUsingSubRecipe(ctx, subRecipeID)
+	if err != nil {
+		return fmt.Errorf("failed to list recipes using sub-recipe: %w", err)
+	}
+	recipes, err := service.recipeDao.GetRecipes(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get recipes using sub-recipe: %w", err)
+	}
+	for _, recipe := range recipes {
+		if visited[recipe.ID] {
+			continue
+		}
+		visited[recipe.ID] = true
+		if err := service.indexRecipe(ctx, recipe); err != nil {
+			return fmt.Errorf("failed to index recipe with id [%s]: %w", recipe.ID, err)
+		}
+		if err := service.reindexRecipesUsing(ctx, recipe.ID, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReindexRecipes indexes again the given recipes and the ones using them as ingredients
+func (service *RecipeService) ReindexRecipes(ctx context.Context, IDs []string) error {
+	recipes, err := service.recipeDao.GetRecipes(ctx, IDs)
+	if err != nil {
+		return fmt.Errorf("failed to get recipes to index: %w", err)
+	}
+	visited := make(map[string]bool, len(recipes))
+	for _, recipe := range recipes {
+		visited[recipe.ID] = true
+		if err := service.indexRecipe(ctx, recipe); err != nil {
+			return fmt.Errorf("failed to index recipe with id [%s]: %w", recipe.ID, err)
+		}
+	}
+	for _, recipe := range recipes {
+		if err := service.reindexRecipesUsing(ctx, recipe.ID, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package similarity
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ligatures are replaced by their letters since they are not decomposed by unicode normalization
+var ligatures = strings.NewReplacer("œ", "oe", "æ", "ae")
+
+// NormalizeName folds accents, ignores case and reduces plural words to their singular form, so that eg. "Échalotes" and "echalote" give the same result
+func NormalizeName(name string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), name)
+	if err != nil {
+		folded = name
+	}
+	words := strings.Fields(ligatures.Replace(strings.ToLower(folded)))
+	for i, word := range words {
+		words[i] = singular(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// singular returns the singular form of a french (or english) word, using the most common rules only
+func singular(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "eaux"), strings.HasSuffix(word, "eux"), strings.HasSuffix(word, "oux"):
+		return strings.TrimSuffix(word, "x")
+	case strings.HasSuffix(word, "ss"):
+		return word
+	case strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "s"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+// Distance returns the Levenshtein distance between two strings, counted in runes
+func Distance(a, b string) int {
+	source, target := []rune(a), []rune(b)
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(target)]
+}
+
+// AreClose tells whether two names most likely designate the same thing: they are equal once normalized, or differ by a typo
+func AreClose(a, b string) bool {
+	return areNormalizedNamesClose(NormalizeName(a), NormalizeName(b))
+}
+
+func areNormalizedNamesClose(a, b string) bool {
+	if a == b {
+		return true
+	}
+	return Distance(a, b) <= maxTypos(min(len([]rune(a)), len([]rune(b))))
+}
+
+// Cluster groups the given names that are close to each other, directly or through other names.
+// It returns the indexes of the names of each group having at least two names, in the order of their first name.
+func Cluster(names []string) [][]int {
+	normalizedNames := make([]string, len(names))
+	for i, name := range names {
+		normalizedNames[i] = NormalizeName(name)
+	}
+
+	parents := make([]int, len(names))
+	for i := range parents {
+		parents[i] = i
+	}
+	var root func(int) int
+	root = func(i int) int {
+		if parents[i] != i {
+			parents[i] = root(parents[i])
+		}
+		return parents[i]
+	}
+	for i := range normalizedNames {
+		for j := i + 1; j < len(normalizedNames); j++ {
+			if areNormalizedNamesClose(normalizedNames[i], normalizedNames[j]) {
+				parents[root(j)] = root(i)
+			}
+		}
+	}
+
+	groupIndexes := make(map[int]int)
+	groups := make([][]int, 0)
+	for i := range names {
+		r := root(i)
+		index, ok := groupIndexes[r]
+		if !ok {
+			index = len(groups)
+			groupIndexes[r] = index
+			groups = append(groups, nil)
+		}
+		groups[index] = append(groups[index], i)
+	}
+	clusters := make([][]int, 0)
+	for _, group := range groups {
+		if len(group) > 1 {
+			clusters = append(clusters, group)
+		}
+	}
+	return clusters
+}
+
+// maxTypos returns how many edits are tolerated between two names, short names being more likely to differ by a single letter
+func maxTypos(length int) int {
+	switch {
+	case length < 5:
+		return 0
+	case length < 10:
+		return 1
+	default:
+		return 2
+	}
+}
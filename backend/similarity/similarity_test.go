@@ -0,0 +1,106 @@
+package similarity
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := map[string]string{
+		"tomate":             "tomate",
+		"Tomates":            "tomate",
+		"  Échalotes  ":      "echalote",
+		"crème fraîche":      "creme fraiche",
+		"Poireaux":           "poireau",
+		"choux de Bruxelles": "chou de bruxelle",
+		"noix":               "noix",
+		"cherries":           "cherry",
+		"cress":              "cress",
+		"riz":                "riz",
+		"Œufs":               "oeuf",
+	}
+	for name, expected := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actual := NormalizeName(name); actual != expected {
+				t.Errorf("unexpected normalized name: wanted [%s], got [%s]", expected, actual)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"tomate", "tomate", 0},
+		{"tomate", "tomtae", 2},
+		{"courgette", "courgete", 1},
+		{"kitten", "sitting", 3},
+		{"crème", "creme", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.a+"/"+test.b, func(t *testing.T) {
+			if actual := Distance(test.a, test.b); actual != test.expected {
+				t.Errorf("unexpected distance: wanted [%d], got [%d]", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAreClose(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"tomate", "Tomates", true},
+		{"crème fraîche", "creme fraiche", true},
+		{"courgette", "courgete", true},
+		{"mozzarella", "mozarella", true},
+		{"sel", "sucre", false},
+		{"riz", "ail", false},
+		{"lait", "lard", false},
+		{"oignon", "oignon rouge", false},
+	}
+	for _, test := range tests {
+		t.Run(test.a+"/"+test.b, func(t *testing.T) {
+			if actual := AreClose(test.a, test.b); actual != test.expected {
+				t.Errorf("unexpected result: wanted [%t], got [%t]", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCluster(t *testing.T) {
+	tests := map[string]struct {
+		names    []string
+		expected [][]int
+	}{
+		"no names": {
+			expected: [][]int{},
+		},
+		"no duplicates": {
+			names:    []string{"sel", "poivre", "sucre"},
+			expected: [][]int{},
+		},
+		"duplicates": {
+			names:    []string{"tomate", "sel", "Tomates", "crème fraîche", "poivre", "creme fraiche", "tomates"},
+			expected: [][]int{{0, 2, 6}, {3, 5}},
+		},
+		"names close through another one": {
+			names:    []string{"courgettes", "courgete", "courgette"},
+			expected: [][]int{{0, 1, 2}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := deep.Equal(Cluster(test.names), test.expected); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}